@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	id := fs.String("id", "", "Only events for this message id")
+	since := fs.String("since", "", "Only events on/after this date (YYYY-MM-DD or RFC3339)")
+	kind := fs.String("kind", "", "Only events of this kind (e.g. message.status)")
+	n := fs.Int("n", 100, "show the most recent n events (0 = all)")
+	verify := fs.Bool("verify", false, "Recompute the hash chain and report tampering")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	sinceT, err := parseDateFlag(*since)
+	if err != nil {
+		fail(exitUsage, "invalid -since: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 60*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	if *verify {
+		checked, err := st.VerifyAuditChain(ctx)
+		if structured() {
+			r := auditVerifyRecord{OK: err == nil, Checked: checked}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			emit(r)
+		}
+		if err != nil {
+			fail(exitFailure, "Audit chain INVALID after %d events: %v", checked, err)
+			return
+		}
+		if !structured() {
+			fmt.Printf("Audit chain OK (%d events)\n", checked)
+		}
+		return
+	}
+
+	evs, err := st.ListEvents(ctx, store.EventFilter{
+		MessageID: strings.TrimSpace(*id),
+		Since:     sinceT,
+		Kind:      strings.TrimSpace(*kind),
+		Limit:     *n,
+		Newest:    true,
+	})
+	if err != nil {
+		fail(exitFailure, "audit failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toEventRecords(evs))
+		return
+	}
+	if len(evs) == 0 {
+		fmt.Println("(no events)")
+		return
+	}
+	for _, ev := range evs {
+		ts := ev.Time.Local().Format("2006-01-02 15:04:05")
+		line := fmt.Sprintf("%6d %s %-24s %-22s", ev.Seq, ts, ev.Actor, ev.Kind)
+		if ev.MessageID != "" {
+			line += " " + ev.MessageID
+		}
+		if ev.OldStatus != "" || ev.NewStatus != "" {
+			line += fmt.Sprintf(" %s->%s", orDash(ev.OldStatus), orDash(ev.NewStatus))
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+		if ev.Payload != "" && ev.Payload != "{}" {
+			fmt.Printf("       %s\n", ev.Payload)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/4current/relayops/internal/config"
+	"github.com/4current/relayops/internal/runtime"
+)
+
+// activeProfile is the station profile the running command uses, for doctor.
+var activeProfile string
+
+// splitProfileFlag removes --profile NAME (or -profile, --profile=NAME) from args,
+// wherever it appears before a "--", and returns its value.
+func splitProfileFlag(args []string) (string, []string) {
+	profile := ""
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		switch {
+		case a == "--profile" || a == "-profile":
+			if i+1 < len(args) {
+				profile = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(a, "--profile="):
+			profile = strings.TrimPrefix(a, "--profile=")
+		case strings.HasPrefix(a, "-profile="):
+			profile = strings.TrimPrefix(a, "-profile=")
+		default:
+			out = append(out, a)
+		}
+	}
+	return profile, out
+}
+
+// applyProfile loads the configuration file and exports the selected profile. A
+// profile named by --profile or RELAYOPS_PROFILE overrides the environment; the
+// default profile only fills in what the environment leaves unset.
+func applyProfile(name string) bool {
+	path, err := runtime.ConfigPath()
+	if err != nil {
+		fail(exitFailure, "config: %v", err)
+		return false
+	}
+	f, err := config.Load(path)
+	if err != nil {
+		fail(exitFailure, "config: %v", err)
+		return false
+	}
+	explicit := name != "" || strings.TrimSpace(os.Getenv(config.EnvProfile)) != ""
+	selected, p, err := f.Select(name)
+	if err != nil {
+		fail(exitFailure, "config: %v", err)
+		return false
+	}
+	if p == nil {
+		return true
+	}
+	if err := p.Apply(explicit); err != nil {
+		fail(exitFailure, "config: apply profile %s: %v", selected, err)
+		return false
+	}
+	activeProfile = selected
+	return true
+}
+
+// configRecord is one profile setting.
+type configRecord struct {
+	Profile string `json:"profile"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
+func runConfig(profile string, args []string) {
+	if len(args) < 1 {
+		usage(
+			"Usage:",
+			"  relayops [--profile name] config get [key]",
+			"  relayops [--profile name] config set <key> <value>   (empty value removes the key; lists are comma-separated)",
+			"  relayops config validate",
+			"",
+			"Keys: default_profile, "+strings.Join(config.Keys(), ", "),
+		)
+		return
+	}
+	path, err := runtime.ConfigPath()
+	if err != nil {
+		fail(exitFailure, "config: %v", err)
+		return
+	}
+
+	sub := args[0]
+	switch sub {
+	case "get":
+		fs := flag.NewFlagSet("config get", flag.ContinueOnError)
+		outputFlag(fs)
+		key, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return
+		}
+		f, err := config.Load(path)
+		if err != nil {
+			fail(exitFailure, "config: %v", err)
+			return
+		}
+		if key == "default_profile" {
+			if structured() {
+				emit(configRecord{Key: "default_profile", Value: f.DefaultProfile})
+				return
+			}
+			fmt.Println(f.DefaultProfile)
+			return
+		}
+		name, p, err := f.Select(profile)
+		if err != nil {
+			fail(exitFailure, "config: %v", err)
+			return
+		}
+		if p == nil {
+			fail(exitFailure, "no profiles in %s (create one with: relayops config set callsign <CALL>)", path)
+			return
+		}
+		if key != "" {
+			v, err := p.Get(key)
+			if err != nil {
+				fail(exitUsage, "config: %v", err)
+				return
+			}
+			if structured() {
+				emit(configRecord{Profile: name, Key: key, Value: v})
+				return
+			}
+			fmt.Println(v)
+			return
+		}
+		out := []configRecord{}
+		for _, kv := range p.Settings() {
+			out = append(out, configRecord{Profile: name, Key: kv[0], Value: kv[1]})
+		}
+		if structured() {
+			emit(out)
+			return
+		}
+		fmt.Printf("# profile %s (%s)\n", name, path)
+		for _, r := range out {
+			fmt.Printf("%s = %s\n", r.Key, r.Value)
+		}
+
+	case "set":
+		// No flags: a value may itself start with "-".
+		if len(args) != 3 {
+			usage("Usage: relayops [--profile name] config set <key> <value>")
+			return
+		}
+		key, value := args[1], args[2]
+		name, err := config.Set(path, profile, key, value)
+		if err != nil {
+			fail(exitFailure, "config set: %v", err)
+			return
+		}
+		if name == "" {
+			fmt.Printf("✔ %s = %s\n", key, value)
+			return
+		}
+		fmt.Printf("✔ [%s] %s = %s\n", name, key, value)
+
+	case "validate":
+		fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		f, err := config.Load(path)
+		if err != nil {
+			fail(exitFailure, "config: %v", err)
+			return
+		}
+		if err := f.Validate(); err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fail(exitFailure, "✘ %s", line)
+			}
+			return
+		}
+		if len(f.Profiles) == 0 {
+			fmt.Printf("✔ %s: no profiles\n", path)
+			return
+		}
+		if _, _, err := f.Select(profile); err != nil {
+			fail(exitFailure, "✘ %v", err)
+			return
+		}
+		fmt.Printf("✔ %s: %d profile(s) OK (%s)\n", path, len(f.Profiles), strings.Join(f.Names(), ", "))
+
+	default:
+		fail(exitUsage, "Unknown config subcommand: %s", sub)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/vault"
+)
+
+func runCrypto(args []string) {
+	if len(args) < 1 {
+		usage(
+			"Usage:",
+			"  relayops crypto status",
+			"  relayops crypto enable [-key-file path] [-generate-key-file path]   (else uses RELAYOPS_PASSPHRASE)",
+			"  relayops crypto rotate [-new-key-file path]                         (else uses RELAYOPS_NEW_PASSPHRASE)",
+		)
+		return
+	}
+
+	// Re-sealing a large store can take a while.
+	ctx, cancel := context.WithTimeout(cliContext(), 600*time.Second)
+	defer cancel()
+
+	sub := args[0]
+	switch sub {
+	case "status":
+		fs := flag.NewFlagSet("crypto status", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		s, err := st.EncryptionStatus(ctx)
+		if err != nil {
+			fail(exitFailure, "crypto status failed: %v", err)
+			return
+		}
+		if structured() {
+			emit(cryptoStatusRecord{
+				Enabled: s.Enabled, Unlocked: s.Unlocked, KeyID: s.ActiveKeyID, KDF: s.KDF,
+				SealedFields: s.SealedFields, PlainFields: s.PlainFields,
+			})
+			return
+		}
+		if !s.Enabled {
+			fmt.Printf("Encryption: disabled (plaintext fields=%d)\n", s.PlainFields)
+			return
+		}
+		state := "locked"
+		if s.Unlocked {
+			state = "unlocked"
+		}
+		fmt.Printf("Encryption: enabled (%s) key=%s kdf=%s sealed=%d plaintext=%d\n",
+			state, s.ActiveKeyID, s.KDF, s.SealedFields, s.PlainFields)
+
+	case "enable":
+		fs := flag.NewFlagSet("crypto enable", flag.ContinueOnError)
+		keyFile := fs.String("key-file", "", "Use an existing key file instead of RELAYOPS_PASSPHRASE")
+		genKeyFile := fs.String("generate-key-file", "", "Generate a new random key file at this path and use it")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		secret := vault.Secret{Passphrase: os.Getenv("RELAYOPS_PASSPHRASE"), KeyFile: strings.TrimSpace(*keyFile)}
+		if p := strings.TrimSpace(*genKeyFile); p != "" {
+			if err := vault.GenerateKeyFile(p); err != nil {
+				fail(exitFailure, "generate key file: %v", err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Generated key file: %s (keep a backup; data cannot be recovered without it)\n", p)
+			secret.KeyFile = p
+		}
+		if secret.KeyFile != "" {
+			secret.Passphrase = ""
+		}
+		if secret.Empty() {
+			fail(exitUsage, "crypto enable requires RELAYOPS_PASSPHRASE, -key-file or -generate-key-file")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		id, err := st.EnableEncryption(ctx, secret)
+		if err != nil {
+			fail(exitFailure, "crypto enable failed: %v", err)
+			return
+		}
+		if structured() {
+			emit(keyRecord{KeyID: id})
+			return
+		}
+		fmt.Printf("Encryption enabled. key=%s\n", id)
+
+	case "rotate":
+		fs := flag.NewFlagSet("crypto rotate", flag.ContinueOnError)
+		newKeyFile := fs.String("new-key-file", "", "Re-encrypt under this key file instead of RELAYOPS_NEW_PASSPHRASE")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		secret := vault.Secret{KeyFile: strings.TrimSpace(*newKeyFile)}
+		if secret.KeyFile == "" {
+			secret.Passphrase = os.Getenv("RELAYOPS_NEW_PASSPHRASE")
+		}
+		if secret.Empty() {
+			fail(exitUsage, "crypto rotate requires RELAYOPS_NEW_PASSPHRASE or -new-key-file")
+			return
+		}
+
+		// The current key comes from RELAYOPS_PASSPHRASE / RELAYOPS_KEY_FILE as usual.
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		id, err := st.RotateKey(ctx, secret)
+		if err != nil {
+			fail(exitFailure, "crypto rotate failed: %v", err)
+			return
+		}
+		if structured() {
+			emit(keyRecord{KeyID: id})
+			return
+		}
+		fmt.Printf("Key rotated. key=%s\n", id)
+
+	default:
+		fail(exitUsage, "Unknown crypto subcommand: %s", sub)
+	}
+}
+
+func runCredential(args []string) {
+	if len(args) < 1 {
+		usage(
+			"Usage:",
+			"  relayops credential list",
+			"  relayops credential set -name winlink:AE4OK   (secret read from stdin; pat send logs in with it)",
+			"  relayops credential delete -name winlink:AE4OK",
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	sub := args[0]
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("credential list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		names, err := st.ListCredentialNames(ctx)
+		if err != nil {
+			fail(exitFailure, "credential list failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]nameRecord, 0, len(names))
+			for _, n := range names {
+				out = append(out, nameRecord{Name: n})
+			}
+			emit(out)
+			return
+		}
+		if len(names) == 0 {
+			fmt.Println("(no credentials)")
+			return
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+
+	case "set":
+		fs := flag.NewFlagSet("credential set", flag.ContinueOnError)
+		name := fs.String("name", "", "Credential name, e.g. winlink:AE4OK")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		if strings.TrimSpace(*name) == "" {
+			fail(exitUsage, "credential set requires -name")
+			return
+		}
+
+		// Read from stdin so the secret never appears in shell history or ps output.
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fail(exitFailure, "read secret from stdin: %v", err)
+			return
+		}
+		secret := strings.TrimRight(line, "\r\n")
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		if err := st.SetCredential(ctx, strings.TrimSpace(*name), secret); err != nil {
+			fail(exitFailure, "credential set failed: %v", err)
+			return
+		}
+		if structured() {
+			emit(nameRecord{Name: strings.TrimSpace(*name)})
+			return
+		}
+		fmt.Printf("Credential saved: %s\n", strings.TrimSpace(*name))
+
+	case "delete":
+		fs := flag.NewFlagSet("credential delete", flag.ContinueOnError)
+		name := fs.String("name", "", "Credential name")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		if strings.TrimSpace(*name) == "" {
+			fail(exitUsage, "credential delete requires -name")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		ok, err := st.DeleteCredential(ctx, strings.TrimSpace(*name))
+		if err != nil {
+			fail(exitFailure, "credential delete failed: %v", err)
+			return
+		}
+		if !ok {
+			fail(exitFailure, "no such credential: %s", strings.TrimSpace(*name))
+			return
+		}
+		if structured() {
+			emit(nameRecord{Name: strings.TrimSpace(*name)})
+			return
+		}
+		fmt.Printf("Credential deleted: %s\n", strings.TrimSpace(*name))
+
+	default:
+		fail(exitUsage, "Unknown credential subcommand: %s", sub)
+	}
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	q := fs.String("q", "", "text to find in subject or body")
+	n := fs.Int("n", 25, "max results")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	if strings.TrimSpace(*q) == "" {
+		fail(exitUsage, "search requires -q")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 30*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	msgs, err := st.SearchMessages(ctx, *q, *n)
+	if err != nil {
+		fail(exitFailure, "search failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toMessageRecords(msgs))
+		return
+	}
+	if len(msgs) == 0 {
+		fmt.Println("(no matches)")
+		return
+	}
+	for _, m := range msgs {
+		ts := m.CreatedAt.Local().Format("2006-01-02 15:04:05")
+		fmt.Printf("%s [%s] %s\n    %s\n", ts, m.Status, m.ID, m.Subject)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/4current/relayops/internal/daemon"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runDaemon(args []string) {
+	sub := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "run":
+		runDaemonLoop(args)
+	case "check":
+		runDaemonCheck(args)
+	case "status":
+		runDaemonStatus(args)
+	case "log":
+		runDaemonLog(args)
+	default:
+		usage(
+			"Usage:",
+			"  relayops daemon [run] [-config ~/.relayops/daemon.yaml] [-grace 2m]",
+			"  relayops daemon check [-config f]   Validate the schedule and print next run times",
+			"  relayops daemon status              Show which process holds the daemon lock",
+			"  relayops daemon log [-since YYYY-MM-DD] [-n 50]",
+		)
+	}
+}
+
+func loadDaemonConfig(path string) (*daemon.Config, string, error) {
+	if path == "" {
+		p, err := runtime.DaemonConfigPath()
+		if err != nil {
+			return nil, "", err
+		}
+		path = p
+	}
+	c, err := daemon.LoadConfig(path)
+	return c, path, err
+}
+
+func runDaemonLoop(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	config := fs.String("config", "", "Schedule file (default ~/.relayops/daemon.yaml)")
+	grace := fs.Duration("grace", daemon.DefaultGrace, "How long a running job may finish after SIGTERM")
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	cfg, path, err := loadDaemonConfig(*config)
+	if err != nil {
+		fail(exitFailure, "daemon config: %v", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(store.WithActor(context.Background(), "daemon"), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	fmt.Printf("RelayOps daemon: %d job(s), %d watch(es) from %s\n", len(cfg.Jobs), len(cfg.Watch), path)
+	d := &daemon.Daemon{Store: st, Config: cfg, Out: os.Stdout, Grace: *grace}
+	if err := d.Run(ctx); err != nil {
+		fail(exitFailure, "daemon: %v", err)
+	}
+}
+
+func runDaemonCheck(args []string) {
+	fs := flag.NewFlagSet("daemon check", flag.ContinueOnError)
+	config := fs.String("config", "", "Schedule file (default ~/.relayops/daemon.yaml)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	cfg, path, err := loadDaemonConfig(*config)
+	if err != nil {
+		fail(exitFailure, "daemon config INVALID: %v", err)
+		return
+	}
+	next := cfg.NextRuns(time.Now())
+	if !structured() {
+		fmt.Printf("%s: %d job(s), %d watch(es) OK\n", path, len(cfg.Jobs), len(cfg.Watch))
+	}
+	jobs := make([]daemonJobRecord, 0, len(cfg.Jobs))
+	for i, j := range cfg.Jobs {
+		trigger := j.Schedule
+		if trigger == "" {
+			trigger = "every " + j.Every
+		}
+		action := "playbook " + j.Playbook
+		if j.Poll != nil {
+			action = "poll"
+			if j.Poll.Connect != "" {
+				action += " via " + j.Poll.Connect
+			}
+		}
+		if structured() {
+			jobs = append(jobs, daemonJobRecord{Name: j.Name, Trigger: trigger, Action: action, NextRun: utcPtr(&next[i])})
+			continue
+		}
+		when := "never"
+		if !next[i].IsZero() {
+			when = next[i].Local().Format("Mon 2006-01-02 15:04")
+		}
+		fmt.Printf("  %-20s %-18s %-30s next %s\n", j.Name, trigger, action, when)
+	}
+	for _, w := range cfg.Watch {
+		action := "pat-import"
+		if w.Callsign != "" {
+			action += " " + w.Callsign
+		}
+		if structured() {
+			jobs = append(jobs, daemonJobRecord{Name: w.Name, Trigger: "new mail", Action: action})
+			continue
+		}
+		fmt.Printf("  %-20s %-18s %-30s\n", w.Name, "new mail", action)
+	}
+	if structured() {
+		emit(jobs)
+	}
+}
+
+func runDaemonStatus(args []string) {
+	fs := flag.NewFlagSet("daemon status", flag.ContinueOnError)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	l, err := st.GetLock(ctx, daemon.LockName)
+	if err != nil {
+		fail(exitFailure, "daemon status failed: %v", err)
+		return
+	}
+	if structured() {
+		r := daemonStatusRecord{}
+		if l != nil {
+			r = daemonStatusRecord{Running: true, PID: l.PID, Host: l.Host, AcquiredAt: utcPtr(&l.AcquiredAt), HeartbeatAt: utcPtr(&l.HeartbeatAt)}
+		}
+		emit(r)
+		return
+	}
+	if l == nil {
+		fmt.Println("Daemon: not running")
+		return
+	}
+	fmt.Printf("Daemon: pid %d on %s since %s (heartbeat %s ago)\n", l.PID, l.Host,
+		l.AcquiredAt.Local().Format(time.RFC3339), time.Since(l.HeartbeatAt).Round(time.Second))
+}
+
+func runDaemonLog(args []string) {
+	fs := flag.NewFlagSet("daemon log", flag.ContinueOnError)
+	since := fs.String("since", "", "Only events on/after this date (YYYY-MM-DD or RFC3339; default 7 days ago)")
+	n := fs.Int("n", 50, "Show the last n events (0 = all)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	sinceT, err := parseDateFlag(*since)
+	if err != nil {
+		fail(exitUsage, "invalid -since: %v", err)
+		return
+	}
+	if sinceT.IsZero() {
+		sinceT = time.Now().AddDate(0, 0, -7)
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	evs, err := st.ListEvents(ctx, store.EventFilter{Since: sinceT, Kind: "daemon.*", Limit: *n, Newest: true})
+	if err != nil {
+		fail(exitFailure, "daemon log failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toEventRecords(evs))
+		return
+	}
+	if len(evs) == 0 {
+		fmt.Println("(no daemon events)")
+		return
+	}
+	for _, ev := range evs {
+		fmt.Printf("%s %-15s %s\n", ev.Time.Local().Format("2006-01-02 15:04:05"), ev.Kind, ev.Payload)
+	}
+}
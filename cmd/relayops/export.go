@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/bundle"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/winlink"
+)
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "-", "Output bundle path ('-' for stdout)")
+	scope := fs.String("scope", "", "Only export messages in this scope: those with an external ref there, and unsent ones from its identities")
+	since := fs.String("since", "", "Only export messages created on/after this date (YYYY-MM-DD or RFC3339)")
+	until := fs.String("until", "", "Only export messages created before this date (YYYY-MM-DD or RFC3339)")
+	tag := fs.String("tag", "", "Only export messages with this tag")
+	includeDeleted := fs.Bool("all", false, "include deleted messages")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	f := store.MessageFilter{
+		Scope:          strings.TrimSpace(*scope),
+		Tag:            strings.TrimSpace(*tag),
+		IncludeDeleted: *includeDeleted,
+	}
+	var err error
+	if f.Since, err = parseDateFlag(*since); err != nil {
+		fail(exitUsage, "invalid -since: %v", err)
+		return
+	}
+	if f.Until, err = parseDateFlag(*until); err != nil {
+		fail(exitUsage, "invalid -until: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 300*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	// Keep stdout clean when the bundle itself is written there: the summary then goes
+	// to stderr, also under -o.
+	var w io.Writer = os.Stdout
+	summary := os.Stderr
+	if p := strings.TrimSpace(*out); p != "" && p != "-" {
+		fh, err := os.Create(p)
+		if err != nil {
+			fail(exitFailure, "create %s: %v", p, err)
+			return
+		}
+		defer func() { _ = fh.Close() }()
+		w = fh
+		if structured() {
+			summary = os.Stdout
+		}
+	}
+
+	report, err := bundle.Export(ctx, st, w, f)
+	if err != nil {
+		fail(exitFailure, "export failed: %v", err)
+		return
+	}
+	if structured() {
+		if err := writeRecords(summary, outFormat, exportRecord{
+			Scopes: report.Scopes, Messages: report.Messages, ExternalRefs: report.ExternalRefs,
+			BackendStates: report.BackendStates, Attempts: report.Attempts,
+		}); err != nil {
+			fail(exitFailure, "write output: %v", err)
+		}
+		return
+	}
+	fmt.Fprintf(summary, "Export complete. Scopes=%d Messages=%d ExternalRefs=%d BackendStates=%d Attempts=%d\n",
+		report.Scopes, report.Messages, report.ExternalRefs, report.BackendStates, report.Attempts)
+}
+
+func runWinlinkExport(args []string) {
+	fs := flag.NewFlagSet("winlink-export", flag.ContinueOnError)
+	root := fs.String("root", os.Getenv("RELAYOPS_WINLINK_ROOT"), "Winlink Express callsign directory to write Messages/*.mime and Data/Registry.txt into")
+	scopeFlag := fs.String("scope", "", "Only export messages with an external ref in this scope; the export is recorded there (default: the station scope)")
+	since := fs.String("since", "", "Only export messages created on/after this date (YYYY-MM-DD or RFC3339)")
+	until := fs.String("until", "", "Only export messages created before this date (YYYY-MM-DD or RFC3339)")
+	tag := fs.String("tag", "", "Only export messages with this tag")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	if strings.TrimSpace(*root) == "" {
+		fail(exitUsage, "winlink-export requires -root")
+		return
+	}
+
+	f := store.MessageFilter{Scope: strings.TrimSpace(*scopeFlag), Tag: strings.TrimSpace(*tag)}
+	var err error
+	if f.Since, err = parseDateFlag(*since); err != nil {
+		fail(exitUsage, "invalid -since: %v", err)
+		return
+	}
+	if f.Until, err = parseDateFlag(*until); err != nil {
+		fail(exitUsage, "invalid -until: %v", err)
+		return
+	}
+	scope := f.Scope
+	if scope == "" {
+		scope = runtime.IdentityScope("")
+	}
+	if scope == "" {
+		fail(exitUsage, "winlink-export requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 300*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	report, err := winlink.ExportToWinlinkExpress(ctx, st, *root, scope, f)
+	if err != nil {
+		fail(exitFailure, "winlink export failed: %v", err)
+		return
+	}
+	if report.Errors > 0 {
+		partial()
+	}
+	if structured() {
+		emit(winlinkExportRecord{
+			Root: *root, Scope: scope, Scanned: report.Scanned, Written: report.Written,
+			Skipped: report.Skipped, Errors: report.Errors,
+		})
+		return
+	}
+	fmt.Printf("Winlink export complete. Root=%s Scope=%s Scanned=%d Written=%d Skipped=%d Errors=%d\n",
+		*root, scope, report.Scanned, report.Written, report.Skipped, report.Errors)
+}
+
+func runImportBundle(args []string) {
+	fs := flag.NewFlagSet("import-bundle", flag.ContinueOnError)
+	in := fs.String("in", "", "Bundle path written by 'relayops export' ('-' for stdin)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	p := strings.TrimSpace(*in)
+	if p == "" {
+		fail(exitUsage, "import-bundle requires -in")
+		return
+	}
+
+	var r io.Reader = os.Stdin
+	if p != "-" {
+		fh, err := os.Open(p)
+		if err != nil {
+			fail(exitFailure, "open %s: %v", p, err)
+			return
+		}
+		defer func() { _ = fh.Close() }()
+		r = fh
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 300*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	report, err := bundle.Import(ctx, st, r)
+	if err != nil {
+		fail(exitFailure, "import-bundle failed: %v", err)
+		return
+	}
+	if report.Errors > 0 {
+		partial()
+	}
+	var failures []bundleFailureRecord
+	for _, f := range report.Failures {
+		failures = append(failures, bundleFailureRecord{Line: f.Line, ID: f.ID, Error: f.Err})
+	}
+	if structured() {
+		emit(bundleImportRecord{
+			Scanned: report.Scanned, Created: report.Created, Existing: report.Existing,
+			Merged: report.Merged, Errors: report.Errors, Failures: failures,
+		})
+		return
+	}
+	fmt.Printf("Bundle import complete. Scanned=%d Created=%d Existing=%d Merged=%d Errors=%d\n",
+		report.Scanned, report.Created, report.Existing, report.Merged, report.Errors)
+	// stderr, so the summary on stdout stays one line.
+	for _, f := range failures {
+		if f.ID != "" {
+			fmt.Fprintf(os.Stderr, "line %d (%s): %s\n", f.Line, f.ID, f.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", f.Line, f.Error)
+		}
+	}
+}
+
+// parseDateFlag accepts an empty string, a calendar date (local midnight) or an RFC3339 timestamp.
+func parseDateFlag(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/4current/relayops/internal/forms"
+	"github.com/4current/relayops/internal/runtime"
+)
+
+func runForms(args []string) {
+	if len(args) == 0 {
+		fail(exitUsage, "forms requires a subcommand: list | show <name> | update -src <dir|file>")
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("forms list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		defs, err := forms.Catalog()
+		if err != nil {
+			fail(exitFailure, "forms list failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]formRecord, 0, len(defs))
+			for _, d := range defs {
+				out = append(out, formRecord{Name: d.Name, Title: d.Title, Source: d.Source})
+			}
+			emit(out)
+			return
+		}
+		dir, _ := runtime.FormsDir()
+		fmt.Printf("Forms catalog (updates in %s):\n", dir)
+		for _, d := range defs {
+			fmt.Printf("  %-12s %-36s %s\n", d.Name, d.Title, d.Source)
+		}
+
+	case "show":
+		fs := flag.NewFlagSet("forms show", flag.ContinueOnError)
+		outputFlag(fs)
+		name, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return
+		}
+		if name == "" {
+			fail(exitUsage, "forms show requires a form name")
+			return
+		}
+		d, err := forms.Lookup(name)
+		if err != nil {
+			fail(exitFailure, "forms show failed: %v", err)
+			return
+		}
+		if structured() {
+			r := formDetail{
+				formRecord: formRecord{Name: d.Name, Title: d.Title, Source: d.Source},
+				Viewer:     d.DisplayForm, Attachment: d.AttachmentName(), Fields: []formFieldRecord{},
+			}
+			for _, f := range d.Fields {
+				r.Fields = append(r.Fields, formFieldRecord{Name: f.Name, Label: f.Label, Required: f.Required, Default: f.Default})
+			}
+			emit(r)
+			return
+		}
+		fmt.Printf("Form:       %s - %s (%s)\n", d.Name, d.Title, d.Source)
+		fmt.Printf("Viewer:     %s\n", d.DisplayForm)
+		fmt.Printf("Attachment: %s\n", d.AttachmentName())
+		fmt.Println("Fields:")
+		for _, f := range d.Fields {
+			flags := ""
+			if f.Required {
+				flags = "required"
+			}
+			if f.Default != "" {
+				flags = strings.TrimSpace(flags + " default=" + f.Default)
+			}
+			fmt.Printf("  %-20s %-36s %s\n", f.Name, f.Label, flags)
+		}
+
+	case "update":
+		fs := flag.NewFlagSet("forms update", flag.ContinueOnError)
+		src := fs.String("src", "", "form definition file or directory of *.yaml definitions")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		if strings.TrimSpace(*src) == "" {
+			fail(exitUsage, "forms update requires -src")
+			return
+		}
+		names, err := forms.Install(*src)
+		if err != nil {
+			fail(exitFailure, "forms update failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]nameRecord, 0, len(names))
+			for _, n := range names {
+				out = append(out, nameRecord{Name: n})
+			}
+			emit(out)
+			return
+		}
+		fmt.Printf("Installed %d form definition(s): %s\n", len(names), strings.Join(names, ", "))
+
+	default:
+		fail(exitUsage, "Unknown forms subcommand: %s", args[0])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func runIdentity(args []string) {
+	if len(args) < 1 {
+		usage(
+			"Usage:",
+			"  relayops identity list",
+			"  relayops identity add -address EOC-PLANS -callsign AE4OK [-scope EOC-PLANS@eoc] [-note \"...\"]",
+			"  relayops identity remove -address EOC-PLANS",
+			"",
+			"A tactical address sends through its licensed callsign's mailbox (compose -from EOC-PLANS),",
+			"and mail addressed to it is imported into its own scope.",
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	sub := args[0]
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("identity list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		ids, err := st.ListIdentities(ctx)
+		if err != nil {
+			fail(exitFailure, "identity list failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]identityRecord, 0, len(ids))
+			for _, id := range ids {
+				out = append(out, toIdentityRecord(id))
+			}
+			emit(out)
+			return
+		}
+		if len(ids) == 0 {
+			fmt.Println("(no tactical identities)")
+			return
+		}
+		for _, id := range ids {
+			fmt.Printf("%s\t%s\tscope=%s\t%s\n", id.Address, id.Callsign, id.Scope, id.Note)
+		}
+
+	case "add":
+		fs := flag.NewFlagSet("identity add", flag.ContinueOnError)
+		address := fs.String("address", "", "Tactical address, e.g. EOC-PLANS")
+		callsign := fs.String("callsign", "", "Licensed callsign that operates it (defaults to RELAYOPS_CALLSIGN)")
+		scope := fs.String("scope", "", "Scope for its mail (default derived from the address and RELAYOPS_STATION)")
+		note := fs.String("note", "", "Optional note")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		if strings.TrimSpace(*address) == "" {
+			fail(exitUsage, "identity add requires -address")
+			return
+		}
+		call := strings.TrimSpace(*callsign)
+		if call == "" {
+			call = envOr("RELAYOPS_CALLSIGN", "")
+		}
+		if call == "" {
+			fail(exitUsage, "identity add requires -callsign (or RELAYOPS_CALLSIGN)")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		id, err := st.SetIdentity(ctx, store.Identity{Address: *address, Callsign: call, Scope: *scope, Note: *note})
+		if err != nil {
+			fail(exitFailure, "identity add failed: %v", err)
+			return
+		}
+		if structured() {
+			emit(toIdentityRecord(*id))
+			return
+		}
+		fmt.Printf("Identity saved: %s -> %s (scope %s)\n", id.Address, id.Callsign, id.Scope)
+
+	case "remove":
+		fs := flag.NewFlagSet("identity remove", flag.ContinueOnError)
+		address := fs.String("address", "", "Tactical address")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		if strings.TrimSpace(*address) == "" {
+			fail(exitUsage, "identity remove requires -address")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		ok, err := st.DeleteIdentity(ctx, *address)
+		if err != nil {
+			fail(exitFailure, "identity remove failed: %v", err)
+			return
+		}
+		if !ok {
+			fail(exitFailure, "no such identity: %s", strings.ToUpper(strings.TrimSpace(*address)))
+			return
+		}
+		if structured() {
+			emit(nameRecord{Name: strings.ToUpper(strings.TrimSpace(*address))})
+			return
+		}
+		fmt.Printf("Identity removed: %s (its scope and messages are kept)\n", strings.ToUpper(strings.TrimSpace(*address)))
+
+	default:
+		fail(exitUsage, "Unknown identity subcommand: %s", sub)
+	}
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/4current/relayops/internal/compose"
 	"github.com/4current/relayops/internal/core"
 	"github.com/4current/relayops/internal/ops"
 	"github.com/4current/relayops/internal/runtime"
@@ -24,20 +27,26 @@ var (
 )
 
 func main() {
+	profile, args := splitProfileFlag(os.Args[1:])
+	os.Args = append(os.Args[:1], args...)
 	if len(os.Args) < 2 {
 		printUsage()
-		return
+		os.Exit(exitUsage)
+	}
+	// config edits the profiles file, so a broken file must not stop it.
+	if os.Args[1] != "config" && !applyProfile(profile) {
+		os.Exit(exitCode)
 	}
 
 	switch os.Args[1] {
 	case "version":
-		fmt.Printf("RelayOps %s\nCommit: %s\nBuilt: %s\n", version, commit, buildDate)
+		runVersion(os.Args[2:])
 
 	case "doctor":
-		runDoctor()
+		runDoctor(os.Args[2:])
 
 	case "init":
-		runInit()
+		runInit(os.Args[2:])
 
 	case "compose":
 		runCompose(os.Args[2:])
@@ -66,16 +75,102 @@ func main() {
 	case "winlink-import":
 		runWinlinkImport(os.Args[2:])
 
+	case "winlink-export":
+		runWinlinkExport(os.Args[2:])
+
 	case "pat-import":
 		runPatImport(os.Args[2:])
 
+	case "pat-sync":
+		runPatSync(os.Args[2:])
+
 	case "scope":
 		runScope(os.Args[2:])
 
+	case "export":
+		runExport(os.Args[2:])
+
+	case "import-bundle":
+		runImportBundle(os.Args[2:])
+
+	case "retention":
+		runRetention(os.Args[2:])
+
+	case "purge":
+		runPurge(os.Args[2:])
+
+	case "crypto":
+		runCrypto(os.Args[2:])
+
+	case "credential":
+		runCredential(os.Args[2:])
+
+	case "audit":
+		runAudit(os.Args[2:])
+
+	case "sessions":
+		runSessions(os.Args[2:])
+
+	case "playbook":
+		runPlaybook(os.Args[2:])
+
+	case "participation":
+		runParticipation(os.Args[2:])
+
+	case "template":
+		runTemplate(os.Args[2:])
+
+	case "show":
+		runShow(os.Args[2:])
+
+	case "forms":
+		runForms(os.Args[2:])
+
+	case "search":
+		runSearch(os.Args[2:])
+
+	case "daemon":
+		runDaemon(os.Args[2:])
+
+	case "serve":
+		runServe(os.Args[2:])
+
+	case "tui":
+		runTUI(os.Args[2:])
+
+	case "identity":
+		runIdentity(os.Args[2:])
+
+	case "config":
+		runConfig(profile, os.Args[2:])
+
 	default:
-		fmt.Printf("Unknown command: %s\n\n", os.Args[1])
+		fail(exitUsage, "Unknown command: %s\n", os.Args[1])
 		printUsage()
 	}
+	os.Exit(exitCode)
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	if structured() {
+		emit(versionRecord{Version: version, Commit: commit, BuildDate: buildDate})
+		return
+	}
+	fmt.Printf("RelayOps %s\nCommit: %s\nBuilt: %s\n", version, commit, buildDate)
+}
+
+// cliContext tags store mutations with the subcommand that made them, e.g. "cli:send".
+func cliContext() context.Context {
+	actor := "cli"
+	if len(os.Args) > 1 {
+		actor += ":" + os.Args[1]
+	}
+	return store.WithActor(context.Background(), actor)
 }
 
 func printUsage() {
@@ -83,87 +178,223 @@ func printUsage() {
 	fmt.Println("")
 	fmt.Println("Usage:")
 	fmt.Println("  relayops version")
-	fmt.Println("  relayops doctor")
-	fmt.Println("  relayops init")
-	fmt.Println("  relayops compose -s \"subject\" -b \"body\" [-t tag1,tag2] [-allow ...] [-prefer ...] [-session winlink|radio_only|post_office|p2p]")
-	fmt.Println("  relayops list [-n 25]")
-	fmt.Println("  relayops outbox [-n 25]")
+	fmt.Println("  relayops doctor [-o json|jsonl|csv|table]  Check the station; exits 3 when a check warns")
+	fmt.Println("  relayops init [-o json|jsonl|csv|table]")
+	fmt.Println("  relayops compose -s \"subject\" -b \"body\" [-t tag1,tag2] [-allow ...] [-prefer ...] [-session winlink|radio_only|post_office|p2p] [-from EOC-PLANS]")
+	fmt.Println("  relayops compose -template checkin [-var key=value ...] [-to ...] [-t ...]  Compose from a template")
+	fmt.Println("  relayops template list | template show|check <name> [-var key=value]  Message templates in ~/.relayops/templates")
+	fmt.Println("  relayops compose -form ICS213|ICS309|Bulletin -var field=value ... [-to ...]  Compose a Winlink form message")
+	fmt.Println("  relayops compose -radiogram -var number=1 -var address=\"NAME|STREET|CITY ST ZIP\" -var text=\"...\" -var signature=NAME [-var hx=HXG] [-to ...]  Compose an NTS radiogram (check is computed)")
+	fmt.Println("  relayops forms list | forms show <name> | forms update -src <dir|file>  Winlink forms catalog (~/.relayops/forms)")
+	fmt.Println("  relayops show -id <message-id> [-raw]  Show a message; forms are rendered readable")
+	fmt.Println("  relayops list [-n 25] [-o json|jsonl|csv|table]")
+	fmt.Println("  relayops outbox [-n 25] [-o json|jsonl|csv|table]")
 	fmt.Println("  relayops queue -tag winlink_wednesday")
-	fmt.Println("  relayops scope list")
+	fmt.Println("  relayops scope list|stats|rename|merge|archive|delete  Manage scopes; merge moves refs and reports conflicts")
 	fmt.Println("  relayops scope create -scope AE4OK@general [-note \"...\" ]")
+	fmt.Println("  relayops identity list|add|remove  Tactical addresses (EOC-PLANS) and the licensed callsign that operates them")
 	fmt.Println("  relayops mark-sent -id <message-id>")
 	fmt.Println("  relayops mark-failed -id <message-id> -err \"reason\"")
-	fmt.Println("  relayops send [-tag t] [-n 25]  Send queued messages (simulated for now)")
-	fmt.Println("  relayops winlink-import -root \"/path/to/RMS Express/AE4OK\"  Import Winlink Express messages into the canonical store")
-	fmt.Println("  relayops pat-import [-mbox \"/path/to/pat/mailbox\"]  Import PAT mailbox messages into the canonical store")
+	fmt.Println("  relayops send [-tag t] [-n 25] [-connect telnet|URL]  Send queued messages via pat connect")
+	fmt.Println("  relayops winlink-import -root \"/path/to/RMS Express/AE4OK\" [-full]  Import Winlink Express messages into the canonical store")
+	fmt.Println("  relayops winlink-export -root \"/path/to/RMS Express/AE4OK\" [-scope s] [-since YYYY-MM-DD] [-tag t]  Write messages into a Winlink Express directory")
+	fmt.Println("  relayops pat-import [-mbox \"/path/to/pat/mailbox\"] [-full] [-watch]  Import PAT mailbox messages into the canonical store; -watch keeps importing new mail")
+	fmt.Println("  relayops pat-sync [-mbox \"/path/to/pat/mailbox\"] [-push -tag t]  Import, then mark delivered mail sent and flag mail deleted from pat's outbox; -push writes drafts tagged t to it")
+	fmt.Println("  relayops export [-out bundle.jsonl] [-scope s] [-since YYYY-MM-DD] [-until YYYY-MM-DD] [-tag t]  Write a portable JSONL bundle")
+	fmt.Println("  relayops import-bundle -in bundle.jsonl  Merge a bundle into the canonical store (idempotent)")
+	fmt.Println("  relayops retention list|set|remove  Manage per-scope/status retention policies")
+	fmt.Println("  relayops purge [--dry-run] [-v]  Apply retention policies (hard-delete/archive)")
+	fmt.Println("  relayops search -q \"text\" [-n 25]  Search subjects and bodies (needs unlock if encrypted)")
+	fmt.Println("  relayops crypto status|enable|rotate  Manage encryption at rest (RELAYOPS_PASSPHRASE / RELAYOPS_KEY_FILE)")
+	fmt.Println("  relayops credential list|set|delete  Manage stored Winlink passwords; send logs in with winlink:<CALL>")
+	fmt.Println("  relayops playbook list | playbook run <file|winlink-wednesday> [--dry-run] [-var k=v]  Run a playbook; each step is written to the audit trail")
+	fmt.Println("  relayops daemon [-config ~/.relayops/daemon.yaml] | daemon check|status|log  Run scheduled playbooks and mail polls unattended")
+	fmt.Println("  relayops serve [-addr 127.0.0.1:8787] [-allow-remote]  Local HTTP/JSON API and web dashboard (token in ~/.relayops/api-token)")
+	fmt.Println("                                     Serve the LAN with -addr :8787 -allow-remote and open http://<station>:8787/")
+	fmt.Println("  relayops tui [-transport pat|sim] [-connect telnet|URL]  Full-screen terminal UI: folders, viewer, compose, live session log")
+	fmt.Println("  relayops participation [-scope s|all] [-event winlink-wednesday] [-since YYYY-MM-DD]  Season participation record")
+	fmt.Println("  relayops sessions [-n 25] [-msg <message-id>] | sessions show -id <session-id> [-transcript]  Connect session records")
+	fmt.Println("  relayops audit [-id <message-id>] [-since YYYY-MM-DD] [-n 100] [-verify]  Show the append-only audit trail")
+	fmt.Println("")
+	fmt.Println("  relayops config get [key] | config set <key> <value> | config validate  Station profiles in ~/.relayops/config.yaml")
+	fmt.Println("")
+	fmt.Println("Profiles:")
+	fmt.Println("  --profile <name> (or RELAYOPS_PROFILE) before or after any command selects a station profile;")
+	fmt.Println("  otherwise default_profile is used. A profile sets callsign, station, scope, DB, templates,")
+	fmt.Println("  PAT/Winlink parameters and compose policy; environment variables still win over the default.")
+	fmt.Println("")
+	fmt.Println("Output:")
+	fmt.Println("  Commands that list or report accept -o json|jsonl|csv|table. Field names are stable;")
+	fmt.Println("  the default text output is for people and may change. Errors go to stderr.")
+	fmt.Println("")
+	fmt.Println("Exit status:")
+	fmt.Println("  0 success, 1 failure, 2 usage error, 3 partial (some messages or files failed)")
 	fmt.Println("")
 }
 
 func runPatImport(args []string) {
 	fs := flag.NewFlagSet("pat-import", flag.ContinueOnError)
-	mbox := fs.String("mbox", defaultPatMailboxDir(), "Path to PAT mailbox directory")
+	defaultMbox, _ := pat.MailboxDir()
+	mbox := fs.String("mbox", defaultMbox, "Path to PAT mailbox directory")
 	callsign := fs.String("callsign", "", "Callsign (defaults to RELAYOPS_CALLSIGN)")
 	scopeFlag := fs.String("scope", "", "Operational scope/container (e.g., AE4OK@general). If empty, derived from RELAYOPS_CALLSIGN/RELAYOPS_STATION.")
 	allowNewScope := fs.Bool("allow-new-scope", false, "Allow creating a new global scope if it does not already exist")
-	patBin := fs.String("pat", "pat", "Path to pat binary (default 'pat' in PATH)")
-	_ = fs.Parse(args)
+	full := fs.Bool("full", false, "Rescan every file, including those unchanged since the last import")
+	watch := fs.Bool("watch", false, "Keep running and import new or changed .b2f files as they land (Ctrl-C to stop)")
+	debounce := fs.Duration("debounce", pat.DefaultDebounce, "With -watch, quiet time after the last change before importing")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	scope := strings.TrimSpace(*scopeFlag)
 	if scope == "" {
 		scope = runtime.IdentityScope("")
 	}
 	if scope == "" {
-		fmt.Println("pat-import requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
+		fail(exitUsage, "pat-import requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
+		return
+	}
+	if *watch {
+		runPatWatch(*mbox, *callsign, scope, *allowNewScope, *debounce)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 180*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
-	exists, err := st.ScopeExists(ctx, scope)
-	if !exists {
-		if !*allowNewScope {
-			fmt.Printf(
-				"Refusing to create new scope '%s'.\nCreate it first with: relayops scope create -scope %s\n(or re-run with -allow-new-scope)\n",
-				scope, scope,
-			)
-			return
-		}
+	if !ensureImportScope(ctx, st, scope, *allowNewScope) {
+		return
+	}
+	report, err := pat.ImportFromMailbox(ctx, st, *mbox, *callsign, scope, *full)
+	if err != nil {
+		fail(exitFailure, "pat import failed: %v", err)
+		return
+	}
+	printImport("PAT", importRecord{Source: "pat", Scope: scope, Scanned: report.Scanned, Created: report.Created, Updated: report.Updated, Errors: report.Errors, Skipped: report.Skipped})
+}
 
-		fmt.Printf(
-			"WARNING: Creating new operational scope '%s'. This should be rare.\n",
-			scope,
-		)
+// runPatWatch is pat-import -watch: one import, then one per burst of new mail.
+func runPatWatch(mbox, callsign, scope string, allowNewScope bool, debounce time.Duration) {
+	ctx, stop := signal.NotifyContext(cliContext(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-		if err := st.CreateScope(ctx, scope, "auto-created by import"); err != nil {
-			fmt.Printf("create scope failed: %v\n", err)
-			return
-		}
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	if !ensureImportScope(ctx, st, scope, allowNewScope) {
+		return
+	}
+	if !structured() {
+		fmt.Printf("Watching PAT mailbox %s for scope %s (Ctrl-C to stop)\n", mbox, scope)
 	}
-	report, err := pat.ImportFromMailbox(ctx, st, *patBin, *mbox, *callsign, scope)
+	first := true
+	err = pat.WatchMailbox(ctx, st, mbox, callsign, scope, pat.WatchOptions{
+		Debounce: debounce,
+		OnImport: func(rep *pat.ImportReport, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "pat import failed: %v\n", err)
+				partial()
+				return
+			}
+			// Later imports only speak up when something arrived.
+			if !first && rep.Created+rep.Updated+rep.Errors == 0 {
+				return
+			}
+			first = false
+			printImport("PAT", importRecord{Source: "pat", Scope: scope, Scanned: rep.Scanned, Created: rep.Created, Updated: rep.Updated, Errors: rep.Errors, Skipped: rep.Skipped})
+		},
+	})
 	if err != nil {
-		fmt.Printf("pat import failed: %v\n", err)
+		fail(exitFailure, "pat watch failed: %v", err)
+	}
+}
+
+func runPatSync(args []string) {
+	fs := flag.NewFlagSet("pat-sync", flag.ContinueOnError)
+	defaultMbox, _ := pat.MailboxDir()
+	mbox := fs.String("mbox", defaultMbox, "Path to PAT mailbox directory")
+	callsign := fs.String("callsign", "", "Callsign (defaults to RELAYOPS_CALLSIGN)")
+	scopeFlag := fs.String("scope", "", "Operational scope/container (e.g., AE4OK@general). If empty, derived from RELAYOPS_CALLSIGN/RELAYOPS_STATION.")
+	allowNewScope := fs.Bool("allow-new-scope", false, "Allow creating a new global scope if it does not already exist")
+	push := fs.Bool("push", false, "Write the drafts tagged -tag that are not in pat yet into its outbox (each draft once)")
+	tag := fs.String("tag", "", "Drafts to push (required with -push)")
+	full := fs.Bool("full", false, "Rescan every file, including those unchanged since the last sync")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	if *push && strings.TrimSpace(*tag) == "" {
+		fail(exitUsage, "pat-sync -push requires -tag")
+		return
+	}
+	scope := strings.TrimSpace(*scopeFlag)
+	if scope == "" {
+		scope = runtime.IdentityScope("")
+	}
+	if scope == "" {
+		fail(exitUsage, "pat-sync requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
 		return
 	}
-	fmt.Printf("PAT import complete. Scope=%s Scanned=%d Created=%d Updated=%d Errors=%d\n", scope, report.Scanned, report.Created, report.Updated, report.Errors)
+
+	ctx, cancel := context.WithTimeout(cliContext(), 180*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	if !ensureImportScope(ctx, st, scope, *allowNewScope) {
+		return
+	}
+	report, err := pat.SyncMailbox(ctx, st, *mbox, *callsign, scope, pat.SyncOptions{Push: *push, Tag: strings.TrimSpace(*tag), Full: *full})
+	if err != nil {
+		fail(exitFailure, "pat sync failed: %v", err)
+		return
+	}
+	imp := report.Import
+	if imp.Errors+report.Errors > 0 {
+		partial()
+	}
+	if structured() {
+		emit(patSyncRecord{
+			Scope: scope, Scanned: imp.Scanned, Created: imp.Created, Updated: imp.Updated, Skipped: imp.Skipped,
+			Sent: report.Sent, Flagged: report.Flagged, Pushed: report.Pushed, Errors: imp.Errors + report.Errors,
+		})
+		return
+	}
+	fmt.Printf("PAT sync complete. Scope=%s Scanned=%d Created=%d Updated=%d Skipped=%d Sent=%d Flagged=%d Pushed=%d Errors=%d\n",
+		scope, imp.Scanned, imp.Created, imp.Updated, imp.Skipped, report.Sent, report.Flagged, report.Pushed, imp.Errors+report.Errors)
 }
 
 func runWinlinkImport(args []string) {
 	fs := flag.NewFlagSet("winlink-import", flag.ContinueOnError)
-	root := fs.String("root", "", "Winlink Express callsign directory (contains Data/Registry.txt and Messages/*.mime)")
+	root := fs.String("root", os.Getenv("RELAYOPS_WINLINK_ROOT"), "Winlink Express callsign directory (contains Data/Registry.txt and Messages/*.mime)")
 	scopeFlag := fs.String("scope", "", "Operational scope/container (e.g., AE4OK@general). If empty, derived from RELAYOPS_CALLSIGN/RELAYOPS_STATION.")
 	allowNewScope := fs.Bool("allow-new-scope", false, "Allow creating a new global scope if it does not already exist")
-	_ = fs.Parse(args)
+	full := fs.Bool("full", false, "Rescan every message, including those unchanged since the last import")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	if strings.TrimSpace(*root) == "" {
-		fmt.Println("winlink-import requires -root")
+		fail(exitUsage, "winlink-import requires -root")
 		return
 	}
 
@@ -172,82 +403,163 @@ func runWinlinkImport(args []string) {
 		scope = runtime.IdentityScope("")
 	}
 	if scope == "" {
-		fmt.Println("winlink-import requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
+		fail(exitUsage, "winlink-import requires a scope (set RELAYOPS_CALLSIGN/RELAYOPS_STATION or pass -scope)")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 120*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
-	exists, err := st.ScopeExists(ctx, scope)
-	if !exists {
-		if !*allowNewScope {
-			fmt.Printf(
-				"Refusing to create new scope '%s'.\nCreate it first with: relayops scope create -scope %s\n(or re-run with -allow-new-scope)\n",
-				scope, scope,
-			)
-			return
-		}
-
-		fmt.Printf(
-			"WARNING: Creating new operational scope '%s'. This should be rare.\n",
-			scope,
-		)
-
-		if err := st.CreateScope(ctx, scope, "auto-created by import"); err != nil {
-			fmt.Printf("create scope failed: %v\n", err)
-			return
-		}
+	if !ensureImportScope(ctx, st, scope, *allowNewScope) {
+		return
 	}
-	report, err := winlink.ImportFromWinlinkExpress(ctx, st, *root, scope)
+	report, err := winlink.ImportFromWinlinkExpress(ctx, st, *root, scope, *full)
 	if err != nil {
-		fmt.Printf("winlink import failed: %v\n", err)
+		fail(exitFailure, "winlink import failed: %v", err)
 		return
 	}
-	fmt.Printf("Winlink import complete. Scope=%s Scanned=%d Created=%d Updated=%d Errors=%d\n", scope, report.Scanned, report.Created, report.Updated, report.Errors)
+	printImport("Winlink", importRecord{Source: "winlink", Scope: scope, Scanned: report.Scanned, Created: report.Created, Updated: report.Updated, Errors: report.Errors, Skipped: report.Skipped, UnmappedColumns: report.Unmapped})
 }
 
-func runDoctor() {
+// ensureImportScope checks that an import's scope exists, creating it only when
+// -allow-new-scope was given.
+func ensureImportScope(ctx context.Context, st *store.Store, scope string, allowNew bool) bool {
+	sc, exists, err := st.GetScope(ctx, scope)
+	if err != nil {
+		fail(exitFailure, "scope lookup failed: %v", err)
+		return false
+	}
+	if exists && !sc.ArchivedAt.IsZero() {
+		fail(exitFailure, "Scope '%s' is archived; unarchive it first with: relayops scope archive -scope %s -undo", scope, scope)
+		return false
+	}
+	if exists {
+		return true
+	}
+	if !allowNew {
+		fail(exitFailure,
+			"Refusing to create new scope '%s'.\nCreate it first with: relayops scope create -scope %s\n(or re-run with -allow-new-scope)",
+			scope, scope,
+		)
+		return false
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// stderr, so -o output on stdout stays parseable.
+	fmt.Fprintf(os.Stderr,
+		"WARNING: Creating new operational scope '%s'. This should be rare.\n",
+		scope,
+	)
 
-	if err := ops.Doctor(ctx); err != nil {
-		fmt.Printf("✘ doctor failed: %v\n", err)
-		return
+	if err := st.CreateScope(ctx, scope, "auto-created by import"); err != nil {
+		fail(exitFailure, "create scope failed: %v", err)
+		return false
 	}
-
+	return true
 }
 
-func runInit() {
-	fmt.Println("Initializing RelayOps...")
+// printImport reports an import; files that failed to import make the exit status partial.
+func printImport(label string, r importRecord) {
+	if r.Errors > 0 {
+		partial()
+	}
+	if structured() {
+		emit(r)
+		return
+	}
+	fmt.Printf("%s import complete. Scope=%s Scanned=%d Created=%d Updated=%d Skipped=%d Errors=%d\n", label, r.Scope, r.Scanned, r.Created, r.Updated, r.Skipped, r.Errors)
+	if len(r.UnmappedColumns) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: Registry.txt columns %v are not in any known layout; their values were kept in the backend state\n", r.UnmappedColumns)
+	}
+}
 
-	// Friendly check 1: runtime dir
-	dir, err := runtime.EnsureAppDir()
-	if err != nil {
-		fmt.Printf("✘ runtime dir: %v\n", err)
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
 		return
 	}
-	fmt.Printf("✔ runtime dir: %s\n", dir)
 
-	// Canonical init logic (includes SQLite open + migrate)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 5*time.Second)
 	defer cancel()
 
-	if err := ops.InitRuntime(ctx); err != nil {
-		fmt.Printf("✘ sqlite store: %v\n", err)
+	var checks []ops.Check
+	if activeProfile != "" {
+		checks = append(checks, ops.Check{Name: "profile", Status: ops.CheckOK, Detail: activeProfile})
+	}
+	if !structured() {
+		fmt.Println("Running diagnostics...")
+	}
+	checks = append(checks, ops.Doctor(ctx)...)
+	reportChecks(checks)
+	if exitCode != exitFailure && !structured() {
+		fmt.Println("Diagnostics complete.")
+	}
+}
+
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
 		return
 	}
-	fmt.Println("✔ sqlite store: OK")
+	if !structured() {
+		fmt.Println("Initializing RelayOps...")
+	}
 
-	fmt.Println("Initialization complete.")
+	// Friendly check 1: runtime dir
+	var checks []ops.Check
+	if dir, err := runtime.EnsureAppDir(); err != nil {
+		checks = append(checks, ops.Check{Name: "runtime dir", Status: ops.CheckFail, Detail: err.Error()})
+	} else {
+		checks = append(checks, ops.Check{Name: "runtime dir", Status: ops.CheckOK, Detail: dir})
+
+		// Canonical init logic (includes SQLite open + migrate)
+		ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+		defer cancel()
+		if err := ops.InitRuntime(ctx); err != nil {
+			checks = append(checks, ops.Check{Name: "sqlite store", Status: ops.CheckFail, Detail: err.Error()})
+		} else {
+			checks = append(checks, ops.Check{Name: "sqlite store", Status: ops.CheckOK, Detail: "OK"})
+		}
+	}
+	reportChecks(checks)
+	if exitCode == exitOK && !structured() {
+		fmt.Println("Initialization complete.")
+	}
+}
+
+// reportChecks prints or emits checks. A failed check exits exitFailure and a
+// warning exitPartial, so scripts can tell a degraded station from a healthy one.
+func reportChecks(checks []ops.Check) {
+	if structured() {
+		recs := make([]checkRecord, 0, len(checks))
+		for _, c := range checks {
+			recs = append(recs, checkRecord{Check: c.Name, Status: c.Status, Detail: c.Detail})
+		}
+		emit(recs)
+	}
+	for _, c := range checks {
+		switch c.Status {
+		case ops.CheckFail:
+			fail(exitFailure, "✘ %s: %s", c.Name, c.Detail)
+		case ops.CheckWarn:
+			partial()
+			if !structured() {
+				fmt.Printf("⚠ %s: %s\n", c.Name, c.Detail)
+			}
+		default:
+			if !structured() {
+				fmt.Printf("✔ %s: %s\n", c.Name, c.Detail)
+			}
+		}
+	}
 }
 
 func runCompose(args []string) {
@@ -257,68 +569,81 @@ func runCompose(args []string) {
 	tagCSV := fs.String("t", "", "comma-separated tags")
 	allowed := fs.String("allow", "", "allowed modes (comma-separated), e.g. packet,ardop,vara_hf")
 	preferred := fs.String("prefer", "", "preferred modes (comma-separated), e.g. packet,vara_fm,telnet")
-	session := fs.String("session", "winlink", "session mode: winlink, radio_only, post_office, p2p")
+	session := fs.String("session", "", "session mode: winlink, radio_only, post_office, p2p (default from the profile, else winlink)")
 	to := fs.String("to", "", "recipient callsign or email, e.g. AE4OK or AE4OK@winlink.org")
-	_ = fs.Parse(args)
-
-	if strings.TrimSpace(*subject) == "" || strings.TrimSpace(*body) == "" {
-		fmt.Println("compose requires -s (subject) and -b (body)")
-		fmt.Println("Example: relayops compose -s \"Winlink Wednesday\" -b \"Check-in\" -t winlink_wednesday")
+	from := fs.String("from", "", "send as this callsign or registered tactical address, e.g. EOC-PLANS (see relayops identity)")
+	tmplName := fs.String("template", "", "template name (in ~/.relayops/templates or built-in) or .tmpl file")
+	formName := fs.String("form", "", "Winlink form from the forms catalog, e.g. ICS213 (fields via -var)")
+	isRadiogram := fs.Bool("radiogram", false, "compose an ARRL/NTS radiogram (fields via -var: number, precedence, hx, address, text, signature, ...)")
+	vars := varFlags{}
+	fs.Var(vars, "var", "Set a template variable or form field, key=value (repeatable)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
 		return
 	}
 
-	msg := core.NewMessage(*subject, *body)
-
-	t := strings.TrimSpace(*to)
-	if t != "" {
-		if strings.Contains(t, "@") {
-			msg.To = append(msg.To, core.Address{Email: t})
-		} else {
-			msg.To = append(msg.To, core.Address{Callsign: t})
-		}
+	if *subject == "" && *body == "" && strings.TrimSpace(*tmplName) == "" && strings.TrimSpace(*formName) == "" && !*isRadiogram {
+		fail(exitUsage, "compose requires -s (subject) and -b (body), -template, -form or -radiogram")
+		fmt.Fprintln(os.Stderr, "Example: relayops compose -s \"Winlink Wednesday\" -b \"Check-in\" -t winlink_wednesday")
+		fmt.Fprintln(os.Stderr, "         relayops compose -template checkin -var location=\"Knoxville, TN\" -to AE4OK")
+		fmt.Fprintln(os.Stderr, "         relayops compose -form ICS213 -var to_name=EOC -var subjectline=Status -var message=\"All OK\" -to AE4OK")
+		return
 	}
 
+	req := compose.Request{
+		Subject:   *subject,
+		Body:      *body,
+		Template:  *tmplName,
+		Form:      *formName,
+		Radiogram: *isRadiogram,
+		Vars:      vars,
+		From:      *from,
+		To:        []string{*to},
+		Tags:      strings.Split(*tagCSV, ","),
+		Session:   *session,
+	}
 	if strings.TrimSpace(*allowed) != "" {
-		msg.Meta.Transport.Allowed = parseModes(*allowed)
+		req.Allow = strings.Split(*allowed, ",")
 	}
-
 	if strings.TrimSpace(*preferred) != "" {
-		msg.Meta.Transport.Preferred = parseModes(*preferred)
+		req.Prefer = strings.Split(*preferred, ",")
 	}
-
-	sess := core.SessionMode(strings.ToLower(strings.TrimSpace(*session)))
-	switch sess {
-	case core.SessionWinlink, core.SessionRadioOnly, core.SessionPostOffice, core.SessionP2P:
-		msg.Meta.Session = sess
-	default:
-		fmt.Println("Invalid -session. Valid: winlink, radio_only, post_office, p2p")
+	msg, err := compose.Build(cliContext(), req)
+	if err != nil {
+		fail(exitFailure, "compose: %v", err)
 		return
 	}
 
-	if strings.TrimSpace(*tagCSV) != "" {
-		for _, t := range strings.Split(*tagCSV, ",") {
-			t = strings.TrimSpace(t)
-			if t != "" {
-				msg.Tags = append(msg.Tags, t)
-			}
-		}
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
+	if msg.From.Callsign != "" {
+		tactical, err := st.IdentityCallsigns(ctx)
+		if err != nil {
+			fail(exitFailure, "compose: %v", err)
+			return
+		}
+		if _, err := pat.MailboxCall(os.Getenv("RELAYOPS_CALLSIGN"), msg.From.Callsign, tactical); err != nil {
+			fail(exitFailure, "compose: %v", err)
+			return
+		}
+	}
 	if err := st.SaveMessage(ctx, msg); err != nil {
-		fmt.Printf("save failed: %v\n", err)
+		fail(exitFailure, "save failed: %v", err)
 		return
 	}
 
+	if structured() {
+		emit(toMessageDetail(msg))
+		return
+	}
 	fmt.Printf("Saved message: %s\n", msg.ID)
 }
 
@@ -326,24 +651,31 @@ func runList(args []string) {
 	fs := flag.NewFlagSet("list", flag.ContinueOnError)
 	n := fs.Int("n", 25, "number of messages")
 	showAll := fs.Bool("all", false, "include deleted messages")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
 	msgs, err := st.ListMessages(ctx, *n, *showAll)
 	if err != nil {
-		fmt.Printf("list failed: %v\n", err)
+		fail(exitFailure, "list failed: %v", err)
 		return
 	}
 
+	if structured() {
+		emit(toMessageRecords(msgs))
+		return
+	}
 	if len(msgs) == 0 {
 		fmt.Println("(no messages)")
 		return
@@ -386,21 +718,6 @@ func runList(args []string) {
 	}
 }
 
-func parseModes(csv string) []core.Mode {
-	var out []core.Mode
-	for _, s := range strings.Split(csv, ",") {
-		s = strings.TrimSpace(strings.ToLower(s))
-		if s == "" {
-			continue
-		}
-		out = append(out, core.Mode(s))
-	}
-	if len(out) == 0 {
-		return []core.Mode{core.ModeAny}
-	}
-	return out
-}
-
 func modesToString(m []core.Mode) string {
 	if len(m) == 0 {
 		return ""
@@ -422,21 +739,28 @@ func sessionToString(s core.SessionMode) string {
 func runOutbox(args []string) {
 	fs := flag.NewFlagSet("outbox", flag.ContinueOnError)
 	n := fs.Int("n", 25, "number of messages")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
 	msgs, err := st.ListByStatus(ctx, []core.MessageStatus{core.StatusDraft, core.StatusQueued, core.StatusFailed}, *n)
 	if err != nil {
-		fmt.Printf("outbox failed: %v\n", err)
+		fail(exitFailure, "outbox failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toMessageRecords(msgs))
 		return
 	}
 	if len(msgs) == 0 {
@@ -465,26 +789,33 @@ func runOutbox(args []string) {
 func runQueue(args []string) {
 	fs := flag.NewFlagSet("queue", flag.ContinueOnError)
 	tag := fs.String("tag", "", "tag to queue (required)")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	if strings.TrimSpace(*tag) == "" {
-		fmt.Println("queue requires -tag")
+		fail(exitUsage, "queue requires -tag")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
 	n, err := st.QueueByTag(ctx, *tag)
 	if err != nil {
-		fmt.Printf("queue failed: %v\n", err)
+		fail(exitFailure, "queue failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(queueRecord{Tag: *tag, Queued: n})
 		return
 	}
 	fmt.Printf("Queued %d message(s) with tag: %s\n", n, *tag)
@@ -493,25 +824,35 @@ func runQueue(args []string) {
 func runMarkSent(args []string) {
 	fs := flag.NewFlagSet("mark-sent", flag.ContinueOnError)
 	id := fs.String("id", "", "message id (required)")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	if strings.TrimSpace(*id) == "" {
-		fmt.Println("mark-sent requires -id")
+		fail(exitUsage, "mark-sent requires -id")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
-	if err := st.SetStatusByID(ctx, *id, core.StatusSent, ""); err != nil {
-		fmt.Printf("mark-sent failed: %v\n", err)
+	if err := st.SetStatusByID(ctx, *id, core.StatusSent, ""); errors.Is(err, store.ErrMessageNotFound) {
+		fail(exitFailure, "no message %s", *id)
+		return
+	} else if err != nil {
+		fail(exitFailure, "mark-sent failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(changeRecord{ID: *id, Status: string(core.StatusSent)})
 		return
 	}
 	fmt.Println("Marked sent:", *id)
@@ -521,25 +862,35 @@ func runMarkFailed(args []string) {
 	fs := flag.NewFlagSet("mark-failed", flag.ContinueOnError)
 	id := fs.String("id", "", "message id (required)")
 	errMsg := fs.String("err", "send failed", "error message")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	if strings.TrimSpace(*id) == "" {
-		fmt.Println("mark-failed requires -id")
+		fail(exitUsage, "mark-failed requires -id")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
-	if err := st.SetStatusByID(ctx, *id, core.StatusFailed, *errMsg); err != nil {
-		fmt.Printf("mark-failed failed: %v\n", err)
+	if err := st.SetStatusByID(ctx, *id, core.StatusFailed, *errMsg); errors.Is(err, store.ErrMessageNotFound) {
+		fail(exitFailure, "no message %s", *id)
+		return
+	} else if err != nil {
+		fail(exitFailure, "mark-failed failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(changeRecord{ID: *id, Status: string(core.StatusFailed), Error: *errMsg})
 		return
 	}
 	fmt.Println("Marked failed:", *id)
@@ -549,55 +900,85 @@ func runSend(args []string) {
 	fs := flag.NewFlagSet("send", flag.ContinueOnError)
 	tag := fs.String("tag", "", "only send queued messages with this tag")
 	n := fs.Int("n", 25, "max messages to send")
-	_ = fs.Parse(args)
+	connect := fs.String("connect", envOr("RELAYOPS_CONNECT", "telnet"), "pat connect alias or URL, e.g. ardop:///W1AW-10?freq=7101.5")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 60*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
 	cfg, cfgPath, err := pat.LoadConfig()
 	if err != nil {
+		fail(exitFailure, "pat config: %v", err)
+		return
+	}
+	if strings.TrimSpace(cfg.MyCall) == "" {
+		fail(exitFailure, "pat config %s has no mycall", cfgPath)
 		return
 	}
-	// optionally: fmt.Printf("Using PAT config: %s (mycall=%s)\n", cfgPath, cfg.MyCall)
-	_ = cfgPath
-	res, err := ops.SendQueued(ctx, st, *tag, *n, pat.New(cfg.MyCall))
+	sender := pat.New(cfg.MyCall)
+	if c := strings.TrimSpace(*connect); c != "" {
+		sender.Service = c
+	}
+	res, err := ops.SendQueued(ctx, st, *tag, *n, sender)
 	if err != nil {
-		fmt.Println("send failed:", err)
+		fail(exitFailure, "send failed: %v", err)
 		return
 	}
+	if res.Failed > 0 {
+		partial()
+	}
 
+	if structured() {
+		emit(toSendRecord("pat", res))
+		return
+	}
 	fmt.Printf("Send complete. sent=%d failed=%d\n", res.Sent, res.Failed)
 }
 
 func runDelete(args []string) {
 	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
 	id := fs.String("id", "", "message id (required)")
-	_ = fs.Parse(args)
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
 
 	if strings.TrimSpace(*id) == "" {
-		fmt.Println("delete requires -id")
+		fail(exitUsage, "delete requires -id")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 	defer cancel()
 
 	st, err := store.Open(ctx)
 	if err != nil {
-		fmt.Printf("store open failed: %v\n", err)
+		fail(exitFailure, "store open failed: %v", err)
 		return
 	}
 	defer func() { _ = st.Close() }()
 
-	if _, err := st.DeleteByID(ctx, *id); err != nil {
-		fmt.Printf("delete failed: %v\n", err)
+	n, err := st.DeleteByID(ctx, *id)
+	if err != nil {
+		fail(exitFailure, "delete failed: %v", err)
+		return
+	}
+	if n == 0 {
+		fail(exitFailure, "no message %s", *id)
+		return
+	}
+	if structured() {
+		emit(changeRecord{ID: *id, Status: string(core.StatusDeleted)})
 		return
 	}
 	fmt.Println("Deleted message:", *id)
@@ -631,25 +1012,45 @@ func containsMode(list []core.Mode, x core.Mode) bool {
 
 func runScope(args []string) {
 	if len(args) < 1 {
-		fmt.Println("Usage:")
-		fmt.Println("  relayops scope list")
-		fmt.Println("  relayops scope create -scope AE4OK@general [-note \"...\"]")
+		usage(
+			"Usage:",
+			"  relayops scope list",
+			"  relayops scope create -scope AE4OK@general [-note \"...\"]",
+			"  relayops scope stats [-scope AE4OK@general]",
+			"  relayops scope rename -from AE4OK@generl -to AE4OK@general",
+			"  relayops scope merge -from AE4OK -into AE4OK@general [--dry-run]",
+			"  relayops scope archive -scope AE4OK@2025 [-undo]",
+			"  relayops scope delete -scope AE4OK@test   (only if it holds no messages)",
+		)
 		return
 	}
 	sub := args[0]
 	switch sub {
 	case "list":
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		fs := flag.NewFlagSet("scope list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 		defer cancel()
 		st, err := store.Open(ctx)
 		if err != nil {
-			fmt.Printf("store open failed: %v\n", err)
+			fail(exitFailure, "store open failed: %v", err)
 			return
 		}
 		defer func() { _ = st.Close() }()
 		scopes, err := st.ListScopes(ctx)
 		if err != nil {
-			fmt.Printf("list scopes failed: %v\n", err)
+			fail(exitFailure, "list scopes failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]scopeRecord, 0, len(scopes))
+			for _, sc := range scopes {
+				out = append(out, toScopeRecord(sc))
+			}
+			emit(out)
 			return
 		}
 		if len(scopes) == 0 {
@@ -658,6 +1059,9 @@ func runScope(args []string) {
 		}
 		for _, sc := range scopes {
 			note := sc.Note
+			if !sc.ArchivedAt.IsZero() {
+				note = strings.TrimSpace("(archived) " + note)
+			}
 			if strings.TrimSpace(note) != "" {
 				fmt.Printf("%s\t%s\n", sc.Scope, note)
 			} else {
@@ -668,31 +1072,60 @@ func runScope(args []string) {
 		fs := flag.NewFlagSet("scope create", flag.ContinueOnError)
 		scope := fs.String("scope", "", "Scope to create (e.g., AE4OK@general)")
 		note := fs.String("note", "", "Optional note/description")
-		_ = fs.Parse(args[1:])
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
 		if strings.TrimSpace(*scope) == "" {
-			fmt.Println("scope create requires -scope")
+			fail(exitUsage, "scope create requires -scope")
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
 		defer cancel()
 		st, err := store.Open(ctx)
 		if err != nil {
-			fmt.Printf("store open failed: %v\n", err)
+			fail(exitFailure, "store open failed: %v", err)
 			return
 		}
 		defer func() { _ = st.Close() }()
 		if err := st.CreateScope(ctx, strings.TrimSpace(*scope), strings.TrimSpace(*note)); err != nil {
-			fmt.Printf("create scope failed: %v\n", err)
+			fail(exitFailure, "create scope failed: %v", err)
+			return
+		}
+		if structured() {
+			scopes, err := st.ListScopes(ctx)
+			if err != nil {
+				fail(exitFailure, "list scopes failed: %v", err)
+				return
+			}
+			for _, sc := range scopes {
+				if sc.Scope == strings.TrimSpace(*scope) {
+					emit(toScopeRecord(sc))
+				}
+			}
 			return
 		}
 		fmt.Printf("Scope created (or already existed): %s\n", strings.TrimSpace(*scope))
+	case "stats":
+		runScopeStats(args[1:])
+	case "rename":
+		runScopeRename(args[1:])
+	case "merge":
+		runScopeMerge(args[1:])
+	case "archive":
+		runScopeArchive(args[1:])
+	case "delete":
+		runScopeDelete(args[1:])
 	default:
-		fmt.Printf("Unknown scope subcommand: %s\n", sub)
+		fail(exitUsage, "Unknown scope subcommand: %s", sub)
 	}
 }
 
-func defaultPatMailboxDir() string {
-	// Keep in sync with PAT defaults; user can override via -mbox or pat --mbox.
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, "Library", "Application Support", "pat", "mailbox")
+// envOr returns the environment variable key, which a station profile may have set,
+// or def when it is empty.
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
 }
@@ -1,4 +1,4 @@
-package main_test
+package main
 
 import (
 	"context"
@@ -30,7 +30,51 @@ func TestDoctor(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := ops.Doctor(ctx); err != nil {
-		t.Fatalf("Doctor: %v", err)
+	checks := ops.Doctor(ctx)
+	if len(checks) == 0 {
+		t.Fatal("Doctor returned no checks")
+	}
+	for _, c := range checks {
+		if c.Status == ops.CheckFail {
+			t.Fatalf("Doctor: %s failed: %s", c.Name, c.Detail)
+		}
+	}
+}
+
+func TestMissingMessageExitsNonZero(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	defer func() { exitCode, outFormat = exitOK, "" }()
+
+	for name, run := range map[string]func([]string){
+		"mark-sent":   runMarkSent,
+		"mark-failed": runMarkFailed,
+		"delete":      runDelete,
+	} {
+		exitCode, outFormat = exitOK, ""
+		run([]string{"-id", "nope"})
+		if exitCode != exitFailure {
+			t.Errorf("%s -id nope: exit code %d, want %d", name, exitCode, exitFailure)
+		}
+		exitCode = exitOK
+		run(nil)
+		if exitCode != exitUsage {
+			t.Errorf("%s without -id: exit code %d, want %d", name, exitCode, exitUsage)
+		}
+	}
+}
+
+func TestDoctorWarningsExitPartial(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("PATH", t.TempDir()) // no pat binary
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdout.Close(); os.Stdout = stdout; exitCode, outFormat = exitOK, "" }()
+
+	exitCode = exitOK
+	runDoctor([]string{"-o", "jsonl"})
+	if exitCode != exitPartial {
+		t.Fatalf("doctor without pat: exit code %d, want %d", exitCode, exitPartial)
 	}
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit status of every command. Scripts may rely on these values.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command could not do what was asked
+	exitUsage   = 2 // bad flags, arguments or subcommand
+	exitPartial = 3 // the command ran but some items failed (send, imports)
+)
+
+// exitCode is what main exits with; commands set it through fail and partial.
+var exitCode = exitOK
+
+// outFormat is the -o value of the running command. Empty means the human-readable
+// text output, which may change between releases; the other formats follow the
+// record types in records.go.
+var outFormat string
+
+var outFormats = []string{"json", "jsonl", "csv", "table"}
+
+// outputFlag registers -o on fs.
+func outputFlag(fs *flag.FlagSet) {
+	fs.StringVar(&outFormat, "o", "", "Output format: "+strings.Join(outFormats, ", ")+" (default human-readable text)")
+}
+
+// structured reports whether -o asked for machine-readable output.
+func structured() bool { return outFormat != "" }
+
+// parseFlags parses args into fs and validates -o. It returns false when the command
+// should stop: after -h (exit 0) or on a bad flag (exitUsage; fs has printed why).
+func parseFlags(fs *flag.FlagSet, args []string) bool {
+	if err := fs.Parse(args); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			exitCode = exitUsage
+		}
+		return false
+	}
+	return checkFormat()
+}
+
+func checkFormat() bool {
+	if outFormat == "" {
+		return true
+	}
+	for _, f := range outFormats {
+		if outFormat == f {
+			return true
+		}
+	}
+	format := outFormat
+	outFormat = ""
+	fail(exitUsage, "unknown output format %q (want %s)", format, strings.Join(outFormats, ", "))
+	return false
+}
+
+// errorRecord is written to stderr in place of the text message under -o json/jsonl.
+type errorRecord struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// fail reports an error on stderr and sets the exit code. The first failure wins,
+// except that a hard failure replaces exitPartial.
+func fail(code int, format string, args ...any) {
+	if exitCode == exitOK || exitCode == exitPartial {
+		exitCode = code
+	}
+	msg := fmt.Sprintf(format, args...)
+	if outFormat == "json" || outFormat == "jsonl" {
+		_ = json.NewEncoder(os.Stderr).Encode(errorRecord{Error: msg, ExitCode: code})
+		return
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// usage prints a command's usage to stderr and sets exitUsage.
+func usage(lines ...string) {
+	exitCode = exitUsage
+	for _, l := range lines {
+		fmt.Fprintln(os.Stderr, l)
+	}
+}
+
+// partial marks a run that completed with some failed items.
+func partial() {
+	if exitCode == exitOK {
+		exitCode = exitPartial
+	}
+}
+
+// emit writes records, a slice of record structs or a single one, to stdout in outFormat.
+func emit(records any) {
+	if err := writeRecords(os.Stdout, outFormat, records); err != nil {
+		fail(exitFailure, "write output: %v", err)
+	}
+}
+
+// writeRecords renders records as:
+//
+//	json   one indented object, or an array for a slice
+//	jsonl  one compact object per line
+//	csv    a header of the json field names, then one row per record
+//	table  the same columns aligned for reading
+//
+// In csv and table cells, times are RFC3339, string lists are comma-joined and
+// nested values are compact JSON.
+func writeRecords(w io.Writer, format string, records any) error {
+	v := reflect.ValueOf(records)
+	if format == "json" {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			records = []struct{}{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	var rows []reflect.Value
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, v.Index(i))
+		}
+	} else {
+		rows = append(rows, v)
+	}
+
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, r := range rows {
+			if err := enc.Encode(r.Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv", "table":
+		t := v.Type()
+		if t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		cols := columns(t, nil)
+		if format == "csv" {
+			cw := csv.NewWriter(w)
+			header := make([]string, len(cols))
+			for i, c := range cols {
+				header[i] = c.name
+			}
+			_ = cw.Write(header)
+			for _, r := range rows {
+				_ = cw.Write(cells(r, cols))
+			}
+			cw.Flush()
+			return cw.Error()
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = strings.ToUpper(c.name)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, r := range rows {
+			row := cells(r, cols)
+			for i, s := range row {
+				row[i] = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(s)
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+type column struct {
+	name  string
+	index []int
+}
+
+// columns lists the json-tagged fields of struct type t, flattening embedded structs
+// the way encoding/json does.
+func columns(t reflect.Type, prefix []int) []column {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int(nil), prefix...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			cols = append(cols, columns(f.Type, index)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		cols = append(cols, column{name: name, index: index})
+	}
+	return cols
+}
+
+func cells(r reflect.Value, cols []column) []string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = cell(r.FieldByIndex(c.index))
+	}
+	return out
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func cell(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	case v.Type() == rawType:
+		return string(v.Bytes())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			parts := make([]string, v.Len())
+			for i := range parts {
+				parts[i] = v.Index(i).String()
+			}
+			return strings.Join(parts, ",")
+		}
+	}
+	b, _ := json.Marshal(v.Interface())
+	return string(b)
+}
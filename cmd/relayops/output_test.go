@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRecords(t *testing.T) {
+	created := time.Date(2026, 3, 4, 19, 30, 0, 0, time.UTC)
+	recs := []messageRecord{
+		{ID: "m1", Status: "queued", CreatedAt: created, Subject: "Net check-in", Tags: []string{"net", "ww"}, Session: "winlink", Allow: []string{}, Prefer: []string{"telnet"}},
+		{ID: "m2", Status: "draft", CreatedAt: created, Subject: "Line one\nline\ttwo, \"quoted\"", Tags: []string{}, Session: "winlink", Allow: []string{}, Prefer: []string{}},
+	}
+
+	var b bytes.Buffer
+	if err := writeRecords(&b, "json", recs); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json output: %v\n%s", err, b.String())
+	}
+	if len(got) != 2 || got[0]["created_at"] != "2026-03-04T19:30:00Z" || got[1]["subject"] != recs[1].Subject {
+		t.Fatalf("json = %v", got)
+	}
+
+	b.Reset()
+	if err := writeRecords(&b, "jsonl", recs); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"id":"m1",`) {
+		t.Fatalf("jsonl = %q", b.String())
+	}
+
+	b.Reset()
+	if err := writeRecords(&b, "csv", recs); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,status,created_at,subject,tags,session,allow,prefer,pat_mid\n" +
+		"m1,queued,2026-03-04T19:30:00Z,Net check-in,\"net,ww\",winlink,,telnet,\n" +
+		"m2,draft,2026-03-04T19:30:00Z,\"Line one\nline\ttwo, \"\"quoted\"\"\",,winlink,,,\n"
+	if b.String() != want {
+		t.Fatalf("csv =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := writeRecords(&b, "table", recs); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID  STATUS") || !strings.Contains(lines[2], `Line one line two, "quoted"`) {
+		t.Fatalf("table =\n%s", b.String())
+	}
+
+	// Empty results are still valid documents.
+	for format, want := range map[string]string{"json": "[]\n", "jsonl": "", "csv": "id,status,created_at,subject,tags,session,allow,prefer,pat_mid\n"} {
+		b.Reset()
+		if err := writeRecords(&b, format, []messageRecord(nil)); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Errorf("empty %s = %q, want %q", format, b.String(), want)
+		}
+	}
+
+	// A single record is an object in json and one row in csv.
+	b.Reset()
+	if err := writeRecords(&b, "json", sendRecord{Transport: "pat", Sent: 2, Failed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "{") {
+		t.Fatalf("single json = %q", b.String())
+	}
+	b.Reset()
+	if err := writeRecords(&b, "csv", sendRecord{Transport: "pat", Sent: 2, Failed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "transport,sent,failed\npat,2,1\n" {
+		t.Fatalf("single csv = %q", b.String())
+	}
+}
+
+func TestWriteRecordsFlattensEmbeddedAndRawJSON(t *testing.T) {
+	var b bytes.Buffer
+	evs := []eventRecord{{Seq: 7, Kind: "message.status", Payload: json.RawMessage(`{"error":"link down"}`)}}
+	if err := writeRecords(&b, "csv", evs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(b.String(), `,"{""error"":""link down""}"`+"\n") {
+		t.Fatalf("payload cell: %q", b.String())
+	}
+	b.Reset()
+	if err := writeRecords(&b, "jsonl", evs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"payload":{"error":"link down"}`) {
+		t.Fatalf("payload json: %q", b.String())
+	}
+
+	cols := columns(reflect.TypeOf(sessionDetail{}), nil)
+	if cols[0].name != "id" || cols[len(cols)-1].name != "transcript" {
+		t.Fatalf("embedded columns = %v", cols)
+	}
+}
+
+// TestRecordSchema pins the output schema. Adding a field at the end of a record is
+// fine (extend the list); renaming, removing or reordering breaks users' scripts.
+func TestRecordSchema(t *testing.T) {
+	for _, c := range []struct {
+		record any
+		want   string
+	}{
+		{messageRecord{}, "id,status,created_at,subject,tags,session,allow,prefer,pat_mid"},
+		{messageDetail{}, "id,status,created_at,subject,tags,session,allow,prefer,pat_mid,from,to,updated_at,sent_at,last_error,body,form,attachments"},
+		{changeRecord{}, "id,status,error"},
+		{queueRecord{}, "tag,queued"},
+		{sendRecord{}, "transport,sent,failed"},
+		{scopeRecord{}, "scope,note,created_at,archived_at,last_import_at"},
+		{scopeStatsRecord{}, "scope,messages,by_status,by_backend,last_import_at,archived"},
+		{scopeMergeRecord{}, "from,into,dry_run,moved,duplicates,other,removed,conflicts"},
+		{identityRecord{}, "address,callsign,scope,note,created_at,updated_at"},
+		{importRecord{}, "source,scope,scanned,created,updated,errors,skipped,unmapped_columns"},
+		{patSyncRecord{}, "scope,scanned,created,updated,skipped,sent,flagged,pushed,errors"},
+		{winlinkExportRecord{}, "root,scope,scanned,written,skipped,errors"},
+		{bundleImportRecord{}, "scanned,created,existing,merged,errors,failures"},
+		{exportRecord{}, "scopes,messages,external_refs,backend_states,attempts"},
+		{retentionRecord{}, "scope,status,action,after_days,updated_at"},
+		{purgeRecord{}, "dry_run,purged,archived,external_refs,backend_states,attempts"},
+		{sessionDetail{}, "id,transport,gateway,frequency,started_at,finished_at,outcome,error,bytes_out,bytes_in,messages,transcript"},
+		{eventRecord{}, "seq,time,actor,kind,message_id,old_status,new_status,payload"},
+		{auditVerifyRecord{}, "ok,checked,error"},
+		{participationRecord{}, "date,scope,event,outcome,message_id,run_id,detail"},
+		{playbookRunRecord{}, "run_id,playbook,dry_run,ok,started_at,finished_at,steps"},
+		{renderedRecord{}, "name,source,required,optional,subject,to,tags,body"},
+		{formDetail{}, "name,title,source,viewer,attachment,fields"},
+		{cryptoStatusRecord{}, "enabled,unlocked,key_id,kdf,sealed_fields,plain_fields"},
+		{daemonStatusRecord{}, "running,pid,host,acquired_at,heartbeat_at"},
+		{daemonJobRecord{}, "name,trigger,action,next_run"},
+		{checkRecord{}, "check,status,detail"},
+		{versionRecord{}, "version,commit,build_date"},
+		{errorRecord{}, "error,exit_code"},
+	} {
+		var names []string
+		for _, col := range columns(reflect.TypeOf(c.record), nil) {
+			names = append(names, col.name)
+		}
+		if got := strings.Join(names, ","); got != c.want {
+			t.Errorf("%T columns:\n got %s\nwant %s", c.record, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runParticipation(args []string) {
+	fs := flag.NewFlagSet("participation", flag.ContinueOnError)
+	scope := fs.String("scope", "", "Scope (defaults to RELAYOPS_CALLSIGN/RELAYOPS_STATION; 'all' for every scope)")
+	event := fs.String("event", "winlink-wednesday", "Event name ('' for all)")
+	since := fs.String("since", "", "Only occurrences on/after this date (YYYY-MM-DD)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	sc := strings.TrimSpace(*scope)
+	switch sc {
+	case "":
+		sc = runtime.IdentityScope("")
+	case "all":
+		sc = ""
+	}
+	if s := strings.TrimSpace(*since); s != "" {
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			fail(exitUsage, "invalid -since: %v", err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	recs, err := st.ListParticipation(ctx, sc, *event, strings.TrimSpace(*since))
+	if err != nil {
+		fail(exitFailure, "participation failed: %v", err)
+		return
+	}
+	if structured() {
+		out := make([]participationRecord, 0, len(recs))
+		for _, p := range recs {
+			out = append(out, participationRecord{
+				Date: p.Date, Scope: p.Scope, Event: p.Event, Outcome: p.Outcome,
+				MessageID: p.MessageID, RunID: p.RunID, Detail: p.Detail,
+			})
+		}
+		emit(out)
+		return
+	}
+	if len(recs) == 0 {
+		fmt.Println("(no participation records)")
+		return
+	}
+
+	type tally struct{ ok, total int }
+	totals := map[string]*tally{}
+	var order []string
+	for _, p := range recs {
+		key := p.Scope + " " + p.Event
+		if totals[key] == nil {
+			totals[key] = &tally{}
+			order = append(order, key)
+		}
+		totals[key].total++
+		if p.Outcome == store.ParticipationCheckedIn {
+			totals[key].ok++
+		}
+		line := fmt.Sprintf("%s %-18s %-20s %-10s", p.Date, p.Scope, p.Event, p.Outcome)
+		if p.MessageID != "" {
+			line += " " + p.MessageID
+		}
+		if p.Detail != "" {
+			line += "  (" + p.Detail + ")"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println("")
+	for _, key := range order {
+		t := totals[key]
+		fmt.Printf("%s: checked in %d of %d\n", key, t.ok, t.total)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runPlaybook(args []string) {
+	if len(args) < 1 || (args[0] != "run" && args[0] != "list") {
+		usage(
+			"Usage:",
+			"  relayops playbook list",
+			"  relayops playbook run <file.yaml|file.json|built-in name> [--dry-run] [-var key=value ...] [-timeout 30m]",
+		)
+		return
+	}
+	if args[0] == "list" {
+		fs := flag.NewFlagSet("playbook list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		var out []playbookRecord
+		for _, name := range playbook.BuiltinNames() {
+			pb, err := playbook.Builtin(name)
+			if err != nil {
+				fail(exitFailure, "%s: %v", name, err)
+				continue
+			}
+			if structured() {
+				out = append(out, playbookRecord{Name: name, Description: strings.TrimSpace(pb.Description)})
+				continue
+			}
+			fmt.Printf("%s\n    %s\n", name, strings.TrimSpace(pb.Description))
+		}
+		if structured() {
+			emit(out)
+		}
+		return
+	}
+
+	fs := flag.NewFlagSet("playbook run", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Validate and print the plan without changing anything")
+	timeout := fs.Duration("timeout", 30*time.Minute, "Abort the run after this long")
+	vars := varFlags{}
+	fs.Var(vars, "var", "Set a playbook variable, key=value (repeatable)")
+	outputFlag(fs)
+	file, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return
+	}
+	if file == "" {
+		fail(exitUsage, "playbook run requires a playbook file or built-in name")
+		return
+	}
+
+	pb, err := playbook.Open(file)
+	if err != nil {
+		fail(exitFailure, "load playbook failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), *timeout)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	// Under -o the step-by-step progress goes to stderr and the result to stdout.
+	progress := os.Stdout
+	if structured() {
+		progress = os.Stderr
+	}
+	mode := ""
+	if *dryRun {
+		mode = " (dry run)"
+	}
+	fmt.Fprintf(progress, "Playbook %s%s: %d step(s)\n", pb.Name, mode, len(pb.Steps))
+
+	r := &playbook.Runner{Store: st, DryRun: *dryRun, Out: progress, Vars: vars}
+	res, err := r.Run(ctx, pb)
+	if err != nil {
+		fail(exitFailure, "playbook run failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toPlaybookRunRecord(res))
+	}
+	if !res.OK() {
+		fail(exitFailure, "Playbook %s FAILED (run %s)", pb.Name, res.RunID)
+		return
+	}
+	fmt.Fprintf(progress, "Playbook %s complete (run %s)\n", pb.Name, res.RunID)
+}
+
+// varFlags collects repeated -var key=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string { return "" }
+
+func (v varFlags) Set(s string) error {
+	k, val, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("want key=value, got %q", s)
+	}
+	v[strings.TrimSpace(k)] = val
+	return nil
+}
+
+// parseInterspersed parses flags that may appear before or after a single positional
+// argument (e.g. "run file.yaml --dry-run") and returns that argument. Like parseFlags,
+// it sets exitUsage on a bad flag and validates -o.
+func parseInterspersed(fs *flag.FlagSet, args []string) (string, error) {
+	var pos string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				exitCode = exitUsage
+			}
+			return "", err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			if !checkFormat() {
+				return "", errors.New("invalid output format")
+			}
+			return pos, nil
+		}
+		if pos == "" {
+			pos = rest[0]
+		}
+		args = rest[1:]
+	}
+}
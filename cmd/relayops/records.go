@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/store"
+)
+
+// The record types below are the output schema of -o json|jsonl|csv|table. Field
+// names are the json tags, which are also the csv/table column names. The schema is
+// stable: fields may be added at the end of a record but are never renamed, removed
+// or reordered, and every field is always present (empty string, 0, false, [] or
+// null). Times are UTC RFC3339. TestRecordSchema pins the column lists.
+
+// messageRecord is one row of list, outbox and search.
+type messageRecord struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	Subject   string    `json:"subject"`
+	Tags      []string  `json:"tags"`
+	Session   string    `json:"session"`
+	Allow     []string  `json:"allow"`
+	Prefer    []string  `json:"prefer"`
+	PatMID    string    `json:"pat_mid"`
+}
+
+// messageDetail is a whole message, written by show and compose.
+type messageDetail struct {
+	messageRecord
+	From        string             `json:"from"`
+	To          []string           `json:"to"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	SentAt      *time.Time         `json:"sent_at"`
+	LastError   string             `json:"last_error"`
+	Body        string             `json:"body"`
+	Form        string             `json:"form"`
+	Attachments []attachmentRecord `json:"attachments"`
+}
+
+type attachmentRecord struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+// changeRecord reports a status change made by mark-sent, mark-failed or delete.
+type changeRecord struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type queueRecord struct {
+	Tag    string `json:"tag"`
+	Queued int64  `json:"queued"`
+}
+
+type sendRecord struct {
+	Transport string `json:"transport"`
+	Sent      int    `json:"sent"`
+	Failed    int    `json:"failed"`
+}
+
+type scopeRecord struct {
+	Scope        string     `json:"scope"`
+	Note         string     `json:"note"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ArchivedAt   *time.Time `json:"archived_at"`
+	LastImportAt *time.Time `json:"last_import_at"`
+}
+
+// scopeStatsRecord is one scope from scope stats.
+type scopeStatsRecord struct {
+	Scope        string         `json:"scope"`
+	Messages     int            `json:"messages"`
+	ByStatus     map[string]int `json:"by_status"`
+	ByBackend    map[string]int `json:"by_backend"`
+	LastImportAt *time.Time     `json:"last_import_at"`
+	Archived     bool           `json:"archived"`
+}
+
+// scopeMergeRecord is the result of scope merge.
+type scopeMergeRecord struct {
+	From       string                `json:"from"`
+	Into       string                `json:"into"`
+	DryRun     bool                  `json:"dry_run"`
+	Moved      int                   `json:"moved"`
+	Duplicates int                   `json:"duplicates"`
+	Other      int                   `json:"other"`
+	Removed    bool                  `json:"removed"`
+	Conflicts  []scopeConflictRecord `json:"conflicts"`
+}
+
+type scopeConflictRecord struct {
+	Kind        string `json:"kind"`
+	Key         string `json:"key"`
+	FromMessage string `json:"from_message"`
+	IntoMessage string `json:"into_message"`
+}
+
+// identityRecord is a tactical address from identity list/add.
+type identityRecord struct {
+	Address   string    `json:"address"`
+	Callsign  string    `json:"callsign"`
+	Scope     string    `json:"scope"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// importRecord is the result of pat-import and winlink-import.
+type importRecord struct {
+	Source  string `json:"source"`
+	Scope   string `json:"scope"`
+	Scanned int    `json:"scanned"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Errors  int    `json:"errors"`
+	Skipped int    `json:"skipped"`
+
+	UnmappedColumns []int `json:"unmapped_columns,omitempty"`
+}
+
+// patSyncRecord is the result of pat-sync.
+type patSyncRecord struct {
+	Scope   string `json:"scope"`
+	Scanned int    `json:"scanned"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Skipped int    `json:"skipped"`
+	Sent    int    `json:"sent"`
+	Flagged int    `json:"flagged"`
+	Pushed  int    `json:"pushed"`
+	Errors  int    `json:"errors"`
+}
+
+type winlinkExportRecord struct {
+	Root    string `json:"root"`
+	Scope   string `json:"scope"`
+	Scanned int    `json:"scanned"`
+	Written int    `json:"written"`
+	Skipped int    `json:"skipped"`
+	Errors  int    `json:"errors"`
+}
+
+type bundleImportRecord struct {
+	Scanned  int `json:"scanned"`
+	Created  int `json:"created"`
+	Existing int `json:"existing"`
+	Merged   int `json:"merged"`
+	Errors   int `json:"errors"`
+
+	Failures []bundleFailureRecord `json:"failures,omitempty"`
+}
+
+// bundleFailureRecord is a bundle line import-bundle rejected.
+type bundleFailureRecord struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type exportRecord struct {
+	Scopes        int `json:"scopes"`
+	Messages      int `json:"messages"`
+	ExternalRefs  int `json:"external_refs"`
+	BackendStates int `json:"backend_states"`
+	Attempts      int `json:"attempts"`
+}
+
+type retentionRecord struct {
+	Scope     string    `json:"scope"`
+	Status    string    `json:"status"`
+	Action    string    `json:"action"`
+	AfterDays int       `json:"after_days"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type purgeRecord struct {
+	DryRun        bool     `json:"dry_run"`
+	Purged        []string `json:"purged"`
+	Archived      []string `json:"archived"`
+	ExternalRefs  int      `json:"external_refs"`
+	BackendStates int      `json:"backend_states"`
+	Attempts      int      `json:"attempts"`
+}
+
+type sessionRecord struct {
+	ID         string    `json:"id"`
+	Transport  string    `json:"transport"`
+	Gateway    string    `json:"gateway"`
+	Frequency  string    `json:"frequency"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error"`
+	BytesOut   int64     `json:"bytes_out"`
+	BytesIn    int64     `json:"bytes_in"`
+}
+
+// sessionDetail is written by sessions show; the transcript only with -transcript.
+type sessionDetail struct {
+	sessionRecord
+	Messages   []sessionMessageRecord `json:"messages"`
+	Transcript string                 `json:"transcript"`
+}
+
+type sessionMessageRecord struct {
+	Direction string `json:"direction"`
+	MID       string `json:"mid"`
+	MessageID string `json:"message_id"`
+}
+
+// eventRecord is one audit trail entry (audit, daemon log). Payload is the event's
+// JSON object.
+type eventRecord struct {
+	Seq       int64           `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Actor     string          `json:"actor"`
+	Kind      string          `json:"kind"`
+	MessageID string          `json:"message_id"`
+	OldStatus string          `json:"old_status"`
+	NewStatus string          `json:"new_status"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type auditVerifyRecord struct {
+	OK      bool   `json:"ok"`
+	Checked int    `json:"checked"`
+	Error   string `json:"error"`
+}
+
+type participationRecord struct {
+	Date      string `json:"date"`
+	Scope     string `json:"scope"`
+	Event     string `json:"event"`
+	Outcome   string `json:"outcome"`
+	MessageID string `json:"message_id"`
+	RunID     string `json:"run_id"`
+	Detail    string `json:"detail"`
+}
+
+type playbookRecord struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type playbookRunRecord struct {
+	RunID      string               `json:"run_id"`
+	Playbook   string               `json:"playbook"`
+	DryRun     bool                 `json:"dry_run"`
+	OK         bool                 `json:"ok"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	Steps      []playbookStepRecord `json:"steps"`
+}
+
+type playbookStepRecord struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	Detail     string `json:"detail"`
+	Error      string `json:"error"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type templateRecord struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// renderedRecord is a template show (unrendered, with its variables) or check result.
+type renderedRecord struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"source"`
+	Required []string `json:"required"`
+	Optional []string `json:"optional"`
+	Subject  string   `json:"subject"`
+	To       []string `json:"to"`
+	Tags     []string `json:"tags"`
+	Body     string   `json:"body"`
+}
+
+type formRecord struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Source string `json:"source"`
+}
+
+type formDetail struct {
+	formRecord
+	Viewer     string            `json:"viewer"`
+	Attachment string            `json:"attachment"`
+	Fields     []formFieldRecord `json:"fields"`
+}
+
+type formFieldRecord struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Required bool   `json:"required"`
+	Default  string `json:"default"`
+}
+
+type nameRecord struct {
+	Name string `json:"name"`
+}
+
+type cryptoStatusRecord struct {
+	Enabled      bool   `json:"enabled"`
+	Unlocked     bool   `json:"unlocked"`
+	KeyID        string `json:"key_id"`
+	KDF          string `json:"kdf"`
+	SealedFields int    `json:"sealed_fields"`
+	PlainFields  int    `json:"plain_fields"`
+}
+
+type keyRecord struct {
+	KeyID string `json:"key_id"`
+}
+
+type daemonStatusRecord struct {
+	Running     bool       `json:"running"`
+	PID         int        `json:"pid"`
+	Host        string     `json:"host"`
+	AcquiredAt  *time.Time `json:"acquired_at"`
+	HeartbeatAt *time.Time `json:"heartbeat_at"`
+}
+
+type daemonJobRecord struct {
+	Name    string     `json:"name"`
+	Trigger string     `json:"trigger"`
+	Action  string     `json:"action"`
+	NextRun *time.Time `json:"next_run"`
+}
+
+// checkRecord is one diagnostic from doctor or init; status is ok, warn or fail.
+type checkRecord struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type versionRecord struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+func toMessageRecord(m store.MessageSummary) messageRecord {
+	return messageRecord{
+		ID:        m.ID,
+		Status:    string(m.Status),
+		CreatedAt: m.CreatedAt.UTC().Truncate(time.Second), // as the store keeps it
+		Subject:   m.Subject,
+		Tags:      nonNil(m.Tags),
+		Session:   sessionToString(m.Meta.Session),
+		Allow:     modeStrings(m.Meta.Transport.Allowed),
+		Prefer:    modeStrings(m.Meta.Transport.Preferred),
+		PatMID:    m.Meta.Delivery.PatMID,
+	}
+}
+
+func toMessageRecords(msgs []store.MessageSummary) []messageRecord {
+	out := make([]messageRecord, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, toMessageRecord(m))
+	}
+	return out
+}
+
+func toMessageDetail(m *core.Message) messageDetail {
+	d := messageDetail{
+		messageRecord: toMessageRecord(store.MessageSummary{
+			ID: m.ID, Subject: m.Subject, CreatedAt: m.CreatedAt, Tags: m.Tags, Meta: m.Meta, Status: m.Status,
+		}),
+		From:        addressString(m.From),
+		To:          []string{},
+		UpdatedAt:   m.UpdatedAt.UTC().Truncate(time.Second),
+		SentAt:      utcPtr(m.SentAt),
+		LastError:   m.LastError,
+		Body:        m.Body,
+		Attachments: []attachmentRecord{},
+	}
+	if d.UpdatedAt.IsZero() {
+		d.UpdatedAt = d.CreatedAt
+	}
+	for _, a := range m.To {
+		d.To = append(d.To, addressString(a))
+	}
+	if m.Meta.Form != nil {
+		d.Form = m.Meta.Form.Name
+	}
+	for _, a := range m.Attachments {
+		d.Attachments = append(d.Attachments, attachmentRecord{Name: a.Name, ContentType: a.ContentType, Size: len(a.Data)})
+	}
+	return d
+}
+
+func toScopeRecord(sc store.Scope) scopeRecord {
+	return scopeRecord{Scope: sc.Scope, Note: sc.Note, CreatedAt: sc.CreatedAt.UTC(), ArchivedAt: utcPtr(&sc.ArchivedAt), LastImportAt: utcPtr(&sc.LastImportAt)}
+}
+
+func toScopeStatsRecord(s *store.ScopeStats) scopeStatsRecord {
+	return scopeStatsRecord{
+		Scope: s.Scope, Messages: s.Messages, ByStatus: s.ByStatus, ByBackend: s.ByBackend,
+		LastImportAt: utcPtr(&s.LastImportAt), Archived: !s.ArchivedAt.IsZero(),
+	}
+}
+
+func toScopeMergeRecord(r *store.MergeReport) scopeMergeRecord {
+	out := scopeMergeRecord{
+		From: r.From, Into: r.Into, DryRun: r.DryRun, Moved: r.Moved, Duplicates: r.Duplicates,
+		Other: r.Other, Removed: r.Removed, Conflicts: []scopeConflictRecord{},
+	}
+	for _, c := range r.Conflicts {
+		out.Conflicts = append(out.Conflicts, scopeConflictRecord{Kind: c.Kind, Key: c.Key, FromMessage: c.FromMessage, IntoMessage: c.IntoMessage})
+	}
+	return out
+}
+
+func toIdentityRecord(id store.Identity) identityRecord {
+	return identityRecord{Address: id.Address, Callsign: id.Callsign, Scope: id.Scope, Note: id.Note, CreatedAt: id.CreatedAt.UTC(), UpdatedAt: id.UpdatedAt.UTC()}
+}
+
+func toRetentionRecord(p store.RetentionPolicy) retentionRecord {
+	return retentionRecord{Scope: p.Scope, Status: string(p.Status), Action: p.Action, AfterDays: p.AfterDays, UpdatedAt: p.UpdatedAt.UTC()}
+}
+
+func toSessionRecord(s store.Session) sessionRecord {
+	return sessionRecord{
+		ID: s.ID, Transport: s.Transport, Gateway: s.Gateway, Frequency: s.Frequency,
+		StartedAt: s.StartedAt.UTC(), FinishedAt: s.FinishedAt.UTC(),
+		Outcome: s.Outcome, Error: s.Error, BytesOut: s.BytesOut, BytesIn: s.BytesIn,
+	}
+}
+
+func toEventRecords(evs []store.Event) []eventRecord {
+	out := make([]eventRecord, 0, len(evs))
+	for _, ev := range evs {
+		payload := json.RawMessage(ev.Payload)
+		if !json.Valid(payload) {
+			payload = json.RawMessage("{}")
+		}
+		out = append(out, eventRecord{
+			Seq: ev.Seq, Time: ev.Time.UTC(), Actor: ev.Actor, Kind: ev.Kind, MessageID: ev.MessageID,
+			OldStatus: ev.OldStatus, NewStatus: ev.NewStatus, Payload: payload,
+		})
+	}
+	return out
+}
+
+func toPlaybookRunRecord(res *playbook.Result) playbookRunRecord {
+	r := playbookRunRecord{
+		RunID: res.RunID, Playbook: res.Playbook, DryRun: res.DryRun, OK: res.OK(),
+		StartedAt: res.Started.UTC(), FinishedAt: res.Finished.UTC(), Steps: []playbookStepRecord{},
+	}
+	for _, s := range res.Steps {
+		r.Steps = append(r.Steps, playbookStepRecord{
+			Index: s.Index, Name: s.Name, Action: s.Action, Outcome: s.Outcome,
+			Detail: s.Detail, Error: s.Error, DurationMS: s.Duration.Milliseconds(),
+		})
+	}
+	return r
+}
+
+func toSendRecord(transport string, res ops.SendResult) sendRecord {
+	return sendRecord{Transport: transport, Sent: res.Sent, Failed: res.Failed}
+}
+
+func modeStrings(m []core.Mode) []string {
+	out := make([]string, 0, len(m))
+	for _, x := range m {
+		out = append(out, string(x))
+	}
+	return out
+}
+
+// nonNil keeps empty lists as [] rather than null in JSON.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	out := []string{}
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runRetention(args []string) {
+	if len(args) < 1 {
+		usage(
+			"Usage:",
+			"  relayops retention list",
+			"  relayops retention set -status deleted -action purge -days 30 [-scope AE4OK@general]",
+			"  relayops retention remove -status deleted [-scope AE4OK@general]",
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	sub := args[0]
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("retention list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		policies, err := st.ListRetentionPolicies(ctx)
+		if err != nil {
+			fail(exitFailure, "list retention failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]retentionRecord, 0, len(policies))
+			for _, p := range policies {
+				out = append(out, toRetentionRecord(p))
+			}
+			emit(out)
+			return
+		}
+		if len(policies) == 0 {
+			fmt.Println("(no retention policies)")
+			return
+		}
+		for _, p := range policies {
+			scope := p.Scope
+			if scope == "" {
+				scope = "*"
+			}
+			fmt.Printf("%s\tstatus=%s\t%s after %d day(s)\n", scope, p.Status, p.Action, p.AfterDays)
+		}
+
+	case "set":
+		fs := flag.NewFlagSet("retention set", flag.ContinueOnError)
+		scope := fs.String("scope", "", "Scope the policy applies to (empty = all scopes; a scope's own policy overrides it)")
+		status := fs.String("status", "", "Message status the policy applies to (e.g. deleted, sent)")
+		action := fs.String("action", store.RetentionPurge, "purge or archive")
+		days := fs.Int("days", 30, "Age in days (since last status change) before the action applies")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		if strings.TrimSpace(*status) == "" {
+			fail(exitUsage, "retention set requires -status")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		p := store.RetentionPolicy{
+			Scope:     strings.TrimSpace(*scope),
+			Status:    core.MessageStatus(strings.ToLower(strings.TrimSpace(*status))),
+			Action:    strings.ToLower(strings.TrimSpace(*action)),
+			AfterDays: *days,
+		}
+		if err := st.SetRetentionPolicy(ctx, p); err != nil {
+			fail(exitFailure, "retention set failed: %v", err)
+			return
+		}
+		if structured() {
+			p.UpdatedAt = time.Now()
+			emit(toRetentionRecord(p))
+			return
+		}
+		fmt.Printf("Retention policy saved: status=%s %s after %d day(s)\n", p.Status, p.Action, p.AfterDays)
+
+	case "remove":
+		fs := flag.NewFlagSet("retention remove", flag.ContinueOnError)
+		scope := fs.String("scope", "", "Scope of the policy (empty = all scopes)")
+		status := fs.String("status", "", "Message status of the policy")
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+
+		if strings.TrimSpace(*status) == "" {
+			fail(exitUsage, "retention remove requires -status")
+			return
+		}
+
+		st, err := store.Open(ctx)
+		if err != nil {
+			fail(exitFailure, "store open failed: %v", err)
+			return
+		}
+		defer func() { _ = st.Close() }()
+
+		ok, err := st.RemoveRetentionPolicy(ctx, *scope, core.MessageStatus(strings.ToLower(strings.TrimSpace(*status))))
+		if err != nil {
+			fail(exitFailure, "retention remove failed: %v", err)
+			return
+		}
+		if !ok {
+			fail(exitFailure, "no such retention policy: status=%s", strings.ToLower(strings.TrimSpace(*status)))
+			return
+		}
+		if structured() {
+			emit(retentionRecord{Scope: strings.TrimSpace(*scope), Status: strings.ToLower(strings.TrimSpace(*status))})
+			return
+		}
+		fmt.Println("Retention policy removed.")
+
+	default:
+		fail(exitUsage, "Unknown retention subcommand: %s", sub)
+	}
+}
+
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would be removed without changing anything")
+	verbose := fs.Bool("v", false, "List affected message IDs")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 120*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	report, err := st.ApplyRetention(ctx, time.Now(), *dryRun)
+	if err != nil {
+		fail(exitFailure, "purge failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(purgeRecord{
+			DryRun: report.DryRun, Purged: nonNil(report.Purged), Archived: nonNil(report.Archived),
+			ExternalRefs: report.ExternalRefs, BackendStates: report.BackendStates, Attempts: report.Attempts,
+		})
+		return
+	}
+
+	verb := "Purge complete."
+	if report.DryRun {
+		verb = "Purge dry run (nothing changed)."
+	}
+	fmt.Printf("%s Purged=%d Archived=%d ExternalRefs=%d BackendStates=%d Attempts=%d\n",
+		verb, len(report.Purged), len(report.Archived), report.ExternalRefs, report.BackendStates, report.Attempts)
+
+	if *verbose {
+		for _, id := range report.Purged {
+			fmt.Printf("  purge   %s\n", id)
+		}
+		for _, id := range report.Archived {
+			fmt.Printf("  archive %s\n", id)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func openScopeStore() (context.Context, context.CancelFunc, *store.Store, bool) {
+	ctx, cancel := context.WithTimeout(cliContext(), 30*time.Second)
+	st, err := store.Open(ctx)
+	if err != nil {
+		cancel()
+		fail(exitFailure, "store open failed: %v", err)
+		return nil, nil, nil, false
+	}
+	return ctx, cancel, st, true
+}
+
+func runScopeStats(args []string) {
+	fs := flag.NewFlagSet("scope stats", flag.ContinueOnError)
+	scope := fs.String("scope", "", "Scope to summarize (default: all scopes)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	ctx, cancel, st, ok := openScopeStore()
+	if !ok {
+		return
+	}
+	defer cancel()
+	defer func() { _ = st.Close() }()
+
+	names := []string{strings.TrimSpace(*scope)}
+	if names[0] == "" {
+		scopes, err := st.ListScopes(ctx)
+		if err != nil {
+			fail(exitFailure, "scope list failed: %v", err)
+			return
+		}
+		names = names[:0]
+		for _, sc := range scopes {
+			names = append(names, sc.Scope)
+		}
+	}
+
+	out := make([]scopeStatsRecord, 0, len(names))
+	for _, name := range names {
+		s, err := st.ScopeStats(ctx, name)
+		if err != nil {
+			fail(exitFailure, "scope stats failed: %v", err)
+			return
+		}
+		out = append(out, toScopeStatsRecord(s))
+	}
+	if structured() {
+		emit(out)
+		return
+	}
+	if len(out) == 0 {
+		fmt.Println("(no scopes)")
+		return
+	}
+	for _, r := range out {
+		last := "never"
+		if r.LastImportAt != nil {
+			last = r.LastImportAt.Format(time.RFC3339)
+		}
+		archived := ""
+		if r.Archived {
+			archived = "  (archived)"
+		}
+		fmt.Printf("%s\t%d message(s)\tlast import %s%s\n", r.Scope, r.Messages, last, archived)
+		if len(r.ByStatus) > 0 {
+			fmt.Printf("  status:  %s\n", formatCounts(r.ByStatus))
+		}
+		if len(r.ByBackend) > 0 {
+			fmt.Printf("  backend: %s\n", formatCounts(r.ByBackend))
+		}
+	}
+}
+
+// formatCounts renders {"sent": 3, "queued": 1} as "queued=1 sent=3".
+func formatCounts(m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func runScopeRename(args []string) {
+	fs := flag.NewFlagSet("scope rename", flag.ContinueOnError)
+	from := fs.String("from", "", "Current scope name")
+	to := fs.String("to", "", "New scope name (must not exist)")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	if strings.TrimSpace(*from) == "" || strings.TrimSpace(*to) == "" {
+		fail(exitUsage, "scope rename requires -from and -to")
+		return
+	}
+	ctx, cancel, st, ok := openScopeStore()
+	if !ok {
+		return
+	}
+	defer cancel()
+	defer func() { _ = st.Close() }()
+
+	if err := st.RenameScope(ctx, *from, *to); err != nil {
+		fail(exitFailure, "scope rename failed: %v", err)
+		return
+	}
+	sc, _, err := st.GetScope(ctx, strings.TrimSpace(*to))
+	if err != nil || sc == nil {
+		fail(exitFailure, "scope rename: reload failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toScopeRecord(*sc))
+		return
+	}
+	fmt.Printf("Scope renamed: %s -> %s\n", strings.TrimSpace(*from), sc.Scope)
+}
+
+func runScopeMerge(args []string) {
+	fs := flag.NewFlagSet("scope merge", flag.ContinueOnError)
+	from := fs.String("from", "", "Scope to fold in (deleted when nothing conflicts)")
+	into := fs.String("into", "", "Scope that receives the messages")
+	dryRun := fs.Bool("dry-run", false, "Report what would move without changing anything")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	if strings.TrimSpace(*from) == "" || strings.TrimSpace(*into) == "" {
+		fail(exitUsage, "scope merge requires -from and -into")
+		return
+	}
+	ctx, cancel, st, ok := openScopeStore()
+	if !ok {
+		return
+	}
+	defer cancel()
+	defer func() { _ = st.Close() }()
+
+	rep, err := st.MergeScope(ctx, *from, *into, *dryRun)
+	if err != nil {
+		fail(exitFailure, "scope merge failed: %v", err)
+		return
+	}
+	if len(rep.Conflicts) > 0 {
+		partial()
+	}
+	if structured() {
+		emit(toScopeMergeRecord(rep))
+		return
+	}
+
+	verb := "Merged"
+	if rep.DryRun {
+		verb = "Would merge"
+	}
+	fmt.Printf("%s %s into %s: %d ref(s) moved, %d duplicate(s) dropped, %d other record(s) moved\n",
+		verb, rep.From, rep.Into, rep.Moved, rep.Duplicates, rep.Other)
+	for _, c := range rep.Conflicts {
+		if c.FromMessage != "" {
+			fmt.Printf("  conflict %s %s: %s here, %s in %s\n", c.Kind, c.Key, c.FromMessage, c.IntoMessage, rep.Into)
+		} else {
+			fmt.Printf("  conflict %s %s: already set in %s\n", c.Kind, c.Key, rep.Into)
+		}
+	}
+	switch {
+	case rep.Removed && rep.DryRun:
+		fmt.Printf("%s would be deleted.\n", rep.From)
+	case rep.Removed:
+		fmt.Printf("%s deleted.\n", rep.From)
+	default:
+		fmt.Printf("%s kept: resolve the %d conflict(s) and merge again.\n", rep.From, len(rep.Conflicts))
+	}
+}
+
+func runScopeArchive(args []string) {
+	fs := flag.NewFlagSet("scope archive", flag.ContinueOnError)
+	scope := fs.String("scope", "", "Scope to archive")
+	undo := fs.Bool("undo", false, "Unarchive the scope")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	name := strings.TrimSpace(*scope)
+	if name == "" {
+		fail(exitUsage, "scope archive requires -scope")
+		return
+	}
+	ctx, cancel, st, ok := openScopeStore()
+	if !ok {
+		return
+	}
+	defer cancel()
+	defer func() { _ = st.Close() }()
+
+	if err := st.ArchiveScope(ctx, name, !*undo); err != nil {
+		fail(exitFailure, "scope archive failed: %v", err)
+		return
+	}
+	sc, _, err := st.GetScope(ctx, name)
+	if err != nil || sc == nil {
+		fail(exitFailure, "scope archive: reload failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(toScopeRecord(*sc))
+		return
+	}
+	if *undo {
+		fmt.Println("Scope unarchived:", name)
+		return
+	}
+	fmt.Println("Scope archived (imports into it are refused):", name)
+}
+
+func runScopeDelete(args []string) {
+	fs := flag.NewFlagSet("scope delete", flag.ContinueOnError)
+	scope := fs.String("scope", "", "Empty scope to delete")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+	name := strings.TrimSpace(*scope)
+	if name == "" {
+		fail(exitUsage, "scope delete requires -scope")
+		return
+	}
+	ctx, cancel, st, ok := openScopeStore()
+	if !ok {
+		return
+	}
+	defer cancel()
+	defer func() { _ = st.Close() }()
+
+	if err := st.DeleteScope(ctx, name); err != nil {
+		if errors.Is(err, store.ErrScopeNotEmpty) {
+			fail(exitFailure, "scope delete refused: %v (merge it into another scope first)", err)
+			return
+		}
+		fail(exitFailure, "scope delete failed: %v", err)
+		return
+	}
+	if structured() {
+		emit(scopeRecord{Scope: name})
+		return
+	}
+	fmt.Println("Scope deleted:", name)
+}
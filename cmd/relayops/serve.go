@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/4current/relayops/internal/api"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8787", "Listen address")
+	allowRemote := fs.Bool("allow-remote", false, "Allow a non-loopback listen address (the token is then the only protection)")
+	tokenFile := fs.String("token-file", "", "Bearer token file, created if missing (default ~/.relayops/api-token; RELAYOPS_API_TOKEN overrides)")
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	if err := api.CheckListenAddr(*addr, *allowRemote); err != nil {
+		fail(exitUsage, "serve: %v", err)
+		return
+	}
+
+	token := strings.TrimSpace(os.Getenv("RELAYOPS_API_TOKEN"))
+	tokenSource := "RELAYOPS_API_TOKEN"
+	if token == "" {
+		path := *tokenFile
+		if path == "" {
+			p, err := runtime.APITokenPath()
+			if err != nil {
+				fail(exitFailure, "serve: %v", err)
+				return
+			}
+			path = p
+		}
+		t, err := api.LoadOrCreateToken(path)
+		if err != nil {
+			fail(exitFailure, "serve: token: %v", err)
+			return
+		}
+		token, tokenSource = t, path
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           (&api.Server{Store: st, Token: token}).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Event streams end on shutdown; sends and playbook runs are detached and finish.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	fmt.Printf("RelayOps API listening on http://%s%s (token: %s)\n", *addr, api.Prefix, tokenSource)
+	fmt.Printf("Dashboard: http://%s/\n", *addr)
+
+	select {
+	case err := <-errc:
+		fail(exitFailure, "serve: %v", err)
+		return
+	case <-ctx.Done():
+	}
+	fmt.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fail(exitFailure, "serve: shutdown: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func runSessions(args []string) {
+	if len(args) > 0 && args[0] == "show" {
+		runSessionShow(args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	n := fs.Int("n", 25, "max sessions")
+	msg := fs.String("msg", "", "Only sessions that carried this message id")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	sessions, err := st.ListSessions(ctx, strings.TrimSpace(*msg), *n)
+	if err != nil {
+		fail(exitFailure, "sessions failed: %v", err)
+		return
+	}
+	if structured() {
+		out := make([]sessionRecord, 0, len(sessions))
+		for _, s := range sessions {
+			out = append(out, toSessionRecord(s))
+		}
+		emit(out)
+		return
+	}
+	if len(sessions) == 0 {
+		fmt.Println("(no sessions)")
+		return
+	}
+	for _, s := range sessions {
+		ts := s.StartedAt.Local().Format("2006-01-02 15:04:05")
+		dur := s.FinishedAt.Sub(s.StartedAt).Round(time.Second)
+		fmt.Printf("%s %-7s %-12s %-24s %8s out=%dB in=%dB %s\n",
+			ts, s.Outcome, s.Transport, orDash(s.Gateway), dur, s.BytesOut, s.BytesIn, s.ID)
+	}
+}
+
+func runSessionShow(args []string) {
+	fs := flag.NewFlagSet("sessions show", flag.ContinueOnError)
+	id := fs.String("id", "", "session id (required)")
+	transcript := fs.Bool("transcript", false, "print the raw transcript")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	if strings.TrimSpace(*id) == "" {
+		fail(exitUsage, "sessions show requires -id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	s, ok, err := st.GetSession(ctx, strings.TrimSpace(*id))
+	if err != nil {
+		fail(exitFailure, "sessions show failed: %v", err)
+		return
+	}
+	if !ok {
+		fail(exitFailure, "no such session: %s", strings.TrimSpace(*id))
+		return
+	}
+
+	if structured() {
+		d := sessionDetail{sessionRecord: toSessionRecord(*s), Messages: []sessionMessageRecord{}}
+		for _, l := range s.Messages {
+			d.Messages = append(d.Messages, sessionMessageRecord{Direction: l.Direction, MID: l.MID, MessageID: l.MessageID})
+		}
+		if *transcript {
+			d.Transcript = s.Transcript
+		}
+		emit(d)
+		return
+	}
+
+	fmt.Printf("Session:   %s\n", s.ID)
+	fmt.Printf("Transport: %s\n", s.Transport)
+	fmt.Printf("Gateway:   %s\n", orDash(s.Gateway))
+	fmt.Printf("Frequency: %s\n", orDash(s.Frequency))
+	fmt.Printf("Started:   %s\n", s.StartedAt.Local().Format(time.RFC3339))
+	fmt.Printf("Finished:  %s (%s)\n", s.FinishedAt.Local().Format(time.RFC3339), s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond))
+	fmt.Printf("Outcome:   %s\n", s.Outcome)
+	if s.Error != "" {
+		fmt.Printf("Error:     %s\n", s.Error)
+	}
+	fmt.Printf("Bytes:     out=%d in=%d\n", s.BytesOut, s.BytesIn)
+	if len(s.Messages) > 0 {
+		fmt.Println("Messages:")
+		for _, l := range s.Messages {
+			fmt.Printf("  %-3s %-14s %s\n", l.Direction, orDash(l.MID), orDash(l.MessageID))
+		}
+	}
+	if *transcript {
+		fmt.Println("Transcript:")
+		fmt.Println(strings.TrimRight(s.Transcript, "\n"))
+	}
+}
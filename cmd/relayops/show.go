@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/forms"
+	"github.com/4current/relayops/internal/radiogram"
+	"github.com/4current/relayops/internal/store"
+)
+
+func runShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	id := fs.String("id", "", "message id (required)")
+	raw := fs.Bool("raw", false, "print form XML and radiograms as-is instead of rendering them")
+	outputFlag(fs)
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	if strings.TrimSpace(*id) == "" {
+		fail(exitUsage, "show requires -id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cliContext(), 10*time.Second)
+	defer cancel()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	m, ok, err := st.GetMessage(ctx, strings.TrimSpace(*id))
+	if err != nil {
+		fail(exitFailure, "show failed: %v", err)
+		return
+	}
+	if !ok {
+		fail(exitFailure, "no such message: %s", strings.TrimSpace(*id))
+		return
+	}
+	if structured() {
+		emit(toMessageDetail(m))
+		return
+	}
+
+	fmt.Printf("Message: %s\n", m.ID)
+	fmt.Printf("Status:  %s\n", m.Status)
+	fmt.Printf("Created: %s\n", m.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Printf("From:    %s\n", orDash(addressString(m.From)))
+	var to []string
+	for _, a := range m.To {
+		to = append(to, addressString(a))
+	}
+	fmt.Printf("To:      %s\n", orDash(strings.Join(to, ", ")))
+	fmt.Printf("Subject: %s\n", m.Subject)
+	if len(m.Tags) > 0 {
+		fmt.Printf("Tags:    %s\n", strings.Join(m.Tags, ", "))
+	}
+	if m.LastError != "" {
+		fmt.Printf("Error:   %s\n", m.LastError)
+	}
+	if len(m.Attachments) > 0 {
+		fmt.Println("Attachments:")
+		for _, a := range m.Attachments {
+			fmt.Printf("  %-48s %6d bytes  %s\n", a.Name, len(a.Data), a.ContentType)
+		}
+	}
+
+	if m.Meta.Form != nil {
+		fmt.Println("")
+		if *raw {
+			for _, a := range m.Attachments {
+				if forms.IsFormAttachment(a.Name) {
+					fmt.Println(strings.TrimRight(string(a.Data), "\r\n"))
+				}
+			}
+		} else {
+			fmt.Print(forms.Describe(m.Meta.Form))
+		}
+		return
+	}
+
+	fmt.Println("")
+	if r, err := radiogram.Parse(m.Body); err == nil && !*raw {
+		fmt.Print(r.Describe())
+		if err := r.Validate(); err != nil {
+			fmt.Printf("\nWARNING: %v\n", err)
+		}
+		return
+	}
+	fmt.Println(strings.TrimRight(m.Body, "\n"))
+}
+
+func addressString(a core.Address) string {
+	if a.Email != "" {
+		return a.Email
+	}
+	return a.Callsign
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/4current/relayops/internal/compose"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/templates"
+)
+
+func runTemplate(args []string) {
+	if len(args) == 0 {
+		fail(exitUsage, "template requires a subcommand: list | show <name> | check <name> [-var key=value]")
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("template list", flag.ContinueOnError)
+		outputFlag(fs)
+		if !parseFlags(fs, args[1:]) {
+			return
+		}
+		list, err := templates.List()
+		if err != nil {
+			fail(exitFailure, "template list failed: %v", err)
+			return
+		}
+		if structured() {
+			out := make([]templateRecord, 0, len(list))
+			for _, t := range list {
+				out = append(out, templateRecord{Name: t.Name, Source: t.Source})
+			}
+			emit(out)
+			return
+		}
+		dir, _ := runtime.TemplatesDir()
+		fmt.Printf("Templates (user templates in %s):\n", dir)
+		for _, t := range list {
+			fmt.Printf("  %-24s %s\n", t.Name, t.Source)
+		}
+
+	case "show":
+		fs := flag.NewFlagSet("template show", flag.ContinueOnError)
+		outputFlag(fs)
+		name, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return
+		}
+		if name == "" {
+			fail(exitUsage, "template show requires a template name")
+			return
+		}
+		t, err := templates.Load(name)
+		if err != nil {
+			fail(exitFailure, "template show failed: %v", err)
+			return
+		}
+		required, optional := t.Variables()
+		if structured() {
+			emit(renderedRecord{
+				Name: t.Name, Source: t.Source, Required: nonNil(required), Optional: nonNil(optional),
+				Subject: t.Subject, To: splitList(t.To), Tags: splitList(t.Tags), Body: t.Body,
+			})
+			return
+		}
+		fmt.Printf("Template: %s (%s)\n", t.Name, t.Source)
+		fmt.Printf("Required vars: %s\n", orDash(strings.Join(required, ", ")))
+		fmt.Printf("Optional vars: %s\n", orDash(strings.Join(optional, ", ")))
+		fmt.Println("")
+		fmt.Printf("Subject: %s\n", t.Subject)
+		if t.To != "" {
+			fmt.Printf("To: %s\n", t.To)
+		}
+		if t.Tags != "" {
+			fmt.Printf("Tags: %s\n", t.Tags)
+		}
+		fmt.Println("")
+		fmt.Println(t.Body)
+
+	case "check":
+		fs := flag.NewFlagSet("template check", flag.ContinueOnError)
+		vars := varFlags{}
+		fs.Var(vars, "var", "Set a template variable, key=value (repeatable)")
+		outputFlag(fs)
+		name, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return
+		}
+		if name == "" {
+			fail(exitUsage, "template check requires a template name")
+			return
+		}
+		out, err := compose.Template(context.Background(), name, vars)
+		if err != nil {
+			fail(exitFailure, "template check failed: %v", err)
+			return
+		}
+		if structured() {
+			r := renderedRecord{
+				Name: name, Required: []string{}, Optional: []string{},
+				Subject: out.Subject, To: nonNil(out.To), Tags: nonNil(out.Tags), Body: out.Body,
+			}
+			if t, err := templates.Load(name); err == nil {
+				r.Name, r.Source = t.Name, t.Source
+				required, optional := t.Variables()
+				r.Required, r.Optional = nonNil(required), nonNil(optional)
+			}
+			emit(r)
+			return
+		}
+		fmt.Printf("Subject: %s\n", out.Subject)
+		if len(out.To) > 0 {
+			fmt.Printf("To: %s\n", strings.Join(out.To, ", "))
+		}
+		if len(out.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(out.Tags, ", "))
+		}
+		fmt.Println("")
+		fmt.Println(out.Body)
+
+	default:
+		fail(exitUsage, "Unknown template subcommand: %s", args[0])
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/tui"
+)
+
+func runTUI(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	transport := fs.String("transport", envOr("RELAYOPS_TRANSPORT", "pat"), "Transport for the send key: pat or sim")
+	connect := fs.String("connect", envOr("RELAYOPS_CONNECT", "telnet"), "pat connect alias or URL, e.g. ardop:///W1AW-10?freq=7101.5")
+	n := fs.Int("n", 25, "max messages per send")
+	if !parseFlags(fs, args) {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(cliContext(), syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		fail(exitFailure, "store open failed: %v", err)
+		return
+	}
+	defer func() { _ = st.Close() }()
+
+	label := *transport
+	if *transport == "pat" && strings.TrimSpace(*connect) != "" {
+		label += " " + strings.TrimSpace(*connect)
+	}
+	app := tui.New(st, tui.Options{
+		NewSender: func() (ops.Sender, error) { return playbook.DefaultTransport(*transport, strings.TrimSpace(*connect)) },
+		Transport: label,
+		Station:   runtime.IdentityScope(""),
+		SendLimit: *n,
+	})
+	if err := app.Run(ctx, os.Stdin, os.Stdout); err != nil {
+		fail(exitFailure, "tui: %v", err)
+	}
+}
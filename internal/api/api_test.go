@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/sim"
+)
+
+const token = "test-token"
+
+func setup(t *testing.T) (*httptest.Server, *store.Store) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("USERPROFILE", tmp)
+	t.Setenv("RELAYOPS_CALLSIGN", "AE4OK")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	s := &Server{
+		Store: st, Token: token, PollInterval: 20 * time.Millisecond,
+		NewTransport: func(use, connect string) (ops.Sender, error) { return sim.New(), nil },
+		Transports: func() []ops.TransportStatus {
+			return []ops.TransportStatus{{Name: "pat", Detail: "pat binary not found in PATH"}, {Name: "sim", Available: true}}
+		},
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts, st
+}
+
+func call(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, ts.URL+Prefix+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, b, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuthRequired(t *testing.T) {
+	ts, _ := setup(t)
+	for _, c := range []struct {
+		path, auth string
+		want       int
+	}{
+		{"/messages", "", http.StatusUnauthorized},
+		{"/messages", "Bearer wrong", http.StatusUnauthorized},
+		{"/messages", "Bearer " + token, http.StatusOK},
+		{"/messages?access_token=" + token, "", http.StatusOK},
+		{"/health", "", http.StatusOK},
+		{"/openapi.yaml", "", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+Prefix+c.path, nil)
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("GET %s (%q) = %d, want %d", c.path, c.auth, resp.StatusCode, c.want)
+		}
+	}
+}
+
+func TestComposeQueueSendFlow(t *testing.T) {
+	ts, _ := setup(t)
+
+	var errBody map[string]string
+	if code := call(t, ts, "POST", "/messages", `{"template":"checkin","subject":"x"}`, &errBody); code != http.StatusUnprocessableEntity || errBody["error"] == "" {
+		t.Fatalf("conflicting compose = %d %v", code, errBody)
+	}
+	if code := call(t, ts, "POST", "/messages", `{"subjekt":"typo"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown field = %d", code)
+	}
+
+	var m Message
+	if code := call(t, ts, "POST", "/messages", `{"subject":"Net check-in","body":"QSL","to":["W1AW"],"tags":["net"]}`, &m); code != http.StatusCreated {
+		t.Fatalf("compose = %d", code)
+	}
+	if m.Status != "draft" || m.Session != "winlink" || len(m.To) != 1 || m.To[0].Callsign != "W1AW" {
+		t.Fatalf("composed = %+v", m)
+	}
+
+	var got Message
+	if code := call(t, ts, "GET", "/messages/"+m.ID, "", &got); code != http.StatusOK || got.Body != "QSL" {
+		t.Fatalf("get = %d %+v", code, got)
+	}
+	if code := call(t, ts, "GET", "/messages/nope", "", nil); code != http.StatusNotFound {
+		t.Fatalf("get missing = %d", code)
+	}
+
+	if code := call(t, ts, "POST", "/messages/"+m.ID+"/queue", "", &got); code != http.StatusOK || got.Status != "queued" {
+		t.Fatalf("queue = %d %+v", code, got)
+	}
+	var list []MessageSummary
+	if code := call(t, ts, "GET", "/messages?status=queued&tag=net", "", &list); code != http.StatusOK || len(list) != 1 {
+		t.Fatalf("list queued = %d %+v", code, list)
+	}
+
+	var sent map[string]int
+	if code := call(t, ts, "POST", "/send", `{"transport":"sim"}`, &sent); code != http.StatusOK || sent["sent"] != 1 {
+		t.Fatalf("send = %d %v", code, sent)
+	}
+	if code := call(t, ts, "POST", "/messages/"+m.ID+"/queue", "", nil); code != http.StatusConflict {
+		t.Fatalf("queue sent message = %d", code)
+	}
+
+	var sessions []Session
+	if code := call(t, ts, "GET", "/sessions", "", &sessions); code != http.StatusOK {
+		t.Fatalf("sessions = %d", code)
+	}
+
+	var st Status
+	if code := call(t, ts, "GET", "/status", "", &st); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if st.Counts["sent"] != 1 || st.Counts["queued"] != 0 {
+		t.Fatalf("status counts = %v", st.Counts)
+	}
+	if st.LastSession == nil || st.LastSession.Outcome != "ok" || len(st.LastSession.Messages) != 1 {
+		t.Fatalf("last session = %+v", st.LastSession)
+	}
+	if len(st.Transports) != 2 || st.Transports[0].Available || !st.Transports[1].Available {
+		t.Fatalf("transports = %+v", st.Transports)
+	}
+}
+
+func TestDashboardAndInbox(t *testing.T) {
+	ts, st := setup(t)
+
+	for path, want := range map[string]string{"/": "<title>RelayOps", "/app.js": "EventSource", "/style.css": ":root"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(b), want) {
+			t.Errorf("GET %s = %d, missing %q", path, resp.StatusCode, want)
+		}
+		if !strings.Contains(resp.Header.Get("Content-Security-Policy"), "default-src 'self'") {
+			t.Errorf("GET %s: no CSP header", path)
+		}
+	}
+
+	var in Message
+	call(t, ts, "POST", "/messages", `{"subject":"Resource request","body":"Need cots"}`, &in)
+	call(t, ts, "POST", "/messages", `{"subject":"Outbound","body":"x"}`, nil)
+	if err := st.UpsertBackendState(context.Background(), in.ID, "pat", "InBox", "Received", ""); err != nil {
+		t.Fatal(err)
+	}
+	var inbox []MessageSummary
+	if code := call(t, ts, "GET", "/messages?folder=inbox", "", &inbox); code != http.StatusOK || len(inbox) != 1 || inbox[0].ID != in.ID {
+		t.Fatalf("inbox = %d %+v", code, inbox)
+	}
+	var outbox []MessageSummary
+	if code := call(t, ts, "GET", "/messages?status=draft&not_folder=inbox", "", &outbox); code != http.StatusOK || len(outbox) != 1 || outbox[0].ID == in.ID {
+		t.Fatalf("outbox = %d %+v", code, outbox)
+	}
+}
+
+func TestScopesAndPlaybooks(t *testing.T) {
+	ts, _ := setup(t)
+	if code := call(t, ts, "POST", "/scopes", `{"scope":"AE4OK@general","note":"home"}`, nil); code != http.StatusCreated {
+		t.Fatalf("create scope = %d", code)
+	}
+	var scopes []Scope
+	if code := call(t, ts, "GET", "/scopes", "", &scopes); code != http.StatusOK || len(scopes) != 1 || scopes[0].Note != "home" {
+		t.Fatalf("scopes = %d %+v", code, scopes)
+	}
+
+	var pbs []map[string]any
+	if code := call(t, ts, "GET", "/playbooks", "", &pbs); code != http.StatusOK || len(pbs) == 0 {
+		t.Fatalf("playbooks = %d %+v", code, pbs)
+	}
+	var run PlaybookRun
+	if code := call(t, ts, "POST", "/playbooks/winlink-wednesday/runs", `{"dry_run":true,"vars":{"to":"W1AW","location":"Knoxville","power":"5W","mode":"packet"}}`, &run); code != http.StatusOK || !run.DryRun || len(run.Steps) != 6 || run.Steps[0].Outcome != "planned" {
+		t.Fatalf("dry run = %d %+v", code, run)
+	}
+	if code := call(t, ts, "POST", "/playbooks/..%2Fetc/runs", `{}`, nil); code != http.StatusNotFound {
+		t.Fatalf("unknown playbook = %d", code)
+	}
+}
+
+func TestEventsStreamStatusChanges(t *testing.T) {
+	ts, _ := setup(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+Prefix+"/events?kind=message.*&access_token="+token, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	var m Message
+	call(t, ts, "POST", "/messages", `{"subject":"s","body":"b","queue":true}`, &m)
+
+	sc := bufio.NewScanner(resp.Body)
+	var kinds []string
+	for sc.Scan() && len(kinds) < 2 {
+		line := sc.Text()
+		if k, ok := strings.CutPrefix(line, "event: "); ok {
+			kinds = append(kinds, k)
+		}
+		if d, ok := strings.CutPrefix(line, "data: "); ok {
+			var ev Event
+			if err := json.Unmarshal([]byte(d), &ev); err != nil || ev.MessageID != m.ID || ev.Actor != "api" {
+				t.Fatalf("event data %q: %v", d, err)
+			}
+		}
+	}
+	if strings.Join(kinds, ",") != "message.created,message.status" {
+		t.Fatalf("streamed kinds = %v", kinds)
+	}
+}
+
+// TestOpenAPICoversRoutes keeps openapi.yaml in step with the route table.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]any `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(OpenAPI(), &doc); err != nil {
+		t.Fatalf("openapi.yaml: %v", err)
+	}
+	for _, rt := range routes {
+		ops, ok := doc.Paths[rt.path]
+		if !ok {
+			t.Errorf("openapi.yaml lacks path %s", rt.path)
+			continue
+		}
+		if _, ok := ops[strings.ToLower(rt.method)]; !ok {
+			t.Errorf("openapi.yaml lacks %s %s", rt.method, rt.path)
+		}
+	}
+	total := 0
+	for _, ops := range doc.Paths {
+		total += len(ops)
+	}
+	if total != len(routes) {
+		t.Errorf("openapi.yaml documents %d operations, server has %d routes", total, len(routes))
+	}
+}
+
+func TestCheckListenAddr(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:8787": true, "[::1]:8787": true, "localhost:8787": true,
+		":8787": false, "0.0.0.0:8787": false, "192.168.1.10:8787": false,
+	} {
+		if err := CheckListenAddr(addr, false); (err == nil) != ok {
+			t.Errorf("CheckListenAddr(%q) = %v", addr, err)
+		}
+	}
+	if err := CheckListenAddr("0.0.0.0:8787", true); err != nil {
+		t.Errorf("allow-remote: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+// events streams audit events as Server-Sent Events: one "id: <seq>", "event: <kind>",
+// "data: <Event JSON>" record per event. The audit trail is polled rather than hooked
+// so changes made by the CLI or the daemon in other processes show up too. Clients
+// resume with Last-Event-ID (EventSource does this on reconnect) or ?after=<seq>;
+// without either, only events after the connection opened are sent. ?kind narrows the
+// stream, e.g. kind=message.status or kind=daemon.*.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	ctx := r.Context()
+
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after")
+	}
+	var seq int64
+	if after != "" {
+		n, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, 0, badRequest("invalid event id %q", after))
+			return
+		}
+		seq = n
+	} else {
+		n, err := s.Store.LastEventSeq(ctx)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		seq = n
+	}
+	kind := r.URL.Query().Get("kind")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-poll.C:
+			evs, err := s.Store.ListEvents(ctx, store.EventFilter{AfterSeq: seq, Limit: 500})
+			if err != nil {
+				return
+			}
+			for _, ev := range evs {
+				seq = ev.Seq
+				if !kindMatches(kind, ev.Kind) {
+					continue
+				}
+				b, _ := json.Marshal(toEvent(ev))
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Kind, b)
+			}
+			if len(evs) > 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// kindMatches applies an EventFilter-style kind (exact, or "prefix.*") to one event.
+func kindMatches(filter, kind string) bool {
+	if filter == "" || filter == kind {
+		return true
+	}
+	if len(filter) > 2 && filter[len(filter)-2:] == ".*" {
+		prefix := filter[:len(filter)-1]
+		return len(kind) > len(prefix) && kind[:len(prefix)] == prefix
+	}
+	return false
+}
@@ -0,0 +1,447 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/4current/relayops/internal/compose"
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/pat"
+	"github.com/4current/relayops/internal/transport/winlink"
+)
+
+func queryLimit(r *http.Request, def int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, badRequest("invalid limit %q", v)
+	}
+	return n, nil
+}
+
+// listMessages returns summaries, newest first. status is a comma-separated list;
+// tag, scope and folder narrow by tag, by the scope of an external ref and by backend
+// mailbox folder (folder=inbox lists received mail); not_folder excludes a folder.
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := queryLimit(r, 50)
+	if err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	var statuses []core.MessageStatus
+	for _, st := range strings.Split(q.Get("status"), ",") {
+		if st = strings.TrimSpace(st); st != "" {
+			statuses = append(statuses, core.MessageStatus(st))
+		}
+	}
+
+	out := []MessageSummary{}
+	filter := store.MessageFilter{Tag: q.Get("tag"), Scope: q.Get("scope"), Folder: q.Get("folder"), NotFolder: q.Get("not_folder")}
+	if filter == (store.MessageFilter{}) {
+		var sums []store.MessageSummary
+		if len(statuses) > 0 {
+			sums, err = s.Store.ListByStatus(r.Context(), statuses, limit)
+		} else {
+			sums, err = s.Store.ListMessages(r.Context(), limit, false)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, m := range sums {
+			out = append(out, toSummary(m))
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+
+	msgs, err := s.Store.QueryMessages(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := len(msgs) - 1; i >= 0 && (limit == 0 || len(out) < limit); i-- {
+		m := msgs[i]
+		if len(statuses) > 0 && !slices.Contains(statuses, m.Status) {
+			continue
+		}
+		out = append(out, toSummary(store.MessageSummary{
+			ID: m.ID, Subject: m.Subject, CreatedAt: m.CreatedAt, Tags: m.Tags, Meta: m.Meta, Status: m.Status,
+		}))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// status summarizes the station for the dashboard.
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.Store.CountByStatus(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sessions, err := s.Store.ListSessions(r.Context(), "", 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := Status{Counts: map[string]int{}, Transports: []Transport{}}
+	for st, n := range counts {
+		out.Counts[string(st)] = n
+	}
+	for _, t := range s.transports() {
+		out.Transports = append(out.Transports, Transport{Name: t.Name, Available: t.Available, Detail: t.Detail})
+	}
+	if len(sessions) > 0 {
+		full, ok, err := s.Store.GetSession(r.Context(), sessions[0].ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if ok {
+			sess := toSession(*full, false)
+			out.LastSession = &sess
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+type createMessageRequest struct {
+	compose.Request
+	// Queue queues the draft right away.
+	Queue bool `json:"queue,omitempty"`
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	var req createMessageRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	msg, err := compose.Build(r.Context(), req.Request)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := s.Store.SaveMessage(r.Context(), msg); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if req.Queue {
+		if err := s.Store.SetStatusByID(r.Context(), msg.ID, core.StatusQueued, ""); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	s.writeMessage(w, r, http.StatusCreated, msg.ID)
+}
+
+func (s *Server) writeMessage(w http.ResponseWriter, r *http.Request, status int, id string) {
+	m, ok, err := s.Store.GetMessage(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, 0, notFound("no message %s", id))
+		return
+	}
+	writeJSON(w, status, toMessage(m))
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	s.writeMessage(w, r, http.StatusOK, r.PathValue("id"))
+}
+
+// queueMessage queues one draft or failed message.
+func (s *Server) queueMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	m, ok, err := s.Store.GetMessage(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, 0, notFound("no message %s", id))
+		return
+	}
+	switch m.Status {
+	case core.StatusDraft, core.StatusFailed:
+	case core.StatusQueued:
+		writeJSON(w, http.StatusOK, toMessage(m))
+		return
+	default:
+		writeError(w, http.StatusConflict, fmt.Errorf("cannot queue a %s message", m.Status))
+		return
+	}
+	if err := s.Store.SetStatusByID(r.Context(), id, core.StatusQueued, ""); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeMessage(w, r, http.StatusOK, id)
+}
+
+func (s *Server) queueTag(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Tag string `json:"tag"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	if strings.TrimSpace(req.Tag) == "" {
+		writeError(w, 0, badRequest("tag is required"))
+		return
+	}
+	n, err := s.Store.QueueByTag(r.Context(), req.Tag)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"queued": n})
+}
+
+// send sends queued messages over one transport, like `relayops send`.
+func (s *Server) send(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Tag       string `json:"tag,omitempty"`
+		Limit     int    `json:"limit,omitempty"`
+		Transport string `json:"transport,omitempty"`
+		Connect   string `json:"connect,omitempty"`
+	}{Limit: 25, Transport: "pat", Connect: "telnet"}
+	if v := os.Getenv("RELAYOPS_TRANSPORT"); v != "" {
+		req.Transport = v
+	}
+	if v := os.Getenv("RELAYOPS_CONNECT"); v != "" {
+		req.Connect = v
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	sender, err := s.newTransport(req.Transport, req.Connect)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := s.detached(r)
+	defer cancel()
+	res, err := ops.SendQueued(ctx, s.Store, req.Tag, req.Limit, sender)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"sent": res.Sent, "failed": res.Failed})
+}
+
+// importMail imports a PAT mailbox or a Winlink Express directory into a scope.
+func (s *Server) importMail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source        string `json:"source"` // "pat" or "winlink"
+		Path          string `json:"path,omitempty"`
+		Scope         string `json:"scope,omitempty"`
+		Callsign      string `json:"callsign,omitempty"`
+		AllowNewScope bool   `json:"allow_new_scope,omitempty"`
+		Full          bool   `json:"full,omitempty"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	scope := strings.TrimSpace(req.Scope)
+	if scope == "" {
+		scope = runtime.IdentityScope("")
+	}
+	if scope == "" {
+		writeError(w, 0, badRequest("scope is required (or set RELAYOPS_CALLSIGN/RELAYOPS_STATION)"))
+		return
+	}
+	sc, exists, err := s.Store.GetScope(r.Context(), scope)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if exists && !sc.ArchivedAt.IsZero() {
+		writeError(w, http.StatusConflict, fmt.Errorf("scope %s is archived", scope))
+		return
+	}
+	if !exists {
+		if !req.AllowNewScope {
+			writeError(w, http.StatusConflict, fmt.Errorf("scope %s does not exist; create it first or set allow_new_scope", scope))
+			return
+		}
+		if err := s.Store.CreateScope(r.Context(), scope, "auto-created by import"); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ctx, cancel := s.detached(r)
+	defer cancel()
+	var scanned, created, updated, skipped, errs int
+	unmapped := []int{}
+	switch req.Source {
+	case "pat":
+		mbox := req.Path
+		if mbox == "" {
+			if mbox, err = pat.MailboxDir(); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		rep, err := pat.ImportFromMailbox(ctx, s.Store, mbox, req.Callsign, scope, req.Full)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		scanned, created, updated, skipped, errs = rep.Scanned, rep.Created, rep.Updated, rep.Skipped, rep.Errors
+	case "winlink":
+		if strings.TrimSpace(req.Path) == "" {
+			writeError(w, 0, badRequest("path (the Winlink Express callsign directory) is required"))
+			return
+		}
+		rep, err := winlink.ImportFromWinlinkExpress(ctx, s.Store, req.Path, scope, req.Full)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		scanned, created, updated, skipped, errs = rep.Scanned, rep.Created, rep.Updated, rep.Skipped, rep.Errors
+		unmapped = append(unmapped, rep.Unmapped...)
+	default:
+		writeError(w, 0, badRequest("source must be pat or winlink"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"scope": scope, "scanned": scanned, "created": created, "updated": updated, "skipped": skipped, "errors": errs,
+		"unmapped_columns": unmapped,
+	})
+}
+
+func (s *Server) listScopes(w http.ResponseWriter, r *http.Request) {
+	scopes, err := s.Store.ListScopes(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []Scope{}
+	for _, sc := range scopes {
+		item := Scope{Scope: sc.Scope, Note: sc.Note, CreatedAt: sc.CreatedAt}
+		if !sc.ArchivedAt.IsZero() {
+			item.ArchivedAt = &sc.ArchivedAt
+		}
+		if !sc.LastImportAt.IsZero() {
+			item.LastImportAt = &sc.LastImportAt
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) createScope(w http.ResponseWriter, r *http.Request) {
+	var req Scope
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	if strings.TrimSpace(req.Scope) == "" {
+		writeError(w, 0, badRequest("scope is required"))
+		return
+	}
+	if err := s.Store.CreateScope(r.Context(), strings.TrimSpace(req.Scope), strings.TrimSpace(req.Note)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"scope": strings.TrimSpace(req.Scope)})
+}
+
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r, 25)
+	if err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	sessions, err := s.Store.ListSessions(r.Context(), r.URL.Query().Get("message"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []Session{}
+	for _, sess := range sessions {
+		out = append(out, toSession(sess, false))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok, err := s.Store.GetSession(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeError(w, 0, notFound("no session %s", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, toSession(*sess, r.URL.Query().Get("transcript") == "1"))
+}
+
+func (s *Server) listPlaybooks(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		Steps       int    `json:"steps"`
+	}
+	out := []entry{}
+	for _, name := range playbook.BuiltinNames() {
+		pb, err := playbook.Builtin(name)
+		if err != nil {
+			continue
+		}
+		out = append(out, entry{Name: name, Description: strings.TrimSpace(pb.Description), Steps: len(pb.Steps)})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// runPlaybook runs a built-in playbook to completion and returns its result. Only
+// built-in playbooks can be run, so API clients cannot point the runner at arbitrary
+// files on the station.
+func (s *Server) runPlaybook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Vars   map[string]string `json:"vars,omitempty"`
+		DryRun bool              `json:"dry_run,omitempty"`
+	}
+	if err := decode(r, &req); err != nil {
+		writeError(w, 0, err)
+		return
+	}
+	name := r.PathValue("name")
+	if !slices.Contains(playbook.BuiltinNames(), name) {
+		writeError(w, 0, notFound("no built-in playbook %q", name))
+		return
+	}
+	pb, err := playbook.Builtin(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx, cancel := s.detached(r)
+	defer cancel()
+	runner := &playbook.Runner{Store: s.Store, DryRun: req.DryRun, Vars: req.Vars, NewTransport: s.NewTransport}
+	res, err := runner.Run(ctx, pb)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPlaybookRun(res))
+}
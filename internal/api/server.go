@@ -0,0 +1,240 @@
+// Package api serves the RelayOps engine over local HTTP/JSON so a GUI (or script) can
+// drive the station without the CLI: compose, list, queue, send, import, scopes,
+// sessions and playbook runs, plus a Server-Sent Events stream of audit events for live
+// status. Every request except /health and /openapi.yaml needs the bearer token.
+//
+// The same server hosts a small embedded web dashboard at / for operators who do not
+// use the CLI; it is static and talks to the API with the token the operator enters.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/store"
+)
+
+// Prefix is the path every API route lives under.
+const Prefix = "/api/v1"
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// OpenAPI returns the API's OpenAPI 3 document.
+func OpenAPI() []byte { return openAPISpec }
+
+// Server is the HTTP API over one store.
+type Server struct {
+	Store *store.Store
+	// Token authenticates requests (Authorization: Bearer <token>, or ?access_token= for
+	// EventSource clients that cannot set headers).
+	Token string
+	// NewTransport builds the sender for /send. Defaults to playbook.DefaultTransport.
+	NewTransport func(use, connect string) (ops.Sender, error)
+	// Transports probes transport availability for /status. Defaults to ops.Transports.
+	Transports func() []ops.TransportStatus
+	// PollInterval is how often /events checks the audit trail (default 1s).
+	PollInterval time.Duration
+	// RunTimeout bounds sends, imports and playbook runs, which outlive the request so a
+	// dropped client does not cut a radio session short (default 30m).
+	RunTimeout time.Duration
+}
+
+// route is one API endpoint; the table doubles as the list checked against openapi.yaml.
+type route struct {
+	method, path string
+	handler      func(*Server, http.ResponseWriter, *http.Request)
+	public       bool
+}
+
+var routes = []route{
+	{"GET", "/health", (*Server).health, true},
+	{"GET", "/openapi.yaml", (*Server).openapi, true},
+	{"GET", "/status", (*Server).status, false},
+	{"GET", "/messages", (*Server).listMessages, false},
+	{"POST", "/messages", (*Server).createMessage, false},
+	{"GET", "/messages/{id}", (*Server).getMessage, false},
+	{"POST", "/messages/{id}/queue", (*Server).queueMessage, false},
+	{"POST", "/queue", (*Server).queueTag, false},
+	{"POST", "/send", (*Server).send, false},
+	{"POST", "/import", (*Server).importMail, false},
+	{"GET", "/scopes", (*Server).listScopes, false},
+	{"POST", "/scopes", (*Server).createScope, false},
+	{"GET", "/sessions", (*Server).listSessions, false},
+	{"GET", "/sessions/{id}", (*Server).getSession, false},
+	{"GET", "/playbooks", (*Server).listPlaybooks, false},
+	{"POST", "/playbooks/{name}/runs", (*Server).runPlaybook, false},
+	{"GET", "/events", (*Server).events, false},
+}
+
+// Handler returns the API's routes, mounted under Prefix, and the web dashboard at /.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /", dashboard())
+	for _, rt := range routes {
+		h := rt.handler
+		var hf http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(store.WithActor(r.Context(), "api"))
+			h(s, w, r)
+		})
+		if !rt.public {
+			hf = s.authenticate(hf)
+		}
+		mux.Handle(rt.method+" "+Prefix+rt.path, hf)
+	}
+	return mux
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.URL.Query().Get("access_token")
+		if h := r.Header.Get("Authorization"); h != "" {
+			got, _ = strings.CutPrefix(h, "Bearer ")
+		}
+		if s.Token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="relayops"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoadOrCreateToken returns the token stored at path, creating a random one (mode 0600)
+// on first use.
+func LoadOrCreateToken(path string) (string, error) {
+	if b, err := os.ReadFile(path); err == nil {
+		if t := strings.TrimSpace(string(b)); t != "" {
+			return t, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	t := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(t+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// CheckListenAddr refuses addresses that are reachable from other hosts unless
+// allowRemote is set. An empty host (":8787") listens on every interface.
+func CheckListenAddr(addr string, allowRemote bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("listen address %q: %w", addr, err)
+	}
+	if allowRemote || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listen address %q is not loopback; pass -allow-remote to serve other hosts", addr)
+}
+
+func (s *Server) runTimeout() time.Duration {
+	if s.RunTimeout > 0 {
+		return s.RunTimeout
+	}
+	return 30 * time.Minute
+}
+
+// detached returns a context for work that should finish even if the client goes away.
+func (s *Server) detached(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), s.runTimeout())
+}
+
+// httpError carries a status code from a handler helper to writeError.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+func (e *httpError) Unwrap() error { return e.err }
+
+func badRequest(format string, args ...any) error {
+	return &httpError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+func notFound(format string, args ...any) error {
+	return &httpError{http.StatusNotFound, fmt.Errorf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeError reports err as {"error": "..."}. An httpError's own status wins over
+// status, which may be 0 when err always carries one.
+func writeError(w http.ResponseWriter, status int, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// decode reads a JSON request body into v, rejecting unknown fields. An empty body
+// leaves v unchanged.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 4<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return badRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "encrypted_locked": s.Store.Locked()})
+}
+
+func (s *Server) openapi(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPISpec)
+}
+
+func (s *Server) transports() []ops.TransportStatus {
+	if s.Transports != nil {
+		return s.Transports()
+	}
+	return ops.Transports()
+}
+
+func (s *Server) newTransport(use, connect string) (ops.Sender, error) {
+	if s.NewTransport != nil {
+		return s.NewTransport(use, connect)
+	}
+	return playbook.DefaultTransport(use, connect)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/store"
+)
+
+// The wire types below are the API's stable JSON schema (see openapi.yaml). They are
+// kept separate from the core and store types so internal refactors do not change it.
+
+// Address is a recipient or sender.
+type Address struct {
+	Callsign string `json:"callsign,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// Attachment describes a file carried by a message; its data is not inlined.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size"`
+}
+
+// Message is a full message.
+type Message struct {
+	ID          string       `json:"id"`
+	Status      string       `json:"status"`
+	Subject     string       `json:"subject"`
+	Body        string       `json:"body"`
+	From        Address      `json:"from"`
+	To          []Address    `json:"to"`
+	Tags        []string     `json:"tags"`
+	Session     string       `json:"session,omitempty"`
+	Allow       []string     `json:"allow,omitempty"`
+	Prefer      []string     `json:"prefer,omitempty"`
+	PatMID      string       `json:"pat_mid,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	SentAt      *time.Time   `json:"sent_at,omitempty"`
+	LastError   string       `json:"last_error,omitempty"`
+	Form        *core.Form   `json:"form,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// MessageSummary is a message without its body, as listed.
+type MessageSummary struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Subject   string    `json:"subject"`
+	Tags      []string  `json:"tags"`
+	Session   string    `json:"session,omitempty"`
+	PatMID    string    `json:"pat_mid,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Scope is an operational scope.
+type Scope struct {
+	Scope     string    `json:"scope"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ArchivedAt and LastImportAt are nil when the scope is active or never imported into.
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+	LastImportAt *time.Time `json:"last_import_at,omitempty"`
+}
+
+// Session is one connect session.
+type Session struct {
+	ID         string           `json:"id"`
+	Transport  string           `json:"transport"`
+	Gateway    string           `json:"gateway,omitempty"`
+	Frequency  string           `json:"frequency,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Outcome    string           `json:"outcome"`
+	Error      string           `json:"error,omitempty"`
+	BytesOut   int64            `json:"bytes_out"`
+	BytesIn    int64            `json:"bytes_in"`
+	Messages   []SessionMessage `json:"messages"`
+	Transcript string           `json:"transcript,omitempty"`
+}
+
+// SessionMessage links a session to a message it carried.
+type SessionMessage struct {
+	MessageID string `json:"message_id,omitempty"`
+	MID       string `json:"mid,omitempty"`
+	Direction string `json:"direction"`
+}
+
+// Transport is the availability of one transport on the station.
+type Transport struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Status is the station at a glance: message counts by status, transport
+// availability and the most recent session.
+type Status struct {
+	Counts      map[string]int `json:"counts"`
+	Transports  []Transport    `json:"transports"`
+	LastSession *Session       `json:"last_session"`
+}
+
+// Event is one audit trail entry, as streamed by /events.
+type Event struct {
+	Seq       int64           `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Actor     string          `json:"actor"`
+	Kind      string          `json:"kind"`
+	MessageID string          `json:"message_id,omitempty"`
+	OldStatus string          `json:"old_status,omitempty"`
+	NewStatus string          `json:"new_status,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// PlaybookRun is the outcome of a playbook run.
+type PlaybookRun struct {
+	RunID    string         `json:"run_id"`
+	Playbook string         `json:"playbook"`
+	DryRun   bool           `json:"dry_run"`
+	OK       bool           `json:"ok"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Steps    []PlaybookStep `json:"steps"`
+}
+
+// PlaybookStep is one step of a playbook run.
+type PlaybookStep struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func address(a core.Address) Address { return Address{Callsign: a.Callsign, Email: a.Email} }
+
+func modeNames(ms []core.Mode) []string {
+	var out []string
+	for _, m := range ms {
+		out = append(out, string(m))
+	}
+	return out
+}
+
+func toMessage(m *core.Message) Message {
+	out := Message{
+		ID: m.ID, Status: string(m.Status), Subject: m.Subject, Body: m.Body,
+		From: address(m.From), To: []Address{}, Tags: m.Tags,
+		Session: string(m.Meta.Session), Allow: modeNames(m.Meta.Transport.Allowed), Prefer: modeNames(m.Meta.Transport.Preferred),
+		PatMID: m.Meta.Delivery.PatMID, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt, SentAt: m.SentAt,
+		LastError: m.LastError, Form: m.Meta.Form, Attachments: []Attachment{},
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	for _, a := range m.To {
+		out.To = append(out.To, address(a))
+	}
+	for _, a := range m.Attachments {
+		out.Attachments = append(out.Attachments, Attachment{Name: a.Name, ContentType: a.ContentType, Size: len(a.Data)})
+	}
+	return out
+}
+
+func toSummary(m store.MessageSummary) MessageSummary {
+	out := MessageSummary{
+		ID: m.ID, Status: string(m.Status), Subject: m.Subject, Tags: m.Tags,
+		Session: string(m.Meta.Session), PatMID: m.Meta.Delivery.PatMID, CreatedAt: m.CreatedAt,
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	return out
+}
+
+func toSession(s store.Session, transcript bool) Session {
+	out := Session{
+		ID: s.ID, Transport: s.Transport, Gateway: s.Gateway, Frequency: s.Frequency,
+		StartedAt: s.StartedAt, FinishedAt: s.FinishedAt, Outcome: s.Outcome, Error: s.Error,
+		BytesOut: s.BytesOut, BytesIn: s.BytesIn, Messages: []SessionMessage{},
+	}
+	for _, m := range s.Messages {
+		out.Messages = append(out.Messages, SessionMessage{MessageID: m.MessageID, MID: m.MID, Direction: m.Direction})
+	}
+	if transcript {
+		out.Transcript = s.Transcript
+	}
+	return out
+}
+
+func toEvent(ev store.Event) Event {
+	out := Event{
+		Seq: ev.Seq, Time: ev.Time, Actor: ev.Actor, Kind: ev.Kind,
+		MessageID: ev.MessageID, OldStatus: ev.OldStatus, NewStatus: ev.NewStatus,
+	}
+	if json.Valid([]byte(ev.Payload)) && ev.Payload != "{}" {
+		out.Payload = json.RawMessage(ev.Payload)
+	}
+	return out
+}
+
+func toPlaybookRun(res *playbook.Result) PlaybookRun {
+	out := PlaybookRun{
+		RunID: res.RunID, Playbook: res.Playbook, DryRun: res.DryRun, OK: res.OK(),
+		Started: res.Started, Finished: res.Finished, Steps: []PlaybookStep{},
+	}
+	for _, s := range res.Steps {
+		out.Steps = append(out.Steps, PlaybookStep{
+			Index: s.Index, Name: s.Name, Action: s.Action, Outcome: s.Outcome,
+			Detail: s.Detail, Error: s.Error, DurationMS: s.Duration.Milliseconds(),
+		})
+	}
+	return out
+}
@@ -0,0 +1,273 @@
+// RelayOps station dashboard. Plain DOM, no build step; every value from the API is
+// inserted as text so message content received over the air cannot inject markup.
+"use strict";
+
+const API = "/api/v1";
+const TOKEN_KEY = "relayops.token";
+const OUTBOX = "draft,queued,sending,failed";
+const COUNTED = ["draft", "queued", "sending", "failed", "sent"];
+
+let token = localStorage.getItem(TOKEN_KEY) || "";
+let events = null;
+let refreshTimer = null;
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const n = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") n.className = v;
+    else if (k.startsWith("on")) n.addEventListener(k.slice(2), v);
+    else n.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c != null) n.append(c instanceof Node ? c : String(c));
+  }
+  return n;
+}
+
+function when(ts) {
+  if (!ts) return "";
+  const d = new Date(ts);
+  return isNaN(d) ? ts : d.toLocaleString();
+}
+
+function list(s) {
+  return s.split(",").map((x) => x.trim()).filter(Boolean);
+}
+
+async function api(method, path, body) {
+  const res = await fetch(API + path, {
+    method,
+    headers: Object.assign({ Authorization: "Bearer " + token }, body ? { "Content-Type": "application/json" } : {}),
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  if (res.status === 401) {
+    signOut("The token was not accepted.");
+    throw new Error("unauthorized");
+  }
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || res.statusText);
+  return data;
+}
+
+function notice(text, isError) {
+  const n = $("notice");
+  n.textContent = text;
+  n.className = isError ? "notice error" : "notice";
+  n.hidden = !text;
+}
+
+function fill(tbody, rows, cols, empty) {
+  tbody.replaceChildren();
+  if (rows.length === 0) {
+    tbody.append(el("tr", null, el("td", { class: "empty", colspan: cols }, empty)));
+    return;
+  }
+  tbody.append(...rows);
+}
+
+function pill(text, cls) {
+  return el("span", { class: "pill " + (cls || text) }, text);
+}
+
+async function showMessage(id) {
+  try {
+    const m = await api("GET", "/messages/" + encodeURIComponent(id));
+    $("v-subject").textContent = m.subject || "(no subject)";
+    const to = (m.to || []).map((a) => a.callsign || a.email).join(", ");
+    const from = m.from.callsign || m.from.email || "";
+    $("v-meta").textContent = [m.status, from && "from " + from, to && "to " + to, when(m.created_at)]
+      .filter(Boolean).join(" · ");
+    $("v-body").textContent = m.body;
+    $("viewer").showModal();
+  } catch (e) {
+    notice(e.message, true);
+  }
+}
+
+async function queueOne(id, button) {
+  button.disabled = true;
+  try {
+    await api("POST", "/messages/" + encodeURIComponent(id) + "/queue");
+    refresh();
+  } catch (e) {
+    notice(e.message, true);
+    button.disabled = false;
+  }
+}
+
+function renderStatus(st) {
+  $("counts").replaceChildren(
+    ...COUNTED.map((s) => el("div", null, el("dt", null, s), el("dd", null, st.counts[s] || 0)))
+  );
+
+  const select = $("send-transport");
+  const current = select.value;
+  select.replaceChildren();
+  $("transports").replaceChildren(
+    ...st.transports.map((t) => {
+      const label = t.name === "sim" ? "sim (drill only)" : t.name;
+      select.append(el("option", t.available ? { value: t.name } : { value: t.name, disabled: "" }, label));
+      return el("li", null, pill(t.available ? "ready" : "unavailable", t.available ? "on" : "off"), " ",
+        el("strong", null, t.name), " ", el("span", { class: "muted" }, t.detail || ""));
+    })
+  );
+  const ready = st.transports.filter((t) => t.available).map((t) => t.name);
+  // Never default to the simulator: a real send must not be faked by accident.
+  select.value = ready.includes(current) ? current : ready.find((n) => n !== "sim") || "";
+
+  const box = $("last-session");
+  const s = st.last_session;
+  if (!s) {
+    box.replaceChildren(el("p", { class: "muted" }, "No sessions yet."));
+    return;
+  }
+  const out = s.messages.filter((m) => m.direction === "out").length;
+  const inn = s.messages.filter((m) => m.direction === "in").length;
+  box.replaceChildren(
+    el("p", null, pill(s.outcome), " ", s.transport, s.gateway ? " via " + s.gateway : ""),
+    el("p", { class: "muted" }, when(s.started_at)),
+    el("p", null, `${out} sent, ${inn} received`),
+    s.error ? el("p", { class: "error" }, s.error) : null
+  );
+}
+
+function renderOutbox(msgs) {
+  fill($("outbox"), msgs.map((m) => {
+    const canQueue = m.status === "draft" || m.status === "failed";
+    const btn = canQueue
+      ? el("button", { class: "small", type: "button", onclick: (e) => { e.stopPropagation(); queueOne(m.id, e.target); } },
+        m.status === "failed" ? "Retry" : "Queue")
+      : null;
+    return el("tr", { class: "clickable", onclick: () => showMessage(m.id) },
+      el("td", null, pill(m.status)), el("td", null, m.subject), el("td", null, m.tags.join(", ")),
+      el("td", null, when(m.created_at)), el("td", null, btn));
+  }), 5, "Nothing waiting to go out.");
+}
+
+function renderInbox(msgs) {
+  fill($("inbox"), msgs.map((m) => el("tr", { class: "clickable", onclick: () => showMessage(m.id) },
+    el("td", null, m.subject), el("td", null, m.tags.join(", ")), el("td", null, when(m.created_at)))),
+  3, "No received messages.");
+}
+
+function renderSessions(sessions) {
+  fill($("sessions"), sessions.map((s) => el("tr", null,
+    el("td", null, when(s.started_at)), el("td", null, s.transport), el("td", null, s.gateway || ""),
+    el("td", null, pill(s.outcome), s.error ? " " + s.error : ""), el("td", null, `${s.bytes_out} / ${s.bytes_in}`))),
+  5, "No sessions yet.");
+}
+
+async function refresh() {
+  try {
+    const [st, outbox, inbox, sessions] = await Promise.all([
+      api("GET", "/status"),
+      api("GET", "/messages?status=" + OUTBOX + "&not_folder=inbox&limit=100"),
+      api("GET", "/messages?folder=inbox&limit=50"),
+      api("GET", "/sessions?limit=10"),
+    ]);
+    renderStatus(st);
+    renderOutbox(outbox);
+    renderInbox(inbox);
+    renderSessions(sessions);
+  } catch (e) {
+    if (e.message !== "unauthorized") notice(e.message, true);
+  }
+}
+
+// scheduleRefresh coalesces bursts of events (a send touches every queued message).
+function scheduleRefresh() {
+  clearTimeout(refreshTimer);
+  refreshTimer = setTimeout(refresh, 300);
+}
+
+function connectEvents() {
+  if (events) events.close();
+  events = new EventSource(API + "/events?access_token=" + encodeURIComponent(token));
+  const live = $("live");
+  events.onopen = () => { live.textContent = "live"; live.className = "pill on"; };
+  events.onerror = () => { live.textContent = "reconnecting"; live.className = "pill off"; };
+  events.onmessage = scheduleRefresh;
+  for (const kind of ["message.created", "message.status", "message.meta", "message.content", "message.deleted", "message.purged",
+    "backend_state.upsert", "session.recorded"]) {
+    events.addEventListener(kind, scheduleRefresh);
+  }
+}
+
+function signOut(reason) {
+  if (events) events.close();
+  events = null;
+  token = "";
+  localStorage.removeItem(TOKEN_KEY);
+  $("app").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("login-error").textContent = reason || "";
+  $("live").textContent = "offline";
+  $("live").className = "pill off";
+}
+
+function start() {
+  $("login").hidden = true;
+  $("app").hidden = false;
+  $("logout").hidden = false;
+  refresh();
+  connectEvents();
+}
+
+$("login-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  token = $("token").value.trim();
+  $("token").value = "";
+  localStorage.setItem(TOKEN_KEY, token);
+  start();
+});
+
+$("logout").addEventListener("click", () => signOut(""));
+
+$("compose-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const button = e.submitter;
+  button.disabled = true;
+  try {
+    const m = await api("POST", "/messages", {
+      to: list($("c-to").value),
+      subject: $("c-subject").value,
+      body: $("c-body").value,
+      tags: list($("c-tags").value),
+      queue: $("c-queue").checked,
+    });
+    e.target.reset();
+    notice(`Saved "${m.subject}" as ${m.status}.`);
+    refresh();
+  } catch (err) {
+    notice(err.message, true);
+  } finally {
+    button.disabled = false;
+  }
+});
+
+$("send-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const button = e.submitter;
+  const transport = $("send-transport").value;
+  if (!transport) {
+    notice("No transport is available.", true);
+    return;
+  }
+  button.disabled = true;
+  notice("Sending over " + transport + "...");
+  try {
+    const res = await api("POST", "/send", { transport, connect: $("send-connect").value.trim() });
+    notice(`Send finished: ${res.sent} sent, ${res.failed} failed.`, res.failed > 0);
+  } catch (err) {
+    notice("Send failed: " + err.message, true);
+  } finally {
+    button.disabled = false;
+    refresh();
+  }
+});
+
+if (token) start();
+else signOut("");
@@ -0,0 +1,118 @@
+// Package bundle reads and writes portable RelayOps bundles.
+//
+// A bundle is a JSONL stream. The first line is a header; every following line is a
+// single record (scope, message, external ref, backend state or attempt). Records for a
+// message always follow the message record itself, so a bundle can be imported in one pass.
+package bundle
+
+import (
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+const (
+	// Format identifies a RelayOps bundle in the header line.
+	Format = "relayops-bundle"
+	// Version is bumped whenever a record shape changes incompatibly.
+	Version = 1
+)
+
+const (
+	TypeHeader       = "header"
+	TypeScope        = "scope"
+	TypeMessage      = "message"
+	TypeExternalRef  = "external_ref"
+	TypeBackendState = "backend_state"
+	TypeAttempt      = "attempt"
+)
+
+// Record is one line of a bundle. Exactly one payload field is set, matching Type.
+type Record struct {
+	Type         string        `json:"type"`
+	Header       *Header       `json:"header,omitempty"`
+	Scope        *Scope        `json:"scope,omitempty"`
+	Message      *Message      `json:"message,omitempty"`
+	ExternalRef  *ExternalRef  `json:"external_ref,omitempty"`
+	BackendState *BackendState `json:"backend_state,omitempty"`
+	Attempt      *Attempt      `json:"attempt,omitempty"`
+}
+
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Filter    Filter    `json:"filter"`
+}
+
+// Filter records which subset of the store was exported.
+type Filter struct {
+	Scope string     `json:"scope,omitempty"`
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	Tag   string     `json:"tag,omitempty"`
+}
+
+type Scope struct {
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	Note      string    `json:"note,omitempty"`
+}
+
+type Address struct {
+	Callsign string `json:"callsign,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+type Message struct {
+	ID        string             `json:"id"`
+	Subject   string             `json:"subject"`
+	Body      string             `json:"body"`
+	From      Address            `json:"from"`
+	To        []Address          `json:"to"`
+	Tags      []string           `json:"tags"`
+	Status    core.MessageStatus `json:"status"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	SentAt    *time.Time         `json:"sent_at,omitempty"`
+	LastError string             `json:"last_error,omitempty"`
+	Meta      core.MessageMeta   `json:"meta"`
+
+	Attachments []core.Attachment `json:"attachments,omitempty"`
+}
+
+type ExternalRef struct {
+	MessageID  string `json:"message_id"`
+	Backend    string `json:"backend"`
+	ExternalID string `json:"external_id"`
+	Scope      string `json:"scope"`
+	MetaJSON   string `json:"meta_json,omitempty"`
+}
+
+type BackendState struct {
+	MessageID string    `json:"message_id"`
+	Backend   string    `json:"backend"`
+	Folder    string    `json:"folder"`
+	State     string    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExtraJSON string    `json:"extra_json,omitempty"`
+}
+
+type Attempt struct {
+	ID         string    `json:"id"`
+	MessageID  string    `json:"message_id"`
+	Backend    string    `json:"backend"`
+	ExternalID string    `json:"external_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func fromCoreAddress(a core.Address) Address {
+	return Address{Callsign: a.Callsign, Email: a.Email}
+}
+
+func (a Address) toCore() core.Address {
+	return core.Address{Callsign: a.Callsign, Email: a.Email}
+}
@@ -0,0 +1,230 @@
+package bundle_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/bundle"
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+// openStoreAt opens a store backed by its own DB file under a temp HOME.
+func openStoreAt(t *testing.T, ctx context.Context, dbPath string) *store.Store {
+	t.Helper()
+	_ = os.Setenv("RELAYOPS_DB", dbPath)
+	t.Cleanup(func() { _ = os.Unsetenv("RELAYOPS_DB") })
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open(%s): %v", dbPath, err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func TestExportImportRoundTripIsIdempotent(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.Setenv("HOME", tmp)
+	_ = os.Setenv("USERPROFILE", tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src := openStoreAt(t, ctx, filepath.Join(tmp, "src.db"))
+
+	if err := src.CreateScope(ctx, "AE4OK@general", "home station"); err != nil {
+		t.Fatalf("CreateScope: %v", err)
+	}
+
+	tagged := core.NewMessage("Check-in", "AE4OK here")
+	tagged.Tags = []string{"winlink_wednesday"}
+	tagged.To = []core.Address{{Callsign: "N0NET"}}
+	other := core.NewMessage("Other", "not exported")
+	for _, m := range []*core.Message{tagged, other} {
+		if err := src.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if err := src.UpsertExternalRef(ctx, tagged.ID, "pat", "MID123", "AE4OK@general", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	if err := src.UpsertBackendState(ctx, tagged.ID, "pat", "Sent", "Sent", `{"mid":"MID123"}`); err != nil {
+		t.Fatalf("UpsertBackendState: %v", err)
+	}
+	if err := src.RecordAttempt(ctx, store.Attempt{MessageID: tagged.ID, Backend: "pat", ExternalID: "MID123", Outcome: "sent"}); err != nil {
+		t.Fatalf("RecordAttempt: %v", err)
+	}
+
+	var buf bytes.Buffer
+	exp, err := bundle.Export(ctx, src, &buf, store.MessageFilter{Tag: "winlink_wednesday"})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if exp.Messages != 1 || exp.ExternalRefs != 1 || exp.BackendStates != 1 || exp.Attempts != 1 {
+		t.Fatalf("unexpected export report: %+v", exp)
+	}
+
+	dst := openStoreAt(t, ctx, filepath.Join(tmp, "dst.db"))
+
+	rep, err := bundle.Import(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if rep.Created != 1 || rep.Errors != 0 {
+		t.Fatalf("first import: %+v", rep)
+	}
+
+	rep, err = bundle.Import(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Import (again): %v", err)
+	}
+	if rep.Created != 0 || rep.Existing != 1 || rep.Errors != 0 {
+		t.Fatalf("second import should be a no-op: %+v", rep)
+	}
+
+	got, found, err := dst.GetMessage(ctx, tagged.ID)
+	if err != nil || !found {
+		t.Fatalf("GetMessage: found=%v err=%v", found, err)
+	}
+	if got.Subject != "Check-in" || len(got.To) != 1 || got.To[0].Callsign != "N0NET" {
+		t.Fatalf("message did not round-trip: %+v", got)
+	}
+	attempts, err := dst.ListAttempts(ctx, tagged.ID)
+	if err != nil {
+		t.Fatalf("ListAttempts: %v", err)
+	}
+	if len(attempts) != 1 {
+		t.Fatalf("expected 1 attempt after two imports, got %d", len(attempts))
+	}
+	if ok, _ := dst.ScopeExists(ctx, "AE4OK@general"); !ok {
+		t.Fatalf("expected scope to be imported")
+	}
+}
+
+func TestImportMergesByExternalRef(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.Setenv("HOME", tmp)
+	_ = os.Setenv("USERPROFILE", tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src := openStoreAt(t, ctx, filepath.Join(tmp, "src.db"))
+	remote := core.NewMessage("Same traffic", "body")
+	if err := src.SaveMessage(ctx, remote); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := src.UpsertExternalRef(ctx, remote.ID, "winlink", "ABC123", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := bundle.Export(ctx, src, &buf, store.MessageFilter{}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	// The destination imported the same Winlink message independently, under its own ID.
+	dst := openStoreAt(t, ctx, filepath.Join(tmp, "dst.db"))
+	local := core.NewMessage("Same traffic", "body")
+	if err := dst.SaveMessage(ctx, local); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := dst.UpsertExternalRef(ctx, local.ID, "winlink", "ABC123", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+
+	rep, err := bundle.Import(ctx, dst, &buf)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if rep.Merged != 1 || rep.Created != 0 {
+		t.Fatalf("expected merge by external ref, got %+v", rep)
+	}
+	if _, found, _ := dst.GetMessage(ctx, remote.ID); found {
+		t.Fatalf("remote ID should not have been created locally")
+	}
+}
+
+func TestImportReportsRejectedRecords(t *testing.T) {
+	tmp := t.TempDir()
+	_ = os.Setenv("HOME", tmp)
+	_ = os.Setenv("USERPROFILE", tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dst := openStoreAt(t, ctx, filepath.Join(tmp, "dst.db"))
+	in := `{"type":"header","header":{"format":"` + bundle.Format + `","version":1}}
+{"type":"external_ref","external_ref":{"message_id":"ORPHAN","backend":"pat","external_id":"MIDX"}}
+{"type":"message","message":{"subject":"no id"}}
+{"type":"message","message":{"id":"01TRUNC","subj
+{"type":"message","message":{"id":"01KEEP","subject":"kept","tags":[]}}
+{"type":"attempt","attempt":{"id":"A1","message_id":"OTHER","backend":"pat"}}
+`
+	rep, err := bundle.Import(ctx, dst, bytes.NewReader([]byte(in)))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if rep.Created != 1 || rep.Errors != 4 || len(rep.Failures) != 4 {
+		t.Fatalf("report = %+v", rep)
+	}
+	for i, want := range []struct {
+		line int
+		id   string
+	}{{2, "ORPHAN"}, {3, ""}, {4, ""}, {6, "OTHER"}} {
+		f := rep.Failures[i]
+		if f.Line != want.line || f.ID != want.id || f.Err == "" {
+			t.Fatalf("failure %d = %+v, want line %d id %q", i, f, want.line, want.id)
+		}
+	}
+}
+
+func TestExportScopeIncludesUnsentMessages(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("USERPROFILE", tmp)
+	t.Setenv("RELAYOPS_CALLSIGN", "")
+	t.Setenv("RELAYOPS_STATION", "")
+	t.Setenv("RELAYOPS_SCOPE", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	st := openStoreAt(t, ctx, filepath.Join(tmp, "src.db"))
+	sent := core.NewMessage("Sent", "filed by its ref")
+	draft := core.NewMessage("Draft", "from AE4OK, not sent yet")
+	draft.From = core.Address{Callsign: "AE4OK"}
+	foreign := core.NewMessage("Foreign", "from another station")
+	foreign.From = core.Address{Callsign: "W1AW"}
+	elsewhere := core.NewMessage("Elsewhere", "from AE4OK but filed in another scope")
+	elsewhere.From = core.Address{Callsign: "AE4OK"}
+	for _, m := range []*core.Message{sent, draft, foreign, elsewhere} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if err := st.UpsertExternalRef(ctx, sent.ID, "pat", "MID1", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	if err := st.UpsertExternalRef(ctx, elsewhere.ID, "pat", "MID2", "AE4OK@eoc", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep, err := bundle.Export(ctx, st, &buf, store.MessageFilter{Scope: "AE4OK"})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if rep.Messages != 2 {
+		t.Fatalf("exported %d messages, want the sent one and the draft:\n%s", rep.Messages, buf.String())
+	}
+	for _, m := range []*core.Message{sent, draft} {
+		if !bytes.Contains(buf.Bytes(), []byte(m.ID)) {
+			t.Fatalf("bundle lacks %q", m.Subject)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package bundle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+// ExportReport summarizes what Export wrote.
+type ExportReport struct {
+	Scopes        int
+	Messages      int
+	ExternalRefs  int
+	BackendStates int
+	Attempts      int
+}
+
+// Export writes the messages matching f, plus their external refs, backend state and
+// attempts, to w as a versioned JSONL bundle. With f.Scope, messages that have no
+// external ref yet (drafts, queued mail) are included when they are from an identity
+// in that scope, the scope send would file them under.
+func Export(ctx context.Context, st *store.Store, w io.Writer, f store.MessageFilter) (*ExportReport, error) {
+	if st == nil {
+		return nil, fmt.Errorf("Export: store is nil")
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	hdr := &Header{Format: Format, Version: Version, CreatedAt: time.Now().UTC(), Filter: Filter{Scope: f.Scope, Tag: f.Tag}}
+	if !f.Since.IsZero() {
+		t := f.Since.UTC()
+		hdr.Filter.Since = &t
+	}
+	if !f.Until.IsZero() {
+		t := f.Until.UTC()
+		hdr.Filter.Until = &t
+	}
+	if err := enc.Encode(Record{Type: TypeHeader, Header: hdr}); err != nil {
+		return nil, fmt.Errorf("Export: write header: %w", err)
+	}
+
+	report := &ExportReport{}
+
+	scopes, err := st.ListScopes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Export: %w", err)
+	}
+	for _, sc := range scopes {
+		if f.Scope != "" && sc.Scope != f.Scope {
+			continue
+		}
+		rec := Record{Type: TypeScope, Scope: &Scope{Scope: sc.Scope, CreatedAt: sc.CreatedAt.UTC(), Note: sc.Note}}
+		if err := enc.Encode(rec); err != nil {
+			return report, fmt.Errorf("Export: write scope: %w", err)
+		}
+		report.Scopes++
+	}
+
+	msgs, err := st.QueryMessages(ctx, f)
+	if err != nil {
+		return report, fmt.Errorf("Export: %w", err)
+	}
+	if f.Scope != "" {
+		unfiled := f
+		unfiled.Scope, unfiled.NoRefs = "", true
+		more, err := st.QueryMessages(ctx, unfiled)
+		if err != nil {
+			return report, fmt.Errorf("Export: %w", err)
+		}
+		for _, m := range more {
+			scope, err := st.IdentityScope(ctx, m.From.Callsign)
+			if err != nil {
+				return report, fmt.Errorf("Export: %w", err)
+			}
+			if scope == f.Scope {
+				msgs = append(msgs, m)
+			}
+		}
+		sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	}
+	for _, m := range msgs {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		default:
+		}
+
+		bm := &Message{
+			ID:        m.ID,
+			Subject:   m.Subject,
+			Body:      m.Body,
+			From:      fromCoreAddress(m.From),
+			Tags:      m.Tags,
+			Status:    m.Status,
+			CreatedAt: m.CreatedAt.UTC(),
+			UpdatedAt: m.UpdatedAt.UTC(),
+			SentAt:    m.SentAt,
+			LastError: m.LastError,
+			Meta:      m.Meta,
+
+			Attachments: m.Attachments,
+		}
+		for _, a := range m.To {
+			bm.To = append(bm.To, fromCoreAddress(a))
+		}
+		if err := enc.Encode(Record{Type: TypeMessage, Message: bm}); err != nil {
+			return report, fmt.Errorf("Export: write message: %w", err)
+		}
+		report.Messages++
+
+		refs, err := st.ListExternalRefs(ctx, m.ID)
+		if err != nil {
+			return report, fmt.Errorf("Export: %w", err)
+		}
+		for _, r := range refs {
+			rec := Record{Type: TypeExternalRef, ExternalRef: &ExternalRef{
+				MessageID: r.MessageID, Backend: r.Backend, ExternalID: r.ExternalID, Scope: r.Scope, MetaJSON: r.MetaJSON,
+			}}
+			if err := enc.Encode(rec); err != nil {
+				return report, fmt.Errorf("Export: write external ref: %w", err)
+			}
+			report.ExternalRefs++
+		}
+
+		states, err := st.ListBackendState(ctx, m.ID)
+		if err != nil {
+			return report, fmt.Errorf("Export: %w", err)
+		}
+		for _, b := range states {
+			rec := Record{Type: TypeBackendState, BackendState: &BackendState{
+				MessageID: b.MessageID, Backend: b.Backend, Folder: b.Folder, State: b.State, UpdatedAt: b.UpdatedAt.UTC(), ExtraJSON: b.ExtraJSON,
+			}}
+			if err := enc.Encode(rec); err != nil {
+				return report, fmt.Errorf("Export: write backend state: %w", err)
+			}
+			report.BackendStates++
+		}
+
+		attempts, err := st.ListAttempts(ctx, m.ID)
+		if err != nil {
+			return report, fmt.Errorf("Export: %w", err)
+		}
+		for _, a := range attempts {
+			rec := Record{Type: TypeAttempt, Attempt: &Attempt{
+				ID: a.ID, MessageID: a.MessageID, Backend: a.Backend, ExternalID: a.ExternalID,
+				StartedAt: a.StartedAt.UTC(), FinishedAt: a.FinishedAt.UTC(), Outcome: a.Outcome, Error: a.Error,
+			}}
+			if err := enc.Encode(rec); err != nil {
+				return report, fmt.Errorf("Export: write attempt: %w", err)
+			}
+			report.Attempts++
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return report, fmt.Errorf("Export: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,274 @@
+package bundle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+// ImportReport summarizes what Import merged into the store.
+type ImportReport struct {
+	Scanned  int // message records read
+	Created  int // messages new to this store
+	Existing int // messages already present by ID
+	Merged   int // messages matched to a local message through an external ref
+	Errors   int
+	Failures []RecordError // one per error, in bundle order
+}
+
+// RecordError is a bundle record that could not be imported.
+type RecordError struct {
+	Line int    // line in the bundle; the header is line 1
+	ID   string // message the record belongs to (the scope for a scope record), if known
+	Err  string
+}
+
+func (r *ImportReport) fail(line int, id string, err error) {
+	r.Errors++
+	r.Failures = append(r.Failures, RecordError{Line: line, ID: id, Err: err.Error()})
+}
+
+// group is a message record and the records that belong to it, with their lines.
+type group struct {
+	msg      *Message
+	line     int
+	refs     []lineRef
+	states   []lineState
+	attempts []lineAttempt
+}
+
+type lineRef struct {
+	line int
+	*ExternalRef
+}
+
+type lineState struct {
+	line int
+	*BackendState
+}
+
+type lineAttempt struct {
+	line int
+	*Attempt
+}
+
+// Import merges a bundle into st. It is idempotent: messages are matched by ID first and
+// then by any of their external refs, and nothing already present is duplicated.
+// Local rows win; the bundle only fills in what the store does not have yet. Records that
+// cannot be imported, including lines that are not JSON, are listed in the report's
+// Failures and the rest of the bundle is still imported.
+func Import(ctx context.Context, st *store.Store, r io.Reader) (*ImportReport, error) {
+	if st == nil {
+		return nil, fmt.Errorf("Import: store is nil")
+	}
+
+	sc := bufio.NewScanner(r)
+	// Message bodies can be large; allow long lines.
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("Import: read header: %w", err)
+		}
+		return nil, fmt.Errorf("Import: empty bundle")
+	}
+	var first Record
+	if err := json.Unmarshal(sc.Bytes(), &first); err != nil {
+		return nil, fmt.Errorf("Import: decode header: %w", err)
+	}
+	if first.Type != TypeHeader || first.Header == nil || first.Header.Format != Format {
+		return nil, fmt.Errorf("Import: not a %s stream", Format)
+	}
+	if first.Header.Version > Version {
+		return nil, fmt.Errorf("Import: bundle version %d is newer than supported version %d", first.Header.Version, Version)
+	}
+
+	report := &ImportReport{}
+	var cur *group
+	flush := func() {
+		if cur != nil {
+			importGroup(ctx, st, cur, report)
+			cur = nil
+		}
+	}
+
+	line := 1
+	for sc.Scan() {
+		line++
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		default:
+		}
+
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			report.fail(line, "", fmt.Errorf("decode: %w", err))
+			continue
+		}
+
+		switch rec.Type {
+		case TypeScope:
+			if rec.Scope == nil || rec.Scope.Scope == "" {
+				report.fail(line, "", fmt.Errorf("scope record without a scope"))
+				continue
+			}
+			if err := st.CreateScope(ctx, rec.Scope.Scope, rec.Scope.Note); err != nil {
+				report.fail(line, rec.Scope.Scope, err)
+			}
+		case TypeMessage:
+			flush()
+			if rec.Message == nil || rec.Message.ID == "" {
+				report.fail(line, "", fmt.Errorf("message record without an id"))
+				continue
+			}
+			report.Scanned++
+			cur = &group{msg: rec.Message, line: line}
+		case TypeExternalRef:
+			if rec.ExternalRef == nil {
+				report.fail(line, "", fmt.Errorf("empty external_ref record"))
+				continue
+			}
+			if cur == nil || rec.ExternalRef.MessageID != cur.msg.ID {
+				report.fail(line, rec.ExternalRef.MessageID, fmt.Errorf("external_ref does not follow its message"))
+				continue
+			}
+			cur.refs = append(cur.refs, lineRef{line, rec.ExternalRef})
+		case TypeBackendState:
+			if rec.BackendState == nil {
+				report.fail(line, "", fmt.Errorf("empty backend_state record"))
+				continue
+			}
+			if cur == nil || rec.BackendState.MessageID != cur.msg.ID {
+				report.fail(line, rec.BackendState.MessageID, fmt.Errorf("backend_state does not follow its message"))
+				continue
+			}
+			cur.states = append(cur.states, lineState{line, rec.BackendState})
+		case TypeAttempt:
+			if rec.Attempt == nil {
+				report.fail(line, "", fmt.Errorf("empty attempt record"))
+				continue
+			}
+			if cur == nil || rec.Attempt.MessageID != cur.msg.ID {
+				report.fail(line, rec.Attempt.MessageID, fmt.Errorf("attempt does not follow its message"))
+				continue
+			}
+			cur.attempts = append(cur.attempts, lineAttempt{line, rec.Attempt})
+		default:
+			// Unknown record types from newer minor revisions are skipped.
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return report, fmt.Errorf("Import: %w", err)
+	}
+	flush()
+
+	return report, nil
+}
+
+func importGroup(ctx context.Context, st *store.Store, g *group, report *ImportReport) {
+	localID, how, err := resolveLocalID(ctx, st, g)
+	if err != nil {
+		report.fail(g.line, g.msg.ID, err)
+		return
+	}
+
+	switch how {
+	case "id":
+		report.Existing++
+	case "ref":
+		report.Merged++
+	default:
+		m := g.msg
+		msg := &core.Message{
+			ID:        m.ID,
+			Subject:   m.Subject,
+			Body:      m.Body,
+			From:      m.From.toCore(),
+			Tags:      m.Tags,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+			Status:    m.Status,
+			SentAt:    m.SentAt,
+			LastError: m.LastError,
+			Meta:      m.Meta,
+
+			Attachments: m.Attachments,
+		}
+		if msg.Tags == nil {
+			msg.Tags = []string{}
+		}
+		for _, a := range m.To {
+			msg.To = append(msg.To, a.toCore())
+		}
+		if err := st.SaveMessage(ctx, msg); err != nil {
+			report.fail(g.line, m.ID, err)
+			return
+		}
+		report.Created++
+	}
+
+	for _, r := range g.refs {
+		if _, found, err := st.GetMessageIDByExternalRef(ctx, r.Backend, r.ExternalID, r.Scope); err != nil {
+			report.fail(r.line, r.MessageID, err)
+			continue
+		} else if found {
+			// Never re-point an existing local ref at a different message.
+			continue
+		}
+		if r.Scope != "" {
+			if err := st.CreateScope(ctx, r.Scope, "auto-created by bundle import"); err != nil {
+				report.fail(r.line, r.MessageID, err)
+				continue
+			}
+		}
+		if err := st.UpsertExternalRef(ctx, localID, r.Backend, r.ExternalID, r.Scope, r.MetaJSON); err != nil {
+			report.fail(r.line, r.MessageID, err)
+		}
+	}
+
+	if how == "" {
+		for _, b := range g.states {
+			if err := st.UpsertBackendState(ctx, localID, b.Backend, b.Folder, b.State, b.ExtraJSON); err != nil {
+				report.fail(b.line, b.MessageID, err)
+			}
+		}
+	}
+
+	for _, a := range g.attempts {
+		err := st.RecordAttempt(ctx, store.Attempt{
+			ID: a.ID, MessageID: localID, Backend: a.Backend, ExternalID: a.ExternalID,
+			StartedAt: a.StartedAt, FinishedAt: a.FinishedAt, Outcome: a.Outcome, Error: a.Error,
+		})
+		if err != nil {
+			report.fail(a.line, a.MessageID, err)
+		}
+	}
+}
+
+// resolveLocalID finds the local message a bundle group corresponds to.
+// how is "id" when the message ID already exists, "ref" when one of its external refs
+// already points at a local message, and "" when the message is new.
+func resolveLocalID(ctx context.Context, st *store.Store, g *group) (string, string, error) {
+	if _, found, err := st.GetMessage(ctx, g.msg.ID); err != nil {
+		return "", "", err
+	} else if found {
+		return g.msg.ID, "id", nil
+	}
+
+	for _, r := range g.refs {
+		id, found, err := st.GetMessageIDByExternalRef(ctx, r.Backend, r.ExternalID, r.Scope)
+		if err != nil {
+			return "", "", err
+		}
+		if found {
+			return id, "ref", nil
+		}
+	}
+	return g.msg.ID, "", nil
+}
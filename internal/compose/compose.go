@@ -0,0 +1,198 @@
+// Package compose builds draft messages from the ways RelayOps can write one: plain
+// subject/body, a message template, a Winlink form or an NTS radiogram. The CLI and the
+// HTTP API share it so a draft means the same thing wherever it was composed.
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/forms"
+	"github.com/4current/relayops/internal/radiogram"
+	"github.com/4current/relayops/internal/templates"
+)
+
+// Request describes a draft. Exactly one of Subject/Body, Template, Form or Radiogram
+// selects the content; Vars feed the template, form fields or radiogram fields.
+type Request struct {
+	Subject   string            `json:"subject,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Template  string            `json:"template,omitempty"`
+	Form      string            `json:"form,omitempty"`
+	Radiogram bool              `json:"radiogram,omitempty"`
+	Vars      map[string]string `json:"vars,omitempty"`
+
+	From    string   `json:"from,omitempty"` // a callsign or registered tactical address; default the station callsign
+	To      []string `json:"to,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Session string   `json:"session,omitempty"` // winlink (default), radio_only, post_office, p2p
+	Allow   []string `json:"allow,omitempty"`
+	Prefer  []string `json:"prefer,omitempty"`
+}
+
+// Build renders the request into an unsaved draft. Missing template variables, form
+// fields and invalid radiograms are reported here, before anything is stored.
+func Build(ctx context.Context, r Request) (*core.Message, error) {
+	kinds := 0
+	for _, set := range []bool{r.Radiogram, strings.TrimSpace(r.Form) != "", strings.TrimSpace(r.Template) != "", r.Subject != "" || r.Body != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return nil, fmt.Errorf("use only one of radiogram, form, template or subject/body")
+	}
+
+	var msg *core.Message
+	var err error
+	switch {
+	case r.Radiogram:
+		msg, err = Radiogram(r.Vars)
+	case strings.TrimSpace(r.Form) != "":
+		msg, err = Form(r.Form, r.Vars)
+	case strings.TrimSpace(r.Template) != "":
+		var out *templates.Rendered
+		if out, err = Template(ctx, r.Template, r.Vars); err == nil {
+			msg = core.NewMessage(out.Subject, out.Body)
+			for _, t := range out.To {
+				msg.To = append(msg.To, core.ParseAddress(t))
+			}
+			msg.Tags = append(msg.Tags, out.Tags...)
+		}
+	default:
+		if strings.TrimSpace(r.Subject) == "" || strings.TrimSpace(r.Body) == "" {
+			return nil, fmt.Errorf("a subject and body, template, form or radiogram is required")
+		}
+		msg = core.NewMessage(r.Subject, r.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if from := strings.ToUpper(strings.TrimSpace(r.From)); from != "" {
+		msg.From = core.Address{Callsign: from}
+	}
+	for _, t := range r.To {
+		if t = strings.TrimSpace(t); t != "" {
+			msg.To = append(msg.To, core.ParseAddress(t))
+		}
+	}
+	for _, t := range r.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			msg.Tags = append(msg.Tags, t)
+		}
+	}
+	// The active station profile supplies the policy the request leaves out.
+	if len(r.Allow) == 0 {
+		r.Allow = envList("RELAYOPS_ALLOW")
+	}
+	if len(r.Prefer) == 0 {
+		r.Prefer = envList("RELAYOPS_PREFER")
+	}
+	if strings.TrimSpace(r.Session) == "" {
+		r.Session = os.Getenv("RELAYOPS_SESSION")
+	}
+	if len(r.Allow) > 0 {
+		msg.Meta.Transport.Allowed = Modes(r.Allow)
+	}
+	if len(r.Prefer) > 0 {
+		msg.Meta.Transport.Preferred = Modes(r.Prefer)
+	}
+
+	sess := core.SessionMode(strings.ToLower(strings.TrimSpace(r.Session)))
+	switch sess {
+	case "":
+		msg.Meta.Session = core.SessionWinlink
+	case core.SessionWinlink, core.SessionRadioOnly, core.SessionPostOffice, core.SessionP2P:
+		msg.Meta.Session = sess
+	default:
+		return nil, fmt.Errorf("invalid session %q (valid: winlink, radio_only, post_office, p2p)", r.Session)
+	}
+	return msg, nil
+}
+
+// Modes converts mode names to core modes; an empty list means any mode.
+func Modes(names []string) []core.Mode {
+	var out []core.Mode
+	for _, s := range names {
+		s = strings.TrimSpace(strings.ToLower(s))
+		if s == "" {
+			continue
+		}
+		out = append(out, core.Mode(s))
+	}
+	if len(out) == 0 {
+		return []core.Mode{core.ModeAny}
+	}
+	return out
+}
+
+// Template loads a template and renders it with the station, date/time, GPS and user
+// variables. Missing variables are reported before anything is saved.
+func Template(ctx context.Context, name string, user map[string]string) (*templates.Rendered, error) {
+	t, err := templates.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	vars, err := templates.Vars(ctx, t, user)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(vars)
+}
+
+// Form fills in a catalog form from field values and builds the draft.
+func Form(name string, values map[string]string) (*core.Message, error) {
+	def, err := forms.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	vars := templates.StationVars()
+	for k, v := range templates.TimeVars(now) {
+		vars[k] = v
+	}
+	f, err := def.Fill(values, vars, now)
+	if err != nil {
+		return nil, err
+	}
+	return def.Message(f)
+}
+
+// Radiogram builds a radiogram from its fields, defaulting the station of origin, place,
+// time and date from the station profile and the clock, and validates it before
+// anything is saved.
+func Radiogram(values map[string]string) (*core.Message, error) {
+	now := time.Now()
+	station := templates.StationVars()
+	defaults := map[string]string{
+		"origin": station["callsign"],
+		"place":  station["location"],
+		"time":   radiogram.FilingTime(now),
+		"date":   radiogram.FilingDate(now.UTC()),
+	}
+	r, err := radiogram.FromFields(values, defaults)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return core.NewMessage(r.Subject(), r.Format()), nil
+}
+
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,382 @@
+// Package config reads the RelayOps configuration file (~/.relayops/config.yaml), which
+// holds named station profiles:
+//
+//	default_profile: home
+//	profiles:
+//	  home:
+//	    callsign: AE4OK
+//	    grid: EM85
+//	    transports:
+//	      pat: {connect: telnet}
+//	    policy: {prefer: [telnet]}
+//	  eoc:
+//	    callsign: AE4OK
+//	    station: eoc
+//	    scope: AE4OK@eoc
+//	    db: /srv/relayops/eoc.db
+//	    templates_dir: /srv/relayops/templates
+//	    transports:
+//	      pat: {config: /srv/pat/eoc.json, connect: "ardop:///W4EOC-10?freq=7101.5"}
+//	      winlink: {root: "/mnt/c/RMS Express/W4EOC"}
+//	    policy: {session: radio_only, allow: [ardop, vara_hf]}
+//
+// A profile takes effect by exporting its settings as the environment variables the
+// rest of RelayOps already reads (RELAYOPS_CALLSIGN, RELAYOPS_DB, PAT_CONFIG, ...), so
+// every command, the API and the daemon see the same station.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// Environment variables set from a profile. The ones not read elsewhere are read by
+// the CLI for flag defaults and by compose for the policy defaults.
+const (
+	EnvProfile     = "RELAYOPS_PROFILE"
+	EnvCallsign    = "RELAYOPS_CALLSIGN"
+	EnvStation     = "RELAYOPS_STATION"
+	EnvName        = "RELAYOPS_NAME"
+	EnvLocation    = "RELAYOPS_LOCATION"
+	EnvGrid        = "RELAYOPS_GRID"
+	EnvScope       = "RELAYOPS_SCOPE"
+	EnvDB          = "RELAYOPS_DB"
+	EnvTemplates   = "RELAYOPS_TEMPLATES"
+	EnvTransport   = "RELAYOPS_TRANSPORT"
+	EnvPatBinary   = "RELAYOPS_PAT_BIN"
+	EnvPatConfig   = "PAT_CONFIG"
+	EnvPatMailbox  = "RELAYOPS_PAT_MAILBOX"
+	EnvConnect     = "RELAYOPS_CONNECT"
+	EnvWinlinkRoot = "RELAYOPS_WINLINK_ROOT"
+	EnvSession     = "RELAYOPS_SESSION"
+	EnvAllow       = "RELAYOPS_ALLOW"
+	EnvPrefer      = "RELAYOPS_PREFER"
+)
+
+// File is the whole configuration file.
+type File struct {
+	DefaultProfile string              `yaml:"default_profile,omitempty"`
+	Profiles       map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile is one station setup.
+type Profile struct {
+	Callsign     string     `yaml:"callsign,omitempty"`
+	Station      string     `yaml:"station,omitempty"` // e.g. "eoc"; the default scope is CALLSIGN@station
+	Name         string     `yaml:"name,omitempty"`
+	Location     string     `yaml:"location,omitempty"`
+	Grid         string     `yaml:"grid,omitempty"`
+	Scope        string     `yaml:"scope,omitempty"` // overrides the derived default scope
+	DB           string     `yaml:"db,omitempty"`
+	TemplatesDir string     `yaml:"templates_dir,omitempty"`
+	Transports   Transports `yaml:"transports,omitempty"`
+	Policy       Policy     `yaml:"policy,omitempty"`
+}
+
+// Transports holds per-transport parameters.
+type Transports struct {
+	Default string  `yaml:"default,omitempty"` // transport for tui and playbooks: pat or sim
+	Pat     Pat     `yaml:"pat,omitempty"`
+	Winlink Winlink `yaml:"winlink,omitempty"`
+}
+
+type Pat struct {
+	Binary  string `yaml:"binary,omitempty"`
+	Config  string `yaml:"config,omitempty"` // PAT's config.json
+	Mailbox string `yaml:"mailbox,omitempty"`
+	Connect string `yaml:"connect,omitempty"` // connect alias or URL, e.g. telnet
+}
+
+type Winlink struct {
+	Root string `yaml:"root,omitempty"` // Winlink Express callsign directory
+}
+
+// Policy holds the defaults for new messages.
+type Policy struct {
+	Session string   `yaml:"session,omitempty"`
+	Allow   []string `yaml:"allow,omitempty"`
+	Prefer  []string `yaml:"prefer,omitempty"`
+}
+
+// Load reads the file at path. A missing file is an empty configuration.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes a configuration file. Unknown keys are rejected; use Validate for the
+// values.
+func Parse(b []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return &f, nil
+}
+
+// Select picks the profile to use: name if given, else RELAYOPS_PROFILE, else
+// default_profile, else the only profile. It returns a nil profile when the file has
+// none to pick.
+func (f *File) Select(name string) (string, *Profile, error) {
+	if name == "" {
+		name = strings.TrimSpace(os.Getenv(EnvProfile))
+	}
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" && len(f.Profiles) == 1 {
+		for n := range f.Profiles {
+			name = n
+		}
+	}
+	if name == "" {
+		return "", nil, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok || p == nil {
+		return "", nil, fmt.Errorf("no profile %q (have: %s)", name, strings.Join(f.Names(), ", "))
+	}
+	return name, p, nil
+}
+
+// Names returns the profile names, sorted.
+func (f *File) Names() []string {
+	var out []string
+	for n := range f.Profiles {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var (
+	profileNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	callsignRe    = regexp.MustCompile(`^[A-Z0-9/]{3,16}$`)
+	gridRe        = regexp.MustCompile(`^[A-R]{2}[0-9]{2}([A-X]{2}([0-9]{2})?)?$`)
+)
+
+var (
+	validModes    = []string{string(core.ModeAny), string(core.ModeTelnet), string(core.ModePacket), string(core.ModeARDOP), string(core.ModeVARAHF), string(core.ModeVARAFM)}
+	validSessions = []string{string(core.SessionWinlink), string(core.SessionRadioOnly), string(core.SessionPostOffice), string(core.SessionP2P)}
+)
+
+// Validate checks every profile and reports all problems at once. Paths that are set
+// must exist, except db, which is created on first use.
+func (f *File) Validate() error {
+	var errs []error
+	if f.DefaultProfile != "" {
+		if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+			errs = append(errs, fmt.Errorf("default_profile %q is not defined", f.DefaultProfile))
+		}
+	}
+	for _, name := range f.Names() {
+		p := f.Profiles[name]
+		bad := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("profile %s: "+format, append([]any{name}, args...)...))
+		}
+		if !profileNameRe.MatchString(name) {
+			bad("name must be letters, digits, - or _")
+		}
+		if p == nil {
+			bad("is empty")
+			continue
+		}
+		if cs := strings.ToUpper(strings.TrimSpace(p.Callsign)); cs == "" {
+			bad("callsign is required")
+		} else if !callsignRe.MatchString(cs) {
+			bad("invalid callsign %q (expected 3-16 of A-Z, 0-9, /)", p.Callsign)
+		}
+		if p.Grid != "" && !gridRe.MatchString(strings.ToUpper(p.Grid)) {
+			bad("invalid grid %q (expected a Maidenhead locator such as EM85 or EM85ab)", p.Grid)
+		}
+		for _, c := range [][2]string{
+			{"templates_dir", p.TemplatesDir},
+			{"transports.pat.config", p.Transports.Pat.Config},
+			{"transports.winlink.root", p.Transports.Winlink.Root},
+		} {
+			if c[1] == "" {
+				continue
+			}
+			if _, err := os.Stat(ExpandHome(c[1])); err != nil {
+				bad("%s: %v", c[0], err)
+			}
+		}
+		switch p.Transports.Default {
+		case "", "pat", "sim":
+		default:
+			bad("transports.default %q is not pat or sim", p.Transports.Default)
+		}
+		if s := p.Policy.Session; s != "" && !contains(validSessions, s) {
+			bad("policy.session %q is not one of %s", s, strings.Join(validSessions, ", "))
+		}
+		checkModes := func(key string, modes []string) {
+			for _, m := range modes {
+				if !contains(validModes, m) {
+					bad("%s: %q is not one of %s", key, m, strings.Join(validModes, ", "))
+				}
+			}
+		}
+		checkModes("policy.allow", p.Policy.Allow)
+		checkModes("policy.prefer", p.Policy.Prefer)
+	}
+	return errors.Join(errs...)
+}
+
+// Env returns the environment variables p sets, leaving out empty settings.
+func (p *Profile) Env() map[string]string {
+	env := map[string]string{}
+	set := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			env[k] = v
+		}
+	}
+	set(EnvCallsign, strings.ToUpper(p.Callsign))
+	set(EnvStation, p.Station)
+	set(EnvName, p.Name)
+	set(EnvLocation, p.Location)
+	set(EnvGrid, p.Grid)
+	set(EnvScope, p.Scope)
+	set(EnvDB, ExpandHome(p.DB))
+	set(EnvTemplates, ExpandHome(p.TemplatesDir))
+	set(EnvTransport, p.Transports.Default)
+	set(EnvPatBinary, ExpandHome(p.Transports.Pat.Binary))
+	set(EnvPatConfig, ExpandHome(p.Transports.Pat.Config))
+	set(EnvPatMailbox, ExpandHome(p.Transports.Pat.Mailbox))
+	set(EnvConnect, p.Transports.Pat.Connect)
+	set(EnvWinlinkRoot, ExpandHome(p.Transports.Winlink.Root))
+	set(EnvSession, p.Policy.Session)
+	set(EnvAllow, strings.Join(p.Policy.Allow, ","))
+	set(EnvPrefer, strings.Join(p.Policy.Prefer, ","))
+	return env
+}
+
+// Apply exports p's settings. Variables already set in the environment win unless
+// override is true, which is how an explicit --profile beats a stray export.
+func (p *Profile) Apply(override bool) error {
+	for k, v := range p.Env() {
+		if _, set := os.LookupEnv(k); set && !override {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpandHome replaces a leading ~/ with the user's home directory.
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path[1:], "/"))
+		}
+	}
+	return path
+}
+
+// Keys lists the settable profile keys, e.g. "transports.pat.connect".
+func Keys() []string {
+	var out []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if f.Type.Kind() == reflect.Struct {
+				walk(f.Type, prefix+name+".")
+				continue
+			}
+			out = append(out, prefix+name)
+		}
+	}
+	walk(reflect.TypeOf(Profile{}), "")
+	return out
+}
+
+// isList reports whether key holds a list (set from a comma-separated value).
+func isList(key string) bool {
+	t := reflect.TypeOf(Profile{})
+	for _, part := range strings.Split(key, ".") {
+		f, ok := fieldByTag(t, part)
+		if !ok {
+			return false
+		}
+		t = f.Type
+	}
+	return t.Kind() == reflect.Slice
+}
+
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Get returns the value of key in p: a string, or a comma-joined list. Unset keys
+// return "".
+func (p *Profile) Get(key string) (string, error) {
+	if !contains(Keys(), key) {
+		return "", fmt.Errorf("unknown key %q (valid: %s)", key, strings.Join(Keys(), ", "))
+	}
+	v := reflect.ValueOf(*p)
+	for _, part := range strings.Split(key, ".") {
+		f, _ := fieldByTag(v.Type(), part)
+		v = v.FieldByIndex(f.Index)
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ","), nil
+	}
+	return v.String(), nil
+}
+
+// Settings returns every key set in p with its value, in Keys order.
+func (p *Profile) Settings() [][2]string {
+	var out [][2]string
+	for _, k := range Keys() {
+		if v, _ := p.Get(k); v != "" {
+			out = append(out, [2]string{k, v})
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = `# station profiles
+default_profile: home
+profiles:
+  home:
+    callsign: ae4ok
+    grid: EM85
+    transports:
+      pat: {connect: telnet}
+    policy: {prefer: [telnet]}
+  eoc:
+    callsign: AE4OK
+    station: eoc
+    scope: AE4OK@eoc
+    db: ~/eoc.db
+    transports:
+      default: sim
+      pat:
+        mailbox: /srv/pat/mailbox
+        connect: ardop:///W4EOC-10?freq=7101.5 # HF net
+    policy:
+      session: radio_only
+      allow: [ardop, vara_hf]
+`
+
+func TestParseSelectAndEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/op")
+	t.Setenv(EnvProfile, "")
+	f, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	name, p, err := f.Select("")
+	if err != nil || name != "home" || p.Grid != "EM85" {
+		t.Fatalf("default Select = %q %+v %v", name, p, err)
+	}
+	t.Setenv(EnvProfile, "eoc")
+	if name, _, _ = f.Select(""); name != "eoc" {
+		t.Fatalf("RELAYOPS_PROFILE Select = %q", name)
+	}
+	if name, _, _ = f.Select("home"); name != "home" {
+		t.Fatalf("flag Select = %q", name)
+	}
+	if _, _, err := f.Select("portable"); err == nil || !strings.Contains(err.Error(), "eoc, home") {
+		t.Fatalf("unknown profile err = %v", err)
+	}
+
+	env := f.Profiles["eoc"].Env()
+	for k, want := range map[string]string{
+		EnvCallsign:   "AE4OK",
+		EnvStation:    "eoc",
+		EnvScope:      "AE4OK@eoc",
+		EnvDB:         filepath.Join("/home/op", "eoc.db"),
+		EnvTransport:  "sim",
+		EnvPatMailbox: "/srv/pat/mailbox",
+		EnvConnect:    "ardop:///W4EOC-10?freq=7101.5",
+		EnvSession:    "radio_only",
+		EnvAllow:      "ardop,vara_hf",
+	} {
+		if env[k] != want {
+			t.Errorf("%s = %q, want %q", k, env[k], want)
+		}
+	}
+	if _, ok := env[EnvGrid]; ok {
+		t.Errorf("unset grid exported: %v", env)
+	}
+	if got := f.Profiles["home"].Env()[EnvCallsign]; got != "AE4OK" {
+		t.Errorf("callsign not uppercased: %q", got)
+	}
+
+	// Single profile without default_profile is selected implicitly; none at all is nil.
+	t.Setenv(EnvProfile, "")
+	one := &File{Profiles: map[string]*Profile{"solo": {Callsign: "W1AW"}}}
+	if name, _, _ := one.Select(""); name != "solo" {
+		t.Fatalf("single profile Select = %q", name)
+	}
+	if name, p, err := (&File{}).Select(""); name != "" || p != nil || err != nil {
+		t.Fatalf("empty Select = %q %v %v", name, p, err)
+	}
+}
+
+func TestApplyKeepsEnvironmentUnlessOverriding(t *testing.T) {
+	p := &Profile{Callsign: "AE4OK", Station: "eoc"}
+	t.Setenv(EnvCallsign, "W1AW")
+	t.Setenv(EnvStation, "")
+	os.Unsetenv(EnvStation)
+
+	if err := p.Apply(false); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(EnvCallsign) != "W1AW" || os.Getenv(EnvStation) != "eoc" {
+		t.Fatalf("default apply: callsign=%q station=%q", os.Getenv(EnvCallsign), os.Getenv(EnvStation))
+	}
+	if err := p.Apply(true); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(EnvCallsign) != "AE4OK" {
+		t.Fatalf("explicit apply: callsign=%q", os.Getenv(EnvCallsign))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if _, err := Parse([]byte("profiles:\n  home:\n    callsine: AE4OK\n")); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+
+	f, err := Parse([]byte(`
+default_profile: field
+profiles:
+  home:
+    grid: XX99
+    templates_dir: /nonexistent/templates
+    transports: {default: carrier-pigeon}
+    policy: {session: smoke, allow: [ardop, cw]}
+  bad name:
+    callsign: "!!"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Validate()
+	if err == nil {
+		t.Fatal("expected problems")
+	}
+	for _, want := range []string{
+		`default_profile "field" is not defined`,
+		"profile home: callsign is required",
+		`profile home: invalid grid "XX99"`,
+		"profile home: templates_dir:",
+		`profile home: transports.default "carrier-pigeon"`,
+		`profile home: policy.session "smoke"`,
+		`profile home: policy.allow: "cw"`,
+		"profile bad name: name must be",
+		`profile bad name: invalid callsign "!!"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestSetPreservesCommentsAndValidates(t *testing.T) {
+	t.Setenv(EnvProfile, "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(sample), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := Set(path, "eoc", "transports.pat.connect", "vara:///W4EOC-10"); err != nil || name != "eoc" {
+		t.Fatalf("Set connect = %q %v", name, err)
+	}
+	if name, err := Set(path, "", "policy.prefer", "packet, telnet"); err != nil || name != "home" {
+		t.Fatalf("Set prefer = %q %v", name, err)
+	}
+	if _, err := Set(path, "", "grid", ""); err != nil {
+		t.Fatalf("unset grid: %v", err)
+	}
+	if _, err := Set(path, "", "grid", "nowhere"); err == nil {
+		t.Fatal("invalid grid accepted")
+	}
+	if _, err := Set(path, "", "frequency", "7101.5"); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), "# station profiles") {
+		t.Errorf("comment lost:\n%s", b)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.Profiles["eoc"].Get("transports.pat.connect"); v != "vara:///W4EOC-10" {
+		t.Errorf("connect = %q", v)
+	}
+	if v, _ := f.Profiles["home"].Get("policy.prefer"); v != "packet,telnet" {
+		t.Errorf("prefer = %q", v)
+	}
+	if f.Profiles["home"].Grid != "" {
+		t.Errorf("grid not removed: %q", f.Profiles["home"].Grid)
+	}
+
+	// A fresh file gets a "default" profile that becomes the default.
+	fresh := filepath.Join(t.TempDir(), "sub", "config.yaml")
+	if name, err := Set(fresh, "", "callsign", "W1AW"); err != nil || name != "default" {
+		t.Fatalf("fresh Set = %q %v", name, err)
+	}
+	f, err = Load(fresh)
+	if err != nil || f.DefaultProfile != "default" || f.Profiles["default"].Callsign != "W1AW" {
+		t.Fatalf("fresh file = %+v %v", f, err)
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Set changes one key of a profile in the file at path and returns the profile name it
+// wrote to. The edit goes through the YAML tree, so comments and the order of keys
+// survive. key is a profile key from Keys (lists are comma-separated) or
+// "default_profile"; an empty value removes the key.
+//
+// The profile is chosen like Select does. When the file has no profiles yet, one named
+// profile (or "default") is created and made the default. A change that introduces a
+// validation problem is refused and the file is left alone.
+func Set(path, profile, key, value string) (string, error) {
+	old, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	if key != "default_profile" && !contains(Keys(), key) {
+		return "", fmt.Errorf("unknown key %q (valid: default_profile, %s)", key, strings.Join(Keys(), ", "))
+	}
+
+	var doc yaml.Node
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return "", fmt.Errorf("%s: parse config: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%s: top level is not a mapping", path)
+	}
+
+	value = strings.TrimSpace(value)
+	name := ""
+	if key == "default_profile" {
+		setKey(root, []string{key}, scalar(value))
+	} else {
+		name = profile
+		if name == "" {
+			name = strings.TrimSpace(os.Getenv(EnvProfile))
+		}
+		if name == "" {
+			if name, _, err = old.Select(""); err != nil {
+				return "", err
+			}
+		}
+		if name == "" {
+			if len(old.Profiles) > 0 {
+				return "", fmt.Errorf("several profiles and no default_profile; pass --profile (have: %s)", strings.Join(old.Names(), ", "))
+			}
+			name = "default"
+		}
+		if len(old.Profiles) == 0 && old.DefaultProfile == "" {
+			setKey(root, []string{"default_profile"}, scalar(name))
+		}
+		var v *yaml.Node
+		if value != "" {
+			v = scalar(value)
+			if isList(key) {
+				v = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+				for _, item := range strings.Split(value, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						v.Content = append(v.Content, scalar(item))
+					}
+				}
+			}
+		}
+		setKey(root, append([]string{"profiles", name}, strings.Split(key, ".")...), v)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	f, err := Parse(out.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if added := newProblems(old.Validate(), f.Validate()); len(added) > 0 {
+		return "", errors.Join(added...)
+	}
+	return name, writeFile(path, out.Bytes())
+}
+
+func scalar(v string) *yaml.Node {
+	if v == "" {
+		return nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
+
+// setKey sets m[path...] to v, creating mappings on the way; a nil v deletes the key.
+func setKey(m *yaml.Node, path []string, v *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			if v == nil {
+				m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			} else {
+				m.Content[i+1] = v
+			}
+			return
+		}
+		child := m.Content[i+1]
+		if child.Kind != yaml.MappingNode {
+			if v == nil {
+				return
+			}
+			*child = yaml.Node{Kind: yaml.MappingNode}
+		}
+		child.Style = 0
+		setKey(child, path[1:], v)
+		return
+	}
+	if v == nil {
+		return
+	}
+	k := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		m.Content = append(m.Content, k, v)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	m.Content = append(m.Content, k, child)
+	setKey(child, path[1:], v)
+}
+
+// newProblems returns the problems in after that were not already in before.
+func newProblems(before, after error) []error {
+	seen := map[string]bool{}
+	for _, e := range unjoin(before) {
+		seen[e.Error()] = true
+	}
+	var out []error
+	for _, e := range unjoin(after) {
+		if !seen[e.Error()] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
+
+// writeFile replaces path atomically, creating its directory if needed.
+func writeFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
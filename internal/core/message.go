@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,6 +38,15 @@ type Address struct {
 	Email    string
 }
 
+// ParseAddress turns "AE4OK" or "AE4OK@winlink.org" into an Address.
+func ParseAddress(s string) Address {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "@") {
+		return Address{Email: s}
+	}
+	return Address{Callsign: s}
+}
+
 type Message struct {
 	ID        string
 	Subject   string
@@ -50,6 +60,15 @@ type Message struct {
 	UpdatedAt time.Time
 	SentAt    *time.Time
 	LastError string
+	// Attachments travel with the message as B2F/MIME file parts.
+	Attachments []Attachment
+}
+
+// Attachment is a file carried by a message.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data"`
 }
 
 type MessageMeta struct {
@@ -59,6 +78,32 @@ type MessageMeta struct {
 	AutomationProfile string
 	Priority          int
 	Delivery          DeliveryResult `json:"delivery,omitempty"`
+	// Form holds the structured fields of a Winlink form attachment, if the message has one.
+	Form *Form `json:"form,omitempty"`
+}
+
+// Form is a Winlink (RMS Express) form: the parameters that identify it and its field
+// values in document order.
+type Form struct {
+	Name       string            `json:"name"` // e.g. "ICS213"
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Fields     []FormField       `json:"fields"`
+}
+
+// FormField is one form variable.
+type FormField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Field returns the value of the named field, or "".
+func (f *Form) Field(name string) string {
+	for _, fl := range f.Fields {
+		if strings.EqualFold(fl.Name, name) {
+			return fl.Value
+		}
+	}
+	return ""
 }
 
 type DeliveryResult struct {
@@ -84,6 +129,8 @@ const (
 	StatusSent    MessageStatus = "sent"
 	StatusFailed  MessageStatus = "failed"
 	StatusDeleted MessageStatus = "deleted"
+	// StatusArchived is set by retention policies; archived messages are kept but out of the way.
+	StatusArchived MessageStatus = "archived"
 )
 
 func NewMessage(subject, body string) *Message {
@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/transport/pat"
+)
+
+// Config is the daemon's schedule file (~/.relayops/daemon.yaml):
+//
+//	jobs:
+//	  - name: wednesday
+//	    schedule: "0 10 * * WED"      # 10:00 local every Wednesday
+//	    playbook: winlink-wednesday
+//	  - name: packet-poll
+//	    every: 2h
+//	    poll: {connect: packet}
+//	watch:
+//	  - name: pat-inbox               # import mail as pat receives it
+//	    callsign: AE4OK
+type Config struct {
+	Jobs  []Job   `yaml:"jobs"`
+	Watch []Watch `yaml:"watch,omitempty"`
+}
+
+// Job is one scheduled task. Exactly one of Schedule/Every and one of Playbook/Poll
+// must be set.
+type Job struct {
+	Name     string `yaml:"name"`
+	Schedule string `yaml:"schedule,omitempty"` // five-field cron expression, local time
+	Every    string `yaml:"every,omitempty"`    // Go duration, e.g. 2h
+
+	Playbook string            `yaml:"playbook,omitempty"` // file or built-in name
+	Vars     map[string]string `yaml:"vars,omitempty"`
+	Poll     *PollJob          `yaml:"poll,omitempty"`
+
+	// Timeout aborts a run that takes longer (default 30m).
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// PollJob connects once to flush the outbox and fetch mail, then imports what arrived.
+type PollJob struct {
+	Transport string `yaml:"transport,omitempty"` // "pat" (default) or "sim"
+	Connect   string `yaml:"connect,omitempty"`   // pat connect alias or URL, e.g. "packet"
+	Scope     string `yaml:"scope,omitempty"`     // scope for imported messages
+}
+
+// Watch keeps a pat mailbox imported while the daemon runs: new mail is imported as
+// it lands (see pat.WatchMailbox).
+type Watch struct {
+	Name     string `yaml:"name"`
+	Mailbox  string `yaml:"mailbox,omitempty"`  // pat mailbox root (default pat's own)
+	Callsign string `yaml:"callsign,omitempty"` // mailbox callsign (default RELAYOPS_CALLSIGN)
+	Scope    string `yaml:"scope,omitempty"`    // scope for imported messages (default the station's)
+	Debounce string `yaml:"debounce,omitempty"` // Go duration (default 500ms)
+}
+
+func (w Watch) debounce() (time.Duration, error) {
+	if w.Debounce == "" {
+		return pat.DefaultDebounce, nil
+	}
+	d, err := time.ParseDuration(w.Debounce)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad debounce %q", w.Debounce)
+	}
+	return d, nil
+}
+
+// DefaultJobTimeout bounds a job run when the job sets no timeout.
+const DefaultJobTimeout = 30 * time.Minute
+
+// LoadConfig reads and validates a schedule file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseConfig decodes a schedule and validates it. Unknown fields are rejected.
+func ParseConfig(b []byte) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("parse daemon config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks every job, including that its schedule parses and its playbook loads,
+// and reports all problems at once.
+func (c *Config) Validate() error {
+	if len(c.Jobs) == 0 && len(c.Watch) == 0 {
+		return fmt.Errorf("daemon config has no jobs or watches")
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for i, j := range c.Jobs {
+		label := fmt.Sprintf("job %d", i+1)
+		if j.Name != "" {
+			label += " (" + j.Name + ")"
+		}
+		if strings.TrimSpace(j.Name) == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", label))
+		} else if seen[j.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate name", label))
+		}
+		seen[j.Name] = true
+
+		if _, err := j.Trigger(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+		if _, err := j.timeout(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+		if _, err := j.BuildPlaybook(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+	}
+	for i, w := range c.Watch {
+		label := fmt.Sprintf("watch %d", i+1)
+		if w.Name != "" {
+			label += " (" + w.Name + ")"
+		}
+		if strings.TrimSpace(w.Name) == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", label))
+		} else if seen[w.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate name", label))
+		}
+		seen[w.Name] = true
+
+		if _, err := w.debounce(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Trigger parses the job's schedule or interval.
+func (j Job) Trigger() (Schedule, error) {
+	switch {
+	case j.Schedule != "" && j.Every != "":
+		return nil, fmt.Errorf("set schedule or every, not both")
+	case j.Schedule != "":
+		return ParseCron(j.Schedule)
+	case j.Every != "":
+		d, err := time.ParseDuration(j.Every)
+		if err != nil {
+			return nil, fmt.Errorf("every: %w", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("every must be positive")
+		}
+		return Every(d), nil
+	default:
+		return nil, fmt.Errorf("schedule or every is required")
+	}
+}
+
+func (j Job) timeout() (time.Duration, error) {
+	if j.Timeout == "" {
+		return DefaultJobTimeout, nil
+	}
+	d, err := time.ParseDuration(j.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad timeout %q", j.Timeout)
+	}
+	return d, nil
+}
+
+// BuildPlaybook returns what a run of the job executes: the named playbook, or for a
+// poll job a generated transport/connect/receive playbook.
+func (j Job) BuildPlaybook() (*playbook.Playbook, error) {
+	switch {
+	case j.Playbook != "" && j.Poll != nil:
+		return nil, fmt.Errorf("set playbook or poll, not both")
+	case j.Playbook != "":
+		return playbook.Open(j.Playbook)
+	case j.Poll != nil:
+		use := j.Poll.Transport
+		if use == "" {
+			use = "pat"
+		}
+		pb := &playbook.Playbook{
+			Name: "poll:" + j.Name,
+			Steps: []playbook.Step{
+				{Transport: &playbook.TransportStep{Use: use, Connect: j.Poll.Connect}},
+				{Connect: &playbook.ConnectStep{}},
+				{Receive: &playbook.ReceiveStep{Scope: j.Poll.Scope}},
+			},
+		}
+		if err := pb.Validate(); err != nil {
+			return nil, err
+		}
+		return pb, nil
+	default:
+		return nil, fmt.Errorf("playbook or poll is required")
+	}
+}
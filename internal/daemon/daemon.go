@@ -0,0 +1,353 @@
+// Package daemon runs RelayOps unattended: jobs from a schedule file (playbooks or mail
+// polls) fire at cron times or fixed intervals, and watched pat mailboxes are imported
+// as mail lands, while a database lock keeps a second daemon from running against the
+// same store. Every start, job run, watch import and stop is written to the audit
+// trail, which doubles as the daemon's event log.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/pat"
+)
+
+// Audit event kinds written by the daemon. "daemon.*" selects all of them.
+const (
+	EventStarted = "daemon.started"
+	EventJob     = "daemon.job"
+	EventWatch   = "daemon.watch" // a watched mailbox imported mail, or its watch failed
+	EventStopped = "daemon.stopped"
+)
+
+// Job outcomes recorded in EventJob.
+const (
+	OutcomeOK          = "ok"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted" // cancelled by shutdown or the job timeout
+)
+
+// LockName is the store lock held while a daemon runs.
+const LockName = "daemon"
+
+// Defaults for Daemon fields left zero.
+const (
+	DefaultGrace     = 2 * time.Minute
+	DefaultHeartbeat = 30 * time.Second
+)
+
+// RunFunc executes one job run and returns a short detail (e.g. the playbook run id).
+type RunFunc func(ctx context.Context, job Job) (string, error)
+
+// Daemon schedules and runs jobs until its context is cancelled.
+type Daemon struct {
+	Store  *store.Store
+	Config *Config
+	// Out receives one log line per event; nil discards them.
+	Out io.Writer
+	// Grace is how long a running job may continue after shutdown is requested.
+	Grace time.Duration
+	// Heartbeat is how often the lock is refreshed; it is stale after four missed beats.
+	Heartbeat time.Duration
+	// Exec runs a job. Defaults to running its playbook with playbook.Runner.
+	Exec RunFunc
+
+	logMu sync.Mutex // watches log from their own goroutines
+}
+
+type scheduled struct {
+	job   Job
+	sched Schedule
+	next  time.Time
+}
+
+// Run takes the daemon lock and runs due jobs one at a time until ctx is cancelled
+// (e.g. by SIGTERM). A job still running at that point gets Grace to finish before it is
+// cancelled. A run that was missed while an earlier job was busy fires once, late,
+// rather than once per missed slot. Watches run alongside the jobs until shutdown. Run
+// fails with store.ErrLockHeld when another daemon holds the lock.
+func (d *Daemon) Run(ctx context.Context) error {
+	if d.Store == nil || d.Config == nil {
+		return fmt.Errorf("daemon: store and config are required")
+	}
+	if err := d.Config.Validate(); err != nil {
+		return err
+	}
+	grace, beat := d.Grace, d.Heartbeat
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	if beat <= 0 {
+		beat = DefaultHeartbeat
+	}
+
+	bg := store.WithActor(context.WithoutCancel(ctx), "daemon")
+	lock, err := d.Store.AcquireLock(bg, LockName, uuid.NewString(), 4*beat)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Store.ReleaseLock(bg, lock) }()
+
+	now := time.Now()
+	jobs := make([]*scheduled, 0, len(d.Config.Jobs))
+	names := make([]string, 0, len(d.Config.Jobs))
+	for _, j := range d.Config.Jobs {
+		s, _ := j.Trigger()
+		jobs = append(jobs, &scheduled{job: j, sched: s, next: s.Next(now)})
+		names = append(names, j.Name)
+	}
+	started := map[string]any{"pid": os.Getpid(), "host": lock.Host, "jobs": names}
+	if len(d.Config.Watch) > 0 {
+		watched := make([]string, 0, len(d.Config.Watch))
+		for _, w := range d.Config.Watch {
+			watched = append(watched, w.Name)
+		}
+		started["watches"] = watched
+	}
+	d.event(bg, EventStarted, started)
+	for _, s := range jobs {
+		d.logf("%s: next run %s", s.job.Name, when(s.next))
+	}
+
+	watchCtx, stopWatches := context.WithCancel(bg)
+	var watches sync.WaitGroup
+	for _, w := range d.Config.Watch {
+		watches.Add(1)
+		go func() {
+			defer watches.Done()
+			d.runWatch(watchCtx, w)
+		}()
+	}
+
+	lost := make(chan error, 1)
+	beatCtx, stopBeat := context.WithCancel(bg)
+	defer stopBeat()
+	go func() {
+		t := time.NewTicker(beat)
+		defer t.Stop()
+		for {
+			select {
+			case <-beatCtx.Done():
+				return
+			case <-t.C:
+				if err := d.Store.HeartbeatLock(beatCtx, lock); err != nil && beatCtx.Err() == nil {
+					lost <- err
+					return
+				}
+			}
+		}
+	}()
+
+	reason := ""
+	var runErr error
+	for reason == "" {
+		due := earliest(jobs)
+		if due == nil && len(d.Config.Watch) > 0 {
+			// Nothing scheduled; the watches run until shutdown.
+			select {
+			case <-ctx.Done():
+				reason = "shutdown"
+			case runErr = <-lost:
+				reason = "lock lost"
+			}
+			break
+		}
+		if due == nil {
+			reason = "no future runs"
+			break
+		}
+		timer := time.NewTimer(time.Until(due.next))
+		select {
+		case <-ctx.Done():
+			reason = "shutdown"
+		case runErr = <-lost:
+			reason = "lock lost"
+		case <-timer.C:
+			if ctx.Err() != nil {
+				reason = "shutdown"
+				break
+			}
+			d.runJob(ctx, bg, due.job, grace)
+			due.next = due.sched.Next(time.Now())
+			d.logf("%s: next run %s", due.job.Name, when(due.next))
+		}
+		timer.Stop()
+	}
+
+	stopWatches()
+	watches.Wait()
+	d.event(bg, EventStopped, map[string]any{"reason": reason})
+	return runErr
+}
+
+// runWatch imports a watched mailbox until ctx is cancelled. Imports that bring
+// nothing new are not recorded.
+func (d *Daemon) runWatch(ctx context.Context, w Watch) {
+	bg := context.WithoutCancel(ctx)
+	mbox := w.Mailbox
+	if mbox == "" {
+		var err error
+		if mbox, err = pat.MailboxDir(); err != nil {
+			d.event(bg, EventWatch, map[string]any{"watch": w.Name, "outcome": OutcomeFailed, "error": err.Error()})
+			return
+		}
+	}
+	scope := w.Scope
+	if scope == "" {
+		scope = runtime.IdentityScope("")
+	}
+	debounce, _ := w.debounce()
+	d.logf("%s: watching %s", w.Name, mbox)
+	err := pat.WatchMailbox(ctx, d.Store, mbox, w.Callsign, scope, pat.WatchOptions{
+		Debounce: debounce,
+		OnImport: func(rep *pat.ImportReport, err error) {
+			p := map[string]any{"watch": w.Name, "outcome": OutcomeOK}
+			if err != nil {
+				p["outcome"], p["error"] = OutcomeFailed, err.Error()
+			} else if rep.Created+rep.Updated+rep.Errors == 0 {
+				return
+			}
+			if rep != nil {
+				p["created"], p["updated"], p["errors"] = rep.Created, rep.Updated, rep.Errors
+			}
+			d.event(bg, EventWatch, p)
+		},
+	})
+	if err != nil {
+		d.event(bg, EventWatch, map[string]any{"watch": w.Name, "outcome": OutcomeFailed, "error": err.Error()})
+	}
+}
+
+// runJob runs one job to completion. Its context outlives ctx by grace so a shutdown
+// request lets it finish cleanly.
+func (d *Daemon) runJob(ctx, bg context.Context, j Job, grace time.Duration) {
+	timeout, _ := j.timeout()
+	jobCtx, cancel := context.WithTimeout(bg, timeout)
+	defer cancel()
+
+	d.logf("%s: starting", j.Name)
+	started := time.Now()
+	type result struct {
+		detail string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		detail, err := d.exec(jobCtx, j)
+		done <- result{detail, err}
+	}()
+
+	var res result
+	interrupted := false
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		d.logf("%s: shutdown requested; waiting up to %s", j.Name, grace)
+		select {
+		case res = <-done:
+		case <-time.After(grace):
+			cancel()
+			res = <-done
+			interrupted = true
+		}
+	}
+
+	outcome := OutcomeOK
+	switch {
+	case interrupted || (res.err != nil && jobCtx.Err() != nil):
+		outcome = OutcomeInterrupted
+	case res.err != nil:
+		outcome = OutcomeFailed
+	}
+	p := map[string]any{"job": j.Name, "outcome": outcome, "duration": time.Since(started).Round(time.Millisecond).String()}
+	if res.detail != "" {
+		p["detail"] = res.detail
+	}
+	if res.err != nil {
+		p["error"] = res.err.Error()
+	}
+	d.event(bg, EventJob, p)
+}
+
+func (d *Daemon) exec(ctx context.Context, j Job) (string, error) {
+	if d.Exec != nil {
+		return d.Exec(ctx, j)
+	}
+	pb, err := j.BuildPlaybook()
+	if err != nil {
+		return "", err
+	}
+	r := &playbook.Runner{Store: d.Store, Out: d.Out, Vars: j.Vars}
+	res, err := r.Run(ctx, pb)
+	if err != nil {
+		return "", err
+	}
+	detail := "run " + res.RunID
+	if !res.OK() {
+		for _, s := range res.Steps {
+			if s.Outcome == playbook.StepFailed {
+				return detail, fmt.Errorf("step %d (%s): %s", s.Index, s.Name, s.Error)
+			}
+		}
+		return detail, fmt.Errorf("playbook %s failed", pb.Name)
+	}
+	return detail, nil
+}
+
+func (d *Daemon) event(ctx context.Context, kind string, p map[string]any) {
+	b, _ := json.Marshal(p)
+	if err := d.Store.RecordEvent(ctx, store.Event{Kind: kind, Payload: string(b)}); err != nil {
+		d.logf("record %s: %v", kind, err)
+	}
+	d.logf("%s %s", kind, b)
+}
+
+func (d *Daemon) logf(format string, args ...any) {
+	if d.Out == nil {
+		return
+	}
+	d.logMu.Lock()
+	defer d.logMu.Unlock()
+	fmt.Fprintf(d.Out, "%s "+format+"\n", append([]any{time.Now().Format("2006-01-02 15:04:05")}, args...)...)
+}
+
+// NextRuns returns each job's next run time after t, in config order.
+func (c *Config) NextRuns(t time.Time) []time.Time {
+	out := make([]time.Time, len(c.Jobs))
+	for i, j := range c.Jobs {
+		if s, err := j.Trigger(); err == nil {
+			out[i] = s.Next(t)
+		}
+	}
+	return out
+}
+
+func earliest(jobs []*scheduled) *scheduled {
+	var best *scheduled
+	for _, s := range jobs {
+		if s.next.IsZero() {
+			continue
+		}
+		if best == nil || s.next.Before(best.next) {
+			best = s
+		}
+	}
+	return best
+}
+
+func when(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("Mon 2006-01-02 15:04")
+}
@@ -0,0 +1,293 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func setupStore(t *testing.T) (*store.Store, context.Context) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("USERPROFILE", tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st, ctx
+}
+
+func TestCronNext(t *testing.T) {
+	loc := time.Local
+	// Tuesday 2026-10-13 11:30 local.
+	from := time.Date(2026, 10, 13, 11, 30, 0, 0, loc)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 10 * * WED", time.Date(2026, 10, 14, 10, 0, 0, 0, loc)},
+		{"0 10 * * 3", time.Date(2026, 10, 14, 10, 0, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2026, 10, 13, 11, 45, 0, 0, loc)},
+		{"0 */2 * * *", time.Date(2026, 10, 13, 12, 0, 0, 0, loc)},
+		{"30 11 * * *", time.Date(2026, 10, 14, 11, 30, 0, 0, loc)},
+		{"0 0 1 JAN *", time.Date(2027, 1, 1, 0, 0, 0, 0, loc)},
+		{"0 9 1 * MON", time.Date(2026, 10, 19, 9, 0, 0, 0, loc)}, // day-of-month OR weekday
+		{"0 8 * * SAT,SUN", time.Date(2026, 10, 17, 8, 0, 0, 0, loc)},
+		{"0 8 * * 7", time.Date(2026, 10, 18, 8, 0, 0, 0, loc)},
+		{"@daily", time.Date(2026, 10, 14, 0, 0, 0, 0, loc)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(c.want) {
+			t.Errorf("%q: Next = %s, want %s", c.spec, got, c.want)
+		}
+	}
+
+	never, _ := ParseCron("0 0 30 2 *")
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("Feb 30 should never run, got %s", got)
+	}
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * FUNDAY", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestCronNextInOtherZones(t *testing.T) {
+	zone := func(name string) *time.Location {
+		t.Helper()
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("no zone data for %s: %v", name, err)
+		}
+		return loc
+	}
+	cases := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		// Half-hour and odd offsets: the minute-0 slot is still reached.
+		{"0 10 * * WED", time.Date(2026, 10, 13, 11, 30, 0, 0, zone("Asia/Kolkata")), time.Date(2026, 10, 14, 10, 0, 0, 0, zone("Asia/Kolkata"))},
+		{"0 10 * * WED", time.Date(2026, 10, 13, 11, 30, 0, 0, zone("America/St_Johns")), time.Date(2026, 10, 14, 10, 0, 0, 0, zone("America/St_Johns"))},
+		{"0 10 * * WED", time.Date(2026, 10, 13, 11, 30, 0, 0, zone("Australia/Adelaide")), time.Date(2026, 10, 14, 10, 0, 0, 0, zone("Australia/Adelaide"))},
+		{"0 * * * *", time.Date(2026, 10, 13, 11, 30, 0, 0, zone("Asia/Kathmandu")), time.Date(2026, 10, 13, 12, 0, 0, 0, zone("Asia/Kathmandu"))},
+		// DST: 02:00 does not exist on 2026-03-08 in New York, the next hour is 03:00 EDT;
+		// on 2026-11-01 the 03:00 run comes once, an hour later in absolute time.
+		{"0 * * * *", time.Date(2026, 3, 8, 1, 30, 0, 0, zone("America/New_York")), time.Date(2026, 3, 8, 3, 0, 0, 0, zone("America/New_York"))},
+		{"0 3 * * *", time.Date(2026, 11, 1, 0, 30, 0, 0, zone("America/New_York")), time.Date(2026, 11, 1, 3, 0, 0, 0, zone("America/New_York"))},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.spec, err)
+		}
+		if got := s.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%q from %s: Next = %s, want %s", c.spec, c.from, got, c.want)
+		}
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	good := `
+jobs:
+  - name: wednesday
+    schedule: "0 10 * * WED"
+    playbook: winlink-wednesday
+    vars: {callsign: AE4OK}
+  - name: packet-poll
+    every: 2h
+    poll: {connect: packet}
+`
+	c, err := ParseConfig([]byte(good))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	pb, err := c.Jobs[1].BuildPlaybook()
+	if err != nil || len(pb.Steps) != 3 || pb.Steps[0].Transport.Use != "pat" || pb.Steps[0].Transport.Connect != "packet" {
+		t.Fatalf("poll playbook = %+v, %v", pb, err)
+	}
+
+	bad := `
+jobs:
+  - name: a
+    schedule: "0 10 * * WED"
+    every: 1h
+    playbook: winlink-wednesday
+  - name: a
+    playbook: no-such-playbook
+  - every: 1h
+    poll: {}
+    timeout: soon
+`
+	_, err = ParseConfig([]byte(bad))
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"not both", "duplicate name", "schedule or every is required", "no-such-playbook", "name is required", "bad timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q: %v", want, err)
+		}
+	}
+	if _, err := ParseConfig([]byte("jobs:\n  - name: x\n    evry: 1h\n")); err == nil {
+		t.Fatal("expected unknown field to be rejected")
+	}
+}
+
+func TestRunSchedulesLocksAndLogs(t *testing.T) {
+	st, ctx := setupStore(t)
+	cfg := &Config{Jobs: []Job{
+		{Name: "fast", Every: "30ms", Poll: &PollJob{Transport: "sim"}},
+		{Name: "broken", Every: "45ms", Poll: &PollJob{Transport: "sim"}},
+	}}
+
+	var runs atomic.Int32
+	runCtx, stop := context.WithCancel(ctx)
+	d := &Daemon{Store: st, Config: cfg, Heartbeat: 10 * time.Millisecond, Exec: func(ctx context.Context, j Job) (string, error) {
+		runs.Add(1)
+		if j.Name == "broken" {
+			return "", errors.New("no gateway")
+		}
+		return "done", nil
+	}}
+	errc := make(chan error, 1)
+	go func() { errc <- d.Run(runCtx) }()
+
+	// A second daemon on the same store must refuse to start.
+	time.Sleep(20 * time.Millisecond)
+	other := &Daemon{Store: st, Config: cfg, Exec: d.Exec}
+	if err := other.Run(ctx); !errors.Is(err, store.ErrLockHeld) {
+		t.Fatalf("second daemon: expected ErrLockHeld, got %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	stop()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if runs.Load() < 3 {
+		t.Fatalf("expected at least 3 job runs, got %d", runs.Load())
+	}
+	if l, _ := st.GetLock(ctx, LockName); l != nil {
+		t.Fatalf("lock not released: %+v", l)
+	}
+
+	evs, err := st.ListEvents(ctx, store.EventFilter{Kind: "daemon.*"})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(evs) < 5 || evs[0].Kind != EventStarted || evs[len(evs)-1].Kind != EventStopped {
+		t.Fatalf("unexpected event log: %+v", evs)
+	}
+	var ok, failed bool
+	for _, ev := range evs {
+		if ev.Actor != "daemon" {
+			t.Fatalf("event %s has actor %q", ev.Kind, ev.Actor)
+		}
+		ok = ok || strings.Contains(ev.Payload, `"outcome":"ok"`)
+		failed = failed || strings.Contains(ev.Payload, `"error":"no gateway"`)
+	}
+	if !ok || !failed {
+		t.Fatalf("job outcomes missing from event log: %+v", evs)
+	}
+}
+
+func TestShutdownWaitsForRunningJob(t *testing.T) {
+	st, ctx := setupStore(t)
+	cfg := &Config{Jobs: []Job{{Name: "slow", Every: "10ms", Poll: &PollJob{Transport: "sim"}}}}
+
+	started := make(chan struct{})
+	var once atomic.Bool
+	runCtx, stop := context.WithCancel(ctx)
+	d := &Daemon{Store: st, Config: cfg, Grace: 30 * time.Millisecond, Exec: func(ctx context.Context, j Job) (string, error) {
+		if once.CompareAndSwap(false, true) {
+			close(started)
+		}
+		<-ctx.Done() // never finishes on its own
+		return "", ctx.Err()
+	}}
+	errc := make(chan error, 1)
+	go func() { errc <- d.Run(runCtx) }()
+
+	<-started
+	stop()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("daemon did not stop after the grace period")
+	}
+
+	evs, _ := st.ListEvents(ctx, store.EventFilter{Kind: EventJob})
+	if len(evs) != 1 || !strings.Contains(evs[0].Payload, `"outcome":"interrupted"`) {
+		t.Fatalf("expected one interrupted job, got %+v", evs)
+	}
+}
+
+func TestWatchImportsUntilShutdown(t *testing.T) {
+	st, ctx := setupStore(t)
+	mbox := t.TempDir()
+	in := filepath.Join(mbox, "AE4OK", "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ParseConfig([]byte("watch:\n  - name: inbox\n    mailbox: " + mbox + "\n    callsign: AE4OK\n    scope: AE4OK\n    debounce: 20ms\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if _, err := ParseConfig([]byte("watch:\n  - name: inbox\n    debounce: soon\n  - name: inbox\n")); err == nil ||
+		!strings.Contains(err.Error(), "bad debounce") || !strings.Contains(err.Error(), "duplicate name") {
+		t.Fatalf("bad watch config: %v", err)
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	d := &Daemon{Store: st, Config: cfg, Heartbeat: 10 * time.Millisecond}
+	errc := make(chan error, 1)
+	go func() { errc <- d.Run(runCtx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	b2f := "Mid: WATCH0000001\r\nDate: 2025/03/01 14:05\r\nType: Private\r\nFrom: W1AW\r\nTo: AE4OK\r\nSubject: Hello\r\nMbo: W1AW\r\nBody: 5\r\n\r\nhello"
+	if err := os.WriteFile(filepath.Join(in, "WATCH0000001.b2f"), []byte(b2f), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok, _ := st.GetMessageIDByExternalRef(ctx, "pat", "WATCH0000001", "AE4OK"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watched mail was not imported")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stop()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	evs, _ := st.ListEvents(ctx, store.EventFilter{Kind: EventWatch})
+	if len(evs) != 1 || !strings.Contains(evs[0].Payload, `"created":1`) || evs[0].Actor != "daemon" {
+		t.Fatalf("watch events = %+v", evs)
+	}
+}
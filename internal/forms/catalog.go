@@ -0,0 +1,347 @@
+package forms
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/runtime"
+)
+
+//go:embed catalog
+var builtinCatalog embed.FS
+
+// Definition describes a form in the catalog: how to fill it in and how Winlink
+// Express should display it.
+type Definition struct {
+	Name          string     `yaml:"name"`
+	Title         string     `yaml:"title"`
+	DisplayForm   string     `yaml:"display_form"`
+	ReplyTemplate string     `yaml:"reply_template,omitempty"`
+	Subject       string     `yaml:"subject"` // text/template over the field values
+	Fields        []FieldDef `yaml:"fields"`
+
+	Source string `yaml:"-"` // file path, or "built-in"
+}
+
+// FieldDef is one form variable.
+type FieldDef struct {
+	Name      string `yaml:"name"`
+	Label     string `yaml:"label"`
+	Required  bool   `yaml:"required,omitempty"`
+	Multiline bool   `yaml:"multiline,omitempty"`
+	// Default is a text/template rendered with the station and date/time variables.
+	Default string `yaml:"default,omitempty"`
+}
+
+// MissingFieldsError lists required fields that were left empty.
+type MissingFieldsError struct {
+	Form    string
+	Missing []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("form %s: missing required field(s): %s (pass -var field=value)", e.Form, strings.Join(e.Missing, ", "))
+}
+
+// ParseDefinition decodes and checks a catalog entry.
+func ParseDefinition(b []byte) (*Definition, error) {
+	var d Definition
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("parse form definition: %w", err)
+	}
+	if strings.TrimSpace(d.Name) == "" || strings.TrimSpace(d.DisplayForm) == "" {
+		return nil, fmt.Errorf("form definition needs name and display_form")
+	}
+	if len(d.Fields) == 0 {
+		return nil, fmt.Errorf("form %s: no fields", d.Name)
+	}
+	seen := map[string]bool{}
+	for _, f := range d.Fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("form %s: field without name", d.Name)
+		}
+		if seen[strings.ToLower(f.Name)] {
+			return nil, fmt.Errorf("form %s: duplicate field %s", d.Name, f.Name)
+		}
+		seen[strings.ToLower(f.Name)] = true
+		if _, err := template.New(f.Name).Parse(f.Default); err != nil {
+			return nil, fmt.Errorf("form %s: field %s default: %w", d.Name, f.Name, err)
+		}
+	}
+	if _, err := template.New("subject").Parse(d.Subject); err != nil {
+		return nil, fmt.Errorf("form %s: subject: %w", d.Name, err)
+	}
+	return &d, nil
+}
+
+// Catalog returns every known form definition: the built-ins, overridden and extended by
+// the definitions in the catalog directory (~/.relayops/forms).
+func Catalog() ([]*Definition, error) {
+	byName := map[string]*Definition{}
+
+	entries, _ := fs.ReadDir(builtinCatalog, "catalog")
+	for _, e := range entries {
+		b, err := builtinCatalog.ReadFile("catalog/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDefinition(b)
+		if err != nil {
+			return nil, fmt.Errorf("built-in %s: %w", e.Name(), err)
+		}
+		d.Source = "built-in"
+		byName[strings.ToUpper(d.Name)] = d
+	}
+
+	dir, err := runtime.FormsDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range files {
+		if e.IsDir() || !isDefinitionFile(e.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDefinition(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		d.Source = p
+		byName[strings.ToUpper(d.Name)] = d
+	}
+
+	out := make([]*Definition, 0, len(byName))
+	for _, d := range byName {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Lookup finds a definition by name, ignoring case and "-"/"_"/" " ("ics-213" finds ICS213).
+func Lookup(name string) (*Definition, error) {
+	all, err := Catalog()
+	if err != nil {
+		return nil, err
+	}
+	want := normalizeName(name)
+	var names []string
+	for _, d := range all {
+		if normalizeName(d.Name) == want || normalizeName(NameFromDisplayForm(d.DisplayForm)) == want {
+			return d, nil
+		}
+		names = append(names, d.Name)
+	}
+	return nil, fmt.Errorf("no form %q in the catalog (have: %s)", name, strings.Join(names, ", "))
+}
+
+func normalizeName(s string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToUpper(s))
+}
+
+func isDefinitionFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Install copies the definitions in src (a file or a directory) into the catalog
+// directory after checking each one, so a bad update cannot break the catalog.
+func Install(src string) ([]string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isDefinitionFile(e.Name()) {
+				paths = append(paths, filepath.Join(src, e.Name()))
+			}
+		}
+	} else {
+		paths = []string{src}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no form definitions (*.yaml) in %s", src)
+	}
+
+	type pending struct {
+		name string
+		data []byte
+	}
+	var ok []pending
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDefinition(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		ok = append(ok, pending{d.Name, b})
+	}
+
+	dir, err := runtime.FormsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range ok {
+		dst := filepath.Join(dir, p.name+".yaml")
+		tmp := dst + ".tmp"
+		if err := os.WriteFile(tmp, p.data, 0o644); err != nil {
+			return names, err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Remove(tmp)
+			return names, err
+		}
+		names = append(names, p.name)
+	}
+	return names, nil
+}
+
+// Field returns the definition of the named field, or nil.
+func (d *Definition) Field(name string) *FieldDef {
+	for i := range d.Fields {
+		if strings.EqualFold(d.Fields[i].Name, name) {
+			return &d.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Fill builds a form from field values. Fields without a value take their default,
+// rendered with vars; empty required fields are reported together as a
+// *MissingFieldsError. Values for fields the definition does not know are an error.
+func (d *Definition) Fill(values, vars map[string]string, now time.Time) (*core.Form, error) {
+	for k := range values {
+		if d.Field(k) == nil {
+			return nil, fmt.Errorf("form %s has no field %q", d.Name, k)
+		}
+	}
+
+	f := &core.Form{Name: d.Name, Parameters: map[string]string{
+		"xml_file_version":    "1.0",
+		"rms_express_version": "RelayOps",
+		"submission_datetime": now.UTC().Format("20060102150405"),
+		"senders_callsign":    vars["callsign"],
+		"grid_square":         vars["grid"],
+		"display_form":        d.DisplayForm,
+	}}
+	if d.ReplyTemplate != "" {
+		f.Parameters["reply_template"] = d.ReplyTemplate
+	}
+
+	var missing []string
+	for _, fd := range d.Fields {
+		v, ok := lookupFold(values, fd.Name)
+		if !ok && fd.Default != "" {
+			var err error
+			if v, err = renderText(fd.Default, vars); err != nil {
+				return nil, fmt.Errorf("form %s: field %s default: %w", d.Name, fd.Name, err)
+			}
+		}
+		if fd.Required && strings.TrimSpace(v) == "" {
+			missing = append(missing, fd.Name)
+		}
+		f.Fields = append(f.Fields, core.FormField{Name: fd.Name, Value: v})
+	}
+	if len(missing) > 0 {
+		return nil, &MissingFieldsError{Form: d.Name, Missing: missing}
+	}
+	return f, nil
+}
+
+// SubjectFor renders the definition's subject line for a filled-in form.
+func (d *Definition) SubjectFor(f *core.Form) (string, error) {
+	data := map[string]string{}
+	for _, fl := range f.Fields {
+		data[fl.Name] = fl.Value
+	}
+	s, err := renderText(d.Subject, data)
+	if err != nil {
+		return "", fmt.Errorf("form %s: subject: %w", d.Name, err)
+	}
+	if s = strings.TrimSpace(s); s == "" {
+		s = d.Title
+	}
+	return s, nil
+}
+
+// AttachmentName is the file name Winlink Express uses for the form's XML.
+func (d *Definition) AttachmentName() string {
+	return AttachmentPrefix + strings.TrimSuffix(d.DisplayForm, filepath.Ext(d.DisplayForm)) + ".xml"
+}
+
+// Attachment returns f as a Winlink form XML attachment.
+func (d *Definition) Attachment(f *core.Form) core.Attachment {
+	return core.Attachment{Name: d.AttachmentName(), ContentType: "text/xml", Data: Marshal(f)}
+}
+
+func lookupFold(m map[string]string, key string) (string, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// renderText executes a template; unknown variables render as "".
+func renderText(text string, vars map[string]string) (string, error) {
+	t, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(b.String(), "<no value>", ""), nil
+}
+
+// Message builds a draft carrying f: the definition's subject, a readable rendering as
+// the body (for clients without form support) and the XML attachment.
+func (d *Definition) Message(f *core.Form) (*core.Message, error) {
+	subject, err := d.SubjectFor(f)
+	if err != nil {
+		return nil, err
+	}
+	msg := core.NewMessage(subject, Text(f, d))
+	msg.Attachments = []core.Attachment{d.Attachment(f)}
+	msg.Meta.Form = f
+	return msg, nil
+}
@@ -0,0 +1,129 @@
+package forms
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fixture = "testdata/RMS_Express_Form_ICS213_Initial_Viewer.xml"
+
+func TestParseWinlinkExpressXML(t *testing.T) {
+	if !IsFormAttachment(filepath.Base(fixture)) || IsFormAttachment("photo.jpg") {
+		t.Fatal("IsFormAttachment misclassified")
+	}
+	b, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if f.Name != "ICS213" || f.Parameters["senders_callsign"] != "AE4OK" {
+		t.Fatalf("form = %+v", f)
+	}
+	if len(f.Fields) != 9 || f.Fields[0].Name != "inc_name" {
+		t.Fatalf("fields = %+v", f.Fields)
+	}
+	if got := f.Field("message"); got != "Shelter open.\nCapacity 40 & rising." {
+		t.Fatalf("message = %q", got)
+	}
+
+	again, err := Parse(Marshal(f))
+	if err != nil {
+		t.Fatalf("Parse(Marshal): %v", err)
+	}
+	if !reflect.DeepEqual(again, f) {
+		t.Fatalf("round trip changed the form:\n%+v\n%+v", again, f)
+	}
+
+	if _, err := Parse([]byte("<html></html>")); err == nil {
+		t.Fatal("expected an error for a non-form document")
+	}
+}
+
+func TestTextUsesCatalogLabels(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b, _ := os.ReadFile(fixture)
+	f, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Describe(f)
+	for _, want := range []string{"ICS 213 General Message", "To (Name/Position):", "Message:\n    Shelter open.\n    Capacity 40 & rising.", "from AE4OK"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered form lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFillDefaultsAndRequired(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	def, err := Lookup("ics-213")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	vars := map[string]string{"callsign": "AE4OK", "utc_date": "2026-10-14", "utc_time": "23:15Z"}
+	now := time.Date(2026, 10, 14, 23, 15, 0, 0, time.UTC)
+
+	_, err = def.Fill(map[string]string{"to_name": "EOC"}, vars, now)
+	var me *MissingFieldsError
+	if !errors.As(err, &me) || strings.Join(me.Missing, ",") != "subjectline,message" {
+		t.Fatalf("expected missing subjectline,message; got %v", err)
+	}
+	if _, err := def.Fill(map[string]string{"bogus": "x"}, vars, now); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+
+	f, err := def.Fill(map[string]string{"to_name": "EOC", "subjectline": "Status", "message": "All OK"}, vars, now)
+	if err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if f.Field("fm_name") != "AE4OK" || f.Field("mdate") != "2026-10-14" || f.Parameters["submission_datetime"] != "20261014231500" {
+		t.Fatalf("defaults not applied: %+v", f)
+	}
+
+	msg, err := def.Message(f)
+	if err != nil {
+		t.Fatalf("Message: %v", err)
+	}
+	if msg.Subject != "ICS213 - Status" || len(msg.Attachments) != 1 || msg.Attachments[0].Name != "RMS_Express_Form_ICS213_Initial_Viewer.xml" {
+		t.Fatalf("message = %+v", msg)
+	}
+	parsed, err := Parse(msg.Attachments[0].Data)
+	if err != nil || parsed.Field("message") != "All OK" {
+		t.Fatalf("attachment does not parse back: %v %+v", err, parsed)
+	}
+}
+
+func TestInstallUpdatesCatalog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	src := t.TempDir()
+	good := "name: ICS213\ntitle: Local ICS 213\ndisplay_form: ICS213_Initial_Viewer.html\nsubject: \"{{.subjectline}}\"\nfields:\n  - {name: subjectline, label: Subject, required: true}\n"
+	if err := os.WriteFile(filepath.Join(src, "ics213.yaml"), []byte(good), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := Install(src)
+	if err != nil || strings.Join(names, ",") != "ICS213" {
+		t.Fatalf("Install = %v, %v", names, err)
+	}
+	def, err := Lookup("ICS213")
+	if err != nil || def.Title != "Local ICS 213" || !strings.HasPrefix(def.Source, home) {
+		t.Fatalf("Lookup after install = %+v, %v", def, err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("name: X\nfields: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(bad); err == nil {
+		t.Fatal("expected Install to reject an invalid definition")
+	}
+}
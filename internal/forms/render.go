@@ -0,0 +1,65 @@
+package forms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// Text renders a form for reading: a title line, then one "Label: value" line per
+// field, with multi-line values indented below their label. def may be nil, in which
+// case field names stand in for labels.
+func Text(f *core.Form, def *Definition) string {
+	var b strings.Builder
+	title := f.Name
+	if def != nil && def.Title != "" {
+		title = def.Title
+	}
+	b.WriteString(title + "\n")
+	b.WriteString(strings.Repeat("=", len(title)) + "\n")
+
+	width := 0
+	labels := make([]string, len(f.Fields))
+	for i, fl := range f.Fields {
+		labels[i] = fl.Name
+		if def != nil {
+			if fd := def.Field(fl.Name); fd != nil && fd.Label != "" {
+				labels[i] = fd.Label
+			}
+		}
+		if len(labels[i]) > width {
+			width = len(labels[i])
+		}
+	}
+
+	for i, fl := range f.Fields {
+		v := strings.TrimRight(fl.Value, "\n")
+		if !strings.Contains(v, "\n") {
+			b.WriteString(strings.TrimRight(fmt.Sprintf("%-*s  %s", width+1, labels[i]+":", v), " ") + "\n")
+			continue
+		}
+		b.WriteString(labels[i] + ":\n")
+		for _, line := range strings.Split(v, "\n") {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+
+	if cs := f.Parameters["senders_callsign"]; cs != "" {
+		fmt.Fprintf(&b, "\n(form %s from %s", f.Name, cs)
+		if ts := f.Parameters["submission_datetime"]; ts != "" {
+			fmt.Fprintf(&b, ", submitted %s", ts)
+		}
+		b.WriteString(")\n")
+	}
+	return b.String()
+}
+
+// Describe renders f using its catalog definition, if the catalog has one.
+func Describe(f *core.Form) string {
+	def, err := Lookup(f.Name)
+	if err != nil {
+		def = nil
+	}
+	return Text(f, def)
+}
@@ -0,0 +1,135 @@
+// Package forms reads and writes Winlink (RMS Express) form data: the
+// RMS_Express_Form_*.xml attachments that carry ICS-213, ICS-309, bulletins and the
+// other standard forms, and the catalog of form definitions used to compose them.
+package forms
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// AttachmentPrefix starts the file name of every Winlink form attachment.
+const AttachmentPrefix = "RMS_Express_Form_"
+
+// IsFormAttachment reports whether an attachment name looks like Winlink form XML.
+func IsFormAttachment(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, `\`, "/"))
+	return strings.HasPrefix(strings.ToUpper(base), strings.ToUpper(AttachmentPrefix)) &&
+		strings.EqualFold(path.Ext(base), ".xml")
+}
+
+// Parse decodes form XML. The form name comes from the display_form parameter
+// ("ICS213_Initial_Viewer.html" -> "ICS213").
+func Parse(b []byte) (*core.Form, error) {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	f := &core.Form{Parameters: map[string]string{}}
+	var stack []string
+	var text strings.Builder
+	sawRoot := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("form xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				if !strings.EqualFold(t.Name.Local, "RMS_Express_Form") {
+					return nil, fmt.Errorf("form xml: root element is %s, want RMS_Express_Form", t.Name.Local)
+				}
+				sawRoot = true
+			}
+			stack = append(stack, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(stack) == 3 {
+				v := strings.TrimSpace(strings.ReplaceAll(text.String(), "\r\n", "\n"))
+				switch strings.ToLower(stack[1]) {
+				case "form_parameters":
+					f.Parameters[strings.ToLower(stack[2])] = v
+				case "variables":
+					f.Fields = append(f.Fields, core.FormField{Name: stack[2], Value: v})
+				}
+			}
+			stack = stack[:len(stack)-1]
+			text.Reset()
+		}
+	}
+	if !sawRoot {
+		return nil, fmt.Errorf("form xml: empty document")
+	}
+	f.Name = NameFromDisplayForm(f.Parameters["display_form"])
+	return f, nil
+}
+
+// NameFromDisplayForm strips the viewer suffix from a display_form parameter.
+func NameFromDisplayForm(display string) string {
+	name := strings.TrimSuffix(path.Base(strings.ReplaceAll(display, `\`, "/")), path.Ext(display))
+	for _, suffix := range []string{"_Initial_Viewer", "_Viewer", "_Initial"} {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) {
+			return name[:len(name)-len(suffix)]
+		}
+	}
+	return name
+}
+
+// Marshal writes f as Winlink form XML.
+func Marshal(f *core.Form) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xml version=\"1.0\"?>\r\n<RMS_Express_Form>\r\n  <form_parameters>\r\n")
+	for _, k := range parameterOrder(f.Parameters) {
+		writeElement(&b, "    ", k, f.Parameters[k])
+	}
+	b.WriteString("  </form_parameters>\r\n  <variables>\r\n")
+	for _, fl := range f.Fields {
+		writeElement(&b, "    ", fl.Name, fl.Value)
+	}
+	b.WriteString("  </variables>\r\n</RMS_Express_Form>\r\n")
+	return b.Bytes()
+}
+
+// parameterOrder lists the well-known parameters the way Winlink Express writes them,
+// then any others alphabetically.
+func parameterOrder(params map[string]string) []string {
+	known := []string{"xml_file_version", "rms_express_version", "submission_datetime",
+		"senders_callsign", "grid_square", "display_form", "reply_template"}
+	var out []string
+	seen := map[string]bool{}
+	for _, k := range known {
+		if _, ok := params[k]; ok {
+			out = append(out, k)
+			seen[k] = true
+		}
+	}
+	var rest []string
+	for k := range params {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(out, rest...)
+}
+
+// textEscaper escapes element text but, unlike xml.EscapeText, keeps line breaks literal
+// the way Winlink Express writes multi-line fields.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func writeElement(b *bytes.Buffer, indent, name, value string) {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", "\n"), "\n", "\r\n")
+	b.WriteString(indent + "<" + name + ">" + textEscaper.Replace(value) + "</" + name + ">\r\n")
+}
@@ -26,50 +26,91 @@ func InitRuntime(ctx context.Context) error {
 	return st.Close()
 }
 
-// Doctor validates core invariants needed to run RelayOps.
-func Doctor(ctx context.Context) error {
-	fmt.Println("Running diagnostics...")
+// Check statuses: a failed check stops Doctor; a warning leaves RelayOps usable but
+// degraded (no PAT means no real sends).
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Check is the result of one diagnostic.
+type Check struct {
+	Name   string
+	Status string // CheckOK, CheckWarn or CheckFail
+	Detail string
+}
 
+// Doctor validates core invariants needed to run RelayOps and returns one Check per
+// diagnostic. It stops at the first failed check, which is then the last one.
+func Doctor(ctx context.Context) []Check {
 	// Includes SQLite open+migrate
 	if err := InitRuntime(ctx); err != nil {
-		return err
+		return []Check{{Name: "runtime + sqlite", Status: CheckFail, Detail: err.Error()}}
 	}
-	fmt.Println("✔ runtime + sqlite: OK")
+	checks := []Check{{Name: "runtime + sqlite", Status: CheckOK, Detail: "OK"}}
 
 	// message model
 	testMsg := core.NewMessage("Test Subject", "Test Body")
 	if testMsg == nil || testMsg.ID == "" {
-		return fmt.Errorf("doctor: NewMessage produced empty message or ID")
+		return append(checks, Check{Name: "message model", Status: CheckFail, Detail: "NewMessage produced empty message or ID"})
 	}
 	if testMsg.Status == "" {
-		return fmt.Errorf("doctor: NewMessage produced empty status")
+		return append(checks, Check{Name: "message model", Status: CheckFail, Detail: "NewMessage produced empty status"})
 	}
-	fmt.Printf("✔ message model OK (ID: %s)\n", testMsg.ID)
+	checks = append(checks, Check{Name: "message model", Status: CheckOK, Detail: fmt.Sprintf("OK (ID: %s)", testMsg.ID)})
 
 	// ---- PAT checks ----
 
 	// 1) pat binary present?
-	if p, err := exec.LookPath("pat"); err != nil {
-		fmt.Printf("⚠ pat binary: not found in PATH (install PAT or fix PATH)\n")
+	if p, err := exec.LookPath(pat.Binary()); err != nil {
+		checks = append(checks, Check{Name: "pat binary", Status: CheckWarn, Detail: "not found in PATH (install PAT or fix PATH)"})
 	} else {
-		fmt.Printf("✔ pat binary: %s\n", p)
+		checks = append(checks, Check{Name: "pat binary", Status: CheckOK, Detail: p})
 	}
 
 	// 2) pat config present + readable?
 	cfg, cfgPath, err := pat.LoadConfig()
 	if err != nil {
-		fmt.Printf("⚠ pat config: %v\n", err)
+		checks = append(checks, Check{Name: "pat config", Status: CheckWarn, Detail: err.Error()})
 	} else {
-		fmt.Printf("✔ pat config: %s\n", cfgPath)
+		checks = append(checks, Check{Name: "pat config", Status: CheckOK, Detail: cfgPath})
 		if err := validateCallsign(cfg.MyCall); err != nil {
-			fmt.Printf("⚠ pat mycall: %v\n", err)
+			checks = append(checks, Check{Name: "pat mycall", Status: CheckWarn, Detail: err.Error()})
 		} else {
-			fmt.Printf("✔ pat mycall: %s\n", cfg.MyCall)
+			checks = append(checks, Check{Name: "pat mycall", Status: CheckOK, Detail: cfg.MyCall})
 		}
 	}
+	return checks
+}
 
-	fmt.Println("Diagnostics complete.")
-	return nil
+// TransportStatus reports whether a transport can be used on this station.
+type TransportStatus struct {
+	Name      string
+	Available bool
+	Detail    string // what was found, or why it is unavailable
+}
+
+// Transports probes the transports `send` and playbooks can use, without connecting.
+// PAT needs its binary in PATH and a config with a valid callsign.
+func Transports() []TransportStatus {
+	p := TransportStatus{Name: "pat"}
+	bin, err := exec.LookPath(pat.Binary())
+	switch {
+	case err != nil:
+		p.Detail = "pat binary not found in PATH"
+	default:
+		cfg, cfgPath, err := pat.LoadConfig()
+		if err != nil {
+			p.Detail = fmt.Sprintf("pat config: %v", err)
+		} else if err := validateCallsign(cfg.MyCall); err != nil {
+			p.Detail = fmt.Sprintf("pat mycall: %v", err)
+		} else {
+			p.Available = true
+			p.Detail = fmt.Sprintf("%s as %s (%s)", bin, strings.ToUpper(cfg.MyCall), cfgPath)
+		}
+	}
+	return []TransportStatus{p, {Name: "sim", Available: true, Detail: "simulated transport for drills and testing"}}
 }
 
 var callsignRe = regexp.MustCompile(`^[A-Z0-9/]{3,16}$`)
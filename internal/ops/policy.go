@@ -0,0 +1,47 @@
+package ops
+
+import (
+	"fmt"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// SelectMode picks one transport mode that every message's policy allows. Preferred
+// modes are tried first, in the order the first message lists them, then allowed ones.
+// Messages that allow "any" default to telnet.
+func SelectMode(msgs []*core.Message) (core.Mode, error) {
+	if len(msgs) == 0 {
+		return "", fmt.Errorf("no messages to select a transport for")
+	}
+
+	var candidates []core.Mode
+	first := msgs[0].Meta.Transport
+	candidates = append(candidates, first.Preferred...)
+	candidates = append(candidates, first.Allowed...)
+	candidates = append(candidates, core.ModeTelnet)
+
+	for _, c := range candidates {
+		if c == core.ModeAny || c == "" {
+			continue
+		}
+		ok := true
+		for _, m := range msgs {
+			if !allowsMode(m, c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("queued messages have no transport mode in common")
+}
+
+func allowsMode(m *core.Message, mode core.Mode) bool {
+	allowed := m.Meta.Transport.Allowed
+	if len(allowed) == 0 {
+		return true
+	}
+	return containsMode(allowed, core.ModeAny) || containsMode(allowed, mode)
+}
@@ -0,0 +1,42 @@
+package ops_test
+
+import (
+	"testing"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+)
+
+func TestSelectMode(t *testing.T) {
+	msg := func(allowed, preferred []core.Mode) *core.Message {
+		m := core.NewMessage("s", "b")
+		m.Meta.Transport.Allowed = allowed
+		m.Meta.Transport.Preferred = preferred
+		return m
+	}
+	anyMode := []core.Mode{core.ModeAny}
+
+	cases := []struct {
+		name string
+		msgs []*core.Message
+		want core.Mode
+		err  bool
+	}{
+		{"default policy", []*core.Message{msg(anyMode, nil)}, core.ModeTelnet, false},
+		{"preferred wins", []*core.Message{msg(anyMode, []core.Mode{core.ModeVARAHF})}, core.ModeVARAHF, false},
+		{"common allowed", []*core.Message{
+			msg([]core.Mode{core.ModePacket, core.ModeARDOP}, nil),
+			msg([]core.Mode{core.ModeARDOP}, nil),
+		}, core.ModeARDOP, false},
+		{"no overlap", []*core.Message{
+			msg([]core.Mode{core.ModePacket}, nil),
+			msg([]core.Mode{core.ModeARDOP}, nil),
+		}, "", true},
+	}
+	for _, c := range cases {
+		got, err := ops.SelectMode(c.msgs)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q (err=%v)", c.name, got, err, c.want, c.err)
+		}
+	}
+}
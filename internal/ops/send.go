@@ -3,6 +3,7 @@ package ops
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/4current/relayops/internal/core"
 	"github.com/4current/relayops/internal/runtime"
@@ -13,6 +14,38 @@ type Sender interface {
 	SendOne(ctx context.Context, m *core.Message) (string, error)
 }
 
+// SessionSender is implemented by senders that open a link per send. The session from
+// the last SendOne is recorded and linked to the message.
+type SessionSender interface {
+	Sender
+	TakeSession() *store.Session
+}
+
+// Connector is implemented by senders that can open a session without sending a
+// message, e.g. to poll a gateway for inbound traffic.
+type Connector interface {
+	Connect(ctx context.Context) error
+}
+
+// TacticalSender is implemented by senders that can send mail from tactical
+// addresses. SendQueued hands them the identity registry (address -> licensed callsign).
+type TacticalSender interface {
+	SetTactical(map[string]string)
+}
+
+// CredentialSender is implemented by senders that log in with a stored password.
+// SendQueued hands them the store's credential lookup (e.g. "winlink:AE4OK").
+type CredentialSender interface {
+	SetCredentials(func(ctx context.Context, name string) (string, bool, error))
+}
+
+// NamedSender is implemented by senders that report the backend they send through.
+// Attempts and external refs are recorded under that name; senders that do not
+// implement it are recorded as "pat".
+type NamedSender interface {
+	Backend() string
+}
+
 type SendResult struct {
 	Sent   int
 	Failed int
@@ -30,6 +63,21 @@ func SendQueued(ctx context.Context, st *store.Store, tag string, limit int, sen
 	if err != nil {
 		return SendResult{}, err
 	}
+	if ts, ok := sender.(TacticalSender); ok {
+		tactical, err := st.IdentityCallsigns(ctx)
+		if err != nil {
+			return SendResult{}, err
+		}
+		ts.SetTactical(tactical)
+	}
+	if cs, ok := sender.(CredentialSender); ok {
+		cs.SetCredentials(st.GetCredential)
+	}
+
+	backend := "pat"
+	if ns, ok := sender.(NamedSender); ok && ns.Backend() != "" {
+		backend = ns.Backend()
+	}
 
 	var res SendResult
 	for _, m := range msgs {
@@ -46,16 +94,23 @@ func SendQueued(ctx context.Context, st *store.Store, tag string, limit int, sen
 			continue
 		}
 
+		started := time.Now()
 		mid, err := sender.SendOne(ctx, m)
+		recordSession(ctx, st, sender, m.ID, mid)
 		if err != nil {
+			_ = st.RecordAttempt(ctx, store.Attempt{MessageID: m.ID, Backend: backend, StartedAt: started, Outcome: "failed", Error: err.Error()})
 			_ = st.SetStatusByID(ctx, m.ID, core.StatusFailed, err.Error())
 			res.Failed++
 			continue
 		}
 
 		// SUCCESS PATH
-		scope := runtime.IdentityScope(m.From.Callsign)
-		_ = st.UpsertExternalRef(ctx, m.ID, "pat", mid, scope, "{}")
+		scope, err := st.IdentityScope(ctx, m.From.Callsign)
+		if err != nil {
+			scope = runtime.IdentityScope(m.From.Callsign)
+		}
+		_ = st.RecordAttempt(ctx, store.Attempt{MessageID: m.ID, Backend: backend, ExternalID: mid, StartedAt: started, Outcome: "sent"})
+		_ = st.UpsertExternalRef(ctx, m.ID, backend, mid, scope, "{}")
 		_ = st.SetStatusByID(ctx, m.ID, core.StatusSent, "")
 		res.Sent++
 	}
@@ -63,6 +118,45 @@ func SendQueued(ctx context.Context, st *store.Store, tag string, limit int, sen
 	return res, nil
 }
 
+// ConnectOnce opens one session with c and records it when c reports sessions.
+func ConnectOnce(ctx context.Context, st *store.Store, c Connector) (*store.Session, error) {
+	if st == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+	err := c.Connect(ctx)
+	var sess *store.Session
+	if ss, ok := c.(interface{ TakeSession() *store.Session }); ok {
+		if sess = ss.TakeSession(); sess != nil {
+			if rerr := st.RecordSession(ctx, sess); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return sess, err
+}
+
+func recordSession(ctx context.Context, st *store.Store, sender Sender, messageID, mid string) {
+	ss, ok := sender.(SessionSender)
+	if !ok {
+		return
+	}
+	sess := ss.TakeSession()
+	if sess == nil {
+		return
+	}
+	linked := false
+	for i := range sess.Messages {
+		if sess.Messages[i].Direction == store.DirectionOut && mid != "" && sess.Messages[i].MID == mid {
+			sess.Messages[i].MessageID = messageID
+			linked = true
+		}
+	}
+	if !linked {
+		sess.Messages = append(sess.Messages, store.SessionMessage{MessageID: messageID, MID: mid, Direction: store.DirectionOut})
+	}
+	_ = st.RecordSession(ctx, sess)
+}
+
 func containsMode(list []core.Mode, x core.Mode) bool {
 	for _, m := range list {
 		if m == x {
@@ -0,0 +1,71 @@
+package ops_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/forms"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/transport/pat"
+)
+
+// b2fSender builds the B2F a pat send would write and keeps it.
+type b2fSender struct {
+	b2f [][]byte
+}
+
+func (s *b2fSender) SendOne(ctx context.Context, m *core.Message) (string, error) {
+	mid := fmt.Sprintf("CAPTURE%05d", len(s.b2f)+1)
+	b, err := pat.BuildB2F("AE4OK", mid, m, nil)
+	if err != nil {
+		return "", err
+	}
+	s.b2f = append(s.b2f, b)
+	return mid, nil
+}
+
+func TestSendQueuedCarriesFormAttachment(t *testing.T) {
+	st, ctx := setupTestStore(t)
+
+	def, err := forms.Lookup("ICS213")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	f, err := def.Fill(map[string]string{"to_name": "EOC", "subjectline": "Status", "message": "All OK"},
+		map[string]string{"callsign": "AE4OK"}, time.Now())
+	if err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	msg, err := def.Message(f)
+	if err != nil {
+		t.Fatalf("Message: %v", err)
+	}
+	msg.To = []core.Address{core.ParseAddress("W1AW")}
+	msg.Tags = []string{"t_form"}
+	if err := st.SaveMessage(ctx, msg); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if _, err := st.QueueByTag(ctx, "t_form"); err != nil {
+		t.Fatalf("QueueByTag: %v", err)
+	}
+
+	sender := &b2fSender{}
+	res, err := ops.SendQueued(ctx, st, "t_form", 10, sender)
+	if err != nil || res.Sent != 1 || len(sender.b2f) != 1 {
+		t.Fatalf("SendQueued = %+v, %v (captured %d)", res, err, len(sender.b2f))
+	}
+
+	xml := forms.Marshal(f)
+	b2f := sender.b2f[0]
+	wantFile := fmt.Sprintf("File: %d %s\n", len(xml), def.AttachmentName())
+	if !bytes.Contains(b2f, []byte(wantFile)) {
+		t.Fatalf("B2F lacks %q:\n%s", wantFile, b2f)
+	}
+	if !bytes.Contains(b2f, xml) {
+		t.Fatalf("B2F lacks the form XML:\n%s", b2f)
+	}
+}
@@ -0,0 +1,76 @@
+package ops_test
+
+import (
+	"testing"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/sim"
+)
+
+func TestSendQueuedRecordsSessions(t *testing.T) {
+	st, ctx := setupTestStore(t)
+
+	ok := core.NewMessage("OK", "body")
+	ok.Tags = []string{"t_sess"}
+	bad := core.NewMessage("BAD", "body")
+	bad.Tags = []string{"t_sess"}
+	bad.Meta.Session = core.SessionRadioOnly
+	bad.Meta.Transport.Allowed = []core.Mode{core.ModeTelnet}
+	for _, m := range []*core.Message{ok, bad} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if _, err := st.QueueByTag(ctx, "t_sess"); err != nil {
+		t.Fatalf("QueueByTag: %v", err)
+	}
+
+	if _, err := ops.SendQueued(ctx, st, "t_sess", 10, sim.New()); err != nil {
+		t.Fatalf("SendQueued: %v", err)
+	}
+
+	all, err := st.ListSessions(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(all))
+	}
+
+	okSess, err := st.ListSessions(ctx, ok.ID, 10)
+	if err != nil || len(okSess) != 1 {
+		t.Fatalf("ListSessions(ok) = %v, %v", okSess, err)
+	}
+	got, found, err := st.GetSession(ctx, okSess[0].ID)
+	if err != nil || !found {
+		t.Fatalf("GetSession: found=%v err=%v", found, err)
+	}
+	if got.Outcome != "ok" || got.Transport != "sim" || got.BytesOut == 0 || got.Transcript == "" {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].MessageID != ok.ID || got.Messages[0].Direction != store.DirectionOut {
+		t.Fatalf("unexpected links: %+v", got.Messages)
+	}
+
+	badSess, err := st.ListSessions(ctx, bad.ID, 10)
+	if err != nil || len(badSess) != 1 {
+		t.Fatalf("ListSessions(bad) = %v, %v", badSess, err)
+	}
+	if badSess[0].Outcome != "failed" || badSess[0].Error == "" {
+		t.Fatalf("expected failed session, got %+v", badSess[0])
+	}
+
+	// Attempts and refs are recorded under the sender's backend, not pat.
+	for _, m := range []*core.Message{ok, bad} {
+		attempts, err := st.ListAttempts(ctx, m.ID)
+		if err != nil || len(attempts) != 1 || attempts[0].Backend != "sim" {
+			t.Fatalf("ListAttempts(%s) = %+v, %v", m.Subject, attempts, err)
+		}
+	}
+	refs, err := st.ListExternalRefs(ctx, ok.ID)
+	if err != nil || len(refs) != 1 || refs[0].Backend != "sim" {
+		t.Fatalf("ListExternalRefs = %+v, %v", refs, err)
+	}
+}
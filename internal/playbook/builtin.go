@@ -0,0 +1,49 @@
+package playbook
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+//go:embed builtin
+var builtinFiles embed.FS
+
+// BuiltinNames lists the playbooks shipped with RelayOps.
+func BuiltinNames() []string {
+	entries, _ := fs.ReadDir(builtinFiles, "builtin")
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".yaml") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builtin loads a shipped playbook by name (e.g. "winlink-wednesday").
+func Builtin(name string) (*Playbook, error) {
+	b, err := builtinFiles.ReadFile("builtin/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("no built-in playbook %q (have: %s)", name, strings.Join(BuiltinNames(), ", "))
+	}
+	pb, err := Parse(b, false)
+	if err != nil {
+		return nil, fmt.Errorf("built-in %s: %w", name, err)
+	}
+	pb.Files, _ = fs.Sub(builtinFiles, "builtin")
+	return pb, nil
+}
+
+// Open loads a playbook file, or the built-in playbook of that name when no such file
+// exists and the argument does not look like a path.
+func Open(nameOrPath string) (*Playbook, error) {
+	if _, err := os.Stat(nameOrPath); err != nil && !strings.ContainsAny(nameOrPath, `/\.`) {
+		return Builtin(nameOrPath)
+	}
+	return Load(nameOrPath)
+}
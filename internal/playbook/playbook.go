@@ -0,0 +1,247 @@
+// Package playbook loads and runs declarative operating procedures: a named list of
+// steps such as "compose a check-in from a template, queue it, connect, send, assert".
+package playbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Playbook is a parsed playbook file. YAML and JSON use the same field names.
+type Playbook struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Vars        map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"`
+	Steps       []Step            `yaml:"steps" json:"steps"`
+
+	// Files resolves relative template paths: the playbook's directory, or the embedded
+	// files for built-in playbooks.
+	Files fs.FS `yaml:"-" json:"-"`
+}
+
+// Step is one playbook step. Exactly one action field must be set.
+type Step struct {
+	Name            string `yaml:"name,omitempty" json:"name,omitempty"`
+	ContinueOnError bool   `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+	// Always runs the step even after an earlier step failed (e.g. to record the outcome).
+	Always bool `yaml:"always,omitempty" json:"always,omitempty"`
+
+	Compose   *ComposeStep   `yaml:"compose,omitempty" json:"compose,omitempty"`
+	Queue     *QueueStep     `yaml:"queue,omitempty" json:"queue,omitempty"`
+	Transport *TransportStep `yaml:"transport,omitempty" json:"transport,omitempty"`
+	Connect   *ConnectStep   `yaml:"connect,omitempty" json:"connect,omitempty"`
+	Send      *SendStep      `yaml:"send,omitempty" json:"send,omitempty"`
+	Receive   *ReceiveStep   `yaml:"receive,omitempty" json:"receive,omitempty"`
+	Wait      *WaitStep      `yaml:"wait,omitempty" json:"wait,omitempty"`
+	Assert    *AssertStep    `yaml:"assert,omitempty" json:"assert,omitempty"`
+	Record    *RecordStep    `yaml:"record,omitempty" json:"record,omitempty"`
+}
+
+// ComposeStep saves a draft. Subject, Body, To and Tags are text/template strings
+// rendered with the playbook vars; Template instead names a template file next to the
+// playbook or in the templates directory (see package templates).
+type ComposeStep struct {
+	Template string            `yaml:"template,omitempty" json:"template,omitempty"`
+	Subject  string            `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Body     string            `yaml:"body,omitempty" json:"body,omitempty"`
+	To       []string          `yaml:"to,omitempty" json:"to,omitempty"`
+	Tags     []string          `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Session  string            `yaml:"session,omitempty" json:"session,omitempty"`
+	Vars     map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"`
+}
+
+// QueueStep queues drafts (and failed messages) carrying Tag, or with Composed only
+// the messages earlier compose steps of this run created.
+type QueueStep struct {
+	Tag      string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Composed bool   `yaml:"composed,omitempty" json:"composed,omitempty"`
+}
+
+// TransportStep selects the transport used by later connect/send/receive steps.
+// "auto" picks pat with the mode that the queued messages tagged Tag allow and prefer.
+type TransportStep struct {
+	Use     string `yaml:"use" json:"use"`                             // "pat", "sim" or "auto"
+	Connect string `yaml:"connect,omitempty" json:"connect,omitempty"` // pat connect alias or URL (template)
+	Tag     string `yaml:"tag,omitempty" json:"tag,omitempty"`         // auto: whose policy to follow
+}
+
+// ConnectStep opens one session without composing anything (flush + poll).
+type ConnectStep struct{}
+
+// SendStep sends queued messages, optionally only those with Tag.
+type SendStep struct {
+	Tag   string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Limit int    `yaml:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ReceiveStep imports inbound traffic for the selected transport.
+type ReceiveStep struct {
+	Mailbox  string `yaml:"mailbox,omitempty" json:"mailbox,omitempty"`
+	Callsign string `yaml:"callsign,omitempty" json:"callsign,omitempty"`
+	Scope    string `yaml:"scope,omitempty" json:"scope,omitempty"`
+}
+
+// WaitStep pauses the run, e.g. to give a gateway time to relay.
+type WaitStep struct {
+	Duration string `yaml:"duration" json:"duration"`
+}
+
+// AssertStep fails the run unless the number of matching messages is within [Min, Max].
+type AssertStep struct {
+	Tag    string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Status string `yaml:"status,omitempty" json:"status,omitempty"`
+	// ThisRun only counts messages created since the run started.
+	ThisRun bool `yaml:"this_run,omitempty" json:"this_run,omitempty"`
+	Min     *int `yaml:"min,omitempty" json:"min,omitempty"`
+	Max     *int `yaml:"max,omitempty" json:"max,omitempty"`
+}
+
+// RecordStep writes a participation record for Event in Scope for the run's date.
+// With Tag, the outcome follows the message tagged Tag that this run composed (checked
+// in once it is sent); without it, whether every earlier step succeeded.
+type RecordStep struct {
+	Event string `yaml:"event" json:"event"`
+	Tag   string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Scope string `yaml:"scope,omitempty" json:"scope,omitempty"`
+}
+
+// Action names the step's action ("compose", "queue", ...), or "" if none is set.
+func (s Step) Action() string {
+	for _, a := range s.actions() {
+		if a.set {
+			return a.name
+		}
+	}
+	return ""
+}
+
+// Label is the step's name, falling back to its action.
+func (s Step) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Action()
+}
+
+type actionField struct {
+	name string
+	set  bool
+}
+
+func (s Step) actions() []actionField {
+	return []actionField{
+		{"compose", s.Compose != nil},
+		{"queue", s.Queue != nil},
+		{"transport", s.Transport != nil},
+		{"connect", s.Connect != nil},
+		{"send", s.Send != nil},
+		{"receive", s.Receive != nil},
+		{"wait", s.Wait != nil},
+		{"assert", s.Assert != nil},
+		{"record", s.Record != nil},
+	}
+}
+
+// Load reads a playbook from a .yaml/.yml/.json file.
+func Load(path string) (*Playbook, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pb, err := Parse(b, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	pb.Files = os.DirFS(filepath.Dir(path))
+	if pb.Name == "" {
+		pb.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return pb, nil
+}
+
+// Parse decodes a playbook and validates it. Unknown fields are rejected so typos in
+// step names do not silently turn into no-ops.
+func Parse(b []byte, isJSON bool) (*Playbook, error) {
+	var pb Playbook
+	if isJSON {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&pb); err != nil {
+			return nil, fmt.Errorf("parse playbook: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&pb); err != nil {
+			return nil, fmt.Errorf("parse playbook: %w", err)
+		}
+	}
+	if err := pb.Validate(); err != nil {
+		return nil, err
+	}
+	return &pb, nil
+}
+
+// Validate checks the playbook's structure without touching the store or radio.
+func (pb *Playbook) Validate() error {
+	if len(pb.Steps) == 0 {
+		return fmt.Errorf("playbook has no steps")
+	}
+	transport := ""
+	for i, s := range pb.Steps {
+		n := 0
+		for _, a := range s.actions() {
+			if a.set {
+				n++
+			}
+		}
+		where := fmt.Sprintf("step %d (%s)", i+1, s.Label())
+		if n != 1 {
+			return fmt.Errorf("step %d: exactly one action is required, got %d", i+1, n)
+		}
+
+		switch {
+		case s.Compose != nil:
+			c := s.Compose
+			if c.Template == "" && (c.Subject == "" || c.Body == "") {
+				return fmt.Errorf("%s: compose needs template or subject+body", where)
+			}
+		case s.Queue != nil:
+			if strings.TrimSpace(s.Queue.Tag) == "" && !s.Queue.Composed {
+				return fmt.Errorf("%s: queue needs tag or composed", where)
+			}
+		case s.Transport != nil:
+			switch s.Transport.Use {
+			case "pat", "sim", "auto":
+				transport = s.Transport.Use
+			default:
+				return fmt.Errorf("%s: unknown transport %q (want pat, sim or auto)", where, s.Transport.Use)
+			}
+		case s.Connect != nil, s.Send != nil, s.Receive != nil:
+			if transport == "" {
+				return fmt.Errorf("%s: no transport selected yet (add a transport step first)", where)
+			}
+		case s.Wait != nil:
+			if _, err := time.ParseDuration(s.Wait.Duration); err != nil {
+				return fmt.Errorf("%s: wait: %w", where, err)
+			}
+		case s.Assert != nil:
+			if s.Assert.Min == nil && s.Assert.Max == nil {
+				return fmt.Errorf("%s: assert needs min and/or max", where)
+			}
+		case s.Record != nil:
+			if strings.TrimSpace(s.Record.Event) == "" {
+				return fmt.Errorf("%s: record needs event", where)
+			}
+		}
+	}
+	return nil
+}
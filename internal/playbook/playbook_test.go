@@ -0,0 +1,322 @@
+package playbook_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/playbook"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/templates"
+	"github.com/4current/relayops/internal/transport/sim"
+)
+
+func setupStore(t *testing.T) (*store.Store, context.Context) {
+	t.Helper()
+	tmp := t.TempDir()
+	_ = os.Setenv("HOME", tmp)
+	_ = os.Setenv("USERPROFILE", tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st, ctx
+}
+
+const checkinYAML = `
+name: checkin
+vars:
+  net: Winlink Wednesday
+  call: AE4OK
+steps:
+  - name: compose check-in
+    compose:
+      template: checkin.tmpl
+      to: [W1AW]
+      tags: [t_pb]
+  - queue: {tag: t_pb}
+  - transport: {use: sim}
+  - send: {tag: t_pb}
+  - assert: {tag: t_pb, status: sent, this_run: true, min: 1, max: 1}
+`
+
+func writePlaybook(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "checkin.yaml"), []byte(checkinYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := "Subject: {{.net}} check-in {{.call}}\n\n{{.call}} checking in for {{.net}}.\n"
+	if err := os.WriteFile(filepath.Join(dir, "checkin.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "checkin.yaml")
+}
+
+func TestParseRejectsBadPlaybooks(t *testing.T) {
+	cases := map[string]string{
+		"no steps":              `name: x`,
+		"two actions":           "steps:\n  - queue: {tag: a}\n    wait: {duration: 1s}\n",
+		"unknown field":         "steps:\n  - queu: {tag: a}\n",
+		"send before transport": "steps:\n  - send: {tag: a}\n",
+		"bad duration":          "steps:\n  - wait: {duration: soon}\n",
+		"assert no bound":       "steps:\n  - assert: {tag: a}\n",
+	}
+	for name, src := range cases {
+		if _, err := playbook.Parse([]byte(src), false); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	js := `{"name":"j","steps":[{"transport":{"use":"sim"}},{"connect":{}}]}`
+	pb, err := playbook.Parse([]byte(js), true)
+	if err != nil {
+		t.Fatalf("Parse(json): %v", err)
+	}
+	if pb.Steps[1].Action() != "connect" {
+		t.Fatalf("unexpected action %q", pb.Steps[1].Action())
+	}
+}
+
+func TestRunComposesSendsAndAudits(t *testing.T) {
+	st, ctx := setupStore(t)
+	pb, err := playbook.Load(writePlaybook(t))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var out bytes.Buffer
+	res, err := (&playbook.Runner{Store: st, Out: &out}).Run(ctx, pb)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.OK() {
+		t.Fatalf("run failed:\n%s", out.String())
+	}
+
+	sent, err := st.ListByStatus(ctx, []core.MessageStatus{core.StatusSent}, 10)
+	if err != nil || len(sent) != 1 {
+		t.Fatalf("ListByStatus(sent) = %d, %v", len(sent), err)
+	}
+	if sent[0].Subject != "Winlink Wednesday check-in AE4OK" {
+		t.Fatalf("unexpected subject %q", sent[0].Subject)
+	}
+	full, _, err := st.GetMessage(ctx, sent[0].ID)
+	if err != nil || !strings.Contains(full.Body, "AE4OK checking in") {
+		t.Fatalf("unexpected body: %+v, %v", full, err)
+	}
+
+	steps, err := st.ListEvents(ctx, store.EventFilter{Kind: playbook.EventStep})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(steps) != len(pb.Steps) {
+		t.Fatalf("expected %d step events, got %d", len(pb.Steps), len(steps))
+	}
+	if steps[0].Actor != "playbook:checkin" {
+		t.Fatalf("unexpected actor %q", steps[0].Actor)
+	}
+	created, err := st.ListEvents(ctx, store.EventFilter{Kind: store.EventMessageCreated})
+	if err != nil || len(created) != 1 || created[0].Actor != "playbook:checkin" {
+		t.Fatalf("message.created events = %+v, %v", created, err)
+	}
+}
+
+func TestDryRunChangesNothing(t *testing.T) {
+	st, ctx := setupStore(t)
+	pb, err := playbook.Load(writePlaybook(t))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	res, err := (&playbook.Runner{Store: st, DryRun: true}).Run(ctx, pb)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, s := range res.Steps {
+		if s.Outcome != playbook.StepPlanned {
+			t.Fatalf("step %d: outcome %s (%s)", s.Index, s.Outcome, s.Error)
+		}
+	}
+
+	msgs, err := st.ListMessages(ctx, 10, true)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("dry run saved messages: %d, %v", len(msgs), err)
+	}
+	evs, err := st.ListEvents(ctx, store.EventFilter{})
+	if err != nil || len(evs) != 0 {
+		t.Fatalf("dry run wrote events: %d, %v", len(evs), err)
+	}
+}
+
+func TestFailedAssertStopsRun(t *testing.T) {
+	st, ctx := setupStore(t)
+	src := `
+name: strict
+steps:
+  - assert: {tag: nothing, min: 1}
+  - queue: {tag: nothing}
+`
+	pb, err := playbook.Parse([]byte(src), false)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	res, err := (&playbook.Runner{Store: st}).Run(ctx, pb)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.OK() || res.Steps[0].Outcome != playbook.StepFailed || res.Steps[1].Outcome != playbook.StepSkipped {
+		t.Fatalf("unexpected result: %+v", res.Steps)
+	}
+}
+
+func TestBuiltinWinlinkWednesdayRecordsParticipation(t *testing.T) {
+	st, ctx := setupStore(t)
+	t.Setenv("RELAYOPS_CALLSIGN", "ae4ok")
+	t.Setenv("RELAYOPS_STATION", "")
+	t.Setenv("PAT_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+
+	// Last week's check-ins and a look-alike tag are not this run's to send.
+	lastWeek := core.NewMessage("old check-in", "x")
+	lastWeek.Tags = []string{"winlink_wednesday"}
+	failed := core.NewMessage("failed check-in", "x")
+	failed.Tags = []string{"winlink_wednesday"}
+	similar := core.NewMessage("similar tag", "x")
+	similar.Tags = []string{"winlink_wednesday_old"}
+	for _, m := range []*core.Message{lastWeek, failed, similar} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if err := st.SetStatusByID(ctx, failed.ID, core.StatusFailed, "no gateway"); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+
+	pb, err := playbook.Builtin("winlink-wednesday")
+	if err != nil {
+		t.Fatalf("Builtin: %v", err)
+	}
+	r := &playbook.Runner{
+		Store: st,
+		Vars:  map[string]string{"to": "W1AW", "location": "EM73", "power": "50W", "mode": "VARA HF"},
+		// Stand in for pat so the check-in goes out without a radio.
+		NewTransport: func(use, connect string) (ops.Sender, error) { return sim.New(), nil },
+	}
+	res, err := r.Run(ctx, pb)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Retrieving replies needs a real pat setup and fails here; the sent check-in still counts.
+	byAction := map[string]playbook.StepResult{}
+	for _, s := range res.Steps {
+		byAction[s.Action] = s
+	}
+	if byAction["send"].Outcome != playbook.StepOK || byAction["receive"].Outcome != playbook.StepFailed {
+		t.Fatalf("unexpected steps: %+v", res.Steps)
+	}
+	if byAction["record"].Outcome != playbook.StepOK {
+		t.Fatalf("record step did not run: %+v", byAction["record"])
+	}
+
+	recs, err := st.ListParticipation(ctx, "AE4OK", "winlink-wednesday", "")
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("ListParticipation = %+v, %v", recs, err)
+	}
+	if recs[0].Outcome != store.ParticipationCheckedIn || recs[0].MessageID == "" {
+		t.Fatalf("unexpected record: %+v", recs[0])
+	}
+
+	m, _, err := st.GetMessage(ctx, recs[0].MessageID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	for _, want := range []string{"AE4OK", "Location: EM73", "Power: 50W", "Mode: VARA HF"} {
+		if !strings.Contains(m.Body, want) {
+			t.Fatalf("check-in body missing %q:\n%s", want, m.Body)
+		}
+	}
+	for id, want := range map[string]core.MessageStatus{lastWeek.ID: core.StatusDraft, failed.ID: core.StatusFailed, similar.ID: core.StatusDraft} {
+		if m, _, _ := st.GetMessage(ctx, id); m.Status != want {
+			t.Fatalf("%q is %s, want %s untouched", m.Subject, m.Status, want)
+		}
+	}
+}
+
+func TestRecordOnlyCountsThisRunsCheckIn(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	// A sent check-in with the tag, created while the run is going (say by a second
+	// operator), is not this run's check-in.
+	other := core.NewMessage("someone else's check-in", "x")
+	other.Tags = []string{"net"}
+	other.CreatedAt = time.Now().Add(time.Hour)
+	if err := st.SaveMessage(ctx, other); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, other.ID, core.StatusSent, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+
+	pb := &playbook.Playbook{Name: "net", Steps: []playbook.Step{
+		{Record: &playbook.RecordStep{Event: "net", Tag: "net", Scope: "AE4OK"}},
+	}}
+	if _, err := (&playbook.Runner{Store: st}).Run(ctx, pb); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	recs, err := st.ListParticipation(ctx, "AE4OK", "net", "")
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("ListParticipation = %+v, %v", recs, err)
+	}
+	if recs[0].Outcome != store.ParticipationFailed || recs[0].MessageID != "" {
+		t.Fatalf("record = %+v, want failed with no message", recs[0])
+	}
+}
+
+func TestMissingTemplateVarsFailBeforeAnyStep(t *testing.T) {
+	st, ctx := setupStore(t)
+	t.Setenv("RELAYOPS_CALLSIGN", "ae4ok")
+	t.Setenv("RELAYOPS_STATION", "")
+	for _, env := range []string{"RELAYOPS_LOCATION", "RELAYOPS_POWER", "RELAYOPS_MODE"} {
+		t.Setenv(env, "")
+	}
+
+	pb, err := playbook.Builtin("winlink-wednesday")
+	if err != nil {
+		t.Fatalf("Builtin: %v", err)
+	}
+	r := &playbook.Runner{
+		Store:        st,
+		Vars:         map[string]string{"to": "W1AW", "power": "50W"},
+		NewTransport: func(use, connect string) (ops.Sender, error) { return sim.New(), nil },
+	}
+	_, err = r.Run(ctx, pb)
+	var me *templates.MissingError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected MissingError, got %v", err)
+	}
+	if strings.Join(me.Missing, ",") != "location,mode" {
+		t.Fatalf("missing = %v", me.Missing)
+	}
+
+	msgs, err := st.ListMessages(ctx, 10, true)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %d (%v)", len(msgs), err)
+	}
+	events, err := st.ListEvents(ctx, store.EventFilter{Kind: playbook.EventRunStarted})
+	if err != nil || len(events) != 0 {
+		t.Fatalf("expected no run events, got %d (%v)", len(events), err)
+	}
+}
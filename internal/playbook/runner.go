@@ -0,0 +1,597 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/templates"
+	"github.com/4current/relayops/internal/transport/pat"
+	"github.com/4current/relayops/internal/transport/sim"
+)
+
+// Audit event kinds written by the runner.
+const (
+	EventRunStarted  = "playbook.started"
+	EventStep        = "playbook.step"
+	EventRunFinished = "playbook.finished"
+)
+
+// Step outcomes.
+const (
+	StepOK      = "ok"
+	StepFailed  = "failed"
+	StepPlanned = "planned" // dry-run
+	StepSkipped = "skipped" // after an earlier failure
+)
+
+// StepResult is what happened to one step.
+type StepResult struct {
+	Index    int
+	Name     string
+	Action   string
+	Outcome  string
+	Detail   string
+	Error    string
+	Duration time.Duration
+}
+
+// Result is the outcome of a whole run.
+type Result struct {
+	RunID    string
+	Playbook string
+	DryRun   bool
+	Started  time.Time
+	Finished time.Time
+	Steps    []StepResult
+}
+
+// OK reports whether every step succeeded (or was planned, for a dry run).
+func (r *Result) OK() bool {
+	for _, s := range r.Steps {
+		if s.Outcome == StepFailed || s.Outcome == StepSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// Runner executes playbooks against a store.
+type Runner struct {
+	Store  *store.Store
+	DryRun bool
+	// Out receives one progress line per step; nil discards them.
+	Out io.Writer
+	// Vars override the playbook's vars (e.g. from -var on the command line).
+	Vars map[string]string
+	// NewTransport builds the sender for a transport step. Defaults to DefaultTransport.
+	NewTransport func(use, connect string) (ops.Sender, error)
+}
+
+// DefaultTransport returns the built-in senders: "sim" and "pat" (via pat connect).
+func DefaultTransport(use, connect string) (ops.Sender, error) {
+	switch use {
+	case "sim":
+		return sim.New(), nil
+	case "pat":
+		cfg, _, err := pat.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		s := pat.New(cfg.MyCall)
+		if connect != "" {
+			s.Service = connect
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q", use)
+	}
+}
+
+type runState struct {
+	result    *Result
+	transport string
+	sender    ops.Sender
+	failure   string   // first step error, if any
+	composed  []string // IDs of the messages compose steps saved
+}
+
+// Run executes pb step by step. It stops at the first failing step unless that step
+// sets continue_on_error; later steps are reported as skipped. Each step is written to
+// the audit trail (not in dry-run mode, which makes no changes at all).
+func (r *Runner) Run(ctx context.Context, pb *Playbook) (*Result, error) {
+	if r.Store == nil {
+		return nil, fmt.Errorf("playbook: store is nil")
+	}
+	if err := pb.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.preflight(ctx, pb); err != nil {
+		return nil, err
+	}
+	ctx = store.WithActor(ctx, "playbook:"+pb.Name)
+
+	res := &Result{RunID: uuid.NewString(), Playbook: pb.Name, DryRun: r.DryRun, Started: time.Now()}
+	state := &runState{result: res}
+	r.audit(ctx, EventRunStarted, map[string]any{"run_id": res.RunID, "playbook": pb.Name, "steps": len(pb.Steps)})
+
+	stopped := false
+	for i, step := range pb.Steps {
+		sr := StepResult{Index: i + 1, Name: step.Label(), Action: step.Action()}
+		if stopped && !step.Always {
+			sr.Outcome = StepSkipped
+		} else {
+			started := time.Now()
+			detail, err := r.runStep(ctx, pb, step, state)
+			sr.Duration = time.Since(started)
+			sr.Detail = detail
+			switch {
+			case err != nil:
+				sr.Outcome, sr.Error = StepFailed, err.Error()
+				stopped = stopped || !step.ContinueOnError
+				if state.failure == "" {
+					state.failure = fmt.Sprintf("step %d (%s): %s", sr.Index, sr.Name, sr.Error)
+				}
+			case r.DryRun:
+				sr.Outcome = StepPlanned
+			default:
+				sr.Outcome = StepOK
+			}
+		}
+		res.Steps = append(res.Steps, sr)
+		r.progress(sr)
+		r.audit(ctx, EventStep, map[string]any{
+			"run_id": res.RunID, "playbook": pb.Name, "step": sr.Index, "name": sr.Name,
+			"action": sr.Action, "outcome": sr.Outcome, "detail": sr.Detail, "error": sr.Error,
+		})
+	}
+
+	res.Finished = time.Now()
+	outcome := StepOK
+	if !res.OK() {
+		outcome = StepFailed
+	}
+	r.audit(ctx, EventRunFinished, map[string]any{"run_id": res.RunID, "playbook": pb.Name, "outcome": outcome})
+	return res, nil
+}
+
+func (r *Runner) audit(ctx context.Context, kind string, p map[string]any) {
+	if r.DryRun {
+		return
+	}
+	_ = r.Store.RecordEvent(ctx, store.Event{Kind: kind, Payload: jsonPayload(p)})
+}
+
+func jsonPayload(p map[string]any) string {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+func (r *Runner) progress(sr StepResult) {
+	if r.Out == nil {
+		return
+	}
+	line := fmt.Sprintf("[%d] %-8s %-10s", sr.Index, sr.Outcome, sr.Action)
+	if sr.Name != sr.Action {
+		line += " " + sr.Name
+	}
+	if sr.Detail != "" {
+		line += ": " + sr.Detail
+	}
+	if sr.Error != "" {
+		line += " (" + sr.Error + ")"
+	}
+	fmt.Fprintln(r.Out, line)
+}
+
+func (r *Runner) runStep(ctx context.Context, pb *Playbook, step Step, state *runState) (string, error) {
+	switch {
+	case step.Compose != nil:
+		return r.compose(ctx, pb, step.Compose, state)
+	case step.Queue != nil:
+		if step.Queue.Composed {
+			if r.DryRun {
+				return "would queue the messages composed by this run", nil
+			}
+			n, err := r.Store.QueueByIDs(ctx, state.composed)
+			return fmt.Sprintf("queued %d", n), err
+		}
+		if r.DryRun {
+			return fmt.Sprintf("would queue messages tagged %q", step.Queue.Tag), nil
+		}
+		n, err := r.Store.QueueByTag(ctx, step.Queue.Tag)
+		return fmt.Sprintf("queued %d", n), err
+	case step.Transport != nil:
+		return r.selectTransport(ctx, pb, step.Transport, state)
+	case step.Connect != nil:
+		if r.DryRun {
+			return fmt.Sprintf("would connect via %s", state.transport), nil
+		}
+		c, ok := state.sender.(ops.Connector)
+		if !ok {
+			return "", fmt.Errorf("transport %s cannot connect on its own", state.transport)
+		}
+		sess, err := ops.ConnectOnce(ctx, r.Store, c)
+		if sess != nil {
+			return fmt.Sprintf("session %s out=%dB in=%dB", sess.ID, sess.BytesOut, sess.BytesIn), err
+		}
+		return "", err
+	case step.Send != nil:
+		if r.DryRun {
+			return fmt.Sprintf("would send queued messages tagged %q via %s", step.Send.Tag, state.transport), nil
+		}
+		limit := step.Send.Limit
+		if limit <= 0 {
+			limit = 25
+		}
+		sr, err := ops.SendQueued(ctx, r.Store, step.Send.Tag, limit, state.sender)
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("sent=%d failed=%d", sr.Sent, sr.Failed)
+		if sr.Failed > 0 {
+			return detail, fmt.Errorf("%d message(s) failed", sr.Failed)
+		}
+		return detail, nil
+	case step.Receive != nil:
+		return r.receive(ctx, step.Receive, state)
+	case step.Wait != nil:
+		d, _ := time.ParseDuration(step.Wait.Duration)
+		if r.DryRun {
+			return fmt.Sprintf("would wait %s", d), nil
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-t.C:
+			return fmt.Sprintf("waited %s", d), nil
+		}
+	case step.Assert != nil:
+		return r.assert(ctx, step.Assert, state)
+	case step.Record != nil:
+		return r.record(ctx, step.Record, state)
+	}
+	return "", fmt.Errorf("step has no action")
+}
+
+func (r *Runner) selectTransport(ctx context.Context, pb *Playbook, t *TransportStep, state *runState) (string, error) {
+	newTransport := r.NewTransport
+	if newTransport == nil {
+		newTransport = DefaultTransport
+	}
+	connect, err := render("connect", t.Connect, r.vars(pb, nil))
+	if err != nil {
+		return "", err
+	}
+	use := t.Use
+	detail := ""
+	if use == "auto" {
+		mode, err := r.policyMode(ctx, t.Tag)
+		if err != nil {
+			return "", err
+		}
+		use = "pat"
+		if connect == "" {
+			connect = pat.ConnectAlias(mode)
+		}
+		detail = fmt.Sprintf("policy chose %s, ", mode)
+	}
+	state.transport = use
+	detail += "using " + use
+	if connect != "" {
+		detail += " (" + connect + ")"
+	}
+	// Building a sender has no side effects (pat only reads its config), so a dry run
+	// does it too and catches a missing pat setup early.
+	s, err := newTransport(use, connect)
+	if err != nil {
+		return detail, err
+	}
+	state.sender = s
+	return detail, nil
+}
+
+// policyMode picks the mode for the queued messages tagged tag from their own
+// transport policy.
+func (r *Runner) policyMode(ctx context.Context, tag string) (core.Mode, error) {
+	queued, err := r.Store.ListQueued(ctx, tag, 100)
+	if err != nil {
+		return "", err
+	}
+	if r.DryRun && len(queued) == 0 {
+		// Nothing is queued yet in a dry run; fall back to the default policy.
+		queued = []*core.Message{core.NewMessage("", "")}
+	}
+	return ops.SelectMode(queued)
+}
+
+// vars merges, lowest precedence first: station profile and date/time defaults, the
+// playbook's vars, the step's vars and the runner's overrides.
+func (r *Runner) vars(pb *Playbook, step map[string]string) map[string]string {
+	vars := templates.StationVars()
+	for k, v := range templates.TimeVars(time.Now()) {
+		vars[k] = v
+	}
+	for _, m := range []map[string]string{pb.Vars, step, r.Vars} {
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// preflight checks every compose step's template against the vars it will get, so a
+// missing variable fails the run before anything is composed or queued.
+func (r *Runner) preflight(ctx context.Context, pb *Playbook) error {
+	for i, step := range pb.Steps {
+		if step.Compose == nil {
+			continue
+		}
+		t, err := composeTemplate(pb, step.Compose)
+		var vars map[string]string
+		if err == nil {
+			vars, err = templates.Vars(ctx, t, r.vars(pb, step.Compose.Vars))
+		}
+		if err == nil {
+			if missing := t.Missing(vars); len(missing) > 0 {
+				err = &templates.MissingError{Template: t.Name, Missing: missing}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Label(), err)
+		}
+	}
+	return nil
+}
+
+// composeTemplate builds the template for a compose step: a template file next to the
+// playbook, a named template, or the step's inline subject and body. The step's To and
+// Tags are added to the template's own.
+func composeTemplate(pb *Playbook, c *ComposeStep) (*templates.Template, error) {
+	var t *templates.Template
+	var err error
+	switch {
+	case c.Template == "":
+		t, err = templates.New("compose", c.Subject, c.Body)
+	default:
+		raw, rerr := readTemplate(pb, c.Template)
+		switch {
+		case rerr == nil:
+			t, err = templates.Parse(strings.TrimSuffix(path.Base(c.Template), templates.Ext), c.Template, string(raw))
+		case errors.Is(rerr, fs.ErrNotExist):
+			t, err = templates.Load(c.Template)
+		default:
+			err = rerr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	to, tags := []string{t.To}, []string{t.Tags}
+	to = append(to, c.To...)
+	tags = append(tags, c.Tags...)
+	merged := *t
+	merged.To = joinNonEmpty(to)
+	merged.Tags = joinNonEmpty(tags)
+	return &merged, nil
+}
+
+func joinNonEmpty(parts []string) string {
+	var out []string
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+func (r *Runner) compose(ctx context.Context, pb *Playbook, c *ComposeStep, state *runState) (string, error) {
+	t, err := composeTemplate(pb, c)
+	if err != nil {
+		return "", err
+	}
+	vars, err := templates.Vars(ctx, t, r.vars(pb, c.Vars))
+	if err != nil {
+		return "", err
+	}
+	out, err := t.Render(vars)
+	if err != nil {
+		return "", err
+	}
+
+	msg := core.NewMessage(out.Subject, out.Body)
+	for _, to := range out.To {
+		msg.To = append(msg.To, core.ParseAddress(to))
+	}
+	msg.Tags = append(msg.Tags, out.Tags...)
+	if c.Session != "" {
+		msg.Meta.Session = core.SessionMode(c.Session)
+	}
+
+	if r.DryRun {
+		return fmt.Sprintf("would compose %q", out.Subject), nil
+	}
+	if err := r.Store.SaveMessage(ctx, msg); err != nil {
+		return "", err
+	}
+	state.composed = append(state.composed, msg.ID)
+	return fmt.Sprintf("composed %s %q", msg.ID, out.Subject), nil
+}
+
+func readTemplate(pb *Playbook, name string) ([]byte, error) {
+	if filepath.IsAbs(name) || pb.Files == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(pb.Files, filepath.ToSlash(name))
+}
+
+func render(name, text string, vars map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"now": func(layout string) string { return time.Now().UTC().Format(layout) },
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (r *Runner) receive(ctx context.Context, rs *ReceiveStep, state *runState) (string, error) {
+	switch state.transport {
+	case "sim":
+		return "nothing to receive from sim", nil
+	case "pat":
+	default:
+		return "", fmt.Errorf("receive is not supported for %s", state.transport)
+	}
+
+	call := rs.Callsign
+	if call == "" {
+		cfg, _, err := pat.LoadConfig()
+		if err != nil {
+			return "", err
+		}
+		call = cfg.MyCall
+	}
+	mbox := rs.Mailbox
+	if mbox == "" {
+		var err error
+		if mbox, err = pat.MailboxDir(); err != nil {
+			return "", err
+		}
+	}
+	scope := rs.Scope
+	if scope == "" {
+		scope = runtime.IdentityScope(call)
+	}
+	if r.DryRun {
+		return fmt.Sprintf("would import %s mailbox %s into %s", call, mbox, scope), nil
+	}
+	rep, err := pat.ImportFromMailbox(ctx, r.Store, mbox, call, scope, false)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("scanned=%d created=%d updated=%d skipped=%d errors=%d", rep.Scanned, rep.Created, rep.Updated, rep.Skipped, rep.Errors), nil
+}
+
+func (r *Runner) assert(ctx context.Context, a *AssertStep, state *runState) (string, error) {
+	what := "messages"
+	if a.Status != "" {
+		what = a.Status + " messages"
+	}
+	if a.Tag != "" {
+		what += " tagged " + a.Tag
+	}
+	if r.DryRun {
+		// Earlier steps did not run, so a count would say nothing yet.
+		return "would check " + what, nil
+	}
+
+	f := store.MessageFilter{Tag: a.Tag}
+	if a.ThisRun {
+		f.Since = state.result.Started
+	}
+	msgs, err := r.Store.QueryMessages(ctx, f)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	for _, m := range msgs {
+		if a.Status == "" || string(m.Status) == a.Status {
+			n++
+		}
+	}
+
+	detail := fmt.Sprintf("%d %s", n, what)
+	if a.Min != nil && n < *a.Min {
+		return detail, fmt.Errorf("expected at least %d", *a.Min)
+	}
+	if a.Max != nil && n > *a.Max {
+		return detail, fmt.Errorf("expected at most %d", *a.Max)
+	}
+	return detail, nil
+}
+
+func (r *Runner) record(ctx context.Context, rs *RecordStep, state *runState) (string, error) {
+	scope := rs.Scope
+	if scope == "" {
+		scope = runtime.IdentityScope("")
+	}
+	if scope == "" {
+		return "", fmt.Errorf("record needs a scope (set RELAYOPS_CALLSIGN or scope:)")
+	}
+	p := store.Participation{
+		Scope:   scope,
+		Event:   rs.Event,
+		Date:    state.result.Started.Format("2006-01-02"),
+		Outcome: store.ParticipationCheckedIn,
+		RunID:   state.result.RunID,
+		Detail:  state.failure,
+	}
+	if state.failure != "" {
+		p.Outcome = store.ParticipationFailed
+	}
+	if r.DryRun {
+		return fmt.Sprintf("would record %s for %s on %s", rs.Event, scope, p.Date), nil
+	}
+
+	// With a tag, the check-in message decides: a sent check-in counts even if a later
+	// step (say, retrieving replies) failed. Only this run's compose steps are looked
+	// at, so a message with the same tag from another run or process cannot stand in.
+	if rs.Tag != "" {
+		var checkin *core.Message
+		for i := len(state.composed) - 1; i >= 0 && checkin == nil; i-- {
+			m, found, err := r.Store.GetMessage(ctx, state.composed[i])
+			if err != nil {
+				return "", err
+			}
+			if found && slices.Contains(m.Tags, rs.Tag) {
+				checkin = m
+			}
+		}
+		switch {
+		case checkin == nil:
+			p.Outcome = store.ParticipationFailed
+			if p.Detail == "" {
+				p.Detail = "no check-in message was composed"
+			}
+		case checkin.Status == core.StatusSent:
+			p.Outcome, p.MessageID = store.ParticipationCheckedIn, checkin.ID
+		default:
+			p.Outcome, p.MessageID = store.ParticipationFailed, checkin.ID
+			if p.Detail == "" {
+				p.Detail = "check-in is " + string(checkin.Status)
+			}
+		}
+	}
+	if err := r.Store.RecordParticipation(ctx, p); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s: %s", scope, rs.Event, p.Date, p.Outcome), nil
+}
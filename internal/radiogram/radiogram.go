@@ -0,0 +1,433 @@
+// Package radiogram models ARRL/NTS radiograms: the preamble (number, precedence,
+// handling instructions, station of origin, check, place, time and date), the address,
+// the text and the signature. It computes and verifies the check and converts
+// radiograms to and from the plain-text message bodies NTS digital stations exchange
+// over Winlink:
+//
+//	NR 1 R HXG W1AW 8 NEWINGTON CT 1830Z JUL 1
+//	DONALD R SMITH
+//	164 EAST SIXTH AVE
+//	NORTH RIVER CITY MO 00789
+//	555 1234
+//	BT
+//	HAPPY BIRTHDAY X SEE YOU SOON X LOVE
+//	BT
+//	DIANA
+//	AR
+package radiogram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precedence is the radiogram's priority. Emergency is always spelled out.
+type Precedence string
+
+const (
+	Routine   Precedence = "R"
+	Welfare   Precedence = "W"
+	Priority  Precedence = "P"
+	Emergency Precedence = "EMERGENCY"
+)
+
+// ParsePrecedence accepts the abbreviations and the spelled-out names.
+func ParsePrecedence(s string) (Precedence, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "R", "ROUTINE", "":
+		return Routine, nil
+	case "W", "WELFARE":
+		return Welfare, nil
+	case "P", "PRIORITY":
+		return Priority, nil
+	case "EMERGENCY", "E":
+		return Emergency, nil
+	}
+	return "", fmt.Errorf("unknown precedence %q (want R, W, P or EMERGENCY)", s)
+}
+
+// Radiogram is one NTS message.
+type Radiogram struct {
+	Number     int
+	Precedence Precedence
+	HX         string // handling instructions, e.g. "HXG" or "HXA50"; optional
+	Station    string // station of origin
+	Check      string // word count of the text, "ARL n" when it uses ARL numbered text
+	Place      string // place of origin, e.g. "NEWINGTON CT"
+	Time       string // filing time, e.g. "1830Z"; optional
+	Date       string // filing date, e.g. "JUL 1"
+	Address    []string
+	Text       string
+	Signature  string
+}
+
+// ComputeCheck counts the groups in text. Every space-separated group counts as one
+// word, including "X" (period) and "QUERY"; a text that uses ARL numbered messages gets
+// an "ARL " prefix.
+func ComputeCheck(text string) string {
+	words := strings.Fields(strings.ToUpper(text))
+	for _, w := range words {
+		if w == "ARL" {
+			return fmt.Sprintf("ARL %d", len(words))
+		}
+	}
+	return strconv.Itoa(len(words))
+}
+
+// FilingDate formats t the way the preamble writes dates, e.g. "JUL 1".
+func FilingDate(t time.Time) string {
+	return strings.ToUpper(t.Format("Jan")) + " " + strconv.Itoa(t.Day())
+}
+
+// FilingTime formats t as a UTC filing time, e.g. "1830Z".
+func FilingTime(t time.Time) string {
+	return t.UTC().Format("1504") + "Z"
+}
+
+var (
+	hxRe    = regexp.MustCompile(`^HX[A-G][0-9]*$`)
+	timeRe  = regexp.MustCompile(`^[0-9]{4}[A-Z]?$`)
+	callRe  = regexp.MustCompile(`^[A-Z0-9]{1,3}[0-9][A-Z0-9]{0,4}(/[A-Z0-9]+)?$`)
+	checkRe = regexp.MustCompile(`^(ARL )?[0-9]+$`)
+	textRe  = regexp.MustCompile(`^[A-Z0-9/ ]*$`)
+)
+
+var months = map[string]bool{
+	"JAN": true, "FEB": true, "MAR": true, "APR": true, "MAY": true, "JUN": true,
+	"JUL": true, "AUG": true, "SEP": true, "OCT": true, "NOV": true, "DEC": true,
+}
+
+func isMonth(s string) bool {
+	s = strings.ToUpper(s)
+	if len(s) < 3 {
+		return false
+	}
+	return months[s[:3]]
+}
+
+// Normalize upper-cases the radiogram and collapses runs of whitespace, and fills in
+// the check when it is empty.
+func (r *Radiogram) Normalize() {
+	clean := func(s string) string { return strings.Join(strings.Fields(strings.ToUpper(s)), " ") }
+	r.Precedence = Precedence(clean(string(r.Precedence)))
+	r.HX = clean(r.HX)
+	r.Station = clean(r.Station)
+	r.Check = clean(r.Check)
+	r.Place = clean(r.Place)
+	r.Time = clean(r.Time)
+	r.Date = clean(r.Date)
+	var addr []string
+	for _, a := range r.Address {
+		if a = clean(a); a != "" {
+			addr = append(addr, a)
+		}
+	}
+	r.Address = addr
+	r.Text = clean(r.Text)
+	r.Signature = clean(r.Signature)
+	if r.Check == "" {
+		r.Check = ComputeCheck(r.Text)
+	}
+}
+
+// Validate reports every problem with the radiogram at once.
+func (r *Radiogram) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if r.Number < 1 {
+		add("number must be a positive integer")
+	}
+	if _, err := ParsePrecedence(string(r.Precedence)); err != nil || r.Precedence == "" {
+		add("precedence %q is not R, W, P or EMERGENCY", r.Precedence)
+	}
+	if r.HX != "" && !hxRe.MatchString(r.HX) {
+		add("handling instructions %q are not HXA..HXG", r.HX)
+	}
+	if !callRe.MatchString(r.Station) {
+		add("station of origin %q is not a callsign", r.Station)
+	}
+	if r.Place == "" {
+		add("place of origin is required")
+	}
+	if r.Time != "" && !timeRe.MatchString(r.Time) {
+		add("filing time %q is not HHMM with an optional zone letter", r.Time)
+	}
+	if f := strings.Fields(r.Date); len(f) != 2 || !isMonth(f[0]) {
+		add("date %q is not MON DAY", r.Date)
+	} else if d, err := strconv.Atoi(f[1]); err != nil || d < 1 || d > 31 {
+		add("date %q has no valid day", r.Date)
+	}
+	if len(r.Address) == 0 {
+		add("address is required")
+	}
+	switch {
+	case strings.TrimSpace(r.Text) == "":
+		add("text is required")
+	case !textRe.MatchString(r.Text):
+		add("text may only hold letters, digits and / (write X for a period, QUERY for a question mark)")
+	}
+	if r.Signature == "" {
+		add("signature is required")
+	}
+	if want := ComputeCheck(r.Text); r.Check != want {
+		if !checkRe.MatchString(r.Check) {
+			add("check %q is not a word count", r.Check)
+		} else {
+			add("check %s does not match the text (counted %s)", r.Check, want)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("radiogram: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Preamble is the first line of the message, starting "NR".
+func (r *Radiogram) Preamble() string {
+	parts := []string{"NR", strconv.Itoa(r.Number), string(r.Precedence)}
+	if r.HX != "" {
+		parts = append(parts, r.HX)
+	}
+	parts = append(parts, r.Station, r.Check, r.Place)
+	if r.Time != "" {
+		parts = append(parts, r.Time)
+	}
+	parts = append(parts, r.Date)
+	return strings.Join(parts, " ")
+}
+
+// Format renders the radiogram as a message body.
+func (r *Radiogram) Format() string {
+	var b strings.Builder
+	b.WriteString(r.Preamble() + "\n")
+	for _, a := range r.Address {
+		b.WriteString(a + "\n")
+	}
+	b.WriteString("BT\n")
+	b.WriteString(r.Text + "\n")
+	b.WriteString("BT\n")
+	b.WriteString(r.Signature + "\n")
+	b.WriteString("AR\n")
+	return b.String()
+}
+
+// Describe renders the radiogram field by field for reading.
+func (r *Radiogram) Describe() string {
+	var b strings.Builder
+	row := func(label, v string) {
+		if v != "" {
+			fmt.Fprintf(&b, "%-12s %s\n", label+":", v)
+		}
+	}
+	b.WriteString("ARRL Radiogram\n==============\n")
+	row("Number", strconv.Itoa(r.Number))
+	row("Precedence", precedenceName(r.Precedence))
+	row("HX", r.HX)
+	row("Origin", r.Station)
+	check := r.Check
+	if want := ComputeCheck(r.Text); want != r.Check {
+		check += " (text counts " + want + ")"
+	}
+	row("Check", check)
+	row("Place", r.Place)
+	row("Time", r.Time)
+	row("Date", r.Date)
+	for i, a := range r.Address {
+		label := ""
+		if i == 0 {
+			label = "To:"
+		}
+		fmt.Fprintf(&b, "%-12s %s\n", label, a)
+	}
+	b.WriteString("\n" + r.Text + "\n\n")
+	row("Signature", r.Signature)
+	return b.String()
+}
+
+func precedenceName(p Precedence) string {
+	switch p {
+	case Routine:
+		return "R (Routine)"
+	case Welfare:
+		return "W (Welfare)"
+	case Priority:
+		return "P (Priority)"
+	}
+	return string(p)
+}
+
+// Subject is the Winlink subject NTS digital stations use: "QTC 1" and the
+// destination city line.
+func (r *Radiogram) Subject() string {
+	// The city/state/ZIP line is the last one after the name that is not a phone number
+	// or an email address.
+	dest := ""
+	for i := 1; i < len(r.Address); i++ {
+		if a := r.Address[i]; !strings.Contains(a, "@") && !isPhone(a) {
+			dest = a
+		}
+	}
+	return strings.TrimSpace("QTC 1 " + dest)
+}
+
+func isPhone(s string) bool {
+	digits := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == ' ' || c == '-' || c == '(' || c == ')' || c == '.':
+		default:
+			return false
+		}
+	}
+	return digits >= 7
+}
+
+// Parse reads a radiogram from a message body. Leading lines before the "NR" preamble
+// (greetings, routing notes) are skipped.
+func Parse(body string) (*Radiogram, error) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	i := 0
+	for i < len(lines) && !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(lines[i])), "NR ") {
+		i++
+	}
+	if i == len(lines) {
+		return nil, fmt.Errorf("radiogram: no NR preamble")
+	}
+	r, err := parsePreamble(strings.TrimSpace(lines[i]))
+	if err != nil {
+		return nil, err
+	}
+
+	// Address up to the first BT, text up to the second, then the signature up to AR.
+	section := 0
+	var text, sig []string
+	for _, line := range lines[i+1:] {
+		l := strings.ToUpper(strings.TrimSpace(line))
+		if l == "" {
+			continue
+		}
+		if l == "BT" {
+			section++
+			continue
+		}
+		if l == "AR" || strings.HasPrefix(l, "AR ") {
+			if section >= 2 {
+				section = 3
+				break
+			}
+		}
+		switch section {
+		case 0:
+			r.Address = append(r.Address, l)
+		case 1:
+			text = append(text, l)
+		case 2:
+			sig = append(sig, l)
+		}
+	}
+	if section < 2 {
+		return nil, fmt.Errorf("radiogram: missing BT separators")
+	}
+	r.Text = strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+	r.Signature = strings.Join(sig, " ")
+	return r, nil
+}
+
+func parsePreamble(line string) (*Radiogram, error) {
+	f := strings.Fields(strings.ToUpper(line))
+	bad := func(why string) error { return fmt.Errorf("radiogram: preamble %q: %s", line, why) }
+	if len(f) < 7 || f[0] != "NR" {
+		return nil, bad("too short")
+	}
+	r := &Radiogram{}
+	n, err := strconv.Atoi(f[1])
+	if err != nil {
+		return nil, bad("number is not numeric")
+	}
+	r.Number = n
+	if r.Precedence, err = ParsePrecedence(f[2]); err != nil {
+		return nil, bad(err.Error())
+	}
+	rest := f[3:]
+	if strings.HasPrefix(rest[0], "HX") {
+		r.HX, rest = rest[0], rest[1:]
+	}
+	if len(rest) < 4 {
+		return nil, bad("too short")
+	}
+	r.Station, rest = rest[0], rest[1:]
+	if rest[0] == "ARL" {
+		r.Check, rest = "ARL "+rest[1], rest[2:]
+	} else {
+		r.Check, rest = rest[0], rest[1:]
+	}
+
+	// The tail is [place...] [time] MON DAY.
+	if len(rest) < 3 || !isMonth(rest[len(rest)-2]) {
+		return nil, bad("no MON DAY date at the end")
+	}
+	r.Date = rest[len(rest)-2] + " " + rest[len(rest)-1]
+	rest = rest[:len(rest)-2]
+	if len(rest) > 1 && timeRe.MatchString(rest[len(rest)-1]) {
+		r.Time, rest = rest[len(rest)-1], rest[:len(rest)-1]
+	}
+	r.Place = strings.Join(rest, " ")
+	return r, nil
+}
+
+// Fields names the keys FromFields understands, in preamble order.
+var Fields = []string{"number", "precedence", "hx", "origin", "check", "place", "time", "date", "address", "text", "signature"}
+
+// FromFields builds a radiogram from key/value pairs (as given with -var on the command
+// line). Address lines are separated by "|". defaults fill keys that are not set.
+// The result is normalized; the check is computed unless given.
+func FromFields(values, defaults map[string]string) (*Radiogram, error) {
+	get := func(k string) string {
+		if v, ok := values[k]; ok {
+			return v
+		}
+		return defaults[k]
+	}
+	known := map[string]bool{}
+	for _, k := range Fields {
+		known[k] = true
+	}
+	for k := range values {
+		if !known[k] {
+			return nil, fmt.Errorf("radiogram has no field %q (have: %s)", k, strings.Join(Fields, ", "))
+		}
+	}
+
+	r := &Radiogram{
+		HX:        get("hx"),
+		Station:   get("origin"),
+		Check:     get("check"),
+		Place:     get("place"),
+		Time:      get("time"),
+		Date:      get("date"),
+		Address:   strings.Split(get("address"), "|"),
+		Text:      get("text"),
+		Signature: get("signature"),
+	}
+	if n := strings.TrimSpace(get("number")); n != "" {
+		num, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, fmt.Errorf("radiogram number %q is not numeric", n)
+		}
+		r.Number = num
+	}
+	p, err := ParsePrecedence(get("precedence"))
+	if err != nil {
+		return nil, err
+	}
+	r.Precedence = p
+	r.Normalize()
+	return r, nil
+}
@@ -0,0 +1,114 @@
+package radiogram
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// TestGolden builds each testdata/*.json radiogram, compares the message body with the
+// matching .golden file and parses the body back.
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.json")
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden inputs: %v", err)
+	}
+	for _, in := range inputs {
+		name := strings.TrimSuffix(filepath.Base(in), ".json")
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]string
+			if err := json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+			r, err := FromFields(fields, nil)
+			if err != nil {
+				t.Fatalf("FromFields: %v", err)
+			}
+			if err := r.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			got := r.Format()
+			golden := strings.TrimSuffix(in, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Fatalf("body mismatch:\n--- got\n%s--- want\n%s", got, want)
+			}
+
+			back, err := Parse("Traffic for your net\r\n\r\n" + strings.ReplaceAll(got, "\n", "\r\n"))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(back, r) {
+				t.Fatalf("round trip changed the radiogram:\n%+v\n%+v", back, r)
+			}
+		})
+	}
+}
+
+func TestComputeCheck(t *testing.T) {
+	cases := map[string]string{
+		"HAPPY BIRTHDAY X SEE YOU SOON X LOVE": "8",
+		"ARL SIXTY FOUR DENVER X LOVE":         "ARL 6",
+		"  ARRIVING  1830 FLIGHT 22/B  ":       "4",
+		"":                                     "0",
+	}
+	for text, want := range cases {
+		if got := ComputeCheck(text); got != want {
+			t.Errorf("ComputeCheck(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	r := &Radiogram{
+		Number: 0, Precedence: "Q", HX: "HXZ", Station: "NOT A CALL", Check: "9",
+		Date: "SMARCH 40", Text: "Hello. How are you?", Signature: "",
+	}
+	err := r.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"number", "precedence", "HXZ", "station of origin", "place", "date", "address", "write X for a period", "signature", "check 9"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error lacks %q: %v", want, err)
+		}
+	}
+}
+
+func TestParseRejectsNonRadiograms(t *testing.T) {
+	for _, body := range []string{
+		"Just a note, no preamble",
+		"NR 1 R W1AW 8 NEWINGTON CT JUL 1\nDONALD\nHAPPY BIRTHDAY",
+		"NR X R W1AW 8 NEWINGTON CT JUL 1\nA\nBT\nB\nBT\nC\nAR",
+	} {
+		if _, err := Parse(body); err == nil {
+			t.Errorf("Parse(%q) succeeded", body)
+		}
+	}
+}
+
+func TestSubject(t *testing.T) {
+	r := &Radiogram{Address: []string{"DONALD R SMITH", "164 EAST SIXTH AVE", "NORTH RIVER CITY MO 00789", "555 1234"}}
+	if got := r.Subject(); got != "QTC 1 NORTH RIVER CITY MO 00789" {
+		t.Fatalf("Subject = %q", got)
+	}
+}
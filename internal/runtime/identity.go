@@ -14,8 +14,16 @@ import (
 //
 // Callsign is taken from the argument when provided, otherwise from RELAYOPS_CALLSIGN.
 // Station is taken from RELAYOPS_STATION (default "default").
+//
+// RELAYOPS_SCOPE (a profile's scope setting) replaces the derived scope for the
+// configured callsign; other callsigns still get their own.
 func IdentityScope(callsign string) string {
 	cs := strings.ToUpper(strings.TrimSpace(callsign))
+	if scope := strings.TrimSpace(os.Getenv("RELAYOPS_SCOPE")); scope != "" {
+		if cs == "" || cs == strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN"))) {
+			return scope
+		}
+	}
 	if cs == "" {
 		cs = strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN")))
 	}
@@ -0,0 +1,28 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/4current/relayops/internal/runtime"
+)
+
+func TestIdentityScope(t *testing.T) {
+	t.Setenv("RELAYOPS_CALLSIGN", "ae4ok")
+	t.Setenv("RELAYOPS_STATION", "")
+	t.Setenv("RELAYOPS_SCOPE", "")
+	if got := runtime.IdentityScope(""); got != "AE4OK" {
+		t.Fatalf("IdentityScope = %q", got)
+	}
+	t.Setenv("RELAYOPS_STATION", "eoc")
+	if got := runtime.IdentityScope("w1aw"); got != "W1AW@eoc" {
+		t.Fatalf("IdentityScope(w1aw) = %q", got)
+	}
+
+	// A profile's scope replaces the derived one for its own callsign only.
+	t.Setenv("RELAYOPS_SCOPE", "AE4OK@county")
+	for call, want := range map[string]string{"": "AE4OK@county", "AE4OK": "AE4OK@county", "W1AW": "W1AW@eoc"} {
+		if got := runtime.IdentityScope(call); got != want {
+			t.Errorf("IdentityScope(%q) = %q, want %q", call, got, want)
+		}
+	}
+}
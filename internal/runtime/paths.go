@@ -38,3 +38,56 @@ func DBPath() (string, error) {
 	}
 	return filepath.Join(dir, "relayops.db"), nil
 }
+
+// TemplatesDir returns where user message templates live, e.g. ~/.relayops/templates.
+// RELAYOPS_TEMPLATES (set by a profile's templates_dir) overrides it.
+func TemplatesDir() (string, error) {
+	if v := os.Getenv("RELAYOPS_TEMPLATES"); v != "" {
+		return v, nil
+	}
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// FormsDir returns the forms catalog directory, e.g. ~/.relayops/forms.
+func FormsDir() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "forms"), nil
+}
+
+// ConfigPath returns the station profiles file, e.g. ~/.relayops/config.yaml.
+// RELAYOPS_CONFIG overrides it.
+func ConfigPath() (string, error) {
+	if v := os.Getenv("RELAYOPS_CONFIG"); v != "" {
+		return v, nil
+	}
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// DaemonConfigPath returns the daemon's schedule file, e.g. ~/.relayops/daemon.yaml.
+func DaemonConfigPath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.yaml"), nil
+}
+
+// APITokenPath returns where `relayops serve` keeps its bearer token, e.g. ~/.relayops/api-token.
+func APITokenPath() (string, error) {
+	dir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "api-token"), nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+func (s *Store) applyV11(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		// data is base64, sealed when encryption is enabled.
+		`CREATE TABLE IF NOT EXISTS message_attachments (
+			message_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			content_type TEXT NOT NULL DEFAULT '',
+			size INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (message_id, seq),
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v11: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV11, now); err != nil {
+		return fmt.Errorf("apply v11: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// insertAttachmentsTx writes msg's attachments in order.
+func (s *Store) insertAttachmentsTx(ctx context.Context, tx *sql.Tx, msg *core.Message) error {
+	for i, a := range msg.Attachments {
+		data, err := s.sealFieldTx(ctx, tx, base64.StdEncoding.EncodeToString(a.Data))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO message_attachments (message_id, seq, name, content_type, size, data)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			msg.ID, i, a.Name, a.ContentType, len(a.Data), data,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAttachments fills in the attachments of msgs.
+func (s *Store) loadAttachments(ctx context.Context, msgs ...*core.Message) error {
+	for _, m := range msgs {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT name, content_type, data FROM message_attachments
+			WHERE message_id = ? ORDER BY seq`, m.ID)
+		if err != nil {
+			return err
+		}
+		m.Attachments = nil
+		for rows.Next() {
+			var a core.Attachment
+			var data string
+			if err := rows.Scan(&a.Name, &a.ContentType, &data); err != nil {
+				rows.Close()
+				return err
+			}
+			if data, err = s.openField(data); err != nil {
+				rows.Close()
+				return err
+			}
+			if a.Data, err = base64.StdEncoding.DecodeString(data); err != nil {
+				rows.Close()
+				return fmt.Errorf("attachment %s of %s: %w", a.Name, m.ID, err)
+			}
+			m.Attachments = append(m.Attachments, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
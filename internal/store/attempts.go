@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attempt records a single delivery attempt for a message against a backend.
+// Attempts are append-only; the message row only carries the latest outcome.
+type Attempt struct {
+	ID         string
+	MessageID  string
+	Backend    string
+	ExternalID string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Outcome    string // "sent" or "failed"
+	Error      string
+}
+
+func (s *Store) applyV5(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS message_attempts (
+			id TEXT PRIMARY KEY,
+			message_id TEXT NOT NULL,
+			backend TEXT NOT NULL,
+			external_id TEXT NOT NULL DEFAULT '',
+			started_at TEXT NOT NULL,
+			finished_at TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_msg_attempts_message_id ON message_attempts(message_id);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v5: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV5, now); err != nil {
+		return fmt.Errorf("apply v5: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RecordAttempt appends a delivery attempt. If a.ID is empty a new one is generated;
+// re-recording an existing ID is a no-op so bundle imports stay idempotent.
+func (s *Store) RecordAttempt(ctx context.Context, a Attempt) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("RecordAttempt: store is nil")
+	}
+	if a.MessageID == "" || a.Backend == "" {
+		return fmt.Errorf("RecordAttempt: messageID/backend required")
+	}
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	if a.FinishedAt.IsZero() {
+		a.FinishedAt = time.Now()
+	}
+	if a.StartedAt.IsZero() {
+		a.StartedAt = a.FinishedAt
+	}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO message_attempts(
+			id, message_id, backend, external_id, started_at, finished_at, outcome, error
+		) VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+			a.ID, a.MessageID, a.Backend, a.ExternalID,
+			a.StartedAt.UTC().Format(time.RFC3339), a.FinishedAt.UTC().Format(time.RFC3339),
+			a.Outcome, a.Error,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind: EventAttempt, MessageID: a.MessageID,
+			Payload: payload(map[string]any{"attempt_id": a.ID, "backend": a.Backend, "external_id": a.ExternalID, "outcome": a.Outcome, "error": a.Error}),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("RecordAttempt: %w", err)
+	}
+	return nil
+}
+
+// ListAttempts returns the delivery attempts for a message, oldest first.
+func (s *Store) ListAttempts(ctx context.Context, messageID string) ([]Attempt, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, message_id, backend, external_id, started_at, finished_at, outcome, error
+		FROM message_attempts
+		WHERE message_id = ?
+		ORDER BY started_at ASC
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("ListAttempts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Attempt
+	for rows.Next() {
+		var a Attempt
+		var started, finished string
+		if err := rows.Scan(&a.ID, &a.MessageID, &a.Backend, &a.ExternalID, &started, &finished, &a.Outcome, &a.Error); err != nil {
+			return nil, fmt.Errorf("ListAttempts: %w", err)
+		}
+		a.StartedAt, _ = time.Parse(time.RFC3339, started)
+		a.FinishedAt, _ = time.Parse(time.RFC3339, finished)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event kinds written to the audit log.
+const (
+	EventMessageCreated  = "message.created"
+	EventMessageStatus   = "message.status"
+	EventMessageDeleted  = "message.deleted"
+	EventMessageMeta     = "message.meta"
+	EventMessageContent  = "message.content"
+	EventMessagePurged   = "message.purged"
+	EventExternalRef     = "external_ref.upsert"
+	EventBackendState    = "backend_state.upsert"
+	EventAttempt         = "attempt.recorded"
+	EventScopeCreated    = "scope.created"
+	EventScopeChanged    = "scope.changed"
+	EventRetentionPolicy = "retention.policy"
+	EventCryptoKey       = "crypto.key"
+	EventCredential      = "credential.changed"
+	EventSession         = "session.recorded"
+	EventParticipation   = "participation.recorded"
+	EventIdentity        = "identity.changed"
+)
+
+// Event is one append-only audit record. Hash chains every event to the one before it,
+// so editing or deleting a row breaks VerifyAuditChain from that point on.
+type Event struct {
+	Seq       int64
+	Time      time.Time
+	Actor     string
+	Kind      string
+	MessageID string
+	OldStatus string
+	NewStatus string
+	Payload   string // JSON object
+	PrevHash  string
+	Hash      string
+}
+
+// EventFilter narrows ListEvents. Zero values mean "no constraint".
+type EventFilter struct {
+	MessageID string
+	Since     time.Time
+	// Kind matches exactly, or by prefix when it ends in ".*" (e.g. "daemon.*").
+	Kind     string
+	AfterSeq int64
+	Limit    int
+	// Newest keeps the last Limit matching events instead of the first; they are
+	// still returned in chain order.
+	Newest bool
+}
+
+type actorKey struct{}
+
+// WithActor records who is mutating the store (e.g. "cli:send", "playbook:winlink-wednesday",
+// "importer:pat"). Nested actors are joined with "/" so the outermost caller is kept.
+func WithActor(ctx context.Context, actor string) context.Context {
+	actor = strings.TrimSpace(actor)
+	if prev, ok := ctx.Value(actorKey{}).(string); ok && prev != "" && actor != "" {
+		actor = prev + "/" + actor
+	}
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or "unknown".
+func ActorFrom(ctx context.Context) string {
+	if a, ok := ctx.Value(actorKey{}).(string); ok && a != "" {
+		return a
+	}
+	return "unknown"
+}
+
+func (s *Store) applyV8(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS events (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			message_id TEXT NOT NULL DEFAULT '',
+			old_status TEXT NOT NULL DEFAULT '',
+			new_status TEXT NOT NULL DEFAULT '',
+			payload_json TEXT NOT NULL DEFAULT '{}',
+			prev_hash TEXT NOT NULL,
+			hash TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_events_message_id ON events(message_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_events_ts ON events(ts);`,
+		// Append-only: refuse edits and deletes at the database level too.
+		`CREATE TRIGGER IF NOT EXISTS events_no_update BEFORE UPDATE ON events
+			BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;`,
+		`CREATE TRIGGER IF NOT EXISTS events_no_delete BEFORE DELETE ON events
+			BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v8: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV8, now); err != nil {
+		return fmt.Errorf("apply v8: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// withTx runs fn in a transaction and commits if it returns nil.
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appendEvent writes ev inside tx, chaining it to the latest event.
+func appendEvent(ctx context.Context, tx *sql.Tx, ev Event) error {
+	if ev.Payload == "" {
+		ev.Payload = "{}"
+	}
+	if ev.Actor == "" {
+		ev.Actor = ActorFrom(ctx)
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM events ORDER BY seq DESC LIMIT 1`).Scan(&ev.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("audit: %w", err)
+	}
+	ev.Hash = eventHash(ev)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO events(ts, actor, kind, message_id, old_status, new_status, payload_json, prev_hash, hash)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, ev.Time.UTC().Format(time.RFC3339Nano), ev.Actor, ev.Kind, ev.MessageID, ev.OldStatus, ev.NewStatus, ev.Payload, ev.PrevHash, ev.Hash)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+// payload marshals v for Event.Payload; audit payloads are small maps so errors are not expected.
+func payload(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+func eventHash(ev Event) string {
+	h := sha256.New()
+	for _, f := range []string{
+		ev.PrevHash,
+		ev.Time.UTC().Format(time.RFC3339Nano),
+		ev.Actor, ev.Kind, ev.MessageID, ev.OldStatus, ev.NewStatus, ev.Payload,
+	} {
+		// Length-prefix each field so values cannot be shifted between fields.
+		fmt.Fprintf(h, "%d:%s|", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RecordEvent appends a free-standing event (playbook steps, operator notes, ...).
+func (s *Store) RecordEvent(ctx context.Context, ev Event) error {
+	if ev.Kind == "" {
+		return fmt.Errorf("RecordEvent: kind required")
+	}
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		return appendEvent(ctx, tx, ev)
+	})
+}
+
+// LastEventSeq returns the sequence number of the newest event, or 0 if there are none.
+func (s *Store) LastEventSeq(ctx context.Context) (int64, error) {
+	var seq sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(seq) FROM events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("LastEventSeq: %w", err)
+	}
+	return seq.Int64, nil
+}
+
+// ListEvents returns audit events matching f in chain order. Limit keeps the oldest
+// matches unless f.Newest is set.
+func (s *Store) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	var where []string
+	var args []any
+	if f.MessageID != "" {
+		where = append(where, "message_id = ?")
+		args = append(args, f.MessageID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, f.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if prefix, ok := strings.CutSuffix(f.Kind, ".*"); ok {
+		where = append(where, "substr(kind, 1, ?) = ?")
+		args = append(args, len(prefix)+1, prefix+".")
+	} else if f.Kind != "" {
+		where = append(where, "kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.AfterSeq > 0 {
+		where = append(where, "seq > ?")
+		args = append(args, f.AfterSeq)
+	}
+
+	q := `SELECT seq, ts, actor, kind, message_id, old_status, new_status, payload_json, prev_hash, hash FROM events`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if f.Newest {
+		q += ` ORDER BY seq DESC`
+	} else {
+		q += ` ORDER BY seq ASC`
+	}
+	if f.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListEvents: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Event
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListEvents: %w", err)
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListEvents: %w", err)
+	}
+	if f.Newest {
+		slices.Reverse(out)
+	}
+	return out, nil
+}
+
+func scanEvent(row rowScanner) (Event, error) {
+	var ev Event
+	var ts string
+	if err := row.Scan(&ev.Seq, &ts, &ev.Actor, &ev.Kind, &ev.MessageID, &ev.OldStatus, &ev.NewStatus, &ev.Payload, &ev.PrevHash, &ev.Hash); err != nil {
+		return ev, err
+	}
+	ev.Time, _ = time.Parse(time.RFC3339Nano, ts)
+	return ev, nil
+}
+
+// VerifyAuditChain recomputes every hash in order. It returns the number of events checked
+// and, if the chain is broken, an error naming the first bad sequence number.
+func (s *Store) VerifyAuditChain(ctx context.Context) (int, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT seq, ts, actor, kind, message_id, old_status, new_status, payload_json, prev_hash, hash FROM events ORDER BY seq ASC`)
+	if err != nil {
+		return 0, fmt.Errorf("VerifyAuditChain: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	prev := ""
+	var lastSeq int64
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return n, fmt.Errorf("VerifyAuditChain: %w", err)
+		}
+		if lastSeq != 0 && ev.Seq != lastSeq+1 {
+			return n, fmt.Errorf("audit chain broken: events %d..%d are missing", lastSeq+1, ev.Seq-1)
+		}
+		if ev.PrevHash != prev {
+			return n, fmt.Errorf("audit chain broken at seq %d: prev_hash does not match seq %d", ev.Seq, lastSeq)
+		}
+		if eventHash(ev) != ev.Hash {
+			return n, fmt.Errorf("audit chain broken at seq %d: contents do not match hash", ev.Seq)
+		}
+		prev = ev.Hash
+		lastSeq = ev.Seq
+		n++
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,125 @@
+package store_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestAuditTrailRecordsMutations(t *testing.T) {
+	st, ctx := setupStore(t)
+	ctx = store.WithActor(ctx, "cli:test")
+
+	m := core.NewMessage("Audit", "Body")
+	if err := st.SaveMessage(ctx, m); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, m.ID, core.StatusQueued, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, m.ID, core.StatusFailed, "link dropped"); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+	if _, err := st.DeleteByID(ctx, m.ID); err != nil {
+		t.Fatalf("DeleteByID: %v", err)
+	}
+
+	evs, err := st.ListEvents(ctx, store.EventFilter{MessageID: m.ID})
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	want := []struct{ kind, old, new string }{
+		{store.EventMessageCreated, "", "draft"},
+		{store.EventMessageStatus, "draft", "queued"},
+		{store.EventMessageStatus, "queued", "failed"},
+		{store.EventMessageDeleted, "failed", "deleted"},
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(evs), len(want), evs)
+	}
+	for i, w := range want {
+		ev := evs[i]
+		if ev.Kind != w.kind || ev.OldStatus != w.old || ev.NewStatus != w.new {
+			t.Fatalf("event %d = %s %s->%s, want %s %s->%s", i, ev.Kind, ev.OldStatus, ev.NewStatus, w.kind, w.old, w.new)
+		}
+		if ev.Actor != "cli:test" {
+			t.Fatalf("event %d actor = %q", i, ev.Actor)
+		}
+	}
+	if !strings.Contains(evs[2].Payload, "link dropped") {
+		t.Fatalf("failure reason missing from payload: %s", evs[2].Payload)
+	}
+
+	n, err := st.VerifyAuditChain(ctx)
+	if err != nil {
+		t.Fatalf("VerifyAuditChain: %v", err)
+	}
+	if n < len(want) {
+		t.Fatalf("verified %d events, want at least %d", n, len(want))
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	m := core.NewMessage("Audit", "Body")
+	if err := st.SaveMessage(ctx, m); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, m.ID, core.StatusSent, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+
+	dbPath, err := runtime.DBPath()
+	if err != nil {
+		t.Fatalf("DBPath: %v", err)
+	}
+	raw, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open raw db: %v", err)
+	}
+	defer raw.Close()
+
+	// The trigger blocks ordinary edits.
+	if _, err := raw.ExecContext(ctx, `UPDATE events SET new_status = 'failed'`); err == nil {
+		t.Fatalf("expected append-only trigger to reject UPDATE")
+	}
+
+	// Someone with file access can drop it, but the chain still gives them away.
+	if _, err := raw.ExecContext(ctx, `DROP TRIGGER events_no_update`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if _, err := raw.ExecContext(ctx, `UPDATE events SET new_status = 'failed' WHERE kind = ?`, store.EventMessageStatus); err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+
+	if _, err := st.VerifyAuditChain(ctx); err == nil {
+		t.Fatalf("expected VerifyAuditChain to detect tampering")
+	}
+}
+
+func TestListEventsNewest(t *testing.T) {
+	st, ctx := setupStore(t)
+	for i := 0; i < 5; i++ {
+		if err := st.SaveMessage(ctx, core.NewMessage("Tail", "Body")); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	all, err := st.ListEvents(ctx, store.EventFilter{})
+	if err != nil || len(all) < 5 {
+		t.Fatalf("ListEvents: %d events, %v", len(all), err)
+	}
+
+	first, err := st.ListEvents(ctx, store.EventFilter{Limit: 2})
+	if err != nil || len(first) != 2 || first[0].Seq != all[0].Seq || first[1].Seq != all[1].Seq {
+		t.Fatalf("Limit 2 = %+v, %v; want the two oldest", first, err)
+	}
+	last, err := st.ListEvents(ctx, store.EventFilter{Limit: 2, Newest: true})
+	if err != nil || len(last) != 2 || last[0].Seq != all[len(all)-2].Seq || last[1].Seq != all[len(all)-1].Seq {
+		t.Fatalf("Limit 2 Newest = %+v, %v; want the two newest in chain order", last, err)
+	}
+}
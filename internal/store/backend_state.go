@@ -2,10 +2,21 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 )
 
+// BackendState is the last known folder/state of a message in a backend's own mailbox.
+type BackendState struct {
+	MessageID string
+	Backend   string
+	Folder    string
+	State     string
+	UpdatedAt time.Time
+	ExtraJSON string
+}
+
 // UpsertBackendState stores per-backend folder/state information for a message.
 // This is intentionally separate from core.Message.Status, which is RelayOps-local.
 func (s *Store) UpsertBackendState(ctx context.Context, messageID, backend, folder, state, extraJSON string) error {
@@ -20,7 +31,8 @@ func (s *Store) UpsertBackendState(ctx context.Context, messageID, backend, fold
 	}
 
 	now := time.Now().UTC().Format(time.RFC3339)
-	_, err := s.db.ExecContext(ctx, `
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
 		INSERT INTO message_backend_state(message_id, backend, folder, state, updated_at, extra_json)
 		VALUES(?, ?, ?, ?, ?, ?)
 		ON CONFLICT(message_id, backend) DO UPDATE SET
@@ -28,9 +40,45 @@ func (s *Store) UpsertBackendState(ctx context.Context, messageID, backend, fold
 			state = excluded.state,
 			updated_at = excluded.updated_at,
 			extra_json = excluded.extra_json
-	`, messageID, backend, folder, state, now, extraJSON)
+	`, messageID, backend, folder, state, now, extraJSON); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind: EventBackendState, MessageID: messageID,
+			Payload: payload(map[string]any{"backend": backend, "folder": folder, "state": state}),
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("UpsertBackendState: %w", err)
 	}
 	return nil
 }
+
+// ListBackendState returns the per-backend state rows for a message.
+func (s *Store) ListBackendState(ctx context.Context, messageID string) ([]BackendState, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("ListBackendState: store is nil")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT message_id, backend, folder, state, updated_at, extra_json
+		FROM message_backend_state
+		WHERE message_id = ?
+		ORDER BY backend
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("ListBackendState: %w", err)
+	}
+	defer rows.Close()
+
+	var out []BackendState
+	for rows.Next() {
+		var b BackendState
+		var updatedAt string
+		if err := rows.Scan(&b.MessageID, &b.Backend, &b.Folder, &b.State, &updatedAt, &b.ExtraJSON); err != nil {
+			return nil, fmt.Errorf("ListBackendState: %w", err)
+		}
+		b.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SetCredential stores a secret (e.g. a Winlink account password) under name,
+// such as "winlink:AE4OK". Secrets are sealed when encryption is enabled.
+func (s *Store) SetCredential(ctx context.Context, name, secret string) error {
+	if name == "" {
+		return fmt.Errorf("SetCredential: name required")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		sealed, err := s.sealFieldTx(ctx, tx, secret)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO credentials(name, secret, updated_at) VALUES(?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET secret = excluded.secret, updated_at = excluded.updated_at
+	`, name, sealed, now); err != nil {
+			return err
+		}
+		// Never put the secret itself in the audit log.
+		return appendEvent(ctx, tx, Event{Kind: EventCredential, Payload: payload(map[string]any{"op": "set", "name": name})})
+	})
+	if err != nil {
+		return fmt.Errorf("SetCredential: %w", err)
+	}
+	return nil
+}
+
+// GetCredential returns the secret stored under name.
+func (s *Store) GetCredential(ctx context.Context, name string) (string, bool, error) {
+	var v string
+	err := s.db.QueryRowContext(ctx, `SELECT secret FROM credentials WHERE name = ?`, name).Scan(&v)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("GetCredential: %w", err)
+	}
+	plain, err := s.openField(v)
+	if err != nil {
+		return "", false, fmt.Errorf("GetCredential: %w", err)
+	}
+	return plain, true, nil
+}
+
+// ListCredentialNames returns the names of stored credentials (never the secrets).
+func (s *Store) ListCredentialNames(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM credentials ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("ListCredentialNames: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("ListCredentialNames: %w", err)
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// DeleteCredential removes a stored credential. It reports whether one existed.
+func (s *Store) DeleteCredential(ctx context.Context, name string) (bool, error) {
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM credentials WHERE name = ?`, name)
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventCredential, Payload: payload(map[string]any{"op": "delete", "name": name})})
+	})
+	if err != nil {
+		return false, fmt.Errorf("DeleteCredential: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,440 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/4current/relayops/internal/vault"
+)
+
+// ErrLocked is returned when an encrypted value is needed but the store has not been unlocked.
+var ErrLocked = errors.New("store is locked (set RELAYOPS_PASSPHRASE or RELAYOPS_KEY_FILE)")
+
+// ErrKeyChanged is returned when another process rotated the data key after this store
+// was opened and the new key cannot be loaded from the environment.
+var ErrKeyChanged = errors.New("store key was rotated by another process (reopen with the new passphrase or key file)")
+
+// EncryptionStatus describes the at-rest encryption state of the store.
+type EncryptionStatus struct {
+	Enabled      bool
+	Unlocked     bool
+	ActiveKeyID  string
+	KDF          string
+	SealedFields int // message bodies/metadata, credentials and session transcripts currently sealed
+	PlainFields  int // the same fields still stored in plaintext
+}
+
+func (s *Store) applyV7(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		// Data keys are never stored; only how to re-derive them (kdf + salt) and their id.
+		`CREATE TABLE IF NOT EXISTS crypto_keys (
+			key_id TEXT PRIMARY KEY,
+			kdf TEXT NOT NULL,
+			salt TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			retired_at TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS credentials (
+			name TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v7: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV7, now); err != nil {
+		return fmt.Errorf("apply v7: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+type keyRow struct {
+	id, kdf, salt string
+}
+
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// activeKeyRow reads the active key from q, the store's db or a transaction.
+func activeKeyRow(ctx context.Context, q rowQuerier) (*keyRow, error) {
+	var r keyRow
+	err := q.QueryRowContext(ctx,
+		`SELECT key_id, kdf, salt FROM crypto_keys WHERE retired_at IS NULL ORDER BY created_at DESC LIMIT 1`,
+	).Scan(&r.id, &r.kdf, &r.salt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *Store) loadKeyState(ctx context.Context) error {
+	row, err := activeKeyRow(ctx, s.db)
+	if err != nil {
+		return fmt.Errorf("load key state: %w", err)
+	}
+	s.encrypted.Store(row != nil)
+	return nil
+}
+
+// syncKeyTx brings the key state read at Open up to date with crypto_keys as tx sees
+// it: another handle (the daemon, a second CLI) may have enabled encryption or rotated
+// the key since. A new key is loaded from RELAYOPS_PASSPHRASE or RELAYOPS_KEY_FILE when
+// they unlock it; otherwise writes are refused rather than sealed under a retired key.
+func (s *Store) syncKeyTx(ctx context.Context, tx *sql.Tx) error {
+	row, err := activeKeyRow(ctx, tx)
+	if err != nil {
+		return err
+	}
+	s.encrypted.Store(row != nil)
+	if row == nil {
+		return nil
+	}
+	prev := s.ring.Active()
+	if prev != nil && prev.ID == row.id {
+		return nil
+	}
+	if secret := vault.SecretFromEnv(); !secret.Empty() {
+		if k, err := keyFromSecret(secret, row.kdf, row.salt); err == nil && k.ID == row.id {
+			s.ring.Add(k, true)
+			return nil
+		}
+	}
+	if prev == nil {
+		return ErrLocked
+	}
+	return ErrKeyChanged
+}
+
+// Locked reports whether the store holds encrypted data it cannot currently read.
+func (s *Store) Locked() bool {
+	return s.encrypted.Load() && s.ring.Active() == nil
+}
+
+// Unlock loads the active data key from a passphrase or key file.
+func (s *Store) Unlock(ctx context.Context, secret vault.Secret) error {
+	row, err := activeKeyRow(ctx, s.db)
+	if err != nil {
+		return fmt.Errorf("Unlock: %w", err)
+	}
+	if row == nil {
+		return fmt.Errorf("Unlock: encryption is not enabled")
+	}
+
+	k, err := keyFromSecret(secret, row.kdf, row.salt)
+	if err != nil {
+		return fmt.Errorf("Unlock: %w", err)
+	}
+	if k.ID != row.id {
+		return fmt.Errorf("Unlock: wrong passphrase or key file")
+	}
+	s.ring.Add(k, true)
+	return nil
+}
+
+// EnableEncryption creates the first data key and seals every existing message body,
+// metadata blob, credential and session transcript with it.
+func (s *Store) EnableEncryption(ctx context.Context, secret vault.Secret) (string, error) {
+	if s.encrypted.Load() {
+		return "", fmt.Errorf("EnableEncryption: already enabled (use rotate)")
+	}
+	return s.installKey(ctx, secret)
+}
+
+// RotateKey re-seals everything under a key derived from newSecret and retires the old key.
+// The store must be unlocked.
+func (s *Store) RotateKey(ctx context.Context, newSecret vault.Secret) (string, error) {
+	if !s.encrypted.Load() {
+		return "", fmt.Errorf("RotateKey: encryption is not enabled")
+	}
+	if s.Locked() {
+		return "", fmt.Errorf("RotateKey: %w", ErrLocked)
+	}
+	return s.installKey(ctx, newSecret)
+}
+
+func (s *Store) installKey(ctx context.Context, secret vault.Secret) (string, error) {
+	kdf, saltStr := vault.KDFKeyFile, ""
+	if secret.KeyFile == "" {
+		salt, err := vault.NewSalt()
+		if err != nil {
+			return "", err
+		}
+		kdf, saltStr = vault.KDFPassphrase, base64.StdEncoding.EncodeToString(salt)
+	}
+	k, err := keyFromSecret(secret, kdf, saltStr)
+	if err != nil {
+		return "", err
+	}
+	if prev := s.ring.Active(); prev != nil && prev.ID == k.ID {
+		return "", fmt.Errorf("new key is the same as the current key")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Another handle may have enabled or rotated encryption since this one read its key.
+	row, err := activeKeyRow(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+	if prev := s.ring.Active(); row != nil && prev == nil {
+		return "", fmt.Errorf("encryption is already enabled (use rotate)")
+	} else if row != nil && prev.ID != row.id {
+		return "", ErrKeyChanged
+	}
+
+	if err := s.resealAllTx(ctx, tx, k); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `UPDATE crypto_keys SET retired_at = ? WHERE retired_at IS NULL`, now); err != nil {
+		return "", fmt.Errorf("retire keys: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO crypto_keys(key_id, kdf, salt, created_at) VALUES(?, ?, ?, ?)`,
+		k.ID, kdf, saltStr, now,
+	); err != nil {
+		return "", fmt.Errorf("record key: %w", err)
+	}
+	prevID := ""
+	if prev := s.ring.Active(); prev != nil {
+		prevID = prev.ID
+	}
+	if err := appendEvent(ctx, tx, Event{
+		Kind:    EventCryptoKey,
+		Payload: payload(map[string]any{"key_id": k.ID, "kdf": kdf, "previous_key_id": prevID}),
+	}); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	s.ring.Add(k, true)
+	s.encrypted.Store(true)
+	return k.ID, nil
+}
+
+// resealAllTx opens every sealable field with the current ring and seals it with k.
+func (s *Store) resealAllTx(ctx context.Context, tx *sql.Tx, k *vault.Key) error {
+	type fieldRow struct{ key, a, b string }
+
+	var msgs []fieldRow
+	rows, err := tx.QueryContext(ctx, `SELECT id, body, meta_json FROM messages`)
+	if err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+	for rows.Next() {
+		var r fieldRow
+		if err := rows.Scan(&r.key, &r.a, &r.b); err != nil {
+			rows.Close()
+			return fmt.Errorf("reseal: %w", err)
+		}
+		msgs = append(msgs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+
+	var creds []fieldRow
+	rows, err = tx.QueryContext(ctx, `SELECT name, secret FROM credentials`)
+	if err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+	for rows.Next() {
+		var r fieldRow
+		if err := rows.Scan(&r.key, &r.a); err != nil {
+			rows.Close()
+			return fmt.Errorf("reseal: %w", err)
+		}
+		creds = append(creds, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+
+	var transcripts []fieldRow
+	rows, err = tx.QueryContext(ctx, `SELECT id, transcript FROM sessions WHERE transcript != ''`)
+	if err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+	for rows.Next() {
+		var r fieldRow
+		if err := rows.Scan(&r.key, &r.a); err != nil {
+			rows.Close()
+			return fmt.Errorf("reseal: %w", err)
+		}
+		transcripts = append(transcripts, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+
+	var attachments []fieldRow
+	rows, err = tx.QueryContext(ctx, `SELECT message_id, seq, data FROM message_attachments`)
+	if err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+	for rows.Next() {
+		var r fieldRow
+		if err := rows.Scan(&r.key, &r.b, &r.a); err != nil {
+			rows.Close()
+			return fmt.Errorf("reseal: %w", err)
+		}
+		attachments = append(attachments, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reseal: %w", err)
+	}
+
+	reseal := func(v string) (string, error) {
+		plain, err := s.openField(v)
+		if err != nil {
+			return "", err
+		}
+		return k.Seal(plain)
+	}
+
+	for _, r := range msgs {
+		body, err := reseal(r.a)
+		if err != nil {
+			return fmt.Errorf("reseal message %s: %w", r.key, err)
+		}
+		meta, err := reseal(r.b)
+		if err != nil {
+			return fmt.Errorf("reseal message %s: %w", r.key, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE messages SET body = ?, meta_json = ? WHERE id = ?`, body, meta, r.key); err != nil {
+			return fmt.Errorf("reseal message %s: %w", r.key, err)
+		}
+	}
+	for _, r := range creds {
+		secret, err := reseal(r.a)
+		if err != nil {
+			return fmt.Errorf("reseal credential %s: %w", r.key, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE credentials SET secret = ? WHERE name = ?`, secret, r.key); err != nil {
+			return fmt.Errorf("reseal credential %s: %w", r.key, err)
+		}
+	}
+	for _, r := range attachments {
+		data, err := reseal(r.a)
+		if err != nil {
+			return fmt.Errorf("reseal attachment %s/%s: %w", r.key, r.b, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE message_attachments SET data = ? WHERE message_id = ? AND seq = ?`, data, r.key, r.b); err != nil {
+			return fmt.Errorf("reseal attachment %s/%s: %w", r.key, r.b, err)
+		}
+	}
+	for _, r := range transcripts {
+		transcript, err := reseal(r.a)
+		if err != nil {
+			return fmt.Errorf("reseal session %s: %w", r.key, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET transcript = ? WHERE id = ?`, transcript, r.key); err != nil {
+			return fmt.Errorf("reseal session %s: %w", r.key, err)
+		}
+	}
+	return nil
+}
+
+// EncryptionStatus reports whether encryption is on and how much data is sealed.
+func (s *Store) EncryptionStatus(ctx context.Context) (*EncryptionStatus, error) {
+	st := &EncryptionStatus{Enabled: s.encrypted.Load(), Unlocked: !s.Locked()}
+	if row, err := activeKeyRow(ctx, s.db); err != nil {
+		return nil, fmt.Errorf("EncryptionStatus: %w", err)
+	} else if row != nil {
+		st.ActiveKeyID, st.KDF = row.id, row.kdf
+	}
+
+	q := `SELECT
+		(SELECT COUNT(1) FROM messages WHERE body LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM messages WHERE meta_json LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM credentials WHERE secret LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM sessions WHERE transcript LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM message_attachments WHERE data LIKE 'enc:v1:%'),
+		(SELECT COUNT(1) FROM messages WHERE body NOT LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM messages WHERE meta_json NOT LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM credentials WHERE secret NOT LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM sessions WHERE transcript != '' AND transcript NOT LIKE 'enc:v1:%') +
+		(SELECT COUNT(1) FROM message_attachments WHERE data NOT LIKE 'enc:v1:%')`
+	if err := s.db.QueryRowContext(ctx, q).Scan(&st.SealedFields, &st.PlainFields); err != nil {
+		return nil, fmt.Errorf("EncryptionStatus: %w", err)
+	}
+	return st, nil
+}
+
+// sealFieldTx encrypts v when encryption is enabled and returns it unchanged otherwise.
+// The key is checked against tx first (see syncKeyTx), so the value is sealed with the
+// key that is active when the write commits.
+func (s *Store) sealFieldTx(ctx context.Context, tx *sql.Tx, v string) (string, error) {
+	if err := s.syncKeyTx(ctx, tx); err != nil {
+		return "", err
+	}
+	if !s.encrypted.Load() {
+		return v, nil
+	}
+	out, err := s.ring.Seal(v)
+	if errors.Is(err, vault.ErrNoKey) {
+		return "", ErrLocked
+	}
+	return out, err
+}
+
+// openField decrypts v if it is sealed; plaintext passes through.
+func (s *Store) openField(v string) (string, error) {
+	out, err := s.ring.Open(v)
+	if errors.Is(err, vault.ErrNoKey) {
+		return "", ErrLocked
+	}
+	return out, err
+}
+
+func keyFromSecret(secret vault.Secret, kdf, saltStr string) (*vault.Key, error) {
+	switch kdf {
+	case vault.KDFKeyFile:
+		if secret.KeyFile == "" {
+			return nil, fmt.Errorf("store key is a key file; set RELAYOPS_KEY_FILE")
+		}
+		return vault.LoadKeyFile(secret.KeyFile)
+	case vault.KDFPassphrase:
+		if secret.Passphrase == "" {
+			return nil, fmt.Errorf("store key is passphrase-derived; set RELAYOPS_PASSPHRASE")
+		}
+		salt, err := base64.StdEncoding.DecodeString(saltStr)
+		if err != nil {
+			return nil, fmt.Errorf("bad key salt: %w", err)
+		}
+		return vault.DeriveKey(secret.Passphrase, salt)
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q", kdf)
+	}
+}
@@ -0,0 +1,156 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/vault"
+)
+
+func reopen(t *testing.T, ctx context.Context, passphrase, keyFile string) *store.Store {
+	t.Helper()
+	_ = os.Setenv("RELAYOPS_PASSPHRASE", passphrase)
+	_ = os.Setenv("RELAYOPS_KEY_FILE", keyFile)
+	t.Cleanup(func() {
+		_ = os.Unsetenv("RELAYOPS_PASSPHRASE")
+		_ = os.Unsetenv("RELAYOPS_KEY_FILE")
+	})
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func TestEncryptionEnableLockAndRotate(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	msg := core.NewMessage("ICS-213 resource request", "Patient: Jane Doe, DOB 1970-01-01")
+	msg.Attachments = []core.Attachment{{Name: "RMS_Express_Form_ICS213_Initial_Viewer.xml", ContentType: "text/xml", Data: []byte("<RMS_Express_Form/>")}}
+	if err := st.SaveMessage(ctx, msg); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := st.SetCredential(ctx, "winlink:AE4OK", "hunter2"); err != nil {
+		t.Fatalf("SetCredential: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "relayops.key")
+	if err := vault.GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	if _, err := st.EnableEncryption(ctx, vault.Secret{KeyFile: keyFile}); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+	status, err := st.EncryptionStatus(ctx)
+	if err != nil {
+		t.Fatalf("EncryptionStatus: %v", err)
+	}
+	if !status.Enabled || status.PlainFields != 0 || status.SealedFields != 4 {
+		t.Fatalf("expected all fields sealed, got %+v", status)
+	}
+	_ = st.Close()
+
+	// Locked: listings work, bodies and search do not.
+	locked := reopen(t, ctx, "", "")
+	if !locked.Locked() {
+		t.Fatalf("expected store to be locked without a key")
+	}
+	if _, err := locked.ListMessages(ctx, 10, false); err != nil {
+		t.Fatalf("ListMessages while locked: %v", err)
+	}
+	if _, _, err := locked.GetMessage(ctx, msg.ID); !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("GetMessage while locked: want ErrLocked, got %v", err)
+	}
+	if _, err := locked.SearchMessages(ctx, "jane", 10); !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("SearchMessages while locked: want ErrLocked, got %v", err)
+	}
+	if err := locked.SaveMessage(ctx, core.NewMessage("new", "body")); !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("SaveMessage while locked: want ErrLocked, got %v", err)
+	}
+	_ = locked.Close()
+
+	unlocked := reopen(t, ctx, "", keyFile)
+	got, _, err := unlocked.GetMessage(ctx, msg.ID)
+	if err != nil {
+		t.Fatalf("GetMessage unlocked: %v", err)
+	}
+	if got.Body != msg.Body {
+		t.Fatalf("body mismatch: %q", got.Body)
+	}
+	if len(got.Attachments) != 1 || string(got.Attachments[0].Data) != "<RMS_Express_Form/>" {
+		t.Fatalf("attachment mismatch: %+v", got.Attachments)
+	}
+	hits, err := unlocked.SearchMessages(ctx, "jane", 10)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("SearchMessages unlocked: hits=%d err=%v", len(hits), err)
+	}
+
+	if _, err := unlocked.RotateKey(ctx, vault.Secret{Passphrase: "correct horse battery staple"}); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	_ = unlocked.Close()
+
+	if _, err := store.Open(ctx); err == nil {
+		t.Fatalf("expected old key file to be rejected after rotation")
+	}
+
+	rotated := reopen(t, ctx, "correct horse battery staple", "")
+	secret, ok, err := rotated.GetCredential(ctx, "winlink:AE4OK")
+	if err != nil || !ok || secret != "hunter2" {
+		t.Fatalf("GetCredential after rotation: %q ok=%v err=%v", secret, ok, err)
+	}
+}
+
+func TestKeyChangesFromAnotherHandle(t *testing.T) {
+	early, ctx := setupStore(t) // opened before encryption is enabled
+
+	keyFile := filepath.Join(t.TempDir(), "relayops.key")
+	if err := vault.GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	first := reopen(t, ctx, "", keyFile)
+	if _, err := first.EnableEncryption(ctx, vault.Secret{KeyFile: keyFile}); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+
+	// early has no key of its own; it picks one up from the environment or refuses
+	// rather than writing plaintext into an encrypted store.
+	_ = os.Unsetenv("RELAYOPS_KEY_FILE")
+	if err := early.SaveMessage(ctx, core.NewMessage("early", "plaintext?")); !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("SaveMessage after another handle enabled encryption: want ErrLocked, got %v", err)
+	}
+	_ = os.Setenv("RELAYOPS_KEY_FILE", keyFile)
+	if err := early.SaveMessage(ctx, core.NewMessage("early", "sealed")); err != nil {
+		t.Fatalf("SaveMessage with the key in the environment: %v", err)
+	}
+
+	second := reopen(t, ctx, "", keyFile)
+	if _, err := second.RotateKey(ctx, vault.Secret{Passphrase: "correct horse battery staple"}); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+
+	// first still holds the retired key.
+	if err := first.SaveMessage(ctx, core.NewMessage("stale", "body")); !errors.Is(err, store.ErrKeyChanged) {
+		t.Fatalf("SaveMessage with a retired key: want ErrKeyChanged, got %v", err)
+	}
+	if _, err := first.RotateKey(ctx, vault.Secret{Passphrase: "another"}); !errors.Is(err, store.ErrKeyChanged) {
+		t.Fatalf("RotateKey with a retired key: want ErrKeyChanged, got %v", err)
+	}
+
+	_ = os.Setenv("RELAYOPS_PASSPHRASE", "correct horse battery staple")
+	_ = os.Unsetenv("RELAYOPS_KEY_FILE")
+	msg := core.NewMessage("fresh", "sealed with the new key")
+	if err := first.SaveMessage(ctx, msg); err != nil {
+		t.Fatalf("SaveMessage after loading the new key: %v", err)
+	}
+	got, _, err := second.GetMessage(ctx, msg.ID)
+	if err != nil || got.Body != msg.Body {
+		t.Fatalf("GetMessage from the rotating handle: %+v, %v", got, err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/4current/relayops/internal/core"
 	"github.com/google/uuid"
 )
 
@@ -39,7 +41,8 @@ func (s *Store) UpsertExternalRef(ctx context.Context, messageID, backend, exter
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	// Ensure uniqueness by (backend, external_id, scope). If already exists, update message_id and metadata.
-	_, err := s.db.ExecContext(ctx, `
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
 	INSERT INTO message_external_refs(
 		id, message_id, backend, external_id, scope, meta_json, created_at, updated_at
 	) VALUES(?, ?, ?, ?, ?, ?, ?, ?)
@@ -48,8 +51,15 @@ func (s *Store) UpsertExternalRef(ctx context.Context, messageID, backend, exter
 		meta_json = excluded.meta_json,
 		updated_at = excluded.updated_at
 	`,
-		uuid.NewString(), messageID, backend, externalID, scope, metaJSON, now, now,
-	)
+			uuid.NewString(), messageID, backend, externalID, scope, metaJSON, now, now,
+		); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind: EventExternalRef, MessageID: messageID,
+			Payload: payload(map[string]any{"backend": backend, "external_id": externalID, "scope": scope}),
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("UpsertExternalRef: %w", err)
 	}
@@ -74,3 +84,106 @@ func (s *Store) GetMessageIDByExternalRef(ctx context.Context, backend, external
 	}
 	return messageID, true, nil
 }
+
+// ListExternalRefs returns all backend references for a message.
+func (s *Store) ListExternalRefs(ctx context.Context, messageID string) ([]ExternalRef, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("ListExternalRefs: store is nil")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, message_id, backend, external_id, scope, meta_json, created_at, updated_at
+		FROM message_external_refs
+		WHERE message_id = ?
+		ORDER BY backend, scope, external_id
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("ListExternalRefs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ExternalRef
+	for rows.Next() {
+		var r ExternalRef
+		var createdAt, updatedAt string
+		if err := rows.Scan(&r.ID, &r.MessageID, &r.Backend, &r.ExternalID, &r.Scope, &r.MetaJSON, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("ListExternalRefs: %w", err)
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// FindExternalRefs returns the references to a backend identifier in every scope.
+// Backend ids like PAT MIDs are unique, so this finds a message whatever scope an
+// import routed it into.
+func (s *Store) FindExternalRefs(ctx context.Context, backend, externalID string) ([]ExternalRef, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("FindExternalRefs: store is nil")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, message_id, backend, external_id, scope, meta_json, created_at, updated_at
+		FROM message_external_refs
+		WHERE backend = ? AND external_id = ?
+		ORDER BY scope
+	`, backend, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("FindExternalRefs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ExternalRef
+	for rows.Next() {
+		var r ExternalRef
+		var createdAt, updatedAt string
+		if err := rows.Scan(&r.ID, &r.MessageID, &r.Backend, &r.ExternalID, &r.Scope, &r.MetaJSON, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("FindExternalRefs: %w", err)
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// ListExternalRefsByStatus returns the references to backend of every message in one
+// of statuses, e.g. to settle the messages still on their way out through it.
+func (s *Store) ListExternalRefsByStatus(ctx context.Context, backend string, statuses ...core.MessageStatus) ([]ExternalRef, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("ListExternalRefsByStatus: store is nil")
+	}
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	ph := make([]string, len(statuses))
+	args := []any{backend}
+	for i, st := range statuses {
+		ph[i] = "?"
+		args = append(args, string(st))
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.message_id, r.backend, r.external_id, r.scope, r.meta_json, r.created_at, r.updated_at
+		FROM message_external_refs r
+		JOIN messages m ON m.id = r.message_id
+		WHERE r.backend = ? AND m.status IN (`+strings.Join(ph, ",")+`)
+		ORDER BY r.created_at, r.external_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListExternalRefsByStatus: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ExternalRef
+	for rows.Next() {
+		var r ExternalRef
+		var createdAt, updatedAt string
+		if err := rows.Scan(&r.ID, &r.MessageID, &r.Backend, &r.ExternalID, &r.Scope, &r.MetaJSON, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("ListExternalRefsByStatus: %w", err)
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/runtime"
+)
+
+// Identity maps a tactical address such as EOC-PLANS to the licensed callsign that
+// sends and receives its mail. Its mail is kept in its own scope.
+type Identity struct {
+	Address   string // tactical address, upper-case
+	Callsign  string // licensed callsign responsible for it (the B2F Mbo)
+	Scope     string // scope its messages are filed under, e.g. EOC-PLANS@eoc
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var (
+	tacticalRe = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{0,11}$`)
+	licenseRe  = regexp.MustCompile(`^[A-Z0-9/]{3,16}$`)
+)
+
+func (s *Store) applyV13(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS identities (
+			address TEXT PRIMARY KEY,
+			callsign TEXT NOT NULL,
+			scope TEXT NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v13: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV13, now); err != nil {
+		return fmt.Errorf("apply v13: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SetIdentity registers a tactical address or changes its callsign, scope or note.
+// An empty scope defaults to runtime.IdentityScope(address); the scope is created if
+// it does not exist yet.
+func (s *Store) SetIdentity(ctx context.Context, id Identity) (*Identity, error) {
+	id.Address = strings.ToUpper(strings.TrimSpace(id.Address))
+	id.Callsign = strings.ToUpper(strings.TrimSpace(id.Callsign))
+	id.Scope = strings.TrimSpace(id.Scope)
+	if !tacticalRe.MatchString(id.Address) {
+		return nil, fmt.Errorf("SetIdentity: invalid tactical address %q (1-12 of A-Z, 0-9, -)", id.Address)
+	}
+	if !licenseRe.MatchString(id.Callsign) {
+		return nil, fmt.Errorf("SetIdentity: invalid callsign %q", id.Callsign)
+	}
+	if id.Address == id.Callsign {
+		return nil, fmt.Errorf("SetIdentity: %s is a callsign, not a tactical address", id.Address)
+	}
+	if id.Scope == "" {
+		id.Scope = runtime.IdentityScope(id.Address)
+	}
+
+	now := time.Now().UTC()
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO identities(address, callsign, scope, note, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)
+		ON CONFLICT(address) DO UPDATE SET
+			callsign = excluded.callsign,
+			scope = excluded.scope,
+			note = excluded.note,
+			updated_at = excluded.updated_at
+	`, id.Address, id.Callsign, id.Scope, id.Note, now.Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO scopes(scope, created_at, note) VALUES (?, ?, ?)`,
+			id.Scope, now.Format(time.RFC3339), "identity "+id.Address,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			if err := appendEvent(ctx, tx, Event{Kind: EventScopeCreated, Payload: payload(map[string]any{"scope": id.Scope, "note": "identity " + id.Address})}); err != nil {
+				return err
+			}
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventIdentity, Payload: payload(map[string]any{
+			"op": "set", "address": id.Address, "callsign": id.Callsign, "scope": id.Scope,
+		})})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SetIdentity: %w", err)
+	}
+	got, _, err := s.GetIdentity(ctx, id.Address)
+	return got, err
+}
+
+// GetIdentity returns the identity registered for address.
+func (s *Store) GetIdentity(ctx context.Context, address string) (*Identity, bool, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT address, callsign, scope, note, created_at, updated_at FROM identities WHERE address = ?`,
+		strings.ToUpper(strings.TrimSpace(address)))
+	id, err := scanIdentity(row)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("GetIdentity: %w", err)
+	}
+	return id, true, nil
+}
+
+// ListIdentities returns the registered identities by address.
+func (s *Store) ListIdentities(ctx context.Context) ([]Identity, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT address, callsign, scope, note, created_at, updated_at FROM identities ORDER BY address`)
+	if err != nil {
+		return nil, fmt.Errorf("ListIdentities: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Identity
+	for rows.Next() {
+		id, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListIdentities: %w", err)
+		}
+		out = append(out, *id)
+	}
+	return out, rows.Err()
+}
+
+// DeleteIdentity removes a tactical address. Its scope and messages stay. It reports
+// whether the address was registered.
+func (s *Store) DeleteIdentity(ctx context.Context, address string) (bool, error) {
+	address = strings.ToUpper(strings.TrimSpace(address))
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM identities WHERE address = ?`, address)
+		if err != nil {
+			return err
+		}
+		if n, _ = res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventIdentity, Payload: payload(map[string]any{"op": "delete", "address": address})})
+	})
+	if err != nil {
+		return false, fmt.Errorf("DeleteIdentity: %w", err)
+	}
+	return n > 0, nil
+}
+
+// IdentityCallsigns returns the registry as tactical address -> licensed callsign.
+func (s *Store) IdentityCallsigns(ctx context.Context) (map[string]string, error) {
+	ids, err := s.ListIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(ids))
+	for _, id := range ids {
+		out[id.Address] = id.Callsign
+	}
+	return out, nil
+}
+
+// IdentityScope is runtime.IdentityScope that knows the registry: a registered
+// tactical address maps to its identity's scope.
+func (s *Store) IdentityScope(ctx context.Context, address string) (string, error) {
+	if a := strings.TrimSpace(address); a != "" {
+		id, ok, err := s.GetIdentity(ctx, a)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return id.Scope, nil
+		}
+	}
+	return runtime.IdentityScope(address), nil
+}
+
+// RouteScope files a message under the scope of the first registered identity among
+// addrs (e.g. the recipients of inbound mail), or def when none is registered.
+// Addresses may carry a domain ("EOC-PLANS@winlink.org").
+func (s *Store) RouteScope(ctx context.Context, def string, addrs ...string) (string, error) {
+	for _, a := range addrs {
+		a, _, _ = strings.Cut(strings.TrimSpace(a), "@")
+		if a == "" {
+			continue
+		}
+		id, ok, err := s.GetIdentity(ctx, a)
+		if err != nil {
+			return "", fmt.Errorf("RouteScope: %w", err)
+		}
+		if ok {
+			return id.Scope, nil
+		}
+	}
+	return def, nil
+}
+
+func scanIdentity(r interface{ Scan(...any) error }) (*Identity, error) {
+	var id Identity
+	var created, updated string
+	if err := r.Scan(&id.Address, &id.Callsign, &id.Scope, &id.Note, &created, &updated); err != nil {
+		return nil, err
+	}
+	id.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	id.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return &id, nil
+}
@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestIdentityRegistryAndRouting(t *testing.T) {
+	st, ctx := setupStore(t)
+	t.Setenv("RELAYOPS_CALLSIGN", "AE4OK")
+	t.Setenv("RELAYOPS_STATION", "eoc")
+	t.Setenv("RELAYOPS_SCOPE", "")
+
+	id, err := st.SetIdentity(ctx, store.Identity{Address: "eoc-plans", Callsign: "ae4ok", Note: "planning section"})
+	if err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+	if id.Address != "EOC-PLANS" || id.Callsign != "AE4OK" || id.Scope != "EOC-PLANS@eoc" {
+		t.Fatalf("identity = %+v", id)
+	}
+	if ok, err := st.ScopeExists(ctx, "EOC-PLANS@eoc"); err != nil || !ok {
+		t.Fatalf("identity scope not created: %v %v", ok, err)
+	}
+	if _, err := st.SetIdentity(ctx, store.Identity{Address: "EOC-LOG", Callsign: "W4EOC", Scope: "logistics"}); err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+	for _, bad := range []store.Identity{
+		{Address: "EOC PLANS", Callsign: "AE4OK"},
+		{Address: "EOC-PLANS", Callsign: "not a call"},
+		{Address: "AE4OK", Callsign: "AE4OK"},
+	} {
+		if _, err := st.SetIdentity(ctx, bad); err == nil {
+			t.Errorf("SetIdentity(%+v) accepted", bad)
+		}
+	}
+
+	calls, err := st.IdentityCallsigns(ctx)
+	if err != nil || len(calls) != 2 || calls["EOC-LOG"] != "W4EOC" {
+		t.Fatalf("IdentityCallsigns = %v %v", calls, err)
+	}
+
+	for _, c := range []struct {
+		addrs []string
+		want  string
+	}{
+		{[]string{"AE4OK"}, "AE4OK@eoc"},
+		{[]string{"W1AW", "eoc-plans@winlink.org"}, "EOC-PLANS@eoc"},
+		{[]string{"EOC-LOG", "EOC-PLANS"}, "logistics"},
+		{nil, "AE4OK@eoc"},
+	} {
+		got, err := st.RouteScope(ctx, "AE4OK@eoc", c.addrs...)
+		if err != nil || got != c.want {
+			t.Errorf("RouteScope(%v) = %q %v, want %q", c.addrs, got, err, c.want)
+		}
+	}
+	if got, _ := st.IdentityScope(ctx, "EOC-LOG"); got != "logistics" {
+		t.Errorf("IdentityScope(EOC-LOG) = %q", got)
+	}
+	if got, _ := st.IdentityScope(ctx, ""); got != "AE4OK@eoc" {
+		t.Errorf("IdentityScope(\"\") = %q", got)
+	}
+
+	if ok, err := st.DeleteIdentity(ctx, "eoc-log"); err != nil || !ok {
+		t.Fatalf("DeleteIdentity: %v %v", ok, err)
+	}
+	if ok, _ := st.DeleteIdentity(ctx, "EOC-LOG"); ok {
+		t.Fatal("second DeleteIdentity reported a removal")
+	}
+	if ids, _ := st.ListIdentities(ctx); len(ids) != 1 || ids[0].Note != "planning section" {
+		t.Fatalf("ListIdentities = %+v", ids)
+	}
+}
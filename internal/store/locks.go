@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrLockHeld is returned by AcquireLock when another live process holds the lock.
+var ErrLockHeld = errors.New("lock held by another process")
+
+// Lock is a named, heartbeated lease in the database, e.g. the daemon's single-instance
+// lock. A lock whose heartbeat is older than its stale period, or whose holder on this
+// host has exited, may be taken over.
+type Lock struct {
+	Name        string
+	Owner       string
+	PID         int
+	Host        string
+	AcquiredAt  time.Time
+	HeartbeatAt time.Time
+}
+
+func (s *Store) applyV12(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS locks (
+			name TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			pid INTEGER NOT NULL,
+			host TEXT NOT NULL,
+			acquired_at TEXT NOT NULL,
+			heartbeat_at TEXT NOT NULL
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v12: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV12, now); err != nil {
+		return fmt.Errorf("apply v12: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AcquireLock takes the named lock for owner. It fails with ErrLockHeld (wrapped, with
+// the holder's details) while another owner holds a fresh lock.
+func (s *Store) AcquireLock(ctx context.Context, name, owner string, stale time.Duration) (*Lock, error) {
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	l := &Lock{Name: name, Owner: owner, PID: os.Getpid(), Host: host, AcquiredAt: now, HeartbeatAt: now}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		cur, err := getLockTx(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if cur != nil && cur.Owner != owner && !lockAbandoned(cur, now, stale) {
+			return fmt.Errorf("%w: %s (pid %d on %s, heartbeat %s)", ErrLockHeld, cur.Name, cur.PID, cur.Host,
+				cur.HeartbeatAt.Local().Format(time.RFC3339))
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO locks (name, owner, pid, host, acquired_at, heartbeat_at) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET owner = excluded.owner, pid = excluded.pid, host = excluded.host,
+				acquired_at = excluded.acquired_at, heartbeat_at = excluded.heartbeat_at`,
+			l.Name, l.Owner, l.PID, l.Host, now.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano),
+		); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AcquireLock: %w", err)
+	}
+	return l, nil
+}
+
+// HeartbeatLock refreshes a held lock. It fails if the lock was taken over.
+func (s *Store) HeartbeatLock(ctx context.Context, l *Lock) error {
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `UPDATE locks SET heartbeat_at = ? WHERE name = ? AND owner = ?`,
+		now.Format(time.RFC3339Nano), l.Name, l.Owner)
+	if err != nil {
+		return fmt.Errorf("HeartbeatLock: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("HeartbeatLock: %s is no longer held by %s", l.Name, l.Owner)
+	}
+	l.HeartbeatAt = now
+	return nil
+}
+
+// ReleaseLock drops a held lock; releasing a lock held by someone else is a no-op.
+func (s *Store) ReleaseLock(ctx context.Context, l *Lock) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM locks WHERE name = ? AND owner = ?`, l.Name, l.Owner); err != nil {
+		return fmt.Errorf("ReleaseLock: %w", err)
+	}
+	return nil
+}
+
+// GetLock returns the current holder of the named lock, or nil.
+func (s *Store) GetLock(ctx context.Context, name string) (*Lock, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("GetLock: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	l, err := getLockTx(ctx, tx, name)
+	if err != nil {
+		return nil, fmt.Errorf("GetLock: %w", err)
+	}
+	return l, nil
+}
+
+func getLockTx(ctx context.Context, tx *sql.Tx, name string) (*Lock, error) {
+	var l Lock
+	var acquired, heartbeat string
+	err := tx.QueryRowContext(ctx, `SELECT name, owner, pid, host, acquired_at, heartbeat_at FROM locks WHERE name = ?`, name).
+		Scan(&l.Name, &l.Owner, &l.PID, &l.Host, &acquired, &heartbeat)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.AcquiredAt, _ = time.Parse(time.RFC3339Nano, acquired)
+	l.HeartbeatAt, _ = time.Parse(time.RFC3339Nano, heartbeat)
+	return &l, nil
+}
+
+// lockAbandoned reports whether a lock can be taken over: its heartbeat is stale, or its
+// holder ran on this host and that process is gone.
+func lockAbandoned(l *Lock, now time.Time, stale time.Duration) bool {
+	if stale > 0 && now.Sub(l.HeartbeatAt) > stale {
+		return true
+	}
+	if host, _ := os.Hostname(); host == l.Host && l.PID > 0 {
+		if p, err := os.FindProcess(l.PID); err != nil || p.Signal(syscall.Signal(0)) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestLockIsExclusiveUntilStale(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	a, err := st.AcquireLock(ctx, "daemon", "a", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock a: %v", err)
+	}
+	if _, err := st.AcquireLock(ctx, "daemon", "b", time.Minute); !errors.Is(err, store.ErrLockHeld) {
+		t.Fatalf("expected ErrLockHeld, got %v", err)
+	}
+	if err := st.HeartbeatLock(ctx, a); err != nil {
+		t.Fatalf("HeartbeatLock: %v", err)
+	}
+
+	// Our own pid is alive, so only a stale heartbeat lets b take over.
+	time.Sleep(20 * time.Millisecond)
+	b, err := st.AcquireLock(ctx, "daemon", "b", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("takeover of stale lock: %v", err)
+	}
+	if err := st.HeartbeatLock(ctx, a); err == nil {
+		t.Fatal("expected heartbeat of a lost lock to fail")
+	}
+	if err := st.ReleaseLock(ctx, a); err != nil {
+		t.Fatalf("ReleaseLock of a lost lock: %v", err)
+	}
+	if cur, err := st.GetLock(ctx, "daemon"); err != nil || cur == nil || cur.Owner != "b" {
+		t.Fatalf("GetLock = %+v, %v", cur, err)
+	}
+
+	if err := st.ReleaseLock(ctx, b); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	if cur, err := st.GetLock(ctx, "daemon"); err != nil || cur != nil {
+		t.Fatalf("lock still held after release: %+v, %v", cur, err)
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Participation outcomes.
+const (
+	ParticipationCheckedIn = "checked_in"
+	ParticipationFailed    = "failed"
+)
+
+// Participation is one station's result for one occurrence of a recurring net or
+// exercise (e.g. Winlink Wednesday on 2026-10-14), kept per scope for season reports.
+type Participation struct {
+	Scope     string
+	Event     string // e.g. "winlink-wednesday"
+	Date      string // YYYY-MM-DD of the occurrence
+	Outcome   string
+	MessageID string
+	RunID     string
+	Detail    string
+	UpdatedAt time.Time
+}
+
+func (s *Store) applyV10(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS participation (
+			scope TEXT NOT NULL,
+			event TEXT NOT NULL,
+			date TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			message_id TEXT NOT NULL DEFAULT '',
+			run_id TEXT NOT NULL DEFAULT '',
+			detail TEXT NOT NULL DEFAULT '',
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (scope, event, date)
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v10: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV10, now); err != nil {
+		return fmt.Errorf("apply v10: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RecordParticipation stores p, replacing any earlier result for the same scope, event
+// and date, except that a failed retry never overwrites a successful check-in.
+func (s *Store) RecordParticipation(ctx context.Context, p Participation) error {
+	p.Scope, p.Event = strings.TrimSpace(p.Scope), strings.TrimSpace(p.Event)
+	if p.Scope == "" || p.Event == "" || p.Date == "" {
+		return fmt.Errorf("RecordParticipation: scope, event and date are required")
+	}
+	if _, err := time.Parse("2006-01-02", p.Date); err != nil {
+		return fmt.Errorf("RecordParticipation: bad date %q", p.Date)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO participation(scope, event, date, outcome, message_id, run_id, detail, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(scope, event, date) DO UPDATE SET
+				outcome = excluded.outcome,
+				message_id = excluded.message_id,
+				run_id = excluded.run_id,
+				detail = excluded.detail,
+				updated_at = excluded.updated_at
+			WHERE participation.outcome != ? OR excluded.outcome = ?
+		`, p.Scope, p.Event, p.Date, p.Outcome, p.MessageID, p.RunID, p.Detail, now,
+			ParticipationCheckedIn, ParticipationCheckedIn)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind: EventParticipation, MessageID: p.MessageID,
+			Payload: payload(map[string]any{
+				"scope": p.Scope, "event": p.Event, "date": p.Date, "outcome": p.Outcome, "run_id": p.RunID,
+			}),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("RecordParticipation: %w", err)
+	}
+	return nil
+}
+
+// ListParticipation returns results for event (all events if empty) in scope (all
+// scopes if empty) on or after since (all if empty), oldest first.
+func (s *Store) ListParticipation(ctx context.Context, scope, event, since string) ([]Participation, error) {
+	var where []string
+	var args []any
+	if scope = strings.TrimSpace(scope); scope != "" {
+		where = append(where, "scope = ?")
+		args = append(args, scope)
+	}
+	if event = strings.TrimSpace(event); event != "" {
+		where = append(where, "event = ?")
+		args = append(args, event)
+	}
+	if since != "" {
+		where = append(where, "date >= ?")
+		args = append(args, since)
+	}
+	q := `SELECT scope, event, date, outcome, message_id, run_id, detail, updated_at FROM participation`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY scope, event, date`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListParticipation: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Participation
+	for rows.Next() {
+		var p Participation
+		var updated string
+		if err := rows.Scan(&p.Scope, &p.Event, &p.Date, &p.Outcome, &p.MessageID, &p.RunID, &p.Detail, &updated); err != nil {
+			return nil, fmt.Errorf("ListParticipation: %w", err)
+		}
+		p.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,46 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestParticipationKeepsCheckIn(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	rec := func(date, outcome string) {
+		t.Helper()
+		if err := st.RecordParticipation(ctx, store.Participation{
+			Scope: "AE4OK", Event: "winlink-wednesday", Date: date, Outcome: outcome,
+		}); err != nil {
+			t.Fatalf("RecordParticipation: %v", err)
+		}
+	}
+	rec("2026-10-07", store.ParticipationFailed)
+	rec("2026-10-07", store.ParticipationCheckedIn) // retry succeeded
+	rec("2026-10-14", store.ParticipationCheckedIn)
+	rec("2026-10-14", store.ParticipationFailed) // a later failed retry must not undo it
+
+	if err := st.RecordParticipation(ctx, store.Participation{Scope: "AE4OK", Event: "x", Date: "14/10/2026"}); err == nil {
+		t.Fatalf("expected bad date to be rejected")
+	}
+
+	recs, err := st.ListParticipation(ctx, "AE4OK", "winlink-wednesday", "")
+	if err != nil {
+		t.Fatalf("ListParticipation: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(recs))
+	}
+	for _, r := range recs {
+		if r.Outcome != store.ParticipationCheckedIn {
+			t.Fatalf("%s: outcome %s", r.Date, r.Outcome)
+		}
+	}
+
+	recs, err = st.ListParticipation(ctx, "", "", "2026-10-10")
+	if err != nil || len(recs) != 1 || recs[0].Date != "2026-10-14" {
+		t.Fatalf("ListParticipation(since) = %+v, %v", recs, err)
+	}
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 
@@ -23,6 +24,11 @@ func (s *Store) SetPatMIDByID(ctx context.Context, id, patMID string) error {
 		return fmt.Errorf("SetPatMIDByID select meta_json: %w", err)
 	}
 
+	metaJSON, err := s.openField(metaJSON)
+	if err != nil {
+		return fmt.Errorf("SetPatMIDByID: %w", err)
+	}
+
 	var meta core.MessageMeta
 	if metaJSON != "" {
 		if err := json.Unmarshal([]byte(metaJSON), &meta); err != nil {
@@ -37,10 +43,19 @@ func (s *Store) SetPatMIDByID(ctx context.Context, id, patMID string) error {
 		return fmt.Errorf("SetPatMIDByID marshal meta: %w", err)
 	}
 
-	_, err = s.db.ExecContext(ctx,
-		`UPDATE messages SET meta_json = ? WHERE id = ?`,
-		string(b), id,
-	)
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		sealed, err := s.sealFieldTx(ctx, tx, string(b))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE messages SET meta_json = ? WHERE id = ?`,
+			sealed, id,
+		); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventMessageMeta, MessageID: id, Payload: payload(map[string]any{"pat_mid": patMID})})
+	})
 	if err != nil {
 		return fmt.Errorf("SetPatMIDByID update meta_json: %w", err)
 	}
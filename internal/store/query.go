@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// MessageFilter narrows QueryMessages. Zero values mean "no constraint".
+type MessageFilter struct {
+	Scope          string    // only messages with an external ref in this scope
+	NoRefs         bool      // only messages without any external ref (not yet sent or imported)
+	Since          time.Time // created_at >= Since
+	Until          time.Time // created_at < Until
+	Tag            string    // exact tag match
+	Folder         string    // backend folder, any backend, case-insensitive (e.g. "inbox")
+	NotFolder      string    // exclude messages in this backend folder (received mail is stored as draft)
+	IncludeDeleted bool
+}
+
+// GetMessage returns the full message row for id.
+func (s *Store) GetMessage(ctx context.Context, id string) (*core.Message, bool, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = ?`, id)
+	m, err := s.scanMessage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("GetMessage: %w", err)
+	}
+	if err := s.loadAttachments(ctx, m); err != nil {
+		return nil, false, fmt.Errorf("GetMessage: %w", err)
+	}
+	return m, true, nil
+}
+
+// QueryMessages returns full messages matching f, oldest first.
+func (s *Store) QueryMessages(ctx context.Context, f MessageFilter) ([]*core.Message, error) {
+	var where []string
+	var args []any
+
+	if !f.IncludeDeleted {
+		where = append(where, "status != 'deleted'")
+	}
+	if sc := strings.TrimSpace(f.Scope); sc != "" {
+		where = append(where, "id IN (SELECT message_id FROM message_external_refs WHERE scope = ?)")
+		args = append(args, sc)
+	}
+	if f.NoRefs {
+		where = append(where, "id NOT IN (SELECT message_id FROM message_external_refs)")
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.Until.UTC().Format(time.RFC3339))
+	}
+	if tag := strings.TrimSpace(f.Tag); tag != "" {
+		where = append(where, hasTag)
+		args = append(args, tag)
+	}
+
+	if folder := strings.TrimSpace(f.Folder); folder != "" {
+		where = append(where, "id IN (SELECT message_id FROM message_backend_state WHERE lower(folder) = lower(?))")
+		args = append(args, folder)
+	}
+	if folder := strings.TrimSpace(f.NotFolder); folder != "" {
+		where = append(where, "id NOT IN (SELECT message_id FROM message_backend_state WHERE lower(folder) = lower(?))")
+		args = append(args, folder)
+	}
+
+	q := `SELECT ` + messageColumns + ` FROM messages`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY created_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("QueryMessages: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*core.Message
+	for rows.Next() {
+		m, err := s.scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("QueryMessages: %w", err)
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("QueryMessages: %w", err)
+	}
+	rows.Close()
+	if err := s.loadAttachments(ctx, out...); err != nil {
+		return nil, fmt.Errorf("QueryMessages: %w", err)
+	}
+	return out, nil
+}
+
+// CountByStatus returns how many messages are in each status. Deleted messages are
+// counted too, under "deleted".
+func (s *Store) CountByStatus(ctx context.Context) (map[core.MessageStatus]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT status, COUNT(1) FROM messages GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("CountByStatus: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[core.MessageStatus]int{}
+	for rows.Next() {
+		var st string
+		var n int
+		if err := rows.Scan(&st, &n); err != nil {
+			return nil, fmt.Errorf("CountByStatus: %w", err)
+		}
+		out[core.MessageStatus(st)] = n
+	}
+	return out, rows.Err()
+}
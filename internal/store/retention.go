@@ -0,0 +1,359 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// Retention actions.
+const (
+	RetentionPurge   = "purge"   // hard-delete the message and everything that hangs off it
+	RetentionArchive = "archive" // move the message to status "archived"
+)
+
+// RetentionPolicy says what to do with messages in Status once they have not changed for AfterDays.
+// An empty Scope applies to every message; otherwise only to messages with an external ref in Scope.
+// A scoped policy overrides the global one for the same status: messages in that scope
+// follow only the scoped policy.
+type RetentionPolicy struct {
+	Scope     string
+	Status    core.MessageStatus
+	Action    string
+	AfterDays int
+	UpdatedAt time.Time
+}
+
+// PurgeReport describes what ApplyRetention removed or archived (or would have, on a dry run).
+type PurgeReport struct {
+	DryRun        bool
+	Purged        []string // message IDs hard-deleted
+	Archived      []string // message IDs moved to archived
+	ExternalRefs  int
+	BackendStates int
+	Attempts      int
+}
+
+func (s *Store) applyV6(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS retention_policies (
+			scope TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			action TEXT NOT NULL,
+			after_days INTEGER NOT NULL,
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (scope, status)
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v6: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV6, now); err != nil {
+		return fmt.Errorf("apply v6: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SetRetentionPolicy creates or replaces the policy for (scope, status).
+func (s *Store) SetRetentionPolicy(ctx context.Context, p RetentionPolicy) error {
+	if p.Status == "" {
+		return fmt.Errorf("SetRetentionPolicy: status required")
+	}
+	switch p.Action {
+	case RetentionPurge:
+	case RetentionArchive:
+		if p.Status == core.StatusDeleted || p.Status == core.StatusArchived {
+			return fmt.Errorf("SetRetentionPolicy: cannot archive %s messages", p.Status)
+		}
+	default:
+		return fmt.Errorf("SetRetentionPolicy: unknown action %q (want %s or %s)", p.Action, RetentionPurge, RetentionArchive)
+	}
+	if p.AfterDays < 0 {
+		return fmt.Errorf("SetRetentionPolicy: after_days must be >= 0")
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	scope := strings.TrimSpace(p.Scope)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+		INSERT INTO retention_policies(scope, status, action, after_days, updated_at)
+		VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(scope, status) DO UPDATE SET
+			action = excluded.action,
+			after_days = excluded.after_days,
+			updated_at = excluded.updated_at
+	`, scope, string(p.Status), p.Action, p.AfterDays, now); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind:    EventRetentionPolicy,
+			Payload: payload(map[string]any{"op": "set", "scope": scope, "status": p.Status, "action": p.Action, "after_days": p.AfterDays}),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("SetRetentionPolicy: %w", err)
+	}
+	return nil
+}
+
+// RemoveRetentionPolicy deletes the policy for (scope, status). It reports whether one existed.
+func (s *Store) RemoveRetentionPolicy(ctx context.Context, scope string, status core.MessageStatus) (bool, error) {
+	scope = strings.TrimSpace(scope)
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`DELETE FROM retention_policies WHERE scope = ? AND status = ?`,
+			scope, string(status),
+		)
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind:    EventRetentionPolicy,
+			Payload: payload(map[string]any{"op": "remove", "scope": scope, "status": status}),
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("RemoveRetentionPolicy: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListRetentionPolicies returns all configured policies.
+func (s *Store) ListRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT scope, status, action, after_days, updated_at FROM retention_policies ORDER BY scope, status`)
+	if err != nil {
+		return nil, fmt.Errorf("ListRetentionPolicies: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RetentionPolicy
+	for rows.Next() {
+		var p RetentionPolicy
+		var status, updatedAt string
+		if err := rows.Scan(&p.Scope, &status, &p.Action, &p.AfterDays, &updatedAt); err != nil {
+			return nil, fmt.Errorf("ListRetentionPolicies: %w", err)
+		}
+		p.Status = core.MessageStatus(status)
+		p.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// ApplyRetention evaluates every policy against now. Messages matched by a purge policy are
+// hard-deleted together with their external refs, backend state and attempts; messages matched
+// only by an archive policy move to StatusArchived. With dryRun nothing is changed.
+//
+// For each message and status the scope's own policies win over the global one; a message in
+// several scopes with policies for its status is matched by each of them.
+func (s *Store) ApplyRetention(ctx context.Context, now time.Time, dryRun bool) (*PurgeReport, error) {
+	policies, err := s.ListRetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	purge := map[string]bool{}
+	archive := map[string]bool{}
+	report := &PurgeReport{DryRun: dryRun}
+
+	// scoped lists, per status, the scopes whose own policy replaces the global one.
+	scoped := map[core.MessageStatus][]string{}
+	for _, p := range policies {
+		if p.Scope != "" {
+			scoped[p.Status] = append(scoped[p.Status], p.Scope)
+		}
+	}
+
+	for _, p := range policies {
+		var overridden []string
+		if p.Scope == "" {
+			overridden = scoped[p.Status]
+		}
+		ids, err := s.retentionCandidates(ctx, p, now, overridden)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if p.Action == RetentionPurge {
+				purge[id] = true
+			} else {
+				archive[id] = true
+			}
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ApplyRetention: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stamp := time.Now().UTC().Format(time.RFC3339)
+	for _, id := range sortedKeys(archive) {
+		if purge[id] {
+			continue
+		}
+		if !dryRun {
+			var old string
+			if err := tx.QueryRowContext(ctx, `SELECT status FROM messages WHERE id = ?`, id).Scan(&old); err != nil {
+				return nil, fmt.Errorf("ApplyRetention: archive %s: %w", id, err)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE messages SET status = ?, updated_at = ? WHERE id = ?`,
+				string(core.StatusArchived), stamp, id,
+			); err != nil {
+				return nil, fmt.Errorf("ApplyRetention: archive %s: %w", id, err)
+			}
+			if err := appendEvent(ctx, tx, Event{
+				Kind: EventMessageStatus, MessageID: id, OldStatus: old, NewStatus: string(core.StatusArchived),
+				Payload: payload(map[string]any{"retention": RetentionArchive}),
+			}); err != nil {
+				return nil, fmt.Errorf("ApplyRetention: %w", err)
+			}
+		}
+		report.Archived = append(report.Archived, id)
+	}
+
+	for _, id := range sortedKeys(purge) {
+		n, err := purgeMessageTx(ctx, tx, id, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("ApplyRetention: purge %s: %w", id, err)
+		}
+		report.ExternalRefs += n.refs
+		report.BackendStates += n.states
+		report.Attempts += n.attempts
+		report.Purged = append(report.Purged, id)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ApplyRetention: %w", err)
+	}
+	return report, nil
+}
+
+// retentionCandidates returns the messages p applies to as of now, leaving out those with an
+// external ref in any of the overridden scopes.
+func (s *Store) retentionCandidates(ctx context.Context, p RetentionPolicy, now time.Time, overridden []string) ([]string, error) {
+	cutoff := now.Add(-time.Duration(p.AfterDays) * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	q := `SELECT id FROM messages WHERE status = ? AND updated_at < ?`
+	args := []any{string(p.Status), cutoff}
+	if p.Scope != "" {
+		q += ` AND id IN (SELECT message_id FROM message_external_refs WHERE scope = ?)`
+		args = append(args, p.Scope)
+	}
+	if len(overridden) > 0 {
+		q += ` AND id NOT IN (SELECT message_id FROM message_external_refs WHERE scope IN (?` + strings.Repeat(", ?", len(overridden)-1) + `))`
+		for _, sc := range overridden {
+			args = append(args, sc)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("retention candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+type purgeCounts struct {
+	refs, states, attempts, attachments int
+}
+
+// purgeMessageTx removes a message and its dependent rows explicitly rather than relying on
+// ON DELETE CASCADE, since foreign_keys is a per-connection pragma in SQLite.
+func purgeMessageTx(ctx context.Context, tx *sql.Tx, id string, dryRun bool) (purgeCounts, error) {
+	var n purgeCounts
+	children := []struct {
+		table string
+		count *int
+	}{
+		{"message_external_refs", &n.refs},
+		{"message_backend_state", &n.states},
+		{"message_attempts", &n.attempts},
+		{"message_attachments", &n.attachments},
+	}
+	for _, c := range children {
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM `+c.table+` WHERE message_id = ?`, id).Scan(c.count); err != nil {
+			return n, err
+		}
+		if dryRun {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+c.table+` WHERE message_id = ?`, id); err != nil {
+			return n, err
+		}
+	}
+	if !dryRun {
+		var old string
+		if err := tx.QueryRowContext(ctx, `SELECT status FROM messages WHERE id = ?`, id).Scan(&old); err != nil {
+			return n, err
+		}
+		// Sessions and participation are station history that outlives the message;
+		// keep those rows but unlink them. A session link whose blank twin already
+		// exists (same session, direction and MID) is dropped instead.
+		unlink := []string{
+			`UPDATE OR IGNORE session_messages SET message_id = '' WHERE message_id = ?`,
+			`DELETE FROM session_messages WHERE message_id = ?`,
+			`UPDATE participation SET message_id = '' WHERE message_id = ?`,
+		}
+		for _, q := range unlink {
+			if _, err := tx.ExecContext(ctx, q, id); err != nil {
+				return n, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, id); err != nil {
+			return n, err
+		}
+		if err := appendEvent(ctx, tx, Event{
+			Kind: EventMessagePurged, MessageID: id, OldStatus: old,
+			Payload: payload(map[string]any{"external_refs": n.refs, "backend_states": n.states, "attempts": n.attempts, "attachments": n.attachments}),
+		}); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,152 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestApplyRetentionPurgesAndArchives(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	deleted := core.NewMessage("old deleted", "x")
+	sent := core.NewMessage("old sent", "y")
+	draft := core.NewMessage("draft", "z")
+	for _, m := range []*core.Message{deleted, sent, draft} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if _, err := st.DeleteByID(ctx, deleted.ID); err != nil {
+		t.Fatalf("DeleteByID: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, sent.ID, core.StatusSent, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+	if err := st.UpsertExternalRef(ctx, deleted.ID, "pat", "MIDDEL", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	if err := st.UpsertBackendState(ctx, deleted.ID, "pat", "InBox", "Received", ""); err != nil {
+		t.Fatalf("UpsertBackendState: %v", err)
+	}
+	sess := &store.Session{Transport: "sim", StartedAt: time.Now(), FinishedAt: time.Now(), Outcome: "ok",
+		Messages: []store.SessionMessage{{MessageID: deleted.ID, MID: "MIDDEL", Direction: store.DirectionOut}}}
+	if err := st.RecordSession(ctx, sess); err != nil {
+		t.Fatalf("RecordSession: %v", err)
+	}
+	if err := st.RecordParticipation(ctx, store.Participation{Scope: "AE4OK", Event: "winlink-wednesday", Date: "2026-01-07", Outcome: "sent", MessageID: deleted.ID}); err != nil {
+		t.Fatalf("RecordParticipation: %v", err)
+	}
+
+	for _, p := range []store.RetentionPolicy{
+		{Status: core.StatusDeleted, Action: store.RetentionPurge, AfterDays: 30},
+		{Status: core.StatusSent, Action: store.RetentionArchive, AfterDays: 365},
+	} {
+		if err := st.SetRetentionPolicy(ctx, p); err != nil {
+			t.Fatalf("SetRetentionPolicy: %v", err)
+		}
+	}
+
+	// Nothing is old enough yet.
+	rep, err := st.ApplyRetention(ctx, time.Now(), false)
+	if err != nil {
+		t.Fatalf("ApplyRetention(now): %v", err)
+	}
+	if len(rep.Purged) != 0 || len(rep.Archived) != 0 {
+		t.Fatalf("expected no-op, got %+v", rep)
+	}
+
+	later := time.Now().Add(400 * 24 * time.Hour)
+	rep, err = st.ApplyRetention(ctx, later, true)
+	if err != nil {
+		t.Fatalf("ApplyRetention(dry-run): %v", err)
+	}
+	if len(rep.Purged) != 1 || len(rep.Archived) != 1 || rep.ExternalRefs != 1 || rep.BackendStates != 1 {
+		t.Fatalf("unexpected dry-run report: %+v", rep)
+	}
+	if _, found, _ := st.GetMessage(ctx, deleted.ID); !found {
+		t.Fatalf("dry run must not delete")
+	}
+
+	if _, err := st.ApplyRetention(ctx, later, false); err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if _, found, _ := st.GetMessage(ctx, deleted.ID); found {
+		t.Fatalf("deleted message should have been purged")
+	}
+	if _, found, _ := st.GetMessageIDByExternalRef(ctx, "pat", "MIDDEL", "AE4OK"); found {
+		t.Fatalf("external ref should have been purged")
+	}
+	// Session and participation history stay, unlinked from the purged message.
+	if linked, err := st.ListSessions(ctx, deleted.ID, 10); err != nil || len(linked) != 0 {
+		t.Fatalf("sessions still linked to the purged message: %+v, %v", linked, err)
+	}
+	if kept, _, err := st.GetSession(ctx, sess.ID); err != nil || kept == nil || len(kept.Messages) != 1 || kept.Messages[0].MID != "MIDDEL" || kept.Messages[0].MessageID != "" {
+		t.Fatalf("session after purge = %+v, %v", kept, err)
+	}
+	parts, err := st.ListParticipation(ctx, "AE4OK", "", "")
+	if err != nil || len(parts) != 1 || parts[0].MessageID != "" {
+		t.Fatalf("participation after purge = %+v, %v", parts, err)
+	}
+	got, _, _ := st.GetMessage(ctx, sent.ID)
+	if got.Status != core.StatusArchived {
+		t.Fatalf("expected sent message archived, got %s", got.Status)
+	}
+	got, _, _ = st.GetMessage(ctx, draft.ID)
+	if got.Status != core.StatusDraft {
+		t.Fatalf("draft should be untouched, got %s", got.Status)
+	}
+}
+
+func TestRetentionScopePolicyOverridesGlobal(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	// Three sent messages: one in a scope with its own policy, one in a scope
+	// without, and one with no refs at all.
+	kept, other, loose := core.NewMessage("kept", "a"), core.NewMessage("other", "b"), core.NewMessage("loose", "c")
+	for _, m := range []*core.Message{kept, other, loose} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		if err := st.SetStatusByID(ctx, m.ID, core.StatusSent, ""); err != nil {
+			t.Fatalf("SetStatusByID: %v", err)
+		}
+	}
+	if err := st.UpsertExternalRef(ctx, kept.ID, "pat", "MIDKEEP", "EOC", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	if err := st.UpsertExternalRef(ctx, other.ID, "pat", "MIDOTHER", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+
+	for _, p := range []store.RetentionPolicy{
+		{Status: core.StatusSent, Action: store.RetentionPurge, AfterDays: 30},
+		{Scope: "EOC", Status: core.StatusSent, Action: store.RetentionArchive, AfterDays: 3650},
+	} {
+		if err := st.SetRetentionPolicy(ctx, p); err != nil {
+			t.Fatalf("SetRetentionPolicy: %v", err)
+		}
+	}
+
+	rep, err := st.ApplyRetention(ctx, time.Now().Add(60*24*time.Hour), false)
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if len(rep.Purged) != 2 || len(rep.Archived) != 0 {
+		t.Fatalf("report = %+v, want other and loose purged only", rep)
+	}
+	if got, found, _ := st.GetMessage(ctx, kept.ID); !found || got.Status != core.StatusSent {
+		t.Fatalf("EOC message fell through to the global purge policy: %+v, found=%v", got, found)
+	}
+
+	// Past the scope's own horizon its policy applies.
+	rep, err = st.ApplyRetention(ctx, time.Now().Add(3700*24*time.Hour), false)
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if len(rep.Purged) != 0 || len(rep.Archived) != 1 || rep.Archived[0] != kept.ID {
+		t.Fatalf("report = %+v, want the EOC message archived", rep)
+	}
+}
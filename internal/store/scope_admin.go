@@ -0,0 +1,400 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrScopeNotEmpty is returned by DeleteScope for a scope that still holds messages.
+var ErrScopeNotEmpty = errors.New("scope is not empty")
+
+// errDryRun rolls back a transaction whose results are only being reported.
+var errDryRun = errors.New("dry run")
+
+func (s *Store) applyV14(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`ALTER TABLE scopes ADD COLUMN archived_at TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE scopes ADD COLUMN last_import_at TEXT NOT NULL DEFAULT '';`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v14: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV14, now); err != nil {
+		return fmt.Errorf("apply v14: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetScope returns one scope.
+func (s *Store) GetScope(ctx context.Context, scope string) (*Scope, bool, error) {
+	var createdAt, note, archivedAt, lastImport string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT created_at, note, archived_at, last_import_at FROM scopes WHERE scope = ?`, scope,
+	).Scan(&createdAt, &note, &archivedAt, &lastImport)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("GetScope: %w", err)
+	}
+	return scopeRow(scope, createdAt, note, archivedAt, lastImport), true, nil
+}
+
+func scopeRow(scope, createdAt, note, archivedAt, lastImport string) *Scope {
+	sc := &Scope{Scope: scope, Note: note}
+	sc.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	sc.ArchivedAt, _ = time.Parse(time.RFC3339, archivedAt)
+	sc.LastImportAt, _ = time.Parse(time.RFC3339, lastImport)
+	return sc
+}
+
+// MarkScopeImported records that an import just wrote into scope.
+func (s *Store) MarkScopeImported(ctx context.Context, scope string) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE scopes SET last_import_at = ? WHERE scope = ?`,
+		time.Now().UTC().Format(time.RFC3339), scope); err != nil {
+		return fmt.Errorf("MarkScopeImported: %w", err)
+	}
+	return nil
+}
+
+// ArchiveScope marks scope archived (imports into it are refused) or, with archived
+// false, makes it active again.
+func (s *Store) ArchiveScope(ctx context.Context, scope string, archived bool) error {
+	at, op := "", "unarchive"
+	if archived {
+		at, op = time.Now().UTC().Format(time.RFC3339), "archive"
+	}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE scopes SET archived_at = ? WHERE scope = ?`, at, scope)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("no such scope %q", scope)
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventScopeChanged, Payload: payload(map[string]any{"op": op, "scope": scope})})
+	})
+	if err != nil {
+		return fmt.Errorf("ArchiveScope: %w", err)
+	}
+	return nil
+}
+
+// RenameScope renames from to to, carrying its external refs, backend state, retention
+// policies, participation records and identities along. to must not exist yet; use
+// MergeScope to fold one scope into another.
+func (s *Store) RenameScope(ctx context.Context, from, to string) error {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" || from == to {
+		return fmt.Errorf("RenameScope: need two different scope names")
+	}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requireScope(ctx, tx, from); err != nil {
+			return err
+		}
+		var one int
+		switch err := tx.QueryRowContext(ctx, `SELECT 1 FROM scopes WHERE scope = ?`, to).Scan(&one); err {
+		case nil:
+			return fmt.Errorf("scope %q already exists (merge into it instead)", to)
+		case sql.ErrNoRows:
+		default:
+			return err
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		for _, q := range []string{
+			`UPDATE scopes SET scope = ? WHERE scope = ?`,
+			`UPDATE message_external_refs SET scope = ?, updated_at = '` + now + `' WHERE scope = ?`,
+			`UPDATE retention_policies SET scope = ? WHERE scope = ?`,
+			`UPDATE participation SET scope = ? WHERE scope = ?`,
+			`UPDATE identities SET scope = ? WHERE scope = ?`,
+			`UPDATE message_backend_state SET extra_json = json_set(extra_json, '$.scope', ?)
+				WHERE json_valid(extra_json) AND json_extract(extra_json, '$.scope') = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, q, to, from); err != nil {
+				return err
+			}
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventScopeChanged, Payload: payload(map[string]any{"op": "rename", "scope": from, "to": to})})
+	})
+	if err != nil {
+		return fmt.Errorf("RenameScope: %w", err)
+	}
+	return nil
+}
+
+// ScopeConflict is something MergeScope could not move because into already has it.
+type ScopeConflict struct {
+	Kind        string // external_ref, retention or participation
+	Key         string // e.g. "pat:ABC123DEF456"
+	FromMessage string // message ids, for external_ref conflicts
+	IntoMessage string
+}
+
+// MergeReport says what MergeScope moved.
+type MergeReport struct {
+	From, Into string
+	DryRun     bool
+	Moved      int // external refs moved to into
+	Duplicates int // refs into already had for the same message, dropped from from
+	Other      int // retention policies, participation records and identities moved
+	Conflicts  []ScopeConflict
+	Removed    bool // from was empty afterwards and has been deleted
+}
+
+// MergeScope moves everything in scope from into scope into. A backend reference that
+// into already has for a different message is a conflict: it stays in from and is
+// reported, and from is kept. Otherwise from is deleted at the end. With dryRun
+// nothing changes and the report says what would.
+func (s *Store) MergeScope(ctx context.Context, from, into string, dryRun bool) (*MergeReport, error) {
+	from, into = strings.TrimSpace(from), strings.TrimSpace(into)
+	if from == "" || into == "" || from == into {
+		return nil, fmt.Errorf("MergeScope: need two different scope names")
+	}
+	rep := &MergeReport{From: from, Into: into, DryRun: dryRun}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, sc := range []string{from, into} {
+			if err := requireScope(ctx, tx, sc); err != nil {
+				return err
+			}
+		}
+		if err := mergeRefs(ctx, tx, rep); err != nil {
+			return err
+		}
+		if err := mergeKeyed(ctx, tx, rep, "retention", "retention_policies", "status"); err != nil {
+			return err
+		}
+		if err := mergeKeyed(ctx, tx, rep, "participation", "participation", "event || ' ' || date"); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `UPDATE identities SET scope = ? WHERE scope = ?`, into, from)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		rep.Other += int(n)
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE message_backend_state SET extra_json = json_set(extra_json, '$.scope', ?)
+			WHERE json_valid(extra_json) AND json_extract(extra_json, '$.scope') = ?
+			  AND message_id NOT IN (SELECT message_id FROM message_external_refs WHERE scope = ?)`,
+			into, from, from); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE scopes SET last_import_at = max(last_import_at, (SELECT last_import_at FROM scopes WHERE scope = ?))
+			WHERE scope = ?`, from, into); err != nil {
+			return err
+		}
+		if len(rep.Conflicts) == 0 {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM scopes WHERE scope = ?`, from); err != nil {
+				return err
+			}
+			rep.Removed = true
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventScopeChanged, Payload: payload(map[string]any{
+			"op": "merge", "scope": from, "into": into, "moved": rep.Moved, "duplicates": rep.Duplicates,
+			"conflicts": len(rep.Conflicts), "removed": rep.Removed,
+		})})
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, fmt.Errorf("MergeScope: %w", err)
+	}
+	return rep, nil
+}
+
+func mergeRefs(ctx context.Context, tx *sql.Tx, rep *MergeReport) error {
+	type ref struct{ id, messageID, backend, externalID, intoMessage string }
+	rows, err := tx.QueryContext(ctx, `
+		SELECT r.id, r.message_id, r.backend, r.external_id, COALESCE(i.message_id, '')
+		FROM message_external_refs r
+		LEFT JOIN message_external_refs i ON i.backend = r.backend AND i.external_id = r.external_id AND i.scope = ?
+		WHERE r.scope = ?
+		ORDER BY r.backend, r.external_id`, rep.Into, rep.From)
+	if err != nil {
+		return err
+	}
+	var refs []ref
+	for rows.Next() {
+		var r ref
+		if err := rows.Scan(&r.id, &r.messageID, &r.backend, &r.externalID, &r.intoMessage); err != nil {
+			rows.Close()
+			return err
+		}
+		refs = append(refs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, r := range refs {
+		switch r.intoMessage {
+		case "":
+			if _, err := tx.ExecContext(ctx, `UPDATE message_external_refs SET scope = ?, updated_at = ? WHERE id = ?`, rep.Into, now, r.id); err != nil {
+				return err
+			}
+			rep.Moved++
+		case r.messageID:
+			if _, err := tx.ExecContext(ctx, `DELETE FROM message_external_refs WHERE id = ?`, r.id); err != nil {
+				return err
+			}
+			rep.Duplicates++
+		default:
+			rep.Conflicts = append(rep.Conflicts, ScopeConflict{
+				Kind: "external_ref", Key: r.backend + ":" + r.externalID, FromMessage: r.messageID, IntoMessage: r.intoMessage,
+			})
+		}
+	}
+	return nil
+}
+
+// mergeKeyed moves the rows of a (scope, key...) table from rep.From to rep.Into,
+// reporting rows whose key into already has.
+func mergeKeyed(ctx context.Context, tx *sql.Tx, rep *MergeReport, kind, table, key string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT `+qualify(key, "f")+` FROM `+table+` f WHERE f.scope = ?
+		AND EXISTS (SELECT 1 FROM `+table+` i WHERE i.scope = ? AND `+qualify(key, "i")+` = `+qualify(key, "f")+`)`,
+		rep.From, rep.Into)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			rows.Close()
+			return err
+		}
+		rep.Conflicts = append(rep.Conflicts, ScopeConflict{Kind: kind, Key: k})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE OR IGNORE `+table+` SET scope = ? WHERE scope = ?`, rep.Into, rep.From)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	rep.Other += int(n)
+	return nil
+}
+
+// qualify prefixes the column names in a key expression such as "event || ' ' || date".
+func qualify(key, alias string) string {
+	parts := strings.Split(key, " || ")
+	for i, p := range parts {
+		if !strings.HasPrefix(p, "'") {
+			parts[i] = alias + "." + p
+		}
+	}
+	return strings.Join(parts, " || ")
+}
+
+// DeleteScope removes a scope that holds no messages, identities or participation
+// records. Its retention policies go with it. A scope that is not empty fails with
+// ErrScopeNotEmpty.
+func (s *Store) DeleteScope(ctx context.Context, scope string) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requireScope(ctx, tx, scope); err != nil {
+			return err
+		}
+		var refs, ids, part int
+		if err := tx.QueryRowContext(ctx, `SELECT
+			(SELECT COUNT(1) FROM message_external_refs WHERE scope = ?),
+			(SELECT COUNT(1) FROM identities WHERE scope = ?),
+			(SELECT COUNT(1) FROM participation WHERE scope = ?)`, scope, scope, scope,
+		).Scan(&refs, &ids, &part); err != nil {
+			return err
+		}
+		if refs+ids+part > 0 {
+			return fmt.Errorf("%w: %d message reference(s), %d identit(y/ies), %d participation record(s)", ErrScopeNotEmpty, refs, ids, part)
+		}
+		for _, q := range []string{`DELETE FROM retention_policies WHERE scope = ?`, `DELETE FROM scopes WHERE scope = ?`} {
+			if _, err := tx.ExecContext(ctx, q, scope); err != nil {
+				return err
+			}
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventScopeChanged, Payload: payload(map[string]any{"op": "delete", "scope": scope})})
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteScope: %w", err)
+	}
+	return nil
+}
+
+func requireScope(ctx context.Context, tx *sql.Tx, scope string) error {
+	var one int
+	err := tx.QueryRowContext(ctx, `SELECT 1 FROM scopes WHERE scope = ?`, scope).Scan(&one)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no such scope %q", scope)
+	}
+	return err
+}
+
+// ScopeStats summarizes one scope. Deleted messages are counted under "deleted".
+type ScopeStats struct {
+	Scope        string
+	Messages     int
+	ByStatus     map[string]int
+	ByBackend    map[string]int // external references per backend
+	LastImportAt time.Time
+	ArchivedAt   time.Time
+}
+
+// ScopeStats returns the statistics of scope.
+func (s *Store) ScopeStats(ctx context.Context, scope string) (*ScopeStats, error) {
+	sc, ok, err := s.GetScope(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("ScopeStats: no such scope %q", scope)
+	}
+	st := &ScopeStats{Scope: scope, ByStatus: map[string]int{}, ByBackend: map[string]int{}, LastImportAt: sc.LastImportAt, ArchivedAt: sc.ArchivedAt}
+
+	count := func(q string, into map[string]int) error {
+		rows, err := s.db.QueryContext(ctx, q, scope)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k string
+			var n int
+			if err := rows.Scan(&k, &n); err != nil {
+				return err
+			}
+			into[k] = n
+		}
+		return rows.Err()
+	}
+	if err := count(`SELECT m.status, COUNT(1) FROM messages m
+		WHERE m.id IN (SELECT message_id FROM message_external_refs WHERE scope = ?)
+		GROUP BY m.status`, st.ByStatus); err != nil {
+		return nil, fmt.Errorf("ScopeStats: %w", err)
+	}
+	if err := count(`SELECT backend, COUNT(1) FROM message_external_refs WHERE scope = ? GROUP BY backend`, st.ByBackend); err != nil {
+		return nil, fmt.Errorf("ScopeStats: %w", err)
+	}
+	for _, n := range st.ByStatus {
+		st.Messages += n
+	}
+	return st, nil
+}
@@ -0,0 +1,162 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestScopeRenameArchiveAndDelete(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	m := core.NewMessage("net report", "x")
+	if err := st.SaveMessage(ctx, m); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	for _, sc := range []string{"AE4OK@generl", "AE4OK@empty"} {
+		if err := st.CreateScope(ctx, sc, ""); err != nil {
+			t.Fatalf("CreateScope: %v", err)
+		}
+	}
+	if err := st.UpsertExternalRef(ctx, m.ID, "pat", "MID1", "AE4OK@generl", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	if err := st.UpsertBackendState(ctx, m.ID, "pat", "in", "received", `{"scope":"AE4OK@generl"}`); err != nil {
+		t.Fatalf("UpsertBackendState: %v", err)
+	}
+	if err := st.SetRetentionPolicy(ctx, store.RetentionPolicy{Scope: "AE4OK@generl", Status: core.StatusSent, Action: store.RetentionPurge, AfterDays: 30}); err != nil {
+		t.Fatalf("SetRetentionPolicy: %v", err)
+	}
+
+	if err := st.RenameScope(ctx, "AE4OK@generl", "AE4OK@empty"); err == nil {
+		t.Fatal("rename onto an existing scope accepted")
+	}
+	if err := st.RenameScope(ctx, "AE4OK@generl", "AE4OK@general"); err != nil {
+		t.Fatalf("RenameScope: %v", err)
+	}
+	if id, ok, _ := st.GetMessageIDByExternalRef(ctx, "pat", "MID1", "AE4OK@general"); !ok || id != m.ID {
+		t.Fatalf("ref not renamed: %q %v", id, ok)
+	}
+	bs, _ := st.ListBackendState(ctx, m.ID)
+	if len(bs) != 1 || bs[0].ExtraJSON != `{"scope":"AE4OK@general"}` {
+		t.Fatalf("backend state = %+v", bs)
+	}
+	pols, _ := st.ListRetentionPolicies(ctx)
+	if len(pols) != 1 || pols[0].Scope != "AE4OK@general" {
+		t.Fatalf("retention = %+v", pols)
+	}
+
+	if err := st.DeleteScope(ctx, "AE4OK@general"); !errors.Is(err, store.ErrScopeNotEmpty) {
+		t.Fatalf("DeleteScope(non-empty) = %v", err)
+	}
+	if err := st.DeleteScope(ctx, "AE4OK@empty"); err != nil {
+		t.Fatalf("DeleteScope: %v", err)
+	}
+	if ok, _ := st.ScopeExists(ctx, "AE4OK@empty"); ok {
+		t.Fatal("empty scope still exists")
+	}
+
+	if err := st.ArchiveScope(ctx, "AE4OK@general", true); err != nil {
+		t.Fatalf("ArchiveScope: %v", err)
+	}
+	if sc, _, _ := st.GetScope(ctx, "AE4OK@general"); sc.ArchivedAt.IsZero() {
+		t.Fatal("scope not archived")
+	}
+	if err := st.ArchiveScope(ctx, "AE4OK@general", false); err != nil {
+		t.Fatalf("unarchive: %v", err)
+	}
+	if sc, _, _ := st.GetScope(ctx, "AE4OK@general"); !sc.ArchivedAt.IsZero() {
+		t.Fatal("scope still archived")
+	}
+	if err := st.ArchiveScope(ctx, "nope", true); err == nil {
+		t.Fatal("archiving a missing scope accepted")
+	}
+}
+
+func TestMergeScopeAndStats(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	a := core.NewMessage("a", "x")
+	b := core.NewMessage("b", "y")
+	c := core.NewMessage("c", "z")
+	for _, m := range []*core.Message{a, b, c} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if err := st.SetStatusByID(ctx, a.ID, core.StatusSent, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+	for _, sc := range []string{"AE4OK", "AE4OK@general"} {
+		if err := st.CreateScope(ctx, sc, ""); err != nil {
+			t.Fatalf("CreateScope: %v", err)
+		}
+	}
+	refs := []struct{ id, backend, ext, scope string }{
+		{a.ID, "pat", "MIDA", "AE4OK"},         // moves
+		{b.ID, "pat", "MIDB", "AE4OK"},         // duplicate of the ref in into
+		{b.ID, "pat", "MIDB", "AE4OK@general"}, //
+		{c.ID, "winlink", "MIDC", "AE4OK"},     // conflicts: into maps MIDC to a
+		{a.ID, "winlink", "MIDC", "AE4OK@general"},
+	}
+	for _, r := range refs {
+		if err := st.UpsertExternalRef(ctx, r.id, r.backend, r.ext, r.scope, "{}"); err != nil {
+			t.Fatalf("UpsertExternalRef: %v", err)
+		}
+	}
+	if err := st.MarkScopeImported(ctx, "AE4OK"); err != nil {
+		t.Fatalf("MarkScopeImported: %v", err)
+	}
+
+	stats, err := st.ScopeStats(ctx, "AE4OK")
+	if err != nil {
+		t.Fatalf("ScopeStats: %v", err)
+	}
+	if stats.Messages != 3 || stats.ByStatus["sent"] != 1 || stats.ByBackend["pat"] != 2 || stats.ByBackend["winlink"] != 1 || stats.LastImportAt.IsZero() {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	dry, err := st.MergeScope(ctx, "AE4OK", "AE4OK@general", true)
+	if err != nil {
+		t.Fatalf("MergeScope dry run: %v", err)
+	}
+	if dry.Moved != 1 || dry.Duplicates != 1 || len(dry.Conflicts) != 1 || dry.Removed {
+		t.Fatalf("dry run = %+v", dry)
+	}
+	if s, _ := st.ScopeStats(ctx, "AE4OK"); s.Messages != 3 {
+		t.Fatalf("dry run changed the store: %+v", s)
+	}
+
+	rep, err := st.MergeScope(ctx, "AE4OK", "AE4OK@general", false)
+	if err != nil {
+		t.Fatalf("MergeScope: %v", err)
+	}
+	want := store.ScopeConflict{Kind: "external_ref", Key: "winlink:MIDC", FromMessage: c.ID, IntoMessage: a.ID}
+	if rep.Moved != 1 || rep.Duplicates != 1 || len(rep.Conflicts) != 1 || rep.Conflicts[0] != want || rep.Removed {
+		t.Fatalf("merge = %+v", rep)
+	}
+	if id, ok, _ := st.GetMessageIDByExternalRef(ctx, "pat", "MIDA", "AE4OK@general"); !ok || id != a.ID {
+		t.Fatalf("MIDA not moved: %q %v", id, ok)
+	}
+
+	// Resolving the conflict lets the next merge finish and remove the source scope.
+	if _, err := st.DeleteByID(ctx, c.ID); err != nil {
+		t.Fatalf("DeleteByID: %v", err)
+	}
+	if err := st.UpsertExternalRef(ctx, a.ID, "winlink", "MIDC", "AE4OK", "{}"); err != nil {
+		t.Fatalf("UpsertExternalRef: %v", err)
+	}
+	rep, err = st.MergeScope(ctx, "AE4OK", "AE4OK@general", false)
+	if err != nil || !rep.Removed || rep.Duplicates != 1 {
+		t.Fatalf("second merge = %+v %v", rep, err)
+	}
+	if ok, _ := st.ScopeExists(ctx, "AE4OK"); ok {
+		t.Fatal("merged scope still exists")
+	}
+	stats, _ = st.ScopeStats(ctx, "AE4OK@general")
+	if stats.Messages != 2 || stats.LastImportAt.IsZero() {
+		t.Fatalf("merged stats = %+v", stats)
+	}
+}
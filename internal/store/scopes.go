@@ -10,9 +10,11 @@ import (
 )
 
 type Scope struct {
-    Scope     string
-    CreatedAt time.Time
-    Note      string
+    Scope        string
+    CreatedAt    time.Time
+    Note         string
+    ArchivedAt   time.Time // zero unless archived
+    LastImportAt time.Time // zero until an import writes into the scope
 }
 
 // ScopeExists returns true if the given scope exists in the global scopes table.
@@ -30,7 +32,7 @@ func (s *Store) ScopeExists(ctx context.Context, scope string) (bool, error) {
 
 // ListScopes returns all known scopes.
 func (s *Store) ListScopes(ctx context.Context) ([]Scope, error) {
-    rows, err := s.db.QueryContext(ctx, `SELECT scope, created_at, note FROM scopes ORDER BY scope`)
+    rows, err := s.db.QueryContext(ctx, `SELECT scope, created_at, note, archived_at, last_import_at FROM scopes ORDER BY scope`)
     if err != nil {
         return nil, fmt.Errorf("ListScopes: %w", err)
     }
@@ -38,12 +40,11 @@ func (s *Store) ListScopes(ctx context.Context) ([]Scope, error) {
 
     var out []Scope
     for rows.Next() {
-        var scope, createdAt, note string
-        if err := rows.Scan(&scope, &createdAt, &note); err != nil {
+        var scope, createdAt, note, archivedAt, lastImport string
+        if err := rows.Scan(&scope, &createdAt, &note, &archivedAt, &lastImport); err != nil {
             return nil, fmt.Errorf("ListScopes: %w", err)
         }
-        t, _ := time.Parse(time.RFC3339, createdAt)
-        out = append(out, Scope{Scope: scope, CreatedAt: t, Note: note})
+        out = append(out, *scopeRow(scope, createdAt, note, archivedAt, lastImport))
     }
     return out, nil
 }
@@ -54,10 +55,19 @@ func (s *Store) CreateScope(ctx context.Context, scope, note string) error {
     // uuid is not stored; kept for future extension; also a cheap way to ensure we imported uuid so go mod keeps it.
     _ = uuid.Nil
 
-    _, err := s.db.ExecContext(ctx,
-        `INSERT OR IGNORE INTO scopes(scope, created_at, note) VALUES (?, ?, ?)`,
-        scope, now, note,
-    )
+    err := s.withTx(ctx, func(tx *sql.Tx) error {
+        res, err := tx.ExecContext(ctx,
+            `INSERT OR IGNORE INTO scopes(scope, created_at, note) VALUES (?, ?, ?)`,
+            scope, now, note,
+        )
+        if err != nil {
+            return err
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            return nil
+        }
+        return appendEvent(ctx, tx, Event{Kind: EventScopeCreated, Payload: payload(map[string]any{"scope": scope, "note": note})})
+    })
     if err != nil {
         return fmt.Errorf("CreateScope: %w", err)
     }
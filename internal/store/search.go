@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+// SearchMessages returns non-deleted messages whose subject or body contains q
+// (case-insensitive), newest first.
+//
+// On an encrypted store bodies can only be matched after decrypting them, so search
+// requires the store to be unlocked and returns ErrLocked otherwise.
+func (s *Store) SearchMessages(ctx context.Context, q string, limit int) ([]MessageSummary, error) {
+	if limit <= 0 {
+		limit = 25
+	}
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, fmt.Errorf("SearchMessages: empty query")
+	}
+	if s.Locked() {
+		return nil, fmt.Errorf("SearchMessages: %w", ErrLocked)
+	}
+
+	base := `SELECT ` + messageColumns + ` FROM messages WHERE status != 'deleted'`
+	var args []any
+	if !s.encrypted.Load() {
+		// Plaintext store: let SQLite do the filtering.
+		base += ` AND (subject LIKE ? OR body LIKE ?)`
+		like := "%" + q + "%"
+		args = append(args, like, like)
+	}
+	base += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, base, args...)
+	if err != nil {
+		return nil, fmt.Errorf("SearchMessages: %w", err)
+	}
+	defer rows.Close()
+
+	needle := strings.ToLower(q)
+	var out []MessageSummary
+	for rows.Next() {
+		m, err := s.scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SearchMessages: %w", err)
+		}
+		if !strings.Contains(strings.ToLower(m.Subject), needle) && !strings.Contains(strings.ToLower(m.Body), needle) {
+			continue
+		}
+		out = append(out, summaryOf(m))
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, rows.Err()
+}
+
+func summaryOf(m *core.Message) MessageSummary {
+	return MessageSummary{
+		ID:        m.ID,
+		Subject:   m.Subject,
+		CreatedAt: m.CreatedAt,
+		Tags:      m.Tags,
+		Meta:      m.Meta,
+		Status:    m.Status,
+	}
+}
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session directions for SessionMessage.
+const (
+	DirectionOut = "out"
+	DirectionIn  = "in"
+)
+
+// Session is one connect: a single link to a gateway or peer, whatever moved over it.
+type Session struct {
+	ID         string
+	Transport  string // "pat", "sim", ...
+	Gateway    string // e.g. "cms.winlink.org:8772" or "W1AW-10"
+	Frequency  string // dial frequency when the link is RF, e.g. "7101.5"
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Outcome    string // "ok" or "failed"
+	Error      string
+	BytesOut   int64
+	BytesIn    int64
+	Transcript string // raw protocol/console output; sealed when encryption is enabled
+	Messages   []SessionMessage
+}
+
+// SessionMessage links a session to a message it carried. MessageID may be empty for
+// inbound traffic that has not been imported yet; it is resolved through external refs.
+type SessionMessage struct {
+	MessageID string
+	MID       string
+	Direction string
+}
+
+func (s *Store) applyV9(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			transport TEXT NOT NULL,
+			gateway TEXT NOT NULL DEFAULT '',
+			frequency TEXT NOT NULL DEFAULT '',
+			started_at TEXT NOT NULL,
+			finished_at TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			bytes_out INTEGER NOT NULL DEFAULT 0,
+			bytes_in INTEGER NOT NULL DEFAULT 0,
+			transcript TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_started_at ON sessions(started_at);`,
+		`CREATE TABLE IF NOT EXISTS session_messages (
+			session_id TEXT NOT NULL,
+			message_id TEXT NOT NULL DEFAULT '',
+			mid TEXT NOT NULL DEFAULT '',
+			direction TEXT NOT NULL,
+			PRIMARY KEY (session_id, direction, mid, message_id),
+			FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_session_messages_message_id ON session_messages(message_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_session_messages_mid ON session_messages(mid);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("apply schema v9: %w", err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version, applied_at) VALUES (?, ?)`, schemaV9, now); err != nil {
+		return fmt.Errorf("apply v9: record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RecordSession stores a finished session and its message links. If sess.ID is empty a
+// new one is generated and written back.
+func (s *Store) RecordSession(ctx context.Context, sess *Session) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("RecordSession: store is nil")
+	}
+	if sess == nil || sess.Transport == "" {
+		return fmt.Errorf("RecordSession: transport required")
+	}
+	if sess.ID == "" {
+		sess.ID = uuid.NewString()
+	}
+	if sess.FinishedAt.IsZero() {
+		sess.FinishedAt = time.Now()
+	}
+	if sess.StartedAt.IsZero() {
+		sess.StartedAt = sess.FinishedAt
+	}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		transcript, err := s.sealFieldTx(ctx, tx, sess.Transcript)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO sessions(
+				id, transport, gateway, frequency, started_at, finished_at,
+				outcome, error, bytes_out, bytes_in, transcript
+			) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			sess.ID, sess.Transport, sess.Gateway, sess.Frequency,
+			sess.StartedAt.UTC().Format(time.RFC3339Nano), sess.FinishedAt.UTC().Format(time.RFC3339Nano),
+			sess.Outcome, sess.Error, sess.BytesOut, sess.BytesIn, transcript,
+		); err != nil {
+			return err
+		}
+		for _, l := range sess.Messages {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR IGNORE INTO session_messages(session_id, message_id, mid, direction) VALUES(?, ?, ?, ?)`,
+				sess.ID, l.MessageID, l.MID, l.Direction,
+			); err != nil {
+				return err
+			}
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind: EventSession,
+			Payload: payload(map[string]any{
+				"session_id": sess.ID, "transport": sess.Transport, "gateway": sess.Gateway,
+				"outcome": sess.Outcome, "messages": len(sess.Messages),
+			}),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("RecordSession: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the most recent sessions (without transcripts or links), newest first.
+// If messageID is set only sessions that carried that message are returned.
+func (s *Store) ListSessions(ctx context.Context, messageID string, limit int) ([]Session, error) {
+	if limit <= 0 {
+		limit = 25
+	}
+	q := `SELECT id, transport, gateway, frequency, started_at, finished_at, outcome, error, bytes_out, bytes_in
+		FROM sessions`
+	args := []any{}
+	if messageID != "" {
+		q += ` WHERE id IN (` + sessionsForMessageSQL + `)`
+		args = append(args, messageID, messageID)
+	}
+	q += ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListSessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListSessions: %w", err)
+		}
+		out = append(out, sess)
+	}
+	return out, rows.Err()
+}
+
+// sessionsForMessageSQL matches sessions linked to a message directly or, for inbound
+// traffic recorded before import, through the message's external ref MIDs.
+const sessionsForMessageSQL = `
+	SELECT session_id FROM session_messages WHERE message_id = ?
+	UNION
+	SELECT sm.session_id FROM session_messages sm
+	JOIN message_external_refs r ON r.external_id = sm.mid
+	WHERE sm.mid != '' AND r.message_id = ?`
+
+// GetSession loads one session with its transcript and message links.
+func (s *Store) GetSession(ctx context.Context, id string) (*Session, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, transport, gateway, frequency, started_at, finished_at, outcome, error, bytes_out, bytes_in
+		FROM sessions WHERE id = ?`, id)
+	sess, err := scanSession(row)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("GetSession: %w", err)
+	}
+
+	var transcript string
+	if err := s.db.QueryRowContext(ctx, `SELECT transcript FROM sessions WHERE id = ?`, id).Scan(&transcript); err != nil {
+		return nil, false, fmt.Errorf("GetSession: %w", err)
+	}
+	if sess.Transcript, err = s.openField(transcript); err != nil {
+		return nil, false, fmt.Errorf("GetSession: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sm.direction, sm.mid,
+			CASE WHEN sm.message_id != '' THEN sm.message_id
+			     ELSE COALESCE((SELECT r.message_id FROM message_external_refs r
+			                    WHERE r.external_id = sm.mid AND sm.mid != '' LIMIT 1), '')
+			END
+		FROM session_messages sm
+		WHERE sm.session_id = ?
+		ORDER BY sm.direction DESC, sm.mid ASC
+	`, id)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetSession: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l SessionMessage
+		if err := rows.Scan(&l.Direction, &l.MID, &l.MessageID); err != nil {
+			return nil, false, fmt.Errorf("GetSession: %w", err)
+		}
+		sess.Messages = append(sess.Messages, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("GetSession: %w", err)
+	}
+	return &sess, true, nil
+}
+
+func scanSession(row rowScanner) (Session, error) {
+	var sess Session
+	var started, finished string
+	if err := row.Scan(&sess.ID, &sess.Transport, &sess.Gateway, &sess.Frequency, &started, &finished,
+		&sess.Outcome, &sess.Error, &sess.BytesOut, &sess.BytesIn); err != nil {
+		return sess, err
+	}
+	sess.StartedAt, _ = time.Parse(time.RFC3339Nano, started)
+	sess.FinishedAt, _ = time.Parse(time.RFC3339Nano, finished)
+	return sess, nil
+}
@@ -4,26 +4,44 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
 
 	"github.com/4current/relayops/internal/core"
 	"github.com/4current/relayops/internal/runtime"
+	"github.com/4current/relayops/internal/vault"
 	"github.com/google/uuid"
 )
 
 const (
-	schemaV1 = 1
-	schemaV2 = 2
-	schemaV3 = 3
-	schemaV4 = 4
+	schemaV1  = 1
+	schemaV2  = 2
+	schemaV3  = 3
+	schemaV4  = 4
+	schemaV5  = 5
+	schemaV6  = 6
+	schemaV7  = 7
+	schemaV8  = 8
+	schemaV9  = 9
+	schemaV10 = 10
+	schemaV11 = 11
+	schemaV12 = 12
+	schemaV13 = 13
+	schemaV14 = 14
+	schemaV15 = 15
 )
 
 type Store struct {
 	db *sql.DB
+
+	// encrypted is true once a data key has been configured; ring holds the unlocked keys.
+	encrypted atomic.Bool
+	ring      *vault.Keyring
 }
 
 func Open(ctx context.Context) (*Store, error) {
@@ -32,8 +50,11 @@ func Open(ctx context.Context) (*Store, error) {
 		return nil, err
 	}
 
-	// modernc sqlite DSN is just a filepath for basic use.
-	db, err := sql.Open("sqlite", dbPath)
+	// modernc sqlite DSN is a filepath; query params apply pragmas to every pooled connection.
+	// busy_timeout lets concurrent writers (CLI + daemon + API) wait instead of failing;
+	// immediate transactions take the write lock up front, so a read-then-write tx (e.g. the
+	// audit hash chain) waits for it rather than failing with SQLITE_BUSY on upgrade.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
@@ -44,12 +65,23 @@ func Open(ctx context.Context) (*Store, error) {
 		return nil, err
 	}
 
-	s := &Store{db: db}
+	s := &Store{db: db, ring: vault.NewKeyring()}
 	if err := s.migrate(ctx); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
 
+	if err := s.loadKeyState(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if secret := vault.SecretFromEnv(); s.encrypted.Load() && !secret.Empty() {
+		if err := s.Unlock(ctx, secret); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
@@ -121,7 +153,9 @@ func (s *Store) migrate(ctx context.Context) error {
 			return fmt.Errorf("record migration: %w", err)
 		}
 
-		return tx.Commit()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
 	}
 
 	// Check for v2, which adds a "priority" field to MessageMeta. This is just an example of how to handle schema changes that require data transformation.
@@ -154,8 +188,118 @@ func (s *Store) migrate(ctx context.Context) error {
 	        return err
 	    }
 	}
-	
-		return nil
+
+	applied5, err := s.hasMigration(ctx, schemaV5)
+	if err != nil {
+		return err
+	}
+	if !applied5 {
+		if err := s.applyV5(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied6, err := s.hasMigration(ctx, schemaV6)
+	if err != nil {
+		return err
+	}
+	if !applied6 {
+		if err := s.applyV6(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied7, err := s.hasMigration(ctx, schemaV7)
+	if err != nil {
+		return err
+	}
+	if !applied7 {
+		if err := s.applyV7(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied8, err := s.hasMigration(ctx, schemaV8)
+	if err != nil {
+		return err
+	}
+	if !applied8 {
+		if err := s.applyV8(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied9, err := s.hasMigration(ctx, schemaV9)
+	if err != nil {
+		return err
+	}
+	if !applied9 {
+		if err := s.applyV9(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied10, err := s.hasMigration(ctx, schemaV10)
+	if err != nil {
+		return err
+	}
+	if !applied10 {
+		if err := s.applyV10(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied11, err := s.hasMigration(ctx, schemaV11)
+	if err != nil {
+		return err
+	}
+	if !applied11 {
+		if err := s.applyV11(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied12, err := s.hasMigration(ctx, schemaV12)
+	if err != nil {
+		return err
+	}
+	if !applied12 {
+		if err := s.applyV12(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied13, err := s.hasMigration(ctx, schemaV13)
+	if err != nil {
+		return err
+	}
+	if !applied13 {
+		if err := s.applyV13(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied14, err := s.hasMigration(ctx, schemaV14)
+	if err != nil {
+		return err
+	}
+	if !applied14 {
+		if err := s.applyV14(ctx); err != nil {
+			return err
+		}
+	}
+
+	applied15, err := s.hasMigration(ctx, schemaV15)
+	if err != nil {
+		return err
+	}
+	if !applied15 {
+		if err := s.applyV15(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *Store) applyV3(ctx context.Context) error {
@@ -316,7 +460,21 @@ func (s *Store) SaveMessage(ctx context.Context, msg *core.Message) error {
 		return fmt.Errorf("SaveMessage: marshal Meta: %w", err)
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	var sentAt any = nil
+	if msg.SentAt != nil {
+		sentAt = msg.SentAt.UTC().Format(time.RFC3339)
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		body, err := s.sealFieldTx(ctx, tx, msg.Body)
+		if err != nil {
+			return err
+		}
+		metaField, err := s.sealFieldTx(ctx, tx, string(metaJSON))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
 	INSERT INTO messages (
 		id, subject, body, created_at,
 		from_callsign, from_email,
@@ -324,14 +482,26 @@ func (s *Store) SaveMessage(ctx context.Context, msg *core.Message) error {
 		status, updated_at, sent_at, last_error
 	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `,
-		msg.ID, msg.Subject, msg.Body, msg.CreatedAt.UTC().Format(time.RFC3339),
-		msg.From.Callsign, msg.From.Email,
-		string(toJSON), string(tagsJSON), string(metaJSON),
-		string(msg.Status),
-		msg.UpdatedAt.UTC().Format(time.RFC3339),
-		nil, // sent_at
-		msg.LastError,
-	)
+			msg.ID, msg.Subject, body, msg.CreatedAt.UTC().Format(time.RFC3339),
+			msg.From.Callsign, msg.From.Email,
+			string(toJSON), string(tagsJSON), metaField,
+			string(msg.Status),
+			msg.UpdatedAt.UTC().Format(time.RFC3339),
+			sentAt,
+			msg.LastError,
+		); err != nil {
+			return err
+		}
+		if err := s.insertAttachmentsTx(ctx, tx, msg); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind:      EventMessageCreated,
+			MessageID: msg.ID,
+			NewStatus: string(msg.Status),
+			Payload:   payload(map[string]any{"tags": msg.Tags}),
+		})
+	})
 
 	if err != nil {
 		return fmt.Errorf("SaveMessage: %w", err)
@@ -339,6 +509,71 @@ func (s *Store) SaveMessage(ctx context.Context, msg *core.Message) error {
 	return nil
 }
 
+// UpdateContent replaces the subject, body, form and attachments of message id with
+// those of msg, e.g. when the file it was imported from changed. Status, tags,
+// addresses and the rest of the metadata are kept. It fails with ErrMessageNotFound
+// when there is no such message.
+func (s *Store) UpdateContent(ctx context.Context, id string, msg *core.Message) error {
+	if msg == nil {
+		return fmt.Errorf("UpdateContent: msg is nil")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var metaField string
+		err := tx.QueryRowContext(ctx, `SELECT meta_json FROM messages WHERE id = ?`, id).Scan(&metaField)
+		if err == sql.ErrNoRows {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		metaJSON, err := s.openField(metaField)
+		if err != nil {
+			return err
+		}
+		var meta core.MessageMeta
+		if metaJSON != "" {
+			if err := json.Unmarshal([]byte(metaJSON), &meta); err != nil {
+				return fmt.Errorf("unmarshal meta_json: %w", err)
+			}
+		}
+		meta.Form = msg.Meta.Form
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+
+		body, err := s.sealFieldTx(ctx, tx, msg.Body)
+		if err != nil {
+			return err
+		}
+		if metaField, err = s.sealFieldTx(ctx, tx, string(b)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE messages SET subject = ?, body = ?, meta_json = ?, updated_at = ? WHERE id = ?`,
+			msg.Subject, body, metaField, now, id,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM message_attachments WHERE message_id = ?`, id); err != nil {
+			return err
+		}
+		if err := s.insertAttachmentsTx(ctx, tx, &core.Message{ID: id, Attachments: msg.Attachments}); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{
+			Kind:      EventMessageContent,
+			MessageID: id,
+			Payload:   payload(map[string]any{"subject": msg.Subject, "attachments": len(msg.Attachments)}),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateContent: %w", err)
+	}
+	return nil
+}
+
 type MessageSummary struct {
 	ID        string
 	Subject   string
@@ -378,8 +613,11 @@ func (s *Store) ListMessages(ctx context.Context, limit int, includeDeleted bool
 		var tags []string
 		_ = json.Unmarshal([]byte(tagsStr), &tags)
 
+		// Metadata stays empty in listings while an encrypted store is locked.
 		var meta core.MessageMeta
-		_ = json.Unmarshal([]byte(metaStr), &meta)
+		if plain, err := s.openField(metaStr); err == nil {
+			_ = json.Unmarshal([]byte(plain), &meta)
+		}
 
 		out = append(out, MessageSummary{
 			ID:        id,
@@ -396,6 +634,11 @@ func (s *Store) ListMessages(ctx context.Context, limit int, includeDeleted bool
 	return out, nil
 }
 
+// ErrMessageNotFound is returned when a message to change does not exist or is deleted.
+var ErrMessageNotFound = errors.New("message not found")
+
+// SetStatusByID moves message id to status; it fails with ErrMessageNotFound when
+// there is no such message.
 func (s *Store) SetStatusByID(ctx context.Context, id string, status core.MessageStatus, lastErr string) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 
@@ -404,11 +647,30 @@ func (s *Store) SetStatusByID(ctx context.Context, id string, status core.Messag
 		sentAt = now
 	}
 
-	_, err := s.db.ExecContext(ctx, `
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var old string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM messages WHERE id = ? AND status != 'deleted'`, id).Scan(&old)
+		if err == sql.ErrNoRows {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
 		UPDATE messages
 		SET status = ?, updated_at = ?, sent_at = COALESCE(?, sent_at), last_error = ?
 		WHERE id = ? AND status != 'deleted'
-	`, string(status), now, sentAt, lastErr, id)
+	`, string(status), now, sentAt, lastErr, id); err != nil {
+			return err
+		}
+
+		ev := Event{Kind: EventMessageStatus, MessageID: id, OldStatus: old, NewStatus: string(status)}
+		if lastErr != "" {
+			ev.Payload = payload(map[string]any{"last_error": lastErr})
+		}
+		return appendEvent(ctx, tx, ev)
+	})
 
 	if err != nil {
 		return fmt.Errorf("SetStatusByID: %w", err)
@@ -416,19 +678,85 @@ func (s *Store) SetStatusByID(ctx context.Context, id string, status core.Messag
 	return nil
 }
 
+// hasTag matches messages whose tags_json array holds the bound tag exactly.
+const hasTag = `EXISTS (SELECT 1 FROM json_each(tags_json) WHERE json_each.value = ?)`
+
+// QueueByTag queues the drafts and failed messages tagged tag.
 func (s *Store) QueueByTag(ctx context.Context, tag string) (int64, error) {
+	n, err := s.queueWhere(ctx, hasTag, []any{tag}, map[string]any{"tag": tag})
+	if err != nil {
+		return 0, fmt.Errorf("QueueByTag: %w", err)
+	}
+	return n, nil
+}
+
+// QueueByIDs queues the messages ids that are drafts or failed; others are left as they are.
+func (s *Store) QueueByIDs(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	ph := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		ph[i], args[i] = "?", id
+	}
+	n, err := s.queueWhere(ctx, "id IN ("+strings.Join(ph, ",")+")", args, nil)
+	if err != nil {
+		return 0, fmt.Errorf("QueueByIDs: %w", err)
+	}
+	return n, nil
+}
+
+// queueWhere queues the drafts and failed messages matching cond, recording p in
+// each status event.
+func (s *Store) queueWhere(ctx context.Context, cond string, args []any, p map[string]any) (int64, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	res, err := s.db.ExecContext(ctx, `
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+		SELECT id, status FROM messages
+		WHERE status IN ('draft','failed')
+		  AND (`+cond+`)
+	`, args...)
+		if err != nil {
+			return err
+		}
+		type change struct{ id, old string }
+		var changes []change
+		for rows.Next() {
+			var c change
+			if err := rows.Scan(&c.id, &c.old); err != nil {
+				rows.Close()
+				return err
+			}
+			changes = append(changes, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			if _, err := tx.ExecContext(ctx, `
 		UPDATE messages
 		SET status = 'queued', updated_at = ?, last_error = ''
-		WHERE status IN ('draft','failed')
-		  AND (tags_json LIKE ?)
-	`, now, "%"+tag+"%")
-	if err != nil {
-		return 0, fmt.Errorf("QueueByTag: %w", err)
-	}
-	return res.RowsAffected()
+		WHERE id = ?
+	`, now, c.id); err != nil {
+				return err
+			}
+			ev := Event{Kind: EventMessageStatus, MessageID: c.id, OldStatus: c.old, NewStatus: string(core.StatusQueued)}
+			if p != nil {
+				ev.Payload = payload(p)
+			}
+			if err := appendEvent(ctx, tx, ev); err != nil {
+				return err
+			}
+		}
+		n = int64(len(changes))
+		return nil
+	})
+	return n, err
 }
 
 func (s *Store) ListByStatus(ctx context.Context, statuses []core.MessageStatus, limit int) ([]MessageSummary, error) {
@@ -473,8 +801,11 @@ func (s *Store) ListByStatus(ctx context.Context, statuses []core.MessageStatus,
 		var tags []string
 		_ = json.Unmarshal([]byte(tagsStr), &tags)
 
+		// Metadata stays empty in listings while an encrypted store is locked.
 		var meta core.MessageMeta
-		_ = json.Unmarshal([]byte(metaStr), &meta)
+		if plain, err := s.openField(metaStr); err == nil {
+			_ = json.Unmarshal([]byte(plain), &meta)
+		}
 
 		out = append(out, MessageSummary{
 			ID:        id,
@@ -496,20 +827,18 @@ func (s *Store) ListQueued(ctx context.Context, tag string, limit int) ([]*core.
 	args := []any{}
 	where := "WHERE status = 'queued'"
 	if strings.TrimSpace(tag) != "" {
-		where += " AND tags_json LIKE ?"
-		args = append(args, "%"+tag+"%")
+		where += " AND " + hasTag
+		args = append(args, strings.TrimSpace(tag))
 	}
 	args = append(args, limit)
 
 	q := fmt.Sprintf(`
-		SELECT id, subject, body, created_at, from_callsign, from_email,
-		       to_json, tags_json, meta_json,
-		       status, updated_at, sent_at, last_error
+		SELECT %s
 		FROM messages
 		%s
 		ORDER BY updated_at ASC
 		LIMIT ?
-	`, where)
+	`, messageColumns, where)
 
 	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
@@ -519,61 +848,97 @@ func (s *Store) ListQueued(ctx context.Context, tag string, limit int) ([]*core.
 
 	var out []*core.Message
 	for rows.Next() {
-		var (
-			id, subject, body, createdAtStr string
-			fromCall, fromEmail             sql.NullString
-			toStr, tagsStr, metaStr         string
-			statusStr, updatedAtStr         string
-			sentAtStr                       sql.NullString
-			lastErr                         string
-		)
-
-		if err := rows.Scan(&id, &subject, &body, &createdAtStr, &fromCall, &fromEmail,
-			&toStr, &tagsStr, &metaStr,
-			&statusStr, &updatedAtStr, &sentAtStr, &lastErr,
-		); err != nil {
+		m, err := s.scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	// Senders build the outbound message from these, form XML included.
+	if err := s.loadAttachments(ctx, out...); err != nil {
+		return nil, fmt.Errorf("ListQueued: %w", err)
+	}
+	return out, nil
+}
 
-		createdAt, _ := time.Parse(time.RFC3339, createdAtStr)
-		updatedAt, _ := time.Parse(time.RFC3339, updatedAtStr)
+// messageColumns is the column list understood by scanMessage.
+const messageColumns = `id, subject, body, created_at, from_callsign, from_email,
+		       to_json, tags_json, meta_json,
+		       status, updated_at, sent_at, last_error`
 
-		var to []core.Address
-		_ = json.Unmarshal([]byte(toStr), &to)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-		var tags []string
-		_ = json.Unmarshal([]byte(tagsStr), &tags)
+// scanMessage decodes a full message row selected with messageColumns.
+// Sealed fields are opened; a locked encrypted store yields ErrLocked.
+func (s *Store) scanMessage(row rowScanner) (*core.Message, error) {
+	var (
+		id, subject, body, createdAtStr string
+		fromCall, fromEmail             sql.NullString
+		toStr, tagsStr, metaStr         string
+		statusStr, updatedAtStr         string
+		sentAtStr                       sql.NullString
+		lastErr                         string
+	)
 
-		var meta core.MessageMeta
-		_ = json.Unmarshal([]byte(metaStr), &meta)
+	if err := row.Scan(&id, &subject, &body, &createdAtStr, &fromCall, &fromEmail,
+		&toStr, &tagsStr, &metaStr,
+		&statusStr, &updatedAtStr, &sentAtStr, &lastErr,
+	); err != nil {
+		return nil, err
+	}
 
-		var sentAtPtr *time.Time
-		if sentAtStr.Valid {
-			t, err := time.Parse(time.RFC3339, sentAtStr.String)
-			if err == nil {
-				sentAtPtr = &t
-			}
-		}
+	createdAt, _ := time.Parse(time.RFC3339, createdAtStr)
+	updatedAt, _ := time.Parse(time.RFC3339, updatedAtStr)
 
-		out = append(out, &core.Message{
-			ID:        id,
-			Subject:   subject,
-			Body:      body,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-			From: core.Address{
-				Callsign: fromCall.String,
-				Email:    fromEmail.String,
-			},
-			To:        to,
-			Tags:      tags,
-			Meta:      meta,
-			Status:    core.MessageStatus(statusStr),
-			SentAt:    sentAtPtr,
-			LastError: lastErr,
-		})
+	var to []core.Address
+	_ = json.Unmarshal([]byte(toStr), &to)
+
+	var tags []string
+	_ = json.Unmarshal([]byte(tagsStr), &tags)
+
+	body, err := s.openField(body)
+	if err != nil {
+		return nil, err
 	}
-	return out, rows.Err()
+	metaStr, err = s.openField(metaStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta core.MessageMeta
+	_ = json.Unmarshal([]byte(metaStr), &meta)
+
+	var sentAtPtr *time.Time
+	if sentAtStr.Valid {
+		t, err := time.Parse(time.RFC3339, sentAtStr.String)
+		if err == nil {
+			sentAtPtr = &t
+		}
+	}
+
+	return &core.Message{
+		ID:        id,
+		Subject:   subject,
+		Body:      body,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+		From: core.Address{
+			Callsign: fromCall.String,
+			Email:    fromEmail.String,
+		},
+		To:        to,
+		Tags:      tags,
+		Meta:      meta,
+		Status:    core.MessageStatus(statusStr),
+		SentAt:    sentAtPtr,
+		LastError: lastErr,
+	}, nil
 }
 
 func (s *Store) MarkSending(ctx context.Context, id string) error {
@@ -581,14 +946,34 @@ func (s *Store) MarkSending(ctx context.Context, id string) error {
 }
 
 func (s *Store) DeleteByID(ctx context.Context, id string) (int64, error) {
-	res, err := s.db.ExecContext(ctx,
-		`UPDATE messages SET status = ? WHERE id = ? AND status != ?`,
-		core.StatusDeleted, id, core.StatusDeleted,
-	)
+	// updated_at marks when the message was deleted; retention ages deleted rows from it.
+	now := time.Now().UTC().Format(time.RFC3339)
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var old string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM messages WHERE id = ? AND status != ?`, id, core.StatusDeleted).Scan(&old)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx,
+			`UPDATE messages SET status = ?, updated_at = ? WHERE id = ? AND status != ?`,
+			core.StatusDeleted, now, id, core.StatusDeleted,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, Event{Kind: EventMessageDeleted, MessageID: id, OldStatus: old, NewStatus: string(core.StatusDeleted)})
+	})
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	return n, nil
 }
 
 
@@ -131,3 +131,73 @@ func TestListQueuedReturnsQueuedMessages(t *testing.T) {
 		t.Fatalf("expected queued status, got %s", msgs[0].Status)
 	}
 }
+
+func TestQueueMatchesWholeTags(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	exact := core.NewMessage("exact", "x")
+	exact.Tags = []string{"other", "net"}
+	longer := core.NewMessage("longer", "x")
+	longer.Tags = []string{"net_old"}
+	byID := core.NewMessage("by id", "x")
+	for _, m := range []*core.Message{exact, longer, byID} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+
+	if n, err := st.QueueByTag(ctx, "net"); err != nil || n != 1 {
+		t.Fatalf("QueueByTag = %d, %v; want only the exact tag", n, err)
+	}
+	if n, err := st.QueueByIDs(ctx, []string{byID.ID, exact.ID}); err != nil || n != 1 {
+		t.Fatalf("QueueByIDs = %d, %v; want only the draft", n, err)
+	}
+	msgs, err := st.ListQueued(ctx, "net", 10)
+	if err != nil || len(msgs) != 1 || msgs[0].ID != exact.ID {
+		t.Fatalf("ListQueued(net) = %+v, %v", msgs, err)
+	}
+	if m, _, _ := st.GetMessage(ctx, longer.ID); m.Status != core.StatusDraft {
+		t.Fatalf("net_old was queued: %s", m.Status)
+	}
+}
+
+func TestFolderFilterAndCountByStatus(t *testing.T) {
+	st, ctx := setupStore(t)
+
+	in := core.NewMessage("Inbound", "Body")
+	out := core.NewMessage("Outbound", "Body")
+	for _, m := range []*core.Message{in, out} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	if err := st.UpsertBackendState(ctx, in.ID, "pat", "InBox", "Received", ""); err != nil {
+		t.Fatalf("UpsertBackendState: %v", err)
+	}
+	if err := st.SetStatusByID(ctx, out.ID, core.StatusQueued, ""); err != nil {
+		t.Fatalf("SetStatusByID: %v", err)
+	}
+
+	got, err := st.QueryMessages(ctx, store.MessageFilter{Folder: "inbox"})
+	if err != nil {
+		t.Fatalf("QueryMessages: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != in.ID {
+		t.Fatalf("expected only the inbox message, got %d", len(got))
+	}
+	got, err = st.QueryMessages(ctx, store.MessageFilter{NotFolder: "INBOX"})
+	if err != nil {
+		t.Fatalf("QueryMessages: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != out.ID {
+		t.Fatalf("expected only the outbound message, got %d", len(got))
+	}
+
+	counts, err := st.CountByStatus(ctx)
+	if err != nil {
+		t.Fatalf("CountByStatus: %v", err)
+	}
+	if counts[core.StatusDraft] != 1 || counts[core.StatusQueued] != 1 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+}
@@ -0,0 +1,318 @@
+// Package templates renders message templates (Go text/template) from
+// ~/.relayops/templates, falling back to the templates shipped with RelayOps.
+//
+// A template file starts with header lines, then a blank line, then the body:
+//
+//	Subject: {{.net}} check-in {{.callsign}}
+//	To: {{.net_control}}
+//	Tags: winlink_wednesday
+//
+//	{{.callsign}} checking in from {{.location}}.
+//
+// Subject is required; To and Tags (comma-separated) are optional. Every header and the
+// body are templates over the same variables.
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/4current/relayops/internal/runtime"
+)
+
+// Ext is the file extension of template files.
+const Ext = ".tmpl"
+
+//go:embed builtin
+var builtinFiles embed.FS
+
+// Template is a parsed message template.
+type Template struct {
+	Name    string
+	Source  string // file path, or "built-in"
+	Subject string
+	To      string
+	Tags    string
+	Body    string
+}
+
+// Rendered is a template with its variables filled in.
+type Rendered struct {
+	Subject string
+	To      []string
+	Tags    []string
+	Body    string
+}
+
+// MissingError lists variables a template needs that were not supplied.
+type MissingError struct {
+	Template string
+	Missing  []string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("template %s: missing variable(s): %s (pass -var key=value)", e.Template, strings.Join(e.Missing, ", "))
+}
+
+// Load finds a template by name in the user's template directory, then among the
+// built-ins. A name containing a path separator or ending in .tmpl is read as a file.
+func Load(name string) (*Template, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasSuffix(name, Ext) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(strings.TrimSuffix(filepath.Base(name), Ext), name, string(b))
+	}
+
+	dir, err := runtime.TemplatesDir()
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.Join(dir, name+Ext)
+	if b, err := os.ReadFile(p); err == nil {
+		return Parse(name, p, string(b))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	b, err := builtinFiles.ReadFile("builtin/" + name + Ext)
+	if err != nil {
+		return nil, fmt.Errorf("no template %q in %s or the built-ins", name, dir)
+	}
+	return Parse(name, "built-in", string(b))
+}
+
+// List returns the names of all available templates, user templates first shadowing
+// built-ins of the same name, sorted.
+func List() ([]Template, error) {
+	seen := map[string]bool{}
+	var out []Template
+
+	if dir, err := runtime.TemplatesDir(); err == nil {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), Ext) {
+				continue
+			}
+			name := strings.TrimSuffix(e.Name(), Ext)
+			seen[name] = true
+			out = append(out, Template{Name: name, Source: filepath.Join(dir, e.Name())})
+		}
+	}
+	entries, _ := fs.ReadDir(builtinFiles, "builtin")
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), Ext)
+		if !seen[name] {
+			out = append(out, Template{Name: name, Source: "built-in"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Parse reads a template file's headers and body and checks that every part is a
+// valid text/template.
+func Parse(name, source, src string) (*Template, error) {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	head, body, _ := strings.Cut(src, "\n\n")
+	t := &Template{Name: name, Source: source, Body: body}
+	for _, line := range strings.Split(head, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("template %s: bad header line %q (want Key: value, then a blank line, then the body)", name, line)
+		}
+		v = strings.TrimSpace(v)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "subject":
+			t.Subject = v
+		case "to":
+			t.To = v
+		case "tags":
+			t.Tags = v
+		default:
+			return nil, fmt.Errorf("template %s: unknown header %q", name, k)
+		}
+	}
+	if t.Subject == "" {
+		return nil, fmt.Errorf("template %s: Subject header is required", name)
+	}
+	if _, err := t.parts(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// New builds a template from inline subject and body text.
+func New(name, subject, body string) (*Template, error) {
+	t := &Template{Name: name, Source: "inline", Subject: subject, Body: body}
+	if _, err := t.parts(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type part struct {
+	name string
+	tmpl *template.Template
+}
+
+func (t *Template) parts() ([]part, error) {
+	var out []part
+	for _, p := range []struct{ name, text string }{
+		{"subject", t.Subject}, {"to", t.To}, {"tags", t.Tags}, {"body", t.Body},
+	} {
+		pt, err := template.New(t.Name + ":" + p.name).Option("missingkey=error").Funcs(funcs).Parse(p.text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		out = append(out, part{p.name, pt})
+	}
+	return out, nil
+}
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"now":   func(layout string) string { return time.Now().UTC().Format(layout) },
+}
+
+// Variables returns the variables the template uses. Variables tested in an if/with
+// condition are optional (they render as "" when unset); all others are required.
+func (t *Template) Variables() (required, optional []string) {
+	parts, err := t.parts()
+	if err != nil {
+		return nil, nil
+	}
+	used, guarded := map[string]bool{}, map[string]bool{}
+	for _, p := range parts {
+		if p.tmpl.Tree != nil {
+			walk(p.tmpl.Tree.Root, used, guarded, false)
+		}
+	}
+	for v := range used {
+		if guarded[v] {
+			optional = append(optional, v)
+		} else {
+			required = append(required, v)
+		}
+	}
+	sort.Strings(required)
+	sort.Strings(optional)
+	return required, optional
+}
+
+func walk(n parse.Node, used, guarded map[string]bool, cond bool) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walk(c, used, guarded, cond)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, used, guarded, cond)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walk(c, used, guarded, cond)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			walk(a, used, guarded, cond)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 0 {
+			used[n.Ident[0]] = true
+			if cond {
+				guarded[n.Ident[0]] = true
+			}
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, used, guarded)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, used, guarded)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, used, guarded)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, used, guarded map[string]bool) {
+	walk(b.Pipe, used, guarded, true)
+	walk(b.List, used, guarded, false)
+	walk(b.ElseList, used, guarded, false)
+}
+
+// Missing returns the required variables that vars does not define.
+func (t *Template) Missing(vars map[string]string) []string {
+	required, _ := t.Variables()
+	var missing []string
+	for _, v := range required {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// Render fills in the template. Missing required variables are reported all at once,
+// as a *MissingError, before anything is rendered.
+func (t *Template) Render(vars map[string]string) (*Rendered, error) {
+	if missing := t.Missing(vars); len(missing) > 0 {
+		return nil, &MissingError{Template: t.Name, Missing: missing}
+	}
+	data := make(map[string]string, len(vars))
+	_, optional := t.Variables()
+	for _, v := range optional {
+		data[v] = ""
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	parts, err := t.parts()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, p := range parts {
+		var b strings.Builder
+		if err := p.tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		out[p.name] = b.String()
+	}
+	return &Rendered{
+		Subject: strings.TrimSpace(out["subject"]),
+		To:      splitList(out["to"]),
+		Tags:    splitList(out["tags"]),
+		Body:    out["body"],
+	}, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAndVariables(t *testing.T) {
+	tm, err := Parse("t", "test", "Subject: {{.net}} check-in {{.callsign}}\nTo: {{.to}}\nTags: ww, {{.extra_tag}}\n\n{{.callsign}}{{if .name}} - {{.name}}{{end}}\n{{with .remarks}}Remarks: {{.}}{{end}}\n")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	required, optional := tm.Variables()
+	if want := []string{"callsign", "extra_tag", "net", "to"}; !reflect.DeepEqual(required, want) {
+		t.Fatalf("required = %v, want %v", required, want)
+	}
+	if want := []string{"name", "remarks"}; !reflect.DeepEqual(optional, want) {
+		t.Fatalf("optional = %v, want %v", optional, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"no subject":     "To: AE4OK\n\nbody",
+		"unknown header": "Subject: x\nFrom: y\n\nbody",
+		"bad syntax":     "Subject: {{.x\n\nbody",
+	}
+	for name, src := range cases {
+		if _, err := Parse(name, "test", src); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRenderReportsAllMissing(t *testing.T) {
+	tm, err := New("t", "{{.a}} {{.b}}", "{{.c}}{{if .d}}{{.d}}{{end}}")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = tm.Render(map[string]string{"b": "x"})
+	var me *MissingError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected MissingError, got %v", err)
+	}
+	if want := []string{"a", "c"}; !reflect.DeepEqual(me.Missing, want) {
+		t.Fatalf("missing = %v, want %v", me.Missing, want)
+	}
+
+	out, err := tm.Render(map[string]string{"a": "1", "b": "2", "c": "3"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if out.Subject != "1 2" || out.Body != "3" {
+		t.Fatalf("rendered %+v", out)
+	}
+}
+
+func TestLoadPrefersUserTemplates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tm, err := Load("checkin")
+	if err != nil {
+		t.Fatalf("Load built-in: %v", err)
+	}
+	if tm.Source != "built-in" {
+		t.Fatalf("source = %q", tm.Source)
+	}
+
+	dir := filepath.Join(home, ".relayops", "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := "Subject: Mine {{.callsign}}\nTags: local\n\nhello\n"
+	if err := os.WriteFile(filepath.Join(dir, "checkin.tmpl"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tm, err = Load("checkin")
+	if err != nil {
+		t.Fatalf("Load user: %v", err)
+	}
+	out, err := tm.Render(map[string]string{"callsign": "AE4OK"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if out.Subject != "Mine AE4OK" || !reflect.DeepEqual(out.Tags, []string{"local"}) {
+		t.Fatalf("rendered %+v", out)
+	}
+
+	list, err := List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, l := range list {
+		names = append(names, l.Name+"="+l.Source)
+	}
+	joined := strings.Join(names, " ")
+	if !strings.Contains(joined, "checkin="+filepath.Join(dir, "checkin.tmpl")) || !strings.Contains(joined, "winlink-wednesday=built-in") {
+		t.Fatalf("List = %v", names)
+	}
+}
+
+func TestVarsPrecedenceAndGPS(t *testing.T) {
+	t.Setenv("RELAYOPS_CALLSIGN", "ae4ok")
+	t.Setenv("RELAYOPS_LOCATION", "Knoxville")
+	t.Setenv("RELAYOPS_GPS", "35.9606,-83.9207")
+
+	tm, err := New("t", "{{.callsign}} {{.gps_grid}}", "{{.location}} {{.utc_date}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := Vars(t.Context(), tm, map[string]string{"location": "Field"})
+	if err != nil {
+		t.Fatalf("Vars: %v", err)
+	}
+	if vars["callsign"] != "AE4OK" || vars["location"] != "Field" || vars["gps_grid"] != "EM85ax" || vars["utc_date"] == "" {
+		t.Fatalf("vars = %v", vars)
+	}
+}
+
+func TestVarsWithoutGPSFix(t *testing.T) {
+	t.Setenv("RELAYOPS_GPS", "no fix") // GPSVars fails without touching gpsd
+
+	optional, err := New("t", "Check-in", "{{if .gps_grid}}Grid {{.gps_grid}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := Vars(t.Context(), optional, nil)
+	if err != nil {
+		t.Fatalf("Vars with an optional gps_ variable: %v", err)
+	}
+	if _, ok := vars["gps_grid"]; ok {
+		t.Fatalf("gps_grid set without a fix: %v", vars)
+	}
+
+	required, err := New("t", "Check-in", "Grid {{.gps_grid}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Vars(t.Context(), required, nil); err == nil {
+		t.Fatal("Vars with a required gps_ variable and no fix: want an error")
+	}
+	if _, err := Vars(t.Context(), required, map[string]string{"gps_grid": "EM73"}); err != nil {
+		t.Fatalf("Vars with the required gps_ variable given: %v", err)
+	}
+}
+
+func TestMaidenhead(t *testing.T) {
+	cases := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{41.714775, -72.727260, "FN31pr"}, // W1AW
+		{51.477928, -0.001545, "IO91xl"},  // Greenwich
+		{-33.8568, 151.2153, "QF56od"},    // Sydney
+	}
+	for _, c := range cases {
+		if got := Maidenhead(c.lat, c.lon); got != c.want {
+			t.Errorf("Maidenhead(%v, %v) = %s, want %s", c.lat, c.lon, got, c.want)
+		}
+	}
+}
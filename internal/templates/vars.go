@@ -0,0 +1,171 @@
+package templates
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/runtime"
+)
+
+// stationEnv maps station profile variables to the environment variables that set them.
+var stationEnv = map[string]string{
+	"name":     "RELAYOPS_NAME",
+	"location": "RELAYOPS_LOCATION",
+	"grid":     "RELAYOPS_GRID",
+	"power":    "RELAYOPS_POWER",
+	"mode":     "RELAYOPS_MODE",
+}
+
+// StationVars returns the station profile: callsign, station, scope and whichever of
+// name/location/grid/power/mode are configured.
+func StationVars() map[string]string {
+	vars := map[string]string{}
+	if cs := strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN"))); cs != "" {
+		vars["callsign"] = cs
+		vars["scope"] = runtime.IdentityScope(cs)
+	}
+	if st := strings.TrimSpace(os.Getenv("RELAYOPS_STATION")); st != "" {
+		vars["station"] = st
+	}
+	for k, env := range stationEnv {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// TimeVars returns local and UTC date/time variables for now.
+func TimeVars(now time.Time) map[string]string {
+	utc := now.UTC()
+	return map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+		"weekday":  now.Weekday().String(),
+		"utc_date": utc.Format("2006-01-02"),
+		"utc_time": utc.Format("15:04Z"),
+		"datetime": utc.Format("2006-01-02 15:04Z"),
+	}
+}
+
+// GPSVars returns gps_lat, gps_lon and gps_grid from RELAYOPS_GPS ("lat,lon") or, if
+// that is unset, a fix from gpsd on localhost.
+func GPSVars(ctx context.Context) (map[string]string, error) {
+	var lat, lon float64
+	if v := strings.TrimSpace(os.Getenv("RELAYOPS_GPS")); v != "" {
+		a, b, ok := strings.Cut(v, ",")
+		var err1, err2 error
+		lat, err1 = strconv.ParseFloat(strings.TrimSpace(a), 64)
+		lon, err2 = strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("RELAYOPS_GPS must be \"lat,lon\", got %q", v)
+		}
+	} else {
+		var err error
+		if lat, lon, err = ReadGPSD(ctx, "127.0.0.1:2947"); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{
+		"gps_lat":  strconv.FormatFloat(lat, 'f', 5, 64),
+		"gps_lon":  strconv.FormatFloat(lon, 'f', 5, 64),
+		"gps_grid": Maidenhead(lat, lon),
+	}, nil
+}
+
+// ReadGPSD asks gpsd at addr for the current position and waits for a 2D/3D fix.
+func ReadGPSD(ctx context.Context, addr string) (float64, float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("gpsd: %w", err)
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	if _, err := fmt.Fprint(conn, `?WATCH={"enable":true,"json":true};`+"\n"); err != nil {
+		return 0, 0, fmt.Errorf("gpsd: %w", err)
+	}
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		var r struct {
+			Class string   `json:"class"`
+			Mode  int      `json:"mode"`
+			Lat   *float64 `json:"lat"`
+			Lon   *float64 `json:"lon"`
+		}
+		if json.Unmarshal(sc.Bytes(), &r) != nil || r.Class != "TPV" {
+			continue
+		}
+		if r.Mode >= 2 && r.Lat != nil && r.Lon != nil {
+			return *r.Lat, *r.Lon, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, 0, fmt.Errorf("gpsd: no fix: %w", err)
+	}
+	return 0, 0, fmt.Errorf("gpsd: no fix")
+}
+
+// Maidenhead returns the 6-character grid locator for a position, e.g. "EM73tu".
+func Maidenhead(lat, lon float64) string {
+	lon = math.Min(math.Max(lon+180, 0), 359.99999)
+	lat = math.Min(math.Max(lat+90, 0), 179.99999)
+	b := []byte{
+		byte('A' + int(lon/20)),
+		byte('A' + int(lat/10)),
+		byte('0' + int(math.Mod(lon, 20)/2)),
+		byte('0' + int(math.Mod(lat, 10))),
+		byte('a' + int(math.Mod(lon, 2)*12)),
+		byte('a' + int(math.Mod(lat, 1)*24)),
+	}
+	return string(b)
+}
+
+// Vars assembles the variables for t, lowest precedence first: station profile, date
+// and time, GPS (only if t uses a gps_ variable) and then user values. Without a GPS
+// fix, only a required gps_ variable is an error; optional ones ({{if}}-guarded) are
+// left unset.
+func Vars(ctx context.Context, t *Template, user map[string]string) (map[string]string, error) {
+	vars := StationVars()
+	for k, v := range TimeVars(time.Now()) {
+		vars[k] = v
+	}
+
+	required, optional := t.Variables()
+	needGPS := func(names []string) bool {
+		for _, v := range names {
+			if _, given := user[v]; strings.HasPrefix(v, "gps_") && !given {
+				return true
+			}
+		}
+		return false
+	}
+	if mustGPS := needGPS(required); mustGPS || needGPS(optional) {
+		gps, err := GPSVars(ctx)
+		if err != nil && mustGPS {
+			return nil, err
+		}
+		for k, v := range gps {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range user {
+		vars[k] = v
+	}
+	return vars, nil
+}
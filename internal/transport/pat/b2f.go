@@ -1,26 +1,40 @@
 package pat
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
+	"mime"
+	"net/textproto"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/4current/relayops/internal/core"
 )
 
-func BuildB2F(mycall, mid string, m *core.Message) ([]byte, error) {
+// BuildB2F encodes m for pat's outbox. From is the message's sender, or mycall; Mbo is
+// the licensed callsign whose mailbox sends it (see MailboxCall). tactical maps
+// registered tactical addresses to their licensed callsigns.
+func BuildB2F(mycall, mid string, m *core.Message, tactical map[string]string) ([]byte, error) {
 	to := firstTo(m)
 	if to == "" {
 		return nil, fmt.Errorf("b2f: missing To")
 	}
-	from := strings.TrimSpace(m.From.Callsign)
+	from := strings.ToUpper(strings.TrimSpace(m.From.Callsign))
 	if from == "" {
-		from = mycall
+		from = strings.ToUpper(strings.TrimSpace(mycall))
 	}
-	if strings.TrimSpace(from) == "" {
+	if from == "" {
 		return nil, fmt.Errorf("b2f: missing From")
 	}
+	mbo, err := MailboxCall(mycall, from, tactical)
+	if err != nil {
+		return nil, fmt.Errorf("b2f: %w", err)
+	}
 
 	body := normalizeLF(m.Body)
 	if !strings.HasSuffix(body, "\n") {
@@ -33,17 +47,175 @@ func BuildB2F(mycall, mid string, m *core.Message) ([]byte, error) {
 	h := ""
 	h += fmt.Sprintf("Mid: %s\n", mid)
 	h += fmt.Sprintf("Body: %d\n", len(bodyBytes))
+	for _, a := range m.Attachments {
+		h += fmt.Sprintf("File: %d %s\n", len(a.Data), sanitizeHeader(a.Name))
+	}
 	h += "Content-Transfer-Encoding: 8bit\n"
 	h += "Content-Type: text/plain; charset=ISO-8859-1\n"
 	h += fmt.Sprintf("Date: %s\n", date)
-	h += fmt.Sprintf("From: %s\n", strings.ToUpper(from))
-	h += fmt.Sprintf("Mbo: %s\n", strings.ToUpper(mycall))
+	h += fmt.Sprintf("From: %s\n", from)
+	h += fmt.Sprintf("Mbo: %s\n", mbo)
 	h += fmt.Sprintf("Subject: %s\n", sanitizeHeader(m.Subject))
 	h += fmt.Sprintf("To: %s\n", strings.ToUpper(to))
 	h += "Type: Private\n"
 	h += "\n"
 
-	return append([]byte(h), bodyBytes...), nil
+	out := append([]byte(h), bodyBytes...)
+	// Each file follows the body, preceded by CRLF; a final CRLF closes the last one.
+	for _, a := range m.Attachments {
+		out = append(out, '\r', '\n')
+		out = append(out, a.Data...)
+	}
+	if len(m.Attachments) > 0 {
+		out = append(out, '\r', '\n')
+	}
+	return out, nil
+}
+
+// B2FMessage is a Winlink message as PAT keeps it in its mailbox (<MID>.b2f): "Key:
+// value" header lines, a blank line, Body: bytes of body text and then the files
+// announced by the File: headers, each preceded by CRLF.
+type B2FMessage struct {
+	Header  textproto.MIMEHeader // every header, including the ones below
+	MID     string
+	Date    time.Time // zero if missing or unparseable
+	From    string
+	To      []string // "SMTP:" prefixes are stripped from internet addresses
+	Cc      []string
+	Mbo     string
+	Subject string
+	Body    string // UTF-8, LF line endings, without trailing newlines
+	Files   []core.Attachment
+}
+
+// b2fDateLayouts are the Date: forms seen in the wild; Winlink uses the first, in UTC.
+var b2fDateLayouts = []string{"2006/01/02 15:04", "2006/01/02 15:04:05", time.RFC1123Z, time.RFC1123}
+
+// ParseB2F decodes an uncompressed B2F message, the format of PAT's .b2f files and of
+// BuildB2F. Header lines may end in CRLF or LF. The body is converted from ISO-8859-1,
+// Winlink's default charset, unless it is declared or already valid UTF-8.
+func ParseB2F(data []byte) (*B2FMessage, error) {
+	m := &B2FMessage{Header: textproto.MIMEHeader{}}
+	off := 0
+	for {
+		if off >= len(data) {
+			return nil, fmt.Errorf("b2f: header not terminated by a blank line")
+		}
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("b2f: header not terminated by a blank line")
+		}
+		line := strings.TrimRight(string(data[off:off+end]), "\r")
+		off += end + 1
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("b2f: malformed header line %q", line)
+		}
+		m.Header.Add(textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(k)), strings.TrimSpace(v))
+	}
+
+	h := m.Header
+	m.MID, m.From, m.Mbo, m.Subject = h.Get("Mid"), h.Get("From"), h.Get("Mbo"), h.Get("Subject")
+	m.To, m.Cc = b2fAddresses(h.Values("To")), b2fAddresses(h.Values("Cc"))
+	for _, layout := range b2fDateLayouts {
+		if t, err := time.Parse(layout, h.Get("Date")); err == nil {
+			m.Date = t
+			break
+		}
+	}
+
+	rest := data[off:]
+	body := rest
+	if v := h.Get("Body"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("b2f: invalid Body: %q", v)
+		}
+		if n > len(rest) {
+			return nil, fmt.Errorf("b2f: body truncated (%d of %d bytes)", len(rest), n)
+		}
+		body, rest = rest[:n], rest[n:]
+	} else if len(h.Values("File")) > 0 {
+		return nil, fmt.Errorf("b2f: File: without Body:")
+	}
+	m.Body = strings.TrimRight(normalizeLF(decodeB2FText(body, h.Get("Content-Type"))), "\n")
+
+	for _, v := range h.Values("File") {
+		size, name, _ := strings.Cut(v, " ")
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("b2f: invalid File: %q", v)
+		}
+		// The separator is CRLF; tolerate a bare LF from files edited by hand.
+		if bytes.HasPrefix(rest, []byte("\r\n")) {
+			rest = rest[2:]
+		} else if bytes.HasPrefix(rest, []byte("\n")) {
+			rest = rest[1:]
+		}
+		if n > len(rest) {
+			return nil, fmt.Errorf("b2f: file %s truncated (%d of %d bytes)", name, len(rest), n)
+		}
+		name = strings.TrimSpace(name)
+		m.Files = append(m.Files, core.Attachment{
+			Name: name, ContentType: mime.TypeByExtension(strings.ToLower(filepath.Ext(name))), Data: rest[:n:n],
+		})
+		rest = rest[n:]
+	}
+	return m, nil
+}
+
+func b2fAddresses(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, a := range strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' }) {
+			a = strings.TrimSpace(a)
+			if len(a) > 5 && strings.EqualFold(a[:5], "SMTP:") {
+				a = a[5:]
+			}
+			if a != "" {
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+// decodeB2FText returns b as UTF-8. Winlink clients send ISO-8859-1, but BuildB2F (and
+// PAT) write Go strings as they are, so valid UTF-8 is kept.
+func decodeB2FText(b []byte, contentType string) string {
+	_, params, _ := mime.ParseMediaType(contentType)
+	if strings.EqualFold(params["charset"], "utf-8") || utf8.Valid(b) {
+		return string(b)
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+var callsignRe = regexp.MustCompile(`^[A-Z0-9]{1,3}[0-9][A-Z0-9]{0,3}[A-Z](-[0-9]{1,2})?$`)
+
+// MailboxCall returns the licensed callsign that sends mail from the address from:
+// the callsign a tactical address is registered to, from itself when it is a callsign
+// (a station may hold several), else mycall. An unregistered tactical address is an
+// error, since the CMS would not accept it from this station.
+func MailboxCall(mycall, from string, tactical map[string]string) (string, error) {
+	mycall = strings.ToUpper(strings.TrimSpace(mycall))
+	from = strings.ToUpper(strings.TrimSpace(from))
+	if from == "" || from == mycall {
+		return mycall, nil
+	}
+	if call, ok := tactical[from]; ok {
+		return strings.ToUpper(call), nil
+	}
+	if callsignRe.MatchString(from) {
+		return from, nil
+	}
+	return "", fmt.Errorf("from %s is neither a callsign nor a registered tactical address (relayops identity add -address %s -callsign CALL)", from, from)
 }
 
 func NewMID(n int) string {
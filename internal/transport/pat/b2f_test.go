@@ -0,0 +1,130 @@
+package pat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+func TestBuildB2FFromAndMbo(t *testing.T) {
+	tactical := map[string]string{"EOC-PLANS": "W4EOC"}
+	for _, c := range []struct {
+		from     string
+		wantFrom string
+		wantMbo  string
+	}{
+		{"", "AE4OK", "AE4OK"},
+		{"eoc-plans", "EOC-PLANS", "W4EOC"},
+		{"K4XYZ", "K4XYZ", "K4XYZ"}, // a second licensed call at the station
+	} {
+		m := core.NewMessage("Status", "All OK")
+		m.To = []core.Address{{Callsign: "W1AW"}}
+		m.From = core.Address{Callsign: c.from}
+		b, err := BuildB2F("ae4ok", "MID123", m, tactical)
+		if err != nil {
+			t.Fatalf("from %q: %v", c.from, err)
+		}
+		h := string(b)
+		if !strings.Contains(h, "\nFrom: "+c.wantFrom+"\n") || !strings.Contains(h, "\nMbo: "+c.wantMbo+"\n") {
+			t.Errorf("from %q: header\n%s", c.from, h)
+		}
+	}
+
+	m := core.NewMessage("Status", "All OK")
+	m.To = []core.Address{{Callsign: "W1AW"}}
+	m.From = core.Address{Callsign: "EOC-LOGISTICS"}
+	if _, err := BuildB2F("AE4OK", "MID124", m, tactical); err == nil || !strings.Contains(err.Error(), "registered tactical address") {
+		t.Fatalf("unregistered tactical From: err = %v", err)
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseB2FHeadersAndLatin1Body(t *testing.T) {
+	m, err := ParseB2F(readFixture(t, "inbound_latin1.b2f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MID != "7RTZ4PQLM2XK" || m.From != "W1AW" || m.Mbo != "W1AW" || m.Subject != "Net check-in" {
+		t.Errorf("headers = %+v", m)
+	}
+	if want := time.Date(2025, 3, 1, 14, 5, 0, 0, time.UTC); !m.Date.Equal(want) {
+		t.Errorf("Date = %v, want %v", m.Date, want)
+	}
+	if !reflect.DeepEqual(m.To, []string{"AE4OK", "EOC-PLANS"}) || !reflect.DeepEqual(m.Cc, []string{"ops@example.org"}) {
+		t.Errorf("To = %v, Cc = %v", m.To, m.Cc)
+	}
+	if want := "Net check-in from Café Lodge.\nAll stations QRV."; m.Body != want {
+		t.Errorf("Body = %q, want %q", m.Body, want)
+	}
+	if len(m.Files) != 0 || m.Header.Get("Type") != "Private" {
+		t.Errorf("files = %d, Type = %q", len(m.Files), m.Header.Get("Type"))
+	}
+}
+
+func TestParseB2FFiles(t *testing.T) {
+	raw := readFixture(t, "with_files.b2f")
+	m, err := ParseB2F(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Body != "ICS-213 attached." || len(m.Files) != 2 {
+		t.Fatalf("body %q, %d files", m.Body, len(m.Files))
+	}
+	form := readFixture(t, "../../../forms/testdata/RMS_Express_Form_ICS213_Initial_Viewer.xml")
+	if f := m.Files[0]; f.Name != "RMS_Express_Form_ICS213_Initial_Viewer.xml" || !bytes.Equal(f.Data, form) || f.ContentType == "" {
+		t.Errorf("form file = %s %q (%d bytes)", f.Name, f.ContentType, len(f.Data))
+	}
+	if f := m.Files[1]; f.Name != "map snippet.png" || f.ContentType != "image/png" || len(f.Data) != 64 || f.Data[63] != 63 {
+		t.Errorf("png file = %s %q %d", f.Name, f.ContentType, len(f.Data))
+	}
+}
+
+func TestParseB2FRejectsBrokenMessages(t *testing.T) {
+	if _, err := ParseB2F(readFixture(t, "truncated.b2f")); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated body: err = %v", err)
+	}
+	for _, raw := range []string{
+		"Mid: X\r\nSubject: no blank line",
+		"Mid: X\r\nnot a header\r\n\r\nbody",
+		"Mid: X\r\nBody: 2\r\nFile: 10 a.txt\r\n\r\nhi\r\nshort\r\n",
+		"Mid: X\r\nFile: 2 a.txt\r\n\r\n\r\nab\r\n",
+	} {
+		if _, err := ParseB2F([]byte(raw)); err == nil {
+			t.Errorf("accepted %q", raw)
+		}
+	}
+}
+
+func TestParseB2FRoundTripsBuildB2F(t *testing.T) {
+	m := core.NewMessage("Supply request", "Need 20 cots.\nDeliver to gym.")
+	m.To = []core.Address{{Callsign: "W1AW"}}
+	m.Attachments = []core.Attachment{{Name: "list.txt", Data: []byte("cots: 20\r\n")}}
+	raw, err := BuildB2F("AE4OK", "RT0000000001", m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseB2F(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MID != "RT0000000001" || got.From != "AE4OK" || got.Body != m.Body || got.Subject != m.Subject || got.Date.IsZero() {
+		t.Errorf("parsed = %+v", got)
+	}
+	if len(got.Files) != 1 || got.Files[0].Name != "list.txt" || string(got.Files[0].Data) != "cots: 20\r\n" {
+		t.Errorf("files = %+v", got.Files)
+	}
+}
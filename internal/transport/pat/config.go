@@ -69,3 +69,41 @@ func exists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// PrivateConfig writes a copy of the pat config at path with secure_login_password
+// set, for `pat --config`, so a password kept (sealed) in the RelayOps store never
+// has to live in pat's own config.json. Other settings are copied unchanged. The
+// copy is readable only by the user; the caller removes it.
+func PrivateConfig(path, password string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return "", fmt.Errorf("pat config %s: %w", path, err)
+	}
+	pw, err := json.Marshal(password)
+	if err != nil {
+		return "", err
+	}
+	cfg["secure_login_password"] = pw
+	if b, err = json.MarshalIndent(cfg, "", "  "); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "relayops-pat-*.json") // created 0600
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
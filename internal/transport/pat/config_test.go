@@ -0,0 +1,79 @@
+package pat
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestConnectUsesStoredPassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pat binary is a shell script")
+	}
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"mycall":"AE4OK","locator":"EM73","secure_login_password":""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PAT_CONFIG", cfgPath)
+	t.Setenv("RELAYOPS_PAT_MAILBOX", filepath.Join(dir, "mailbox"))
+
+	// The fake pat keeps its arguments and the config it was pointed at.
+	fake := filepath.Join(dir, "pat")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\n" +
+		"[ \"$1\" = --config ] && cp \"$2\" " + filepath.Join(dir, "used.json") + "\nexit 0\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New("AE4OK")
+	s.PatBinary = fake
+	var asked string
+	s.SetCredentials(func(_ context.Context, name string) (string, bool, error) {
+		asked = name
+		return "hunter2", true, nil
+	})
+	if err := s.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if asked != "winlink:AE4OK" {
+		t.Fatalf("looked up credential %q, want winlink:AE4OK", asked)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	fields := strings.Fields(string(args))
+	if len(fields) != 4 || fields[0] != "--config" || fields[2] != "connect" {
+		t.Fatalf("pat args = %q, want --config <file> connect telnet", args)
+	}
+	if _, err := os.Stat(fields[1]); !os.IsNotExist(err) {
+		t.Fatalf("private config %s was not removed (%v)", fields[1], err)
+	}
+
+	var used map[string]string
+	b, err := os.ReadFile(filepath.Join(dir, "used.json"))
+	if err != nil {
+		t.Fatalf("pat was not given the private config: %v", err)
+	}
+	if err := json.Unmarshal(b, &used); err != nil {
+		t.Fatal(err)
+	}
+	if used["secure_login_password"] != "hunter2" || used["mycall"] != "AE4OK" || used["locator"] != "EM73" {
+		t.Fatalf("private config = %v", used)
+	}
+	if orig, _ := os.ReadFile(cfgPath); strings.Contains(string(orig), "hunter2") {
+		t.Fatal("password was written to pat's own config.json")
+	}
+
+	// Without a stored credential pat uses its own config.
+	s.SetCredentials(func(context.Context, string) (string, bool, error) { return "", false, nil })
+	if err := s.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); strings.TrimSpace(string(args)) != "connect telnet" {
+		t.Fatalf("pat args without a credential = %q", args)
+	}
+}
@@ -1,19 +1,16 @@
 package pat
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/forms"
 	"github.com/4current/relayops/internal/store"
 	"github.com/google/uuid"
 )
@@ -23,22 +20,24 @@ type ImportReport struct {
 	Scanned int
 	Created int
 	Updated int
+	Skipped int // unchanged since the last import
 	Errors  int
 }
 
 // ImportFromMailbox imports (read-only) PAT mailbox messages into the canonical RelayOps store.
 //
 // It scans: <mbox>/<CALLSIGN>/{in,out,sent,archive}/*.b2f
-// and uses `pat extract <MID>` to decode each message.
+// and decodes each message with ParseB2F, so the pat binary is not needed.
+//
+// Files whose size, modification time and contents are unchanged since the last
+// import into scope are skipped; full rescans every file.
 //
 // callsign is optional; if empty RELAYOPS_CALLSIGN is used.
-func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, callsign string, scope string) (*ImportReport, error) {
+func ImportFromMailbox(ctx context.Context, st *store.Store, mbox, callsign string, scope string, full bool) (*ImportReport, error) {
 	if st == nil {
 		return nil, fmt.Errorf("ImportFromMailbox: store is nil")
 	}
-	if strings.TrimSpace(patBinary) == "" {
-		patBinary = "pat"
-	}
+	ctx = store.WithActor(ctx, "importer:pat")
 	call := strings.ToUpper(strings.TrimSpace(callsign))
 	if call == "" {
 		call = strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN")))
@@ -62,7 +61,20 @@ func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, ca
 		files = append(files, matches...)
 	}
 
+	root := filepath.Join(mbox, call)
+	source := cursorSource(scope, root)
+	cursor := map[string]store.FileStamp{}
+	if !full {
+		var err error
+		if cursor, err = st.ImportCursor(ctx, source); err != nil {
+			return nil, fmt.Errorf("ImportFromMailbox: %w", err)
+		}
+	}
+
 	report := &ImportReport{Scanned: len(files)}
+	touched := map[string]bool{scope: true}
+	defer markImported(ctx, st, touched)
+	seen := map[string]bool{}
 	for _, p := range files {
 		select {
 		case <-ctx.Done():
@@ -76,22 +88,60 @@ func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, ca
 			continue
 		}
 
+		rel, _ := filepath.Rel(root, p)
+		seen[rel] = true
+		prev, ok := cursor[rel]
+		if !ok {
+			prev = store.FileStamp{Path: rel}
+		}
+		stamp, data, changed, err := store.StampFile(p, prev)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		if !changed {
+			if stamp != prev {
+				_ = st.SaveFileStamp(ctx, source, stamp) // touched, same contents
+			}
+			report.Skipped++
+			continue
+		}
+
 		folderKey := strings.ToLower(filepath.Base(filepath.Dir(p)))
 		folder, state, canonicalStatus := mapPatFolder(folderKey)
 
-		messageID, found, err := st.GetMessageIDByExternalRef(ctx, "pat", mid, scope)
+		b2f, err := ParseB2F(data)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		if b2f.MID == "" {
+			b2f.MID = mid
+		}
+
+		// Mail to (or, outbound, from) a registered tactical address belongs to that
+		// identity's scope rather than the mailbox owner's.
+		routeBy := []string{b2f.From}
+		if folderKey == "in" {
+			routeBy = append(append([]string{}, b2f.To...), b2f.Cc...)
+		}
+		msgScope, err := st.RouteScope(ctx, scope, routeBy...)
 		if err != nil {
 			report.Errors++
 			continue
 		}
 
-		hdr, body, extra, err := patExtract(ctx, patBinary, mbox, call, mid)
+		touched[msgScope] = true
+
+		messageID, found, err := st.GetMessageIDByExternalRef(ctx, "pat", mid, msgScope)
 		if err != nil {
 			report.Errors++
 			continue
 		}
-		extra.Folder = folder
-		extra.Scope = scope
+		extra := patExtra{
+			MID: b2f.MID, Folder: folder, Scope: msgScope, FileHash: stamp.SHA256,
+			RawDate: b2f.Header.Get("Date"), RawSubject: b2f.Subject, Mbo: b2f.Mbo, Mailbox: call,
+		}
 		extraJSON, _ := json.Marshal(extra)
 
 		if found {
@@ -99,33 +149,46 @@ func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, ca
 				report.Errors++
 			} else {
 				report.Updated++
+				_ = st.SaveFileStamp(ctx, source, stamp)
 			}
 			continue
 		}
 
 		now := time.Now()
-		created := hdr.Date
+		created := b2f.Date
 		if created.IsZero() {
 			created = now
 		}
 
-		subj := strings.TrimSpace(hdr.Subject)
+		subj := strings.TrimSpace(b2f.Subject)
 		if subj == "" {
 			subj = "(no subject)"
 		}
 
 		msg := &core.Message{
-			ID:        uuid.NewString(),
-			Subject:   subj,
-			Body:      body,
-			From:      core.Address{Callsign: hdr.From},
-			To:        hdr.To,
-			Tags:      []string{},
-			CreatedAt: created,
-			UpdatedAt: now,
-			Status:    canonicalStatus,
-			Meta:      core.DefaultMeta(),
-			LastError: "",
+			ID:          uuid.NewString(),
+			Subject:     subj,
+			Body:        b2f.Body,
+			From:        toAddress(b2f.From),
+			Tags:        []string{},
+			CreatedAt:   created,
+			UpdatedAt:   now,
+			Status:      canonicalStatus,
+			Meta:        core.DefaultMeta(),
+			LastError:   "",
+			Attachments: b2f.Files,
+		}
+		for _, a := range b2f.To {
+			msg.To = append(msg.To, toAddress(a))
+		}
+		// Winlink form XML rides along as a file; keep its parsed fields too.
+		for _, a := range b2f.Files {
+			if forms.IsFormAttachment(a.Name) {
+				if f, err := forms.Parse(a.Data); err == nil {
+					msg.Meta.Form = f
+					break
+				}
+			}
 		}
 
 		if err := st.SaveMessage(ctx, msg); err != nil {
@@ -133,7 +196,7 @@ func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, ca
 			continue
 		}
 
-		if err := st.UpsertExternalRef(ctx, msg.ID, "pat", mid, scope, "{}"); err != nil {
+		if err := st.UpsertExternalRef(ctx, msg.ID, "pat", mid, msgScope, "{}"); err != nil {
 			report.Errors++
 			continue
 		}
@@ -144,87 +207,40 @@ func ImportFromMailbox(ctx context.Context, st *store.Store, patBinary, mbox, ca
 		}
 
 		report.Created++
+		_ = st.SaveFileStamp(ctx, source, stamp)
 	}
 
+	if _, err := st.PruneImportCursor(ctx, source, seen); err != nil {
+		return report, fmt.Errorf("ImportFromMailbox: %w", err)
+	}
 	return report, nil
 }
 
-type patHeader struct {
-	MID     string
-	Date    time.Time
-	From    string
-	To      []core.Address
-	Subject string
+// cursorSource names the import cursor of a mailbox directory imported into scope.
+func cursorSource(scope, dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return "pat:" + scope + ":" + filepath.ToSlash(dir)
 }
 
 type patExtra struct {
 	MID        string `json:"mid"`
 	Folder     string `json:"folder"`
 	Scope      string `json:"scope"`
-	TextHash   string `json:"text_sha256"`
+	FileHash   string `json:"b2f_sha256"`
 	RawDate    string `json:"raw_date"`
 	RawSubject string `json:"raw_subject"`
+	Mbo        string `json:"mbo,omitempty"`
+	Mailbox    string `json:"mailbox,omitempty"` // callsign of the pat mailbox the file is in
 }
 
-func patExtract(ctx context.Context, patBinary, mbox, call, mid string) (*patHeader, string, *patExtra, error) {
-	args := []string{"--mbox", mbox, "--mycall", call, "extract", mid}
-	cmd := exec.CommandContext(ctx, patBinary, args...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	if err := cmd.Run(); err != nil {
-		return nil, "", nil, fmt.Errorf("pat extract failed: %w: %s", err, strings.TrimSpace(out.String()))
+// toAddress files an internet address as Email and anything else as a callsign.
+func toAddress(a string) core.Address {
+	if strings.Contains(a, "@") {
+		return core.Address{Email: a}
 	}
-	text := out.String()
-	hdr, body := parsePatDump(text)
-
-	sum := sha256.Sum256([]byte(text))
-	hexHash := hex.EncodeToString(sum[:])
-	extra := &patExtra{MID: hdr.MID, TextHash: hexHash, RawDate: hdr.Date.Format(time.RFC3339), RawSubject: hdr.Subject}
-	return hdr, body, extra, nil
-}
-
-func parsePatDump(s string) (*patHeader, string) {
-	h := &patHeader{}
-	lines := strings.Split(s, "\n")
-	i := 0
-	for ; i < len(lines); i++ {
-		line := strings.TrimRight(lines[i], "\r")
-		if strings.TrimSpace(line) == "" {
-			i++
-			break
-		}
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key := strings.TrimSpace(parts[0])
-		val := strings.TrimSpace(parts[1])
-		switch strings.ToLower(key) {
-		case "mid":
-			h.MID = val
-		case "date":
-			if t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", val); err == nil {
-				h.Date = t
-			} else if t, err := time.Parse(time.RFC3339, val); err == nil {
-				h.Date = t
-			}
-		case "from":
-			h.From = val
-		case "to":
-			if val != "" {
-				if strings.Contains(val, "@") {
-					h.To = append(h.To, core.Address{Email: val})
-				} else {
-					h.To = append(h.To, core.Address{Callsign: val})
-				}
-			}
-		case "subject":
-			h.Subject = val
-		}
-	}
-	body := strings.TrimRight(strings.Join(lines[i:], "\n"), "\n")
-	return h, body
+	return core.Address{Callsign: a}
 }
 
 func mapPatFolder(folderKey string) (folder string, state string, canonical core.MessageStatus) {
@@ -241,3 +257,10 @@ func mapPatFolder(folderKey string) (folder string, state string, canonical core
 		return folderKey, "", core.StatusDraft
 	}
 }
+
+// markImported stamps the last import time of every scope an import wrote into.
+func markImported(ctx context.Context, st *store.Store, scopes map[string]bool) {
+	for sc := range scopes {
+		_ = st.MarkScopeImported(ctx, sc)
+	}
+}
@@ -0,0 +1,54 @@
+package pat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestImportFromMailboxWithoutPat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("RELAYOPS_PAT_BIN", "/nonexistent/pat")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	mbox := t.TempDir()
+	in := filepath.Join(mbox, "AE4OK", "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"inbound_latin1.b2f", "with_files.b2f", "truncated.b2f"} {
+		if err := os.WriteFile(filepath.Join(in, strings.ToUpper(strings.TrimSuffix(name, ".b2f"))+".b2f"), readFixture(t, name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rep, err := ImportFromMailbox(ctx, st, mbox, "AE4OK", "AE4OK", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Created != 2 || rep.Errors != 1 {
+		t.Fatalf("report = %+v", rep)
+	}
+	id, ok, err := st.GetMessageIDByExternalRef(ctx, "pat", "WITH_FILES", "AE4OK")
+	if err != nil || !ok {
+		t.Fatalf("ref: %v %v", ok, err)
+	}
+	msg, _, err := st.GetMessage(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "ICS-213 request" || len(msg.Attachments) != 2 || msg.Meta.Form == nil || msg.Meta.Form.Name != "ICS213" {
+		t.Fatalf("message = %s, %d attachments, form %+v", msg.Subject, len(msg.Attachments), msg.Meta.Form)
+	}
+}
@@ -8,18 +8,19 @@ import (
 	"strings"
 )
 
-func OutboxDir(mycall string) (string, error) {
+// MailboxDir returns pat's default mailbox root (which holds one directory per callsign).
+// RELAYOPS_PAT_MAILBOX (a profile's transports.pat.mailbox) overrides it.
+func MailboxDir() (string, error) {
+	if p := os.Getenv("RELAYOPS_PAT_MAILBOX"); p != "" {
+		return p, nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	call := strings.ToUpper(strings.TrimSpace(mycall))
-	if call == "" {
-		return "", fmt.Errorf("empty mycall")
-	}
 
 	if runtime.GOOS == "darwin" {
-		return filepath.Join(home, "Library", "Application Support", "pat", "mailbox", call, "out"), nil
+		return filepath.Join(home, "Library", "Application Support", "pat", "mailbox"), nil
 	}
 
 	// Linux default
@@ -27,7 +28,27 @@ func OutboxDir(mycall string) (string, error) {
 	if dataHome == "" {
 		dataHome = filepath.Join(home, ".local", "share")
 	}
-	return filepath.Join(dataHome, "pat", "mailbox", call, "out"), nil
+	return filepath.Join(dataHome, "pat", "mailbox"), nil
+}
+
+// Binary returns the pat executable to run: RELAYOPS_PAT_BIN, or "pat" from PATH.
+func Binary() string {
+	if p := strings.TrimSpace(os.Getenv("RELAYOPS_PAT_BIN")); p != "" {
+		return p
+	}
+	return "pat"
+}
+
+func OutboxDir(mycall string) (string, error) {
+	call := strings.ToUpper(strings.TrimSpace(mycall))
+	if call == "" {
+		return "", fmt.Errorf("empty mycall")
+	}
+	root, err := MailboxDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, call, "out"), nil
 }
 
 func WriteB2F(outboxDir, mid string, b []byte) (string, error) {
@@ -4,56 +4,175 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
 )
 
 type Sender struct {
-	PatBinary       string // default "pat"
-	DefaultFromCall string // e.g. "AE4OK"
-	Service         string // e.g. "telnet"
+	PatBinary       string            // default Binary()
+	DefaultFromCall string            // e.g. "AE4OK"
+	Service         string            // e.g. "telnet" or a connect URL like "ardop:///W1AW-10?freq=7101.5"
+	Tactical        map[string]string // tactical address -> licensed callsign, see SetTactical
+
+	// Credentials looks up stored secrets such as "winlink:AE4OK", see SetCredentials.
+	Credentials func(ctx context.Context, name string) (string, bool, error)
+
+	last *store.Session
 }
 
 func New(defaultFromCall string) *Sender {
 	return &Sender{
-		PatBinary:       "pat",
+		PatBinary:       Binary(),
 		DefaultFromCall: defaultFromCall,
 		Service:         "telnet",
 	}
 }
 
+// Backend names the sender in attempts and external refs.
+func (s *Sender) Backend() string { return "pat" }
+
+// SetTactical gives the sender the identity registry, so mail from a tactical address
+// goes out through its licensed callsign's mailbox.
+func (s *Sender) SetTactical(tactical map[string]string) { s.Tactical = tactical }
+
+// SetCredentials gives the sender the store's credentials. When one is stored for
+// the mailbox callsign ("winlink:" + call), pat logs in with it instead of the
+// secure_login_password in its config.json.
+func (s *Sender) SetCredentials(lookup func(ctx context.Context, name string) (string, bool, error)) {
+	s.Credentials = lookup
+}
+
 func (s *Sender) SendOne(ctx context.Context, m *core.Message) (string, error) {
+	s.last = nil
+
 	cfg, _, err := LoadConfig()
 	if err != nil {
 		return "", err
 	}
 
-	outDir, err := OutboxDir(cfg.MyCall)
+	// Mail from another of the station's callsigns, or from a tactical address
+	// licensed to one, goes through that callsign's mailbox (pat --mycall).
+	call, err := MailboxCall(cfg.MyCall, m.From.Callsign, s.Tactical)
+	if err != nil {
+		return "", err
+	}
+	outDir, err := OutboxDir(call)
 	if err != nil {
 		return "", err
 	}
 
 	mid := NewMID(12)
-	b2f, err := BuildB2F(cfg.MyCall, mid, m)
+	b2f, err := BuildB2F(cfg.MyCall, mid, m, s.Tactical)
 	if err != nil {
 		return "", err
 	}
 	if _, err := WriteB2F(outDir, mid, b2f); err != nil {
 		return "", err
 	}
+
 	// Run a connect to flush outbox.
 	// Later we’ll optimize: send batches per session, not per message.
-	args := []string{"connect", s.Service}
+	if !strings.EqualFold(call, cfg.MyCall) {
+		err = s.connect(ctx, outDir, call, "--mycall", call)
+	} else {
+		err = s.connect(ctx, outDir, call)
+	}
+	if err != nil {
+		return "", err
+	}
+	return mid, nil
+}
+
+// Connect runs a single `pat connect` without adding anything to the outbox, which
+// flushes whatever is already queued in pat and picks up inbound traffic.
+func (s *Sender) Connect(ctx context.Context) error {
+	s.last = nil
+
+	cfg, _, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	outDir, err := OutboxDir(cfg.MyCall)
+	if err != nil {
+		return err
+	}
+	return s.connect(ctx, outDir, cfg.MyCall)
+}
+
+// connect runs `pat connect` as call, whose mailbox is outDir.
+func (s *Sender) connect(ctx context.Context, outDir, call string, globalArgs ...string) error {
+	if s.Credentials != nil {
+		password, ok, err := s.Credentials(ctx, "winlink:"+strings.ToUpper(call))
+		if err != nil {
+			return err
+		}
+		if ok {
+			_, cfgPath, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			private, err := PrivateConfig(cfgPath, password)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(private)
+			globalArgs = append([]string{"--config", private}, globalArgs...)
+		}
+	}
+
+	// pat moves delivered messages out of out/ and drops received ones in in/,
+	// so the folder contents before and after the connect tell us what moved.
+	inDir := filepath.Join(filepath.Dir(outDir), "in")
+	outBefore, inBefore := snapshotFolder(outDir), snapshotFolder(inDir)
+
+	args := append(globalArgs, "connect", s.Service)
 	cmd := exec.CommandContext(ctx, s.PatBinary, args...)
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("pat connect failed: %w: %s", err, strings.TrimSpace(out.String()))
+	started := time.Now()
+	runErr := cmd.Run()
+
+	sentMIDs, bytesOut := diffSnapshots(outBefore, snapshotFolder(outDir))
+	recvMIDs, bytesIn := diffSnapshots(snapshotFolder(inDir), inBefore)
+	target := parseConnectTarget(s.Service)
+	sess := &store.Session{
+		Transport:  "pat:" + target.Transport,
+		Gateway:    target.Gateway,
+		Frequency:  target.Frequency,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Outcome:    "ok",
+		BytesOut:   bytesOut,
+		BytesIn:    bytesIn,
+		Transcript: out.String(),
+		Messages:   sessionLinks(sentMIDs, recvMIDs),
 	}
-	return mid, nil
+	if gw := gatewayFromTranscript(sess.Transcript); gw != "" {
+		sess.Gateway = gw
+	}
+	if runErr != nil {
+		sess.Outcome, sess.Error = "failed", runErr.Error()
+	}
+	s.last = sess
+
+	if runErr != nil {
+		return fmt.Errorf("pat connect failed: %w: %s", runErr, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// TakeSession returns the session opened by the last SendOne or Connect call, once.
+func (s *Sender) TakeSession() *store.Session {
+	sess := s.last
+	s.last = nil
+	return sess
 }
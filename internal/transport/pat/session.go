@@ -0,0 +1,120 @@
+package pat
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+// connectTarget describes what a `pat connect` argument points at.
+type connectTarget struct {
+	Transport string
+	Gateway   string
+	Frequency string
+}
+
+// parseConnectTarget understands pat connect aliases ("telnet") and connect URLs such as
+// "ardop:///W1AW-10?freq=7101.5" or "telnet://AE4OK:pw@cms.winlink.org:8772/wl2k".
+func parseConnectTarget(arg string) connectTarget {
+	arg = strings.TrimSpace(arg)
+	if !strings.Contains(arg, "://") {
+		return connectTarget{Transport: arg}
+	}
+	u, err := url.Parse(arg)
+	if err != nil {
+		return connectTarget{Transport: arg}
+	}
+	t := connectTarget{Transport: u.Scheme, Frequency: u.Query().Get("freq")}
+	switch {
+	case u.Host != "":
+		t.Gateway = u.Host
+	default:
+		t.Gateway = strings.Trim(u.Path, "/")
+	}
+	return t
+}
+
+var connectedRe = regexp.MustCompile(`(?i)connected to (\S+)`)
+
+// gatewayFromTranscript picks the remote end out of pat's console output, e.g.
+// "Connected to W1AW-10 (ARDOP)" or "Connected to cms.winlink.org:8772".
+func gatewayFromTranscript(out string) string {
+	m := connectedRe.FindStringSubmatch(out)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimRight(m[1], ".,")
+}
+
+// mailboxSnapshot maps MID to file size for the .b2f files in a mailbox folder.
+type mailboxSnapshot map[string]int64
+
+func snapshotFolder(dir string) mailboxSnapshot {
+	snap := mailboxSnapshot{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return snap
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".b2f") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snap[strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))] = info.Size()
+	}
+	return snap
+}
+
+// diffSnapshots returns the MIDs present in a but not in b, sorted, and their total size.
+func diffSnapshots(a, b mailboxSnapshot) ([]string, int64) {
+	var mids []string
+	var total int64
+	for mid, size := range a {
+		if _, ok := b[mid]; ok {
+			continue
+		}
+		mids = append(mids, mid)
+		total += size
+	}
+	sort.Strings(mids)
+	return mids, total
+}
+
+// sessionLinks turns the outbound/inbound MID lists into session message links.
+func sessionLinks(out, in []string) []store.SessionMessage {
+	var links []store.SessionMessage
+	for _, mid := range out {
+		links = append(links, store.SessionMessage{MID: mid, Direction: store.DirectionOut})
+	}
+	for _, mid := range in {
+		links = append(links, store.SessionMessage{MID: mid, Direction: store.DirectionIn})
+	}
+	return links
+}
+
+// ConnectAlias maps a RelayOps mode to the pat transport name used as a connect alias.
+// Only "telnet" is predefined by pat; the others need a matching connect_aliases entry
+// in pat's config (or an explicit connect URL).
+func ConnectAlias(mode core.Mode) string {
+	switch mode {
+	case core.ModePacket:
+		return "ax25"
+	case core.ModeVARAHF:
+		return "varahf"
+	case core.ModeVARAFM:
+		return "varafm"
+	case core.ModeARDOP:
+		return "ardop"
+	default:
+		return "telnet"
+	}
+}
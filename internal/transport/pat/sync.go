@@ -0,0 +1,257 @@
+package pat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+// SyncOptions tunes SyncMailbox.
+type SyncOptions struct {
+	Push bool   // write RelayOps drafts carrying Tag into pat's outbox
+	Tag  string // the drafts to push; required with Push
+	Full bool   // import every file, not just those changed since the last sync
+}
+
+// SyncReport summarizes SyncMailbox.
+type SyncReport struct {
+	Import  ImportReport
+	Sent    int // moved from out/ to sent/ by pat, now sent in RelayOps
+	Flagged int // deleted from pat's outbox before they were sent
+	Pushed  int // drafts written to pat's outbox
+	Errors  int
+}
+
+// SyncMailbox reconciles RelayOps with the pat mailbox <mbox>/<callsign> in both
+// directions:
+//
+//   - with opts.Push, drafts tagged opts.Tag that are from the mailbox's callsign (or
+//     a tactical address licensed to it), are not received mail and have no PAT MID
+//     yet are written to out/ and marked sending. Drafts belong to no scope until they
+//     go out, so the tag is what picks them; without one nothing is pushed. The pat
+//     external ref recorded for each is what keeps a draft from being pushed twice.
+//   - the mailbox is imported into scope as ImportFromMailbox does.
+//   - messages with a PAT MID that are still on their way out (draft, queued, sending,
+//     or failed) are marked sent once their file is in sent/, and messages last seen
+//     in this mailbox's outbox whose file is now in no folder (deleted in pat) are
+//     marked failed so an operator can re-queue or discard them.
+//
+// The last step works from the stored status and backend state rather than from
+// what changed since the last import, so it finds every transition no matter how many
+// plain or watch imports ran in between.
+func SyncMailbox(ctx context.Context, st *store.Store, mbox, callsign, scope string, opts SyncOptions) (*SyncReport, error) {
+	if st == nil {
+		return nil, fmt.Errorf("SyncMailbox: store is nil")
+	}
+	call := strings.ToUpper(strings.TrimSpace(callsign))
+	if call == "" {
+		call = strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN")))
+	}
+	if call == "" {
+		return nil, fmt.Errorf("SyncMailbox: callsign is required (set RELAYOPS_CALLSIGN or pass -callsign)")
+	}
+	if strings.TrimSpace(mbox) == "" {
+		return nil, fmt.Errorf("SyncMailbox: mbox path is required")
+	}
+	if strings.TrimSpace(scope) == "" {
+		return nil, fmt.Errorf("SyncMailbox: scope is required")
+	}
+	if opts.Push && strings.TrimSpace(opts.Tag) == "" {
+		return nil, fmt.Errorf("SyncMailbox: push needs a tag to pick the drafts")
+	}
+
+	root := filepath.Join(mbox, call)
+	report := &SyncReport{}
+	if opts.Push {
+		if err := pushDrafts(store.WithActor(ctx, "sync:pat"), st, root, call, scope, opts.Tag, report); err != nil {
+			return report, fmt.Errorf("SyncMailbox: %w", err)
+		}
+	}
+
+	imp, err := ImportFromMailbox(ctx, st, mbox, call, scope, opts.Full)
+	if imp != nil {
+		report.Import = *imp
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if err := reconcile(store.WithActor(ctx, "sync:pat"), st, call, mailboxFolders(root), report); err != nil {
+		return report, fmt.Errorf("SyncMailbox: %w", err)
+	}
+	return report, nil
+}
+
+// reconcile settles the outgoing messages with a PAT MID against now, the folder of
+// every file in the mailbox of call.
+func reconcile(ctx context.Context, st *store.Store, call string, now map[string]string, report *SyncReport) error {
+	refs, err := st.ListExternalRefsByStatus(ctx, "pat", core.StatusDraft, core.StatusQueued, core.StatusSending, core.StatusFailed)
+	if err != nil {
+		return err
+	}
+	done := map[string]bool{}
+	for _, r := range refs {
+		if done[r.MessageID] {
+			continue // refs in several scopes
+		}
+		done[r.MessageID] = true
+
+		folder, present := now[r.ExternalID]
+		switch {
+		case present && folder == "sent":
+			n, err := settle(ctx, st, r.MessageID, core.StatusSent, "")
+			report.Sent += n
+			if err != nil {
+				report.Errors++
+			}
+		case !present:
+			inOutbox, err := lastInOutbox(ctx, st, r.MessageID, call)
+			if err != nil {
+				report.Errors++
+				continue
+			}
+			if !inOutbox {
+				continue // never in this mailbox's out/, or already flagged
+			}
+			n, err := settle(ctx, st, r.MessageID, core.StatusFailed, "deleted from the PAT outbox before it was sent")
+			report.Flagged += n
+			if err != nil {
+				report.Errors++
+			}
+			extra, _ := json.Marshal(patExtra{MID: r.ExternalID, Folder: "Deleted", Scope: r.Scope, Mailbox: call})
+			_ = st.UpsertBackendState(ctx, r.MessageID, "pat", "Deleted", "Deleted", string(extra))
+		}
+	}
+	return nil
+}
+
+// lastInOutbox reports whether the pat backend state of a message puts it in the
+// outbox of the mailbox of call.
+func lastInOutbox(ctx context.Context, st *store.Store, messageID, call string) (bool, error) {
+	states, err := st.ListBackendState(ctx, messageID)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range states {
+		if b.Backend != "pat" || b.Folder != "Outbox" {
+			continue
+		}
+		var extra patExtra
+		_ = json.Unmarshal([]byte(b.ExtraJSON), &extra)
+		return strings.EqualFold(extra.Mailbox, call), nil
+	}
+	return false, nil
+}
+
+// settle moves a message that is still on its way out (draft, queued, sending or
+// failed) to status, and returns 1 if it did.
+func settle(ctx context.Context, st *store.Store, messageID string, status core.MessageStatus, lastErr string) (int, error) {
+	m, ok, err := st.GetMessage(ctx, messageID)
+	if err != nil || !ok {
+		return 0, err
+	}
+	switch m.Status {
+	case core.StatusDraft, core.StatusQueued, core.StatusSending, core.StatusFailed:
+	default:
+		return 0, nil
+	}
+	if m.Status == status {
+		return 0, nil
+	}
+	if err := st.SetStatusByID(ctx, m.ID, status, lastErr); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// pushDrafts writes the drafts tagged tag that belong in the mailbox of call to its
+// out/ folder.
+func pushDrafts(ctx context.Context, st *store.Store, root, call, scope, tag string, report *SyncReport) error {
+	// Received mail is stored as draft too; it is not ours to send.
+	msgs, err := st.QueryMessages(ctx, store.MessageFilter{Tag: tag, NotFolder: "inbox"})
+	if err != nil {
+		return err
+	}
+	tactical, err := st.IdentityCallsigns(ctx)
+	if err != nil {
+		return err
+	}
+	outDir := filepath.Join(root, "out")
+	for _, m := range msgs {
+		if m.Status != core.StatusDraft {
+			continue
+		}
+		refs, err := st.ListExternalRefs(ctx, m.ID)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		if hasBackend(refs, "pat") {
+			continue // pushed (or sent) already
+		}
+		if mc, err := MailboxCall(call, m.From.Callsign, tactical); err != nil || mc != call {
+			continue // another mailbox's mail
+		}
+		// Route the way the import will route the file, by its From line.
+		from := m.From.Callsign
+		if strings.TrimSpace(from) == "" {
+			from = call
+		}
+		msgScope, err := st.RouteScope(ctx, scope, from)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+
+		mid := NewMID(12)
+		b2f, err := BuildB2F(call, mid, m, tactical)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		path, err := WriteB2F(outDir, mid, b2f)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		// Without its ref the file would be imported as a new message and the draft
+		// pushed again, so take the file back.
+		if err := st.UpsertExternalRef(ctx, m.ID, "pat", mid, msgScope, `{"pushed":true}`); err != nil {
+			_ = os.Remove(path)
+			report.Errors++
+			continue
+		}
+		extra, _ := json.Marshal(patExtra{MID: mid, Folder: "Outbox", Scope: msgScope, RawSubject: m.Subject, Mailbox: call})
+		_ = st.UpsertBackendState(ctx, m.ID, "pat", "Outbox", "Queued", string(extra))
+		_ = st.MarkSending(ctx, m.ID)
+		report.Pushed++
+	}
+	return nil
+}
+
+// mailboxFolders maps the MID of every .b2f file under root to its folder.
+func mailboxFolders(root string) map[string]string {
+	out := map[string]string{}
+	for _, f := range mailFolders {
+		matches, _ := filepath.Glob(filepath.Join(root, f, "*.b2f"))
+		for _, p := range matches {
+			out[strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))] = f
+		}
+	}
+	return out
+}
+
+func hasBackend(refs []store.ExternalRef, backend string) bool {
+	for _, r := range refs {
+		if r.Backend == backend {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package pat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestSyncMailbox(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	var drafts []*core.Message
+	for _, subj := range []string{"Net check-in", "Supply request"} {
+		m := core.NewMessage(subj, "body of "+subj)
+		m.From = core.Address{Callsign: "AE4OK"}
+		m.To = []core.Address{{Callsign: "W1AW"}}
+		m.Tags = []string{"net"}
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		drafts = append(drafts, m)
+	}
+	// A draft without the tag is not this sync's to push.
+	other := core.NewMessage("Unrelated", "not yet")
+	other.From = core.Address{Callsign: "AE4OK"}
+	other.To = []core.Address{{Callsign: "W1AW"}}
+	if err := st.SaveMessage(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	// Received mail is stored as a draft too and must stay put.
+	received := core.NewMessage("Hello", "inbound")
+	received.From = core.Address{Callsign: "W1AW"}
+	received.To = []core.Address{{Callsign: "AE4OK"}}
+	received.Tags = []string{"net"}
+	if err := st.SaveMessage(ctx, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpsertBackendState(ctx, received.ID, "pat", "InBox", "Received", "{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	mbox := t.TempDir()
+	sync := func() SyncReport {
+		t.Helper()
+		rep, err := SyncMailbox(ctx, st, mbox, "AE4OK", "AE4OK", SyncOptions{Push: true, Tag: "net"})
+		if err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		return *rep
+	}
+	status := func(m *core.Message) (core.MessageStatus, string) {
+		t.Helper()
+		got, _, err := st.GetMessage(ctx, m.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Status, got.LastError
+	}
+
+	if rep := sync(); rep.Pushed != 2 || rep.Import.Created != 0 || rep.Import.Updated != 2 || rep.Errors != 0 {
+		t.Fatalf("first sync = %+v", rep)
+	}
+	mids := make([]string, len(drafts))
+	for i, m := range drafts {
+		refs, err := st.ListExternalRefs(ctx, m.ID)
+		if err != nil || len(refs) != 1 || refs[0].Backend != "pat" || refs[0].Scope != "AE4OK" {
+			t.Fatalf("refs of %s = %+v %v", m.Subject, refs, err)
+		}
+		mids[i] = refs[0].ExternalID
+		if s, _ := status(m); s != core.StatusSending {
+			t.Fatalf("%s status = %s", m.Subject, s)
+		}
+	}
+	for _, m := range []*core.Message{received, other} {
+		if s, _ := status(m); s != core.StatusDraft {
+			t.Fatalf("%s status = %s", m.Subject, s)
+		}
+	}
+	if _, err := SyncMailbox(ctx, st, mbox, "AE4OK", "AE4OK", SyncOptions{Push: true}); err == nil {
+		t.Fatal("push without a tag should fail")
+	}
+
+	// The external ref keeps drafts from being pushed twice.
+	if rep := sync(); rep.Pushed != 0 || rep.Import.Skipped != 2 {
+		t.Fatalf("second sync = %+v", rep)
+	}
+
+	// pat delivers one message and the operator deletes the other.
+	box := filepath.Join(mbox, "AE4OK")
+	if err := os.MkdirAll(filepath.Join(box, "sent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(box, "out", mids[0]+".b2f"), filepath.Join(box, "sent", mids[0]+".b2f")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(box, "out", mids[1]+".b2f")); err != nil {
+		t.Fatal(err)
+	}
+	// A plain import (or a watch) sees the move and the deletion before sync does;
+	// sync still settles both from what is stored.
+	if rep, err := ImportFromMailbox(ctx, st, mbox, "AE4OK", "AE4OK", false); err != nil || rep.Updated != 1 {
+		t.Fatalf("import between syncs = %+v, %v", rep, err)
+	}
+	if rep := sync(); rep.Sent != 1 || rep.Flagged != 1 || rep.Pushed != 0 || rep.Errors != 0 {
+		t.Fatalf("third sync = %+v", rep)
+	}
+	if s, _ := status(drafts[0]); s != core.StatusSent {
+		t.Fatalf("delivered status = %s", s)
+	}
+	if s, why := status(drafts[1]); s != core.StatusFailed || why == "" {
+		t.Fatalf("deleted status = %s %q", s, why)
+	}
+	states, _ := st.ListBackendState(ctx, drafts[1].ID)
+	if len(states) != 1 || states[0].Folder != "Deleted" {
+		t.Fatalf("deleted backend state = %+v", states)
+	}
+
+	if rep := sync(); rep.Sent != 0 || rep.Flagged != 0 || rep.Pushed != 0 {
+		t.Fatalf("fourth sync = %+v", rep)
+	}
+}
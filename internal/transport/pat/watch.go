@@ -0,0 +1,125 @@
+package pat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+// mailFolders are the folders of a pat mailbox that hold messages.
+var mailFolders = []string{"in", "out", "sent", "archive"}
+
+// DefaultDebounce is how long WatchMailbox waits after the last change before it
+// imports.
+const DefaultDebounce = 500 * time.Millisecond
+
+// WatchOptions tunes WatchMailbox.
+type WatchOptions struct {
+	// Debounce is the quiet time after the last change before an import runs, so a
+	// burst of files (a pat connect delivering a dozen messages) is one import. Under
+	// a steady stream of changes an import still runs 4*Debounce after the first.
+	Debounce time.Duration
+	// OnImport is called after every import with its report or error.
+	OnImport func(*ImportReport, error)
+}
+
+// WatchMailbox imports the pat mailbox <mbox>/<callsign> into scope once, then again
+// whenever a .b2f file is written into or renamed into one of its folders, until ctx
+// is cancelled. On Linux changes come from inotify; elsewhere the folders are polled.
+//
+// Only finished files count: a file is reported when it is closed after writing or
+// renamed into place, and the temporary file of a write-then-rename (as WriteB2F does)
+// is ignored. Each import is ImportFromMailbox, so only new or changed files are read.
+// An import that fails is reported to OnImport and the watch goes on; WatchMailbox
+// itself fails only when the mailbox cannot be watched.
+func WatchMailbox(ctx context.Context, st *store.Store, mbox, callsign, scope string, opts WatchOptions) error {
+	if st == nil {
+		return fmt.Errorf("WatchMailbox: store is nil")
+	}
+	call := strings.ToUpper(strings.TrimSpace(callsign))
+	if call == "" {
+		call = strings.ToUpper(strings.TrimSpace(os.Getenv("RELAYOPS_CALLSIGN")))
+	}
+	if call == "" {
+		return fmt.Errorf("WatchMailbox: callsign is required (set RELAYOPS_CALLSIGN or pass -callsign)")
+	}
+	root := filepath.Join(mbox, call)
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return fmt.Errorf("WatchMailbox: %s is not a pat mailbox directory", root)
+	}
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	kick := make(chan struct{}, 1)
+	changed := func(name string) {
+		if name != "" && !strings.EqualFold(filepath.Ext(name), ".b2f") {
+			return
+		}
+		select {
+		case kick <- struct{}{}:
+		default: // an import is already due
+		}
+	}
+	wctx, stop := context.WithCancel(ctx)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- watchFolders(wctx, root, changed) }()
+
+	importNow := func() {
+		rep, err := ImportFromMailbox(ctx, st, mbox, call, scope, false)
+		if opts.OnImport != nil && ctx.Err() == nil {
+			opts.OnImport(rep, err)
+		}
+	}
+	// Catch up on mail that arrived while nobody was watching.
+	importNow()
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	var pending time.Time // first change not imported yet
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			<-errc
+			return nil
+		case err := <-errc:
+			if err != nil {
+				return fmt.Errorf("WatchMailbox: %w", err)
+			}
+			return nil
+		case <-kick:
+			now := time.Now()
+			if pending.IsZero() {
+				pending = now
+			}
+			timer.Reset(debounceDelay(pending, now, debounce))
+		case <-timer.C:
+			pending = time.Time{}
+			importNow()
+		}
+	}
+}
+
+// debounceDelay is how long to wait, at now, before importing changes that have been
+// pending since first: debounce, but no later than 4*debounce after first.
+func debounceDelay(first, now time.Time, debounce time.Duration) time.Duration {
+	return max(min(debounce, first.Add(4*debounce).Sub(now)), 0)
+}
+
+func isMailFolder(name string) bool {
+	for _, f := range mailFolders {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
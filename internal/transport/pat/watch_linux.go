@@ -0,0 +1,88 @@
+//go:build linux
+
+package pat
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchFolders calls changed with the name of every file closed after writing or
+// renamed into one of root's mail folders, and with "" when everything should be
+// rescanned (a folder appeared or inotify's queue overflowed), until ctx is cancelled.
+func watchFolders(ctx context.Context, root string, changed func(name string)) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// A non-blocking fd goes through the runtime poller, so closing it ends a Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	defer context.AfterFunc(ctx, func() { _ = f.Close() })()
+
+	const fileMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO
+	dirs := map[int32]string{}
+	add := func(dir string, mask uint32) error {
+		wd, err := unix.InotifyAddWatch(fd, dir, mask)
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", dir, err)
+		}
+		dirs[int32(wd)] = dir
+		return nil
+	}
+	// pat creates sent/ and archive/ on first use; watching root catches that.
+	if err := add(root, unix.IN_CREATE|unix.IN_MOVED_TO|unix.IN_ONLYDIR); err != nil {
+		return err
+	}
+	for _, name := range mailFolders {
+		if fi, err := os.Stat(filepath.Join(root, name)); err == nil && fi.IsDir() {
+			if err := add(filepath.Join(root, name), fileMask); err != nil {
+				return err
+			}
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("inotify: %w", err)
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + unix.SizeofInotifyEvent
+			off = start + nameLen
+			if off > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[start:off]), "\x00")
+
+			switch dir := dirs[wd]; {
+			case mask&unix.IN_Q_OVERFLOW != 0:
+				changed("")
+			case mask&unix.IN_IGNORED != 0:
+				delete(dirs, wd)
+			case dir == root:
+				if mask&unix.IN_ISDIR != 0 && isMailFolder(name) {
+					if err := add(filepath.Join(root, name), fileMask); err != nil {
+						return err
+					}
+					changed("")
+				}
+			case dir != "":
+				changed(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !linux
+
+package pat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often watchFolders looks at the mailbox where there is no
+// inotify.
+const pollInterval = time.Second
+
+// watchFolders calls changed with the name of every .b2f file that appeared or
+// changed in one of root's mail folders, until ctx is cancelled. Without inotify it
+// compares the folders' listings every pollInterval; a file still being written is
+// reported again once it stops changing.
+func watchFolders(ctx context.Context, root string, changed func(name string)) error {
+	type stamp struct {
+		size int64
+		mod  time.Time
+	}
+	list := func() map[string]stamp {
+		out := map[string]stamp{}
+		for _, f := range mailFolders {
+			entries, _ := os.ReadDir(filepath.Join(root, f))
+			for _, e := range entries {
+				if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".b2f") {
+					continue
+				}
+				if info, err := e.Info(); err == nil {
+					out[filepath.Join(f, e.Name())] = stamp{info.Size(), info.ModTime()}
+				}
+			}
+		}
+		return out
+	}
+
+	prev := list()
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		cur := list()
+		for name, s := range cur {
+			if old, ok := prev[name]; !ok || old != s {
+				changed(filepath.Base(name))
+			}
+		}
+		prev = cur
+	}
+}
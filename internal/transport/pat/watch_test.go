@@ -0,0 +1,136 @@
+package pat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/store"
+)
+
+func TestWatchMailbox(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	mbox := t.TempDir()
+	in := filepath.Join(mbox, "AE4OK", "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan ImportReport, 16)
+	wctx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchMailbox(wctx, st, mbox, "AE4OK", "AE4OK", WatchOptions{
+			Debounce: 50 * time.Millisecond,
+			OnImport: func(rep *ImportReport, err error) {
+				if err != nil {
+					t.Errorf("import: %v", err)
+					return
+				}
+				reports <- *rep
+			},
+		})
+	}()
+	next := func(what string) ImportReport {
+		t.Helper()
+		select {
+		case rep := <-reports:
+			return rep
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no import", what)
+			return ImportReport{}
+		}
+	}
+	if rep := next("initial"); rep.Scanned != 0 {
+		t.Fatalf("initial import = %+v", rep)
+	}
+	time.Sleep(100 * time.Millisecond) // let the watches settle
+
+	// WriteB2F's write-then-rename is one new message.
+	if _, err := WriteB2F(in, "INBOUND1", readFixture(t, "inbound_latin1.b2f")); err != nil {
+		t.Fatal(err)
+	}
+	if rep := next("rename"); rep.Created != 1 || rep.Errors != 0 {
+		t.Fatalf("after rename = %+v", rep)
+	}
+
+	// A file written in pieces is imported once it is closed, not half-way.
+	if runtime.GOOS == "linux" {
+		data := readFixture(t, "with_files.b2f")
+		f, err := os.Create(filepath.Join(in, "SLOWWRITE1.b2f"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data[:len(data)/2]); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case rep := <-reports:
+			t.Fatalf("imported a partial write: %+v", rep)
+		case <-time.After(300 * time.Millisecond):
+		}
+		if _, err := f.Write(data[len(data)/2:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if rep := next("slow write"); rep.Created != 1 || rep.Errors != 0 {
+			t.Fatalf("after slow write = %+v", rep)
+		}
+	}
+
+	// A folder pat creates later is watched too.
+	sent := filepath.Join(mbox, "AE4OK", "sent")
+	if err := os.Mkdir(sent, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	next("new folder")
+	time.Sleep(100 * time.Millisecond)
+	if _, err := WriteB2F(sent, "SENT00000001", readFixture(t, "inbound_latin1.b2f")); err != nil {
+		t.Fatal(err)
+	}
+	if rep := next("sent"); rep.Created != 1 {
+		t.Fatalf("after sent = %+v", rep)
+	}
+
+	stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WatchMailbox: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchMailbox did not stop")
+	}
+}
+
+func TestDebounceDelayIsCapped(t *testing.T) {
+	first := time.Date(2026, 3, 4, 19, 0, 0, 0, time.UTC)
+	d := 500 * time.Millisecond
+	for _, c := range []struct {
+		since time.Duration
+		want  time.Duration
+	}{
+		{0, d},                       // first change: the full quiet time
+		{time.Second, d},             // changes keep coming, still well inside the cap
+		{1500 * time.Millisecond, d}, // the cap (2s) is exactly one quiet time away
+		{1800 * time.Millisecond, 200 * time.Millisecond},
+		{3 * time.Second, 0}, // overdue: import now
+	} {
+		if got := debounceDelay(first, first.Add(c.since), d); got != c.want {
+			t.Errorf("%v after the first change: delay %v, want %v", c.since, got, c.want)
+		}
+	}
+}
@@ -3,17 +3,49 @@ package sim
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
 )
 
-type Sender struct{}
+type Sender struct {
+	last *store.Session
+}
 
 func New() *Sender { return &Sender{} }
 
+// Backend names the sender in attempts and external refs.
+func (s *Sender) Backend() string { return "sim" }
+
 func (s *Sender) SendOne(ctx context.Context, m *core.Message) (string, error) {
 	_ = ctx
 
+	started := time.Now()
+	mid, err := s.send(m)
+
+	// Every simulated send is its own session, like a native one-shot connect.
+	sess := &store.Session{
+		Transport:  "sim",
+		Gateway:    "SIM",
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Outcome:    "ok",
+		BytesOut:   int64(len(m.Subject) + len(m.Body)),
+		Transcript: fmt.Sprintf("*** connected to SIM\n>FC EM %s\n", mid),
+	}
+	if err != nil {
+		sess.Outcome, sess.Error = "failed", err.Error()
+		sess.BytesOut = 0
+		sess.Transcript = fmt.Sprintf("*** connected to SIM\n*** %v\n", err)
+	} else {
+		sess.Messages = []store.SessionMessage{{MID: mid, Direction: store.DirectionOut}}
+	}
+	s.last = sess
+	return mid, err
+}
+
+func (s *Sender) send(m *core.Message) (string, error) {
 	// Accept by default.
 	if len(m.Meta.Transport.Allowed) == 0 || contains(m.Meta.Transport.Allowed, core.ModeAny) {
 		return "SIM-OK", nil
@@ -29,6 +61,28 @@ func (s *Sender) SendOne(ctx context.Context, m *core.Message) (string, error) {
 	return "SIM-OK", nil
 }
 
+// Connect simulates a poll with nothing to send or receive.
+func (s *Sender) Connect(ctx context.Context) error {
+	_ = ctx
+	now := time.Now()
+	s.last = &store.Session{
+		Transport:  "sim",
+		Gateway:    "SIM",
+		StartedAt:  now,
+		FinishedAt: now,
+		Outcome:    "ok",
+		Transcript: "*** connected to SIM\n>FF\n",
+	}
+	return nil
+}
+
+// TakeSession returns the session opened by the last SendOne or Connect call, once.
+func (s *Sender) TakeSession() *store.Session {
+	sess := s.last
+	s.last = nil
+	return sess
+}
+
 func contains(list []core.Mode, x core.Mode) bool {
 	for _, m := range list {
 		if m == x {
@@ -0,0 +1,370 @@
+package winlink
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+// ExportReport summarizes ExportToWinlinkExpress.
+type ExportReport struct {
+	Scanned int
+	Written int
+	Skipped int // already in the Winlink Express directory
+	Errors  int
+}
+
+// ExportToWinlinkExpress writes the messages matching f into a Winlink Express
+// callsign directory (e.g. .../RMS Express/AE4OK), creating it if needed, so traffic
+// can move to a Windows station.
+//
+// Each message becomes Messages/<id>.mime plus a row appended to Data/Registry.txt.
+// <id> is the message's Winlink or PAT MID when it has one, else a new one. Rows use
+// the column layout most of the existing registry uses. Messages
+// whose id the directory already has are skipped. A winlink external ref in scope
+// records each export, so importing the directory back into scope updates the same
+// messages instead of duplicating them.
+func ExportToWinlinkExpress(ctx context.Context, st *store.Store, root, scope string, f store.MessageFilter) (*ExportReport, error) {
+	if st == nil {
+		return nil, fmt.Errorf("ExportToWinlinkExpress: store is nil")
+	}
+	if strings.TrimSpace(root) == "" {
+		return nil, fmt.Errorf("ExportToWinlinkExpress: root is required")
+	}
+	if strings.TrimSpace(scope) == "" {
+		return nil, fmt.Errorf("ExportToWinlinkExpress: scope is required")
+	}
+	ctx = store.WithActor(ctx, "exporter:winlink")
+
+	have := map[string]bool{}
+	recs, err := ReadRegistry(root)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	layout := registryFileLayout(recs)
+	for _, r := range recs {
+		have[strings.ToUpper(r.ID)] = true
+	}
+	if err := os.MkdirAll(filepath.Join(root, "Messages"), 0o755); err != nil {
+		return nil, fmt.Errorf("ExportToWinlinkExpress: %w", err)
+	}
+
+	msgs, err := st.QueryMessages(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("ExportToWinlinkExpress: %w", err)
+	}
+
+	report := &ExportReport{Scanned: len(msgs)}
+	for _, m := range msgs {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		default:
+		}
+
+		refs, err := st.ListExternalRefs(ctx, m.ID)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		id := exportID(refs)
+		mimePath := filepath.Join(root, "Messages", id+".mime")
+		if have[id] || fileExists(mimePath) {
+			report.Skipped++
+			continue
+		}
+
+		states, err := st.ListBackendState(ctx, m.ID)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		folder, state := exportFolder(m, states)
+
+		raw, err := BuildMIME(id, m)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		if err := writeFileAtomic(mimePath, raw); err != nil {
+			report.Errors++
+			continue
+		}
+		rec := RegistryRecord{
+			ID: id, CreatedAt: m.CreatedAt.UTC(), From: addressCall(m.From), To: joinAddresses(m.To),
+			Subject: m.Subject, Folder: folder, State: state, Freq: "0", Read: folder != "Inbox",
+			Priority: "Normal", Type: "Private", Attachments: len(m.Attachments), Layout: layout.Name,
+		}
+		if err := AppendRegistry(root, rec); err != nil {
+			_ = os.Remove(mimePath)
+			report.Errors++
+			continue
+		}
+		have[id] = true
+
+		sum := sha256Hex(raw)
+		if err := st.UpsertExternalRef(ctx, m.ID, "winlink", id, scope, "{}"); err != nil {
+			report.Errors++
+		}
+		_ = st.UpsertBackendState(ctx, m.ID, "winlink", folder, state, buildBackendExtra(rec, sum))
+		report.Written++
+	}
+	return report, nil
+}
+
+// exportID picks the Winlink message id for a message: an existing Winlink id, else
+// its PAT MID (both are the same 12-character B2F MID), else a new one.
+func exportID(refs []store.ExternalRef) string {
+	for _, backend := range []string{"winlink", "pat"} {
+		for _, r := range refs {
+			if r.Backend == backend && r.ExternalID != "" {
+				return strings.ToUpper(r.ExternalID)
+			}
+		}
+	}
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
+
+// exportFolder maps a message onto a Winlink Express folder and state, the inverse of
+// mapWinlinkFolderToStatus. Received mail is a draft that some backend files in its
+// inbox.
+func exportFolder(m *core.Message, states []store.BackendState) (folder, state string) {
+	switch m.Status {
+	case core.StatusSent:
+		return "Sent Items", "Sent"
+	case core.StatusQueued, core.StatusSending, core.StatusFailed:
+		return "Outbox", "Outbox"
+	case core.StatusArchived:
+		return "Saved Items", ""
+	case core.StatusDeleted:
+		return "Deleted Items", ""
+	}
+	for _, s := range states {
+		if strings.EqualFold(s.Folder, "inbox") {
+			return "Inbox", "Received"
+		}
+	}
+	return "Drafts", "Drafts"
+}
+
+// BuildMIME renders m as the RFC 822 message Winlink Express keeps in
+// Messages/<id>.mime: a quoted-printable text body (ISO-8859-1 when the text allows,
+// else UTF-8) and, when there are attachments, a multipart/mixed with one base64
+// part per file.
+func BuildMIME(id string, m *core.Message) ([]byte, error) {
+	if m == nil {
+		return nil, fmt.Errorf("mime: message is nil")
+	}
+	var b bytes.Buffer
+	hdr := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	date := m.CreatedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+	hdr("MIME-Version", "1.0")
+	hdr("Date", date.UTC().Format(time.RFC1123Z))
+	hdr("From", mailAddress(m.From))
+	if len(m.To) > 0 {
+		to := make([]string, 0, len(m.To))
+		for _, a := range m.To {
+			to = append(to, mailAddress(a))
+		}
+		hdr("To", strings.Join(to, ", "))
+	}
+	hdr("Subject", mime.QEncoding.Encode("utf-8", sanitize(m.Subject)))
+	hdr("Message-ID", "<"+id+"@winlink.org>")
+	if call := addressCall(m.From); call != "" {
+		hdr("X-Source", call)
+	}
+
+	text, charset := encodeText(m.Body)
+	if len(m.Attachments) == 0 {
+		writeTextPart(&b, text, charset)
+		return b.Bytes(), nil
+	}
+
+	boundary := "boundary" + strings.ToLower(id)
+	hdr("Content-Type", `multipart/mixed; boundary="`+boundary+`"`)
+	b.WriteString("\r\n--" + boundary + "\r\n")
+	writeTextPart(&b, text, charset)
+	for _, a := range m.Attachments {
+		ctype := a.ContentType
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		name := sanitize(a.Name)
+		b.WriteString("\r\n--" + boundary + "\r\n")
+		hdr("Content-Type", mime.FormatMediaType(ctype, map[string]string{"name": name}))
+		hdr("Content-Transfer-Encoding", "base64")
+		hdr("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		b.WriteString("\r\n")
+		enc := base64.StdEncoding.EncodeToString(a.Data)
+		for len(enc) > 76 {
+			b.WriteString(enc[:76] + "\r\n")
+			enc = enc[76:]
+		}
+		b.WriteString(enc + "\r\n")
+	}
+	b.WriteString("\r\n--" + boundary + "--\r\n")
+	return b.Bytes(), nil
+}
+
+func writeTextPart(b *bytes.Buffer, text []byte, charset string) {
+	fmt.Fprintf(b, "Content-Type: text/plain; charset=%q\r\n", charset)
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(b)
+	_, _ = w.Write(text)
+	_ = w.Close()
+	b.WriteString("\r\n")
+}
+
+// encodeText returns s with CRLF line endings in ISO-8859-1, Winlink's usual charset,
+// or in UTF-8 when s has characters Latin-1 lacks.
+func encodeText(s string) ([]byte, string) {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return []byte(s), "utf-8"
+		}
+		out = append(out, byte(r))
+	}
+	return out, "iso-8859-1"
+}
+
+// FormatRegistryRow renders r as a Data/Registry.txt line (without line ending) that
+// ReadRegistry parses back: fields are 0x01-delimited in the order of r.Layout, or of
+// the widest layout when r.Layout is empty or unknown.
+func FormatRegistryRow(r RegistryRecord) string {
+	layout, ok := RegistryLayoutByName(r.Layout)
+	if !ok {
+		layout = RegistryLayouts[len(RegistryLayouts)-1]
+	}
+	date := ""
+	if !r.CreatedAt.IsZero() {
+		date = r.CreatedAt.UTC().Format("2006/01/02 15:04")
+	}
+	values := map[string]string{
+		"id": r.ID, "date": date, "from": r.From, "to": r.To, "read": "False", "priority": r.Priority,
+		"msg_num": r.MsgNum, "subject": r.Subject, "folder": r.Folder, "state": r.State, "freq": r.Freq,
+		"gps": r.GPS, "type": r.Type, "source": r.Source, "session": r.Session, "attachments": strconv.Itoa(r.Attachments),
+	}
+	if r.Read {
+		values["read"] = "True"
+	}
+	fields := make([]string, len(layout.Columns))
+	for i, col := range layout.Columns {
+		fields[i] = strings.NewReplacer("\x01", " ", "\r", " ", "\n", " ").Replace(values[col])
+	}
+	return strings.Join(fields, "\x01")
+}
+
+// AppendRegistry appends rows for recs to <root>/Data/Registry.txt, creating it if
+// needed. Lines end in CRLF as Winlink Express writes them.
+func AppendRegistry(root string, recs ...RegistryRecord) error {
+	path := filepath.Join(root, "Data", "Registry.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	// A file that does not end in a newline would glue our first row onto its last.
+	if old, err := os.ReadFile(path); err == nil && len(old) > 0 && old[len(old)-1] != '\n' {
+		buf.WriteString("\r\n")
+	}
+	for _, r := range recs {
+		buf.WriteString(FormatRegistryRow(r) + "\r\n")
+	}
+	fh, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(buf.Bytes()); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// mailAddress renders a as an RFC 822 address; callsigns get the winlink.org domain.
+func mailAddress(a core.Address) string {
+	if e := strings.TrimSpace(a.Email); e != "" {
+		return e
+	}
+	if c := strings.ToUpper(strings.TrimSpace(a.Callsign)); c != "" {
+		return c + "@winlink.org"
+	}
+	return ""
+}
+
+// addressCall is the registry form of a: the callsign, or the e-mail address.
+func addressCall(a core.Address) string {
+	if c := strings.ToUpper(strings.TrimSpace(a.Callsign)); c != "" {
+		return c
+	}
+	return strings.TrimSpace(a.Email)
+}
+
+func joinAddresses(addrs []core.Address) string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if s := addressCall(a); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+func sanitize(s string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(s))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeFileAtomic writes path through a temporary file in the same directory, so
+// Winlink Express never sees a half-written message.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".relayops-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
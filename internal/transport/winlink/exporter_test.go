@@ -0,0 +1,158 @@
+package winlink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func testStore(t *testing.T) (*store.Store, context.Context) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st, ctx
+}
+
+func TestRegistryRowRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An existing registry without a final newline must not swallow the first new row.
+	if err := os.WriteFile(filepath.Join(root, "Data", "Registry.txt"), []byte(registryRow("OLD000000001", "W1AW", "AE4OK", "old", "Inbox")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := RegistryRecord{
+		ID: "NEW000000001", CreatedAt: time.Date(2025, 3, 4, 18, 30, 0, 0, time.UTC), From: "AE4OK", To: "W1AW;EOC-PLANS",
+		Subject: "Line\nbreak \x01 and delimiter", Folder: "Sent Items", State: "Sent", Freq: "14105000",
+	}
+	if err := AppendRegistry(root, want); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := ReadRegistry(root)
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("ReadRegistry = %d records, %v", len(recs), err)
+	}
+	got := recs[1]
+	if got.ID != want.ID || !got.CreatedAt.Equal(want.CreatedAt) || got.From != want.From || got.To != want.To ||
+		got.Subject != "Line break   and delimiter" || got.Folder != want.Folder || got.State != want.State || got.Freq != want.Freq {
+		t.Fatalf("round trip = %+v", got)
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	st, ctx := testStore(t)
+	for _, sc := range []string{"AE4OK", "K4XYZ"} {
+		if err := st.CreateScope(ctx, sc, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := core.NewMessage("Supply request", "Need 20 cots.\nDeliver to the gym.")
+	sent.From = core.Address{Callsign: "AE4OK"}
+	sent.To = []core.Address{{Callsign: "W1AW"}, {Email: "ops@example.org"}}
+	sent.Attachments = []core.Attachment{{Name: "list.csv", ContentType: "text/csv", Data: []byte("item,qty\ncots,20\n")}}
+	received := core.NewMessage("Café hours", "Open until 22:00 — bring a mug.")
+	received.From = core.Address{Callsign: "W1AW"}
+	received.To = []core.Address{{Callsign: "AE4OK"}}
+	for _, m := range []*core.Message{sent, received} {
+		if err := st.SaveMessage(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.SetStatusByID(ctx, sent.ID, core.StatusSent, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpsertExternalRef(ctx, sent.ID, "pat", "PATMID000001", "AE4OK", "{}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpsertBackendState(ctx, received.ID, "pat", "InBox", "Received", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(t.TempDir(), "RMS Express", "AE4OK")
+	rep, err := ExportToWinlinkExpress(ctx, st, root, "AE4OK", store.MessageFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Scanned != 2 || rep.Written != 2 || rep.Errors != 0 {
+		t.Fatalf("export = %+v", rep)
+	}
+
+	recs, err := ReadRegistry(root)
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("ReadRegistry = %+v %v", recs, err)
+	}
+	byID := map[string]RegistryRecord{}
+	for _, r := range recs {
+		byID[r.ID] = r
+	}
+	s, ok := byID["PATMID000001"]
+	if !ok || s.Folder != "Sent Items" || s.State != "Sent" || s.From != "AE4OK" || s.To != "W1AW;ops@example.org" || s.Subject != "Supply request" {
+		t.Fatalf("sent row = %+v (have %v)", s, byID)
+	}
+	var in RegistryRecord
+	for id, r := range byID {
+		if id != "PATMID000001" {
+			in = r
+		}
+	}
+	if len(in.ID) != 12 || in.Folder != "Inbox" || in.State != "Received" {
+		t.Fatalf("received row = %+v", in)
+	}
+
+	// The .mime files decode back to the messages.
+	body, files := decodeMIME(t, filepath.Join(root, "Messages", "PATMID000001.mime"))
+	if body != "Need 20 cots.\nDeliver to the gym." || string(files["list.csv"]) != "item,qty\ncots,20\n" {
+		t.Fatalf("sent mime body %q files %v", body, files)
+	}
+	if body, _ := decodeMIME(t, filepath.Join(root, "Messages", in.ID+".mime")); body != "Open until 22:00 — bring a mug." {
+		t.Fatalf("received mime body %q", body)
+	}
+
+	// Exporting again writes nothing; importing back into the scope finds the same messages.
+	if rep, _ := ExportToWinlinkExpress(ctx, st, root, "AE4OK", store.MessageFilter{}); rep.Skipped != 2 || rep.Written != 0 {
+		t.Fatalf("second export = %+v", rep)
+	}
+	imp, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", true)
+	if err != nil || imp.Created != 0 || imp.Updated != 2 {
+		t.Fatalf("import into AE4OK = %+v %v", imp, err)
+	}
+
+	// Another station importing the directory gets equivalent messages.
+	imp, err = ImportFromWinlinkExpress(ctx, st, root, "K4XYZ", false)
+	if err != nil || imp.Created != 2 {
+		t.Fatalf("import into K4XYZ = %+v %v", imp, err)
+	}
+	id, _, _ := st.GetMessageIDByExternalRef(ctx, "winlink", "PATMID000001", "K4XYZ")
+	m, _, err := st.GetMessage(ctx, id)
+	if err != nil || m.Subject != "Supply request" || m.Status != core.StatusSent || m.From.Callsign != "AE4OK" || len(m.To) != 2 {
+		t.Fatalf("reimported = %+v %v", m, err)
+	}
+}
+
+// decodeMIME returns the text body and the attachments of a .mime file.
+func decodeMIME(t *testing.T, path string) (string, map[string][]byte) {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := parseRFC822(raw)
+	files := map[string][]byte{}
+	for _, a := range m.Attachments {
+		files[a.Name] = a.Data
+	}
+	return m.Body, files
+}
@@ -1,20 +1,17 @@
 package winlink
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/mail"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/forms"
 	"github.com/4current/relayops/internal/store"
 	"github.com/google/uuid"
 )
@@ -23,17 +20,27 @@ type ImportReport struct {
 	Scanned int
 	Created int
 	Updated int
+	Skipped int // unchanged since the last import
 	Errors  int
+
+	// Unmapped lists Registry.txt columns that hold data no known layout names; their
+	// values are kept in the backend state.
+	Unmapped []int
 }
 
 // ImportFromWinlinkExpress imports (read-only) Winlink Express messages into the canonical RelayOps store.
 //
 // root must point at the callsign directory (e.g. .../RMS Express/AE4OK).
 //
-func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string, scope string) (*ImportReport, error) {
+// A message whose .mime file and Registry.txt row are unchanged since the last import
+// into scope is skipped; full rescans every message. A message imported before has its
+// subject, body and attachments replaced when its .mime file changed, and its backend
+// state refreshed either way.
+func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string, scope string, full bool) (*ImportReport, error) {
 	if st == nil {
 		return nil, fmt.Errorf("ImportFromWinlinkExpress: store is nil")
 	}
+	ctx = store.WithActor(ctx, "importer:winlink")
 	recs, err := ReadRegistry(root)
 	if err != nil {
 		return nil, err
@@ -44,7 +51,26 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		return nil, fmt.Errorf("ImportFromWinlinkExpress: scope is required")
 	}
 
-	report := &ImportReport{Scanned: len(recs)}
+	dir := root
+	if abs, err := filepath.Abs(root); err == nil {
+		dir = abs
+	}
+	source := "winlink:" + scope + ":" + filepath.ToSlash(dir)
+	cursor := map[string]store.FileStamp{}
+	if !full {
+		if cursor, err = st.ImportCursor(ctx, source); err != nil {
+			return nil, fmt.Errorf("ImportFromWinlinkExpress: %w", err)
+		}
+	}
+	seen := map[string]bool{}
+
+	report := &ImportReport{Scanned: len(recs), Unmapped: UnmappedColumns(recs)}
+	touched := map[string]bool{scope: true}
+	defer func() {
+		for sc := range touched {
+			_ = st.MarkScopeImported(ctx, sc)
+		}
+	}()
 	for _, rec := range recs {
 		select {
 		case <-ctx.Done():
@@ -52,22 +78,58 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		default:
 		}
 
-		messageID, found, err := st.GetMessageIDByExternalRef(ctx, "winlink", rec.ID, scope)
+		mimePath := filepath.Join(root, "Messages", rec.ID+".mime")
+		rel := "Messages/" + rec.ID + ".mime"
+		seen[rel] = true
+		prev, ok := cursor[rel]
+		if !ok {
+			prev = store.FileStamp{Path: rel}
+		}
+		stamp, raw, changed, err := store.StampFile(mimePath, prev)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+		// A folder move or state change only touches Registry.txt, so the row counts too.
+		rowKey := buildBackendExtra(rec, "")
+		if !changed && prev.Meta == rowKey {
+			if stamp != prev {
+				_ = st.SaveFileStamp(ctx, source, stamp) // touched, same contents
+			}
+			report.Skipped++
+			continue
+		}
+		stamp.Meta = rowKey
+		if raw == nil {
+			if raw, err = os.ReadFile(mimePath); err != nil {
+				report.Errors++
+				continue
+			}
+		}
+
+		// Mail to (or, outbound, from) a registered tactical address belongs to that
+		// identity's scope rather than the mailbox owner's.
+		routeBy := []string{rec.From}
+		if strings.EqualFold(strings.TrimSpace(rec.Folder), "inbox") {
+			routeBy = splitAddresses(rec.To)
+		}
+		msgScope, err := st.RouteScope(ctx, scope, routeBy...)
 		if err != nil {
 			report.Errors++
 			continue
 		}
 
-		mimePath := filepath.Join(root, "Messages", rec.ID+".mime")
-		raw, err := os.ReadFile(mimePath)
+		touched[msgScope] = true
+
+		messageID, found, err := st.GetMessageIDByExternalRef(ctx, "winlink", rec.ID, msgScope)
 		if err != nil {
 			report.Errors++
 			continue
 		}
-		mimeHash := sha256.Sum256(raw)
-		hashHex := hex.EncodeToString(mimeHash[:])
 
-		parsed, body := parseRFC822(raw)
+		hashHex := stamp.SHA256
+
+		parsed := parseRFC822(raw)
 		// Prefer registry subject; fall back to MIME subject.
 		subject := strings.TrimSpace(rec.Subject)
 		if subject == "" {
@@ -88,18 +150,32 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		}
 
 		if found {
-			// For now we do not update the canonical message row (your Store lacks update/upsert).
-			// We still keep backend state fresh.
+			// A folder move or state change leaves the .mime file alone; only a changed
+			// file rewrites the canonical message. Status and tags stay RelayOps'.
+			prevHash, err := importedMIMEHash(ctx, st, messageID)
+			if err != nil {
+				report.Errors++
+				continue
+			}
+			if prevHash != hashHex {
+				upd := &core.Message{Subject: subject, Body: parsed.Body, Attachments: parsed.Attachments}
+				upd.Meta.Form = attachedForm(parsed.Attachments)
+				if err := st.UpdateContent(ctx, messageID, upd); err != nil {
+					report.Errors++
+					continue
+				}
+			}
 			extra := buildBackendExtra(rec, hashHex)
 			_ = st.UpsertBackendState(ctx, messageID, "winlink", rec.Folder, rec.State, extra)
 			report.Updated++
+			_ = st.SaveFileStamp(ctx, source, stamp)
 			continue
 		}
 
 		// Create a new canonical message.
 		msg.ID = uuid.NewString()
 		msg.Subject = subject
-		msg.Body = body
+		msg.Body = parsed.Body
 		msg.CreatedAt = created
 		msg.UpdatedAt = created
 		msg.LastError = ""
@@ -109,6 +185,11 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		msg.Meta.Transport.Allowed = []core.Mode{core.ModeAny}
 		msg.Meta.Transport.Preferred = []core.Mode{}
 
+		// Every part besides the text body is kept; Winlink form XML also has its
+		// fields parsed.
+		msg.Attachments = parsed.Attachments
+		msg.Meta.Form = attachedForm(parsed.Attachments)
+
 		// Addresses: prefer MIME headers, but fall back to Registry.
 		msg.From = core.Address{Callsign: strings.TrimSpace(rec.From)}
 		if parsed.FromCallsign != "" {
@@ -136,7 +217,7 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		}
 
 		// Write external ref.
-		if err := st.UpsertExternalRef(ctx, msg.ID, "winlink", rec.ID, scope, "{}"); err != nil {
+		if err := st.UpsertExternalRef(ctx, msg.ID, "winlink", rec.ID, msgScope, "{}"); err != nil {
 			report.Errors++
 			// message row exists; keep going
 		}
@@ -146,64 +227,45 @@ func ImportFromWinlinkExpress(ctx context.Context, st *store.Store, root string,
 		_ = st.UpsertBackendState(ctx, msg.ID, "winlink", rec.Folder, rec.State, extra)
 
 		report.Created++
+		_ = st.SaveFileStamp(ctx, source, stamp)
 	}
 
+	if _, err := st.PruneImportCursor(ctx, source, seen); err != nil {
+		return report, fmt.Errorf("ImportFromWinlinkExpress: %w", err)
+	}
 	return report, nil
 }
 
-type parsedHeaders struct {
-	Subject      string
-	Date         time.Time
-	FromCallsign string
-	FromEmail    string
-	To           []core.Address
+// attachedForm parses the first Winlink form among atts, or returns nil.
+func attachedForm(atts []core.Attachment) *core.Form {
+	for _, a := range atts {
+		if forms.IsFormAttachment(a.Name) {
+			if f, err := forms.Parse(a.Data); err == nil {
+				return f
+			}
+		}
+	}
+	return nil
 }
 
-func parseRFC822(raw []byte) (parsedHeaders, string) {
-	var out parsedHeaders
-	body := ""
-
-	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+// importedMIMEHash returns the SHA-256 of the .mime file message id was last imported
+// from, as kept in its winlink backend state, or "" if there is none.
+func importedMIMEHash(ctx context.Context, st *store.Store, id string) (string, error) {
+	states, err := st.ListBackendState(ctx, id)
 	if err != nil {
-		return out, body
-	}
-	out.Subject = msg.Header.Get("Subject")
-	if ds := msg.Header.Get("Date"); ds != "" {
-		if t, err := mail.ParseDate(ds); err == nil {
-			out.Date = t
-		}
+		return "", err
 	}
-
-	// From
-	if f := msg.Header.Get("From"); f != "" {
-		if addrs, err := mail.ParseAddressList(f); err == nil && len(addrs) > 0 {
-			// Winlink From often looks like CALLSIGN@winlink.org
-			out.FromEmail = addrs[0].Address
-			out.FromCallsign = strings.Split(addrs[0].Address, "@")[0]
+	for _, b := range states {
+		if b.Backend != "winlink" {
+			continue
 		}
-	}
-
-	// To
-	if t := msg.Header.Get("To"); t != "" {
-		if addrs, err := mail.ParseAddressList(t); err == nil {
-			for _, a := range addrs {
-				addr := core.Address{}
-				if strings.Contains(a.Address, "@") {
-					addr.Email = a.Address
-					addr.Callsign = strings.Split(a.Address, "@")[0]
-				} else {
-					addr.Callsign = a.Address
-				}
-				out.To = append(out.To, addr)
-			}
+		var extra struct {
+			MIMESHA256 string `json:"mime_sha256"`
 		}
+		_ = json.Unmarshal([]byte(b.ExtraJSON), &extra)
+		return extra.MIMESHA256, nil
 	}
-
-	// Body: best-effort read raw body (may include MIME multipart).
-	b, _ := io.ReadAll(msg.Body)
-	body = strings.TrimSpace(string(b))
-
-	return out, body
+	return "", nil
 }
 
 func mapWinlinkFolderToStatus(folder string) core.MessageStatus {
@@ -227,15 +289,31 @@ func buildBackendExtra(rec RegistryRecord, mimeSHA256 string) string {
 		"state":       rec.State,
 		"freq":        rec.Freq,
 		"gps":         rec.GPS,
+		"read":        rec.Read,
+		"priority":    rec.Priority,
+		"type":        rec.Type,
+		"source":      rec.Source,
+		"session":     rec.Session,
+		"attachments": rec.Attachments,
+		"layout":      rec.Layout,
 		"mime_sha256": mimeSHA256,
 	}
 	if !rec.CreatedAt.IsZero() {
 		extra["created_at"] = rec.CreatedAt.UTC().Format(time.RFC3339)
 	}
-	if len(rec.RawFields) > 0 {
-		// Keep a slim record of unknown fields for future reverse engineering.
-		extra["raw_field_count"] = len(rec.RawFields)
+	if len(rec.Unmapped) > 0 {
+		// Keep columns no layout knows for future reverse engineering.
+		unmapped := map[string]string{}
+		for i, v := range rec.Unmapped {
+			unmapped[strconv.Itoa(i)] = v
+		}
+		extra["unmapped"] = unmapped
 	}
 	b, _ := json.Marshal(extra)
 	return string(b)
 }
+
+// splitAddresses splits a Registry.txt address list ("A; B, C").
+func splitAddresses(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' || r == ' ' })
+}
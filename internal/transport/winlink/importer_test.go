@@ -0,0 +1,149 @@
+package winlink
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/store"
+)
+
+func registryRow(id, from, to, subject, folder string) string {
+	return strings.Join([]string{id, "2025/03/01 14:05", from, to, "", "", "1", subject, folder, "0", ""}, "\x01")
+}
+
+func writeExpress(t *testing.T, root string, rows ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "Data", "Registry.txt"), []byte(strings.Join(rows, "\r\n")+"\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeMIME(t *testing.T, root, id, subject string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "Messages"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mime := "From: W1AW@winlink.org\r\nTo: AE4OK@winlink.org\r\nSubject: " + subject + "\r\n\r\nbody of " + id + "\r\n"
+	if err := os.WriteFile(filepath.Join(root, "Messages", id+".mime"), []byte(mime), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportSkipsUnchangedMessages(t *testing.T) {
+	st, ctx := testStore(t)
+
+	root := filepath.Join(t.TempDir(), "AE4OK")
+	writeMIME(t, root, "AAAA11112222", "one")
+	writeMIME(t, root, "BBBB33334444", "two")
+	writeExpress(t, root,
+		registryRow("AAAA11112222", "W1AW", "AE4OK", "one", "Inbox"),
+		registryRow("BBBB33334444", "W1AW", "AE4OK", "two", "Inbox"),
+	)
+
+	run := func(full bool) ImportReport {
+		t.Helper()
+		rep, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", full)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		return *rep
+	}
+	if got := run(false); got.Created != 2 || got.Skipped != 0 || got.Errors != 0 {
+		t.Fatalf("first import = %+v", got)
+	}
+	if got := run(false); got.Skipped != 2 || got.Created+got.Updated != 0 {
+		t.Fatalf("second import = %+v", got)
+	}
+
+	// A folder move changes only Registry.txt; a touched file with the same contents is
+	// still unchanged.
+	writeExpress(t, root,
+		registryRow("AAAA11112222", "W1AW", "AE4OK", "one", "Archive"),
+		registryRow("BBBB33334444", "W1AW", "AE4OK", "two", "Inbox"),
+	)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "Messages", "BBBB33334444.mime"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(false); got.Updated != 1 || got.Skipped != 1 {
+		t.Fatalf("after folder move = %+v", got)
+	}
+
+	writeMIME(t, root, "BBBB33334444", "two, corrected")
+	if got := run(false); got.Updated != 1 || got.Skipped != 1 {
+		t.Fatalf("after edit = %+v", got)
+	}
+	if got := run(true); got.Updated != 2 || got.Skipped != 0 {
+		t.Fatalf("full import = %+v", got)
+	}
+
+	// Messages gone from the registry are forgotten by the cursor.
+	writeExpress(t, root, registryRow("AAAA11112222", "W1AW", "AE4OK", "one", "Archive"))
+	run(false)
+	abs, _ := filepath.Abs(root)
+	cur, err := st.ImportCursor(ctx, "winlink:AE4OK:"+filepath.ToSlash(abs))
+	if err != nil || len(cur) != 1 {
+		t.Fatalf("cursor = %v %v", cur, err)
+	}
+}
+
+func TestImportUpdatesChangedMessages(t *testing.T) {
+	st, ctx := testStore(t)
+
+	root := filepath.Join(t.TempDir(), "AE4OK")
+	writeMIME(t, root, "AAAA11112222", "one")
+	writeExpress(t, root, registryRow("AAAA11112222", "W1AW", "AE4OK", "one", "Inbox"))
+	if _, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", false); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	id, found, err := st.GetMessageIDByExternalRef(ctx, "winlink", "AAAA11112222", "AE4OK")
+	if err != nil || !found {
+		t.Fatalf("GetMessageIDByExternalRef = %v, %v", found, err)
+	}
+	if err := st.SetStatusByID(ctx, id, core.StatusArchived, ""); err != nil {
+		t.Fatal(err)
+	}
+	contentEvents := func() int {
+		t.Helper()
+		evs, err := st.ListEvents(ctx, store.EventFilter{MessageID: id, Kind: store.EventMessageContent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(evs)
+	}
+
+	// A folder move alone leaves the message as it is, even on a full rescan.
+	writeExpress(t, root, registryRow("AAAA11112222", "W1AW", "AE4OK", "one", "Archive"))
+	if _, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", true); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if n := contentEvents(); n != 0 {
+		t.Fatalf("%d content updates after a folder move", n)
+	}
+
+	mime := "From: W1AW@winlink.org\r\nTo: AE4OK@winlink.org\r\nSubject: one\r\n\r\ncorrected body\r\n"
+	if err := os.WriteFile(filepath.Join(root, "Messages", "AAAA11112222.mime"), []byte(mime), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", false)
+	if err != nil || rep.Updated != 1 {
+		t.Fatalf("import after edit = %+v, %v", rep, err)
+	}
+	m, _, err := st.GetMessage(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(m.Body) != "corrected body" || m.Status != core.StatusArchived {
+		t.Fatalf("after edit: body %q status %s, want the new body and the status kept", m.Body, m.Status)
+	}
+	if n := contentEvents(); n != 1 {
+		t.Fatalf("%d content updates after an edit, want 1", n)
+	}
+}
@@ -0,0 +1,248 @@
+package winlink
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+type parsedHeaders struct {
+	Subject      string
+	Date         time.Time
+	FromCallsign string
+	FromEmail    string
+	To           []core.Address
+}
+
+// parsedMIME is a decoded .mime message.
+type parsedMIME struct {
+	parsedHeaders
+	Body        string // the text/plain part as UTF-8 with LF line endings
+	Attachments []core.Attachment
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseRFC822 decodes a Winlink Express .mime file. RFC 2047 encoded headers are
+// decoded; the body is the first text/plain part (or, lacking one, the first inline
+// text part), with its transfer encoding and charset undone. Every other leaf part is
+// returned as an attachment, except the alternatives to the chosen body. A message
+// that is not RFC 822 at all yields zero values.
+func parseRFC822(raw []byte) parsedMIME {
+	var out parsedMIME
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return out
+	}
+
+	if s, err := wordDecoder.DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		out.Subject = s
+	} else {
+		out.Subject = msg.Header.Get("Subject")
+	}
+	if ds := msg.Header.Get("Date"); ds != "" {
+		if t, err := mail.ParseDate(ds); err == nil {
+			out.Date = t
+		}
+	}
+
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	// From: Winlink From often looks like CALLSIGN@winlink.org
+	if addrs, err := parser.ParseList(msg.Header.Get("From")); err == nil && len(addrs) > 0 {
+		out.FromEmail = addrs[0].Address
+		out.FromCallsign = strings.Split(addrs[0].Address, "@")[0]
+	}
+	if addrs, err := parser.ParseList(msg.Header.Get("To")); err == nil {
+		for _, a := range addrs {
+			addr := core.Address{}
+			if strings.Contains(a.Address, "@") {
+				addr.Email = a.Address
+				addr.Callsign = strings.Split(a.Address, "@")[0]
+			} else {
+				addr.Callsign = a.Address
+			}
+			out.To = append(out.To, addr)
+		}
+	}
+
+	var parts []mimePart
+	collectParts(textproto.MIMEHeader(msg.Header), msg.Body, "", &parts)
+
+	body := -1
+	for _, want := range []func(p mimePart) bool{
+		func(p mimePart) bool { return p.ctype == "text/plain" },
+		func(p mimePart) bool { return strings.HasPrefix(p.ctype, "text/") },
+	} {
+		for i, p := range parts {
+			if !p.attachment && want(p) {
+				body = i
+				break
+			}
+		}
+		if body >= 0 {
+			break
+		}
+	}
+	if body >= 0 {
+		out.Body = strings.TrimSpace(strings.ReplaceAll(decodeCharset(parts[body].charset, parts[body].data), "\r\n", "\n"))
+	}
+
+	for i, p := range parts {
+		if i == body || (body >= 0 && p.alternative != "" && p.alternative == parts[body].alternative) {
+			continue
+		}
+		out.Attachments = append(out.Attachments, core.Attachment{Name: p.filename(i), ContentType: p.ctype, Data: p.data})
+	}
+	return out
+}
+
+// mimePart is one decoded leaf of a MIME tree.
+type mimePart struct {
+	ctype       string
+	charset     string
+	name        string
+	attachment  bool   // Content-Disposition: attachment, or a named part
+	alternative string // the boundary of the multipart/alternative it belongs to
+	data        []byte // transfer encoding undone
+}
+
+// filename names the attachment, making one up for unnamed parts.
+func (p mimePart) filename(i int) string {
+	if p.name != "" {
+		return p.name
+	}
+	ext := ".bin"
+	if p.ctype == "message/rfc822" {
+		ext = ".eml"
+	} else if exts, _ := mime.ExtensionsByType(p.ctype); len(exts) > 0 {
+		ext = exts[0]
+	}
+	return fmt.Sprintf("part%d%s", i+1, ext)
+}
+
+// collectParts appends the leaf parts under h/r to parts, descending into multiparts.
+func collectParts(h textproto.MIMEHeader, r io.Reader, alternative string, parts *[]mimePart) {
+	ctype, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		ctype, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(ctype, "multipart/") && params["boundary"] != "" {
+		if ctype == "multipart/alternative" {
+			alternative = params["boundary"]
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			collectParts(p.Header, p, alternative, parts)
+		}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	p := mimePart{ctype: ctype, charset: params["charset"], alternative: alternative, data: decodeTransfer(h.Get("Content-Transfer-Encoding"), data)}
+	disp, dp, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err == nil {
+		p.name = dp["filename"]
+		p.attachment = disp == "attachment"
+	}
+	if p.name == "" {
+		p.name = params["name"]
+	}
+	if p.name != "" {
+		if n, err := wordDecoder.DecodeHeader(p.name); err == nil {
+			p.name = n
+		}
+		p.attachment = true
+	}
+	*parts = append(*parts, p)
+}
+
+// decodeTransfer undoes a Content-Transfer-Encoding. multipart.Reader already decodes
+// quoted-printable parts (and drops the header); the top-level body it does not.
+// Undecodable data is kept as it is.
+func decodeTransfer(cte string, data []byte) []byte {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "quoted-printable":
+		if dec, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data))); err == nil {
+			return dec
+		}
+	case "base64":
+		if dec, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), "")); err == nil {
+			return dec
+		}
+	}
+	return data
+}
+
+// charsetReader lets mime.WordDecoder decode the charsets decodeCharset knows.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(charset, b)), nil
+}
+
+// decodeCharset converts b from charset to UTF-8. Winlink clients mostly send
+// ISO-8859-1 or Windows-1252; an unknown charset is taken as UTF-8 when b is valid
+// UTF-8 and as ISO-8859-1 otherwise, so no byte is lost.
+func decodeCharset(charset string, b []byte) string {
+	var high *[128]rune
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"`)) {
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "cp819":
+	case "windows-1252", "cp1252", "x-cp1252":
+		high = &cp1252
+	case "iso-8859-15", "iso8859-15", "latin9":
+		high = &latin9
+	default:
+		if utf8.Valid(b) {
+			return string(b)
+		}
+	}
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for _, c := range b {
+		if c >= 0x80 && high != nil {
+			sb.WriteRune(high[c-0x80])
+		} else {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// cp1252 and latin9 map bytes 0x80-0xFF to Unicode; both only differ from
+// ISO-8859-1 in a few places.
+var cp1252, latin9 [128]rune
+
+func init() {
+	for i := range cp1252 {
+		cp1252[i] = rune(0x80 + i)
+		latin9[i] = rune(0x80 + i)
+	}
+	for i, r := range []rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+	} {
+		cp1252[i] = r
+	}
+	for b, r := range map[int]rune{0xa4: '€', 0xa6: 'Š', 0xa8: 'š', 0xb4: 'Ž', 0xb8: 'ž', 0xbc: 'Œ', 0xbd: 'œ', 0xbe: 'Ÿ'} {
+		latin9[b-0x80] = r
+	}
+}
@@ -0,0 +1,105 @@
+package winlink
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseMultipartLatin1(t *testing.T) {
+	m := parseRFC822(readFixture(t, "multipart_latin1.mime"))
+	if m.Subject != "Café shelter — status" {
+		t.Fatalf("subject = %q", m.Subject)
+	}
+	if m.FromCallsign != "F4ABC" || len(m.To) != 2 || m.To[1].Callsign != "EOC-PLANS" {
+		t.Fatalf("addresses = %q %+v", m.FromCallsign, m.To)
+	}
+	if m.Body != "Café shelter open.\nCapacité: 40 lits." {
+		t.Fatalf("body = %q", m.Body)
+	}
+	if len(m.Attachments) != 2 {
+		t.Fatalf("attachments = %+v", m.Attachments)
+	}
+	if a := m.Attachments[0]; a.Name != "RMS_Express_Form_ICS213_Initial_Viewer.xml" || a.ContentType != "text/xml" || len(a.Data) == 0 || a.Data[0] != '<' {
+		t.Fatalf("form attachment = %q %q %q", a.Name, a.ContentType, a.Data)
+	}
+	if a := m.Attachments[1]; a.Name != "plén.png" || len(a.Data) != 64 || a.Data[63] != 63 {
+		t.Fatalf("png attachment = %q %d bytes", a.Name, len(a.Data))
+	}
+}
+
+func TestParseAlternativeUTF8(t *testing.T) {
+	m := parseRFC822(readFixture(t, "alternative_utf8.mime"))
+	if m.Subject != "Route üpdate" {
+		t.Fatalf("subject = %q", m.Subject)
+	}
+	if m.Body != "Road to the gym is closed — use 5th St.\nÜberfall? No." {
+		t.Fatalf("body = %q", m.Body)
+	}
+	// The HTML alternative is dropped; the forwarded message is kept under a made-up name.
+	if len(m.Attachments) != 1 || m.Attachments[0].Name != "part3.eml" || m.Attachments[0].ContentType != "message/rfc822" {
+		t.Fatalf("attachments = %+v", m.Attachments)
+	}
+}
+
+func TestParseSinglePartCP1252(t *testing.T) {
+	m := parseRFC822(readFixture(t, "single_cp1252.mime"))
+	if m.Body != "“All clear” at 18:00 – cost €0." || len(m.Attachments) != 0 {
+		t.Fatalf("body = %q, attachments = %+v", m.Body, m.Attachments)
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	for _, tc := range []struct {
+		charset string
+		in      []byte
+		want    string
+	}{
+		{"ISO-8859-1", []byte{'c', 'a', 'f', 0xe9}, "café"},
+		{"iso-8859-15", []byte{0xa4}, "€"},
+		{"windows-1252", []byte{0x80, 0x93, 0xe9}, "€“é"},
+		{"", []byte("café"), "café"},
+		{"utf-8", []byte{'c', 'a', 'f', 0xe9}, "café"}, // mislabelled Latin-1
+		{"x-unknown", []byte("plain"), "plain"},
+	} {
+		if got := decodeCharset(tc.charset, tc.in); got != tc.want {
+			t.Errorf("decodeCharset(%q, %q) = %q, want %q", tc.charset, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestImportDecodesMIME(t *testing.T) {
+	st, ctx := testStore(t)
+	root := filepath.Join(t.TempDir(), "AE4OK")
+	if err := os.MkdirAll(filepath.Join(root, "Messages"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "Messages", "MIXED0000001.mime"), readFixture(t, "multipart_latin1.mime"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeExpress(t, root, registryRow("MIXED0000001", "F4ABC", "AE4OK", "", "Inbox"))
+
+	if rep, err := ImportFromWinlinkExpress(ctx, st, root, "AE4OK", false); err != nil || rep.Created != 1 {
+		t.Fatalf("import = %+v %v", rep, err)
+	}
+	id, _, _ := st.GetMessageIDByExternalRef(ctx, "winlink", "MIXED0000001", "AE4OK")
+	m, _, err := st.GetMessage(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "Café shelter — status" || m.Body != "Café shelter open.\nCapacité: 40 lits." || len(m.Attachments) != 2 {
+		t.Fatalf("message = %q %q %d attachments", m.Subject, m.Body, len(m.Attachments))
+	}
+	if m.Meta.Form == nil || m.Meta.Form.Name != "ICS213" {
+		t.Fatalf("form = %+v", m.Meta.Form)
+	}
+}
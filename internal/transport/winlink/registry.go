@@ -3,26 +3,92 @@ package winlink
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-// RegistryRecord is a best-effort parse of a single Winlink Express registry row.
+// RegistryRecord is a parsed Winlink Express registry row.
 // Winlink Express (RMS Express) persists message state in Data/Registry.txt, with fields delimited by ASCII 0x01.
+// Rows do not all have the same columns; see RegistryLayouts.
 type RegistryRecord struct {
-	ID        string
-	CreatedAt time.Time
-	From      string
-	To        string
-	MsgNum    string
-	Subject   string
-	Folder    string
-	State     string // e.g. "Sent" (best-effort)
-	Freq      string // best-effort (often numeric kHz/Hz-ish)
-	GPS       string // best-effort (trailing field in your samples)
-	RawFields []string
+	ID          string
+	CreatedAt   time.Time
+	From        string
+	To          string
+	Read        bool
+	Priority    string // e.g. "Normal", "Priority", "Immediate", "Flash"
+	MsgNum      string
+	Subject     string
+	Folder      string
+	State       string // e.g. "Sent", "Received", "Outbox"
+	Freq        string // Hz, "0" when not sent over radio
+	GPS         string // position report, e.g. "34.0522N 118.2437W"
+	Type        string // e.g. "Private", "Bulletin", "Acknowledgement"
+	Source      string // the station that handled the message, for tactical addresses
+	Session     string // e.g. "Telnet", "Packet", "VARA HF", "Pactor", "P2P"
+	Attachments int
+	Layout      string         // name of the RegistryLayout the row was read with
+	Unmapped    map[int]string // non-empty columns the layout does not know, by index
+	RawFields   []string
+}
+
+// RegistryLayout is a column order of Registry.txt rows. Rows are told apart by
+// their column count, and layouts are named after it.
+type RegistryLayout struct {
+	Name    string
+	Columns []string
+}
+
+// RegistryLayouts lists the known Registry.txt layouts, narrowest first:
+//
+//	11col  id, date, from, to, read, priority, msg_num, subject, folder, freq, gps
+//	12col  11col with the message state after the folder
+//	16col  12col followed by type, source, session and attachment count
+//
+// The layouts are not tied to particular Winlink Express releases and have not been
+// checked against files from each one; columns they do not cover are reported as
+// unmapped (see UnmappedColumns) so they can be corrected from real files. Rows
+// without a state column get State from the last state word ("Sent", "Received",
+// ...) found in any column, as before the layouts existed.
+//
+// A row wider than every layout is read with the widest one and its extra columns
+// are reported as unmapped; a row narrower than 11col (but with at least the folder)
+// is read as 11col with the missing columns empty.
+var RegistryLayouts = []RegistryLayout{
+	{"11col", []string{"id", "date", "from", "to", "read", "priority", "msg_num", "subject", "folder", "freq", "gps"}},
+	{"12col", []string{"id", "date", "from", "to", "read", "priority", "msg_num", "subject", "folder", "state", "freq", "gps"}},
+	{"16col", []string{"id", "date", "from", "to", "read", "priority", "msg_num", "subject", "folder", "state", "freq", "gps", "type", "source", "session", "attachments"}},
+}
+
+// minRegistryFields is the narrowest row still worth reading: up to the folder.
+const minRegistryFields = 9
+
+// registryLayout picks the layout for a row of n columns.
+func registryLayout(n int) RegistryLayout {
+	l := RegistryLayouts[0]
+	for _, cand := range RegistryLayouts {
+		if len(cand.Columns) <= n {
+			l = cand
+		}
+	}
+	return l
+}
+
+// RegistryLayoutByName returns the layout called name, if it is known.
+func RegistryLayoutByName(name string) (RegistryLayout, bool) {
+	for _, l := range RegistryLayouts {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return RegistryLayout{}, false
 }
 
 // ReadRegistry reads <root>/Data/Registry.txt and returns parsed records.
@@ -33,68 +99,157 @@ func ReadRegistry(root string) ([]RegistryRecord, error) {
 		return nil, fmt.Errorf("open Registry.txt: %w", err)
 	}
 	defer f.Close()
+	return ParseRegistry(f)
+}
 
-	scanner := bufio.NewScanner(f)
+// ParseRegistry parses Registry.txt rows. Rows too short to hold a folder, and rows
+// without an id, are ignored. Text is UTF-8, or Windows-1252 where it is not valid
+// UTF-8.
+func ParseRegistry(r io.Reader) ([]RegistryRecord, error) {
+	scanner := bufio.NewScanner(r)
 	// Registry lines can be long; bump the buffer.
 	buf := make([]byte, 0, 1024*64)
 	scanner.Buffer(buf, 1024*1024)
 
 	var out []RegistryRecord
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
+		line := scanner.Bytes()
+		text := string(line)
+		if !utf8.Valid(line) {
+			text = decodeCharset("windows-1252", line)
+		}
+		text = strings.TrimRight(text, "\r")
+		if strings.TrimSpace(text) == "" {
 			continue
 		}
-		fields := strings.Split(line, "\x01")
-		if len(fields) < 9 {
+		fields := strings.Split(text, "\x01")
+		if len(fields) < minRegistryFields {
 			continue // ignore short/unknown rows
 		}
-
-		r := RegistryRecord{RawFields: fields}
-		r.ID = strings.TrimSpace(fields[0])
-		r.From = strings.TrimSpace(fields[2])
-		r.To = strings.TrimSpace(fields[3])
-		r.MsgNum = strings.TrimSpace(fields[6])
-		r.Subject = strings.TrimSpace(fields[7])
-		r.Folder = strings.TrimSpace(fields[8])
-
-		// Timestamp is typically YYYY/MM/DD HH:MM in field[1].
-		if ts := strings.TrimSpace(fields[1]); ts != "" {
-			if t, err := time.Parse("2006/01/02 15:04", ts); err == nil {
-				r.CreatedAt = t
-			}
+		if r := parseRegistryRow(fields); r.ID != "" {
+			out = append(out, r)
 		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan Registry.txt: %w", err)
+	}
+	return out, nil
+}
 
-		// Best-effort: find a state token like "Sent" in the remaining fields.
-		for _, f := range fields {
-			v := strings.TrimSpace(f)
-			switch v {
-			case "Sent", "Outbox", "Inbox", "Drafts", "Posted", "Received":
-				r.State = v
+func parseRegistryRow(fields []string) RegistryRecord {
+	layout := registryLayout(len(fields))
+	r := RegistryRecord{Layout: layout.Name, RawFields: fields}
+	for i, v := range fields {
+		v = strings.TrimSpace(v)
+		if i >= len(layout.Columns) {
+			if v != "" {
+				if r.Unmapped == nil {
+					r.Unmapped = map[int]string{}
+				}
+				r.Unmapped[i] = v
 			}
+			continue
 		}
-
-		// Best-effort: frequency and GPS are often toward the end.
-		if len(fields) >= 2 {
-			last := strings.TrimSpace(fields[len(fields)-1])
-			if strings.Contains(last, "GPS") || strings.Contains(last, "N,") || strings.Contains(last, "W") {
-				r.GPS = last
+		switch layout.Columns[i] {
+		case "id":
+			r.ID = v
+		case "date":
+			// Timestamp is YYYY/MM/DD HH:MM, with seconds in some files.
+			for _, layout := range []string{"2006/01/02 15:04", "2006/01/02 15:04:05"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					r.CreatedAt = t
+					break
+				}
 			}
+		case "from":
+			r.From = v
+		case "to":
+			r.To = v
+		case "read":
+			r.Read = registryBool(v)
+		case "priority":
+			r.Priority = v
+		case "msg_num":
+			r.MsgNum = v
+		case "subject":
+			r.Subject = v
+		case "folder":
+			r.Folder = v
+		case "state":
+			r.State = v
+		case "freq":
+			r.Freq = v
+		case "gps":
+			r.GPS = v
+		case "type":
+			r.Type = v
+		case "source":
+			r.Source = v
+		case "session":
+			r.Session = v
+		case "attachments":
+			r.Attachments, _ = strconv.Atoi(v)
 		}
-		if len(fields) >= 3 {
-			maybeFreq := strings.TrimSpace(fields[len(fields)-2])
-			// In your examples this was "0" or "145090".
-			if maybeFreq != "" {
-				r.Freq = maybeFreq
-			}
+	}
+	if !slices.Contains(layout.Columns, "state") {
+		r.State = registryStateToken(fields)
+	}
+	return r
+}
+
+// registryStateToken guesses the state of a row that has no state column from the
+// state words Winlink Express writes; the last one found wins.
+func registryStateToken(fields []string) string {
+	state := ""
+	for _, f := range fields {
+		switch v := strings.TrimSpace(f); v {
+		case "Sent", "Outbox", "Inbox", "Drafts", "Posted", "Received":
+			state = v
 		}
+	}
+	return state
+}
 
-		if r.ID != "" {
-			out = append(out, r)
+// registryBool reads the True/False (or 1/0) flags Winlink Express writes.
+func registryBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "1", "y", "yes":
+		return true
+	}
+	return false
+}
+
+// UnmappedColumns returns the indexes of the columns that hold data in recs but are
+// not part of their rows' layout, in ascending order. A non-empty result means the
+// file has columns RegistryLayouts does not cover yet.
+func UnmappedColumns(recs []RegistryRecord) []int {
+	seen := map[int]bool{}
+	for _, r := range recs {
+		for i := range r.Unmapped {
+			seen[i] = true
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan Registry.txt: %w", err)
+	out := make([]int, 0, len(seen))
+	for i := range seen {
+		out = append(out, i)
 	}
-	return out, nil
+	sort.Ints(out)
+	return out
+}
+
+// registryFileLayout is the layout most rows of recs use, so rows appended to an
+// existing file match it; an empty file gets the widest layout.
+func registryFileLayout(recs []RegistryRecord) RegistryLayout {
+	counts := map[string]int{}
+	best := RegistryLayouts[len(RegistryLayouts)-1]
+	for _, r := range recs {
+		counts[r.Layout]++
+	}
+	n := 0
+	for _, l := range RegistryLayouts {
+		if counts[l.Name] > n {
+			best, n = l, counts[l.Name]
+		}
+	}
+	return best
 }
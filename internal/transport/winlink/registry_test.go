@@ -0,0 +1,94 @@
+package winlink
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string) []RegistryRecord {
+	t.Helper()
+	recs, err := ParseRegistry(bytes.NewReader(readFixture(t, name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+// The registry fixtures are hand-written rows in each layout, not captures from
+// Winlink Express; they pin the column map, not what Winlink Express writes.
+func TestParseRegistryLayouts(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		want []RegistryRecord
+	}{
+		{"registry_11col.txt", []RegistryRecord{
+			{ID: "6QF2LZP0ABCD", CreatedAt: time.Date(2019, 6, 12, 18, 2, 0, 0, time.UTC), From: "N0CALL", To: "W1AW", Read: true, Priority: "Normal",
+				MsgNum: "12", Subject: "Net check-in", Folder: "Sent Items", Freq: "0", Layout: "11col"},
+			{ID: "H7KD20XZ9QWE", CreatedAt: time.Date(2019, 6, 13, 7, 45, 0, 0, time.UTC), From: "W1AW", To: "N0CALL", Priority: "Priority",
+				MsgNum: "13", Subject: "Café re-opening", Folder: "Inbox", State: "Inbox", Freq: "7103000", GPS: "34.0522N 118.2437W", Layout: "11col"},
+		}},
+		{"registry_12col.txt", []RegistryRecord{
+			{ID: "AB12CD34EF56", CreatedAt: time.Date(2021, 11, 3, 20, 15, 0, 0, time.UTC), From: "N0CALL", To: "W1AW;K4XYZ", Read: true, Priority: "Normal",
+				MsgNum: "31", Subject: "Weekly net", Folder: "Sent Items", State: "Sent", Freq: "145090000", Layout: "12col"},
+			{ID: "ZZ99YY88XX77", CreatedAt: time.Date(2021, 11, 4, 8, 0, 0, 0, time.UTC), From: "K4XYZ", To: "N0CALL", Priority: "Immediate",
+				MsgNum: "32", Subject: "Shelter count", Folder: "Inbox", State: "Received", Freq: "0", GPS: "35.1N 80.8W", Layout: "12col"},
+		}},
+		{"registry_16col.txt", []RegistryRecord{
+			{ID: "M1N2B3V4C5X6", CreatedAt: time.Date(2024, 2, 28, 19, 30, 12, 0, time.UTC), From: "EOC-PLANS", To: "W1AW", Read: true, Priority: "Normal",
+				MsgNum: "77", Subject: "ICS-213 supply", Folder: "Outbox", State: "Outbox", Freq: "0",
+				Type: "Private", Source: "N0CALL", Session: "VARA HF", Attachments: 1, Layout: "16col"},
+			{ID: "Q1W2E3R4T5Y6", CreatedAt: time.Date(2024, 2, 29, 6, 10, 0, 0, time.UTC), From: "W1AW", To: "EOC-PLANS", Priority: "Flash",
+				MsgNum: "78", Subject: "ARES bulletin", Folder: "Inbox", State: "Received", Freq: "14105000",
+				Type: "Bulletin", Source: "N0CALL", Session: "Telnet", Layout: "16col"},
+		}},
+	} {
+		got := parseFixture(t, tc.file)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: %d records, want %d", tc.file, len(got), len(tc.want))
+		}
+		for i := range got {
+			got[i].RawFields = nil
+			if !reflect.DeepEqual(got[i], tc.want[i]) {
+				t.Errorf("%s row %d:\n got %+v\nwant %+v", tc.file, i, got[i], tc.want[i])
+			}
+		}
+		if u := UnmappedColumns(got); len(u) != 0 {
+			t.Errorf("%s: unmapped columns %v", tc.file, u)
+		}
+	}
+}
+
+func TestParseRegistryReportsUnmappedColumns(t *testing.T) {
+	recs := parseFixture(t, "registry_unknown.txt")
+	if len(recs) != 2 || recs[0].Layout != "16col" || recs[1].Attachments != 2 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if got := UnmappedColumns(recs); !reflect.DeepEqual(got, []int{16, 17}) {
+		t.Fatalf("unmapped = %v", got)
+	}
+	if !reflect.DeepEqual(recs[0].Unmapped, map[int]string{17: "x-signed"}) || !reflect.DeepEqual(recs[1].Unmapped, map[int]string{16: "AES"}) {
+		t.Fatalf("row unmapped = %v %v", recs[0].Unmapped, recs[1].Unmapped)
+	}
+}
+
+func TestFormatRegistryRowKeepsLayout(t *testing.T) {
+	for _, rec := range parseFixture(t, "registry_12col.txt") {
+		rec.RawFields = nil
+		back, err := ParseRegistry(bytes.NewReader([]byte(FormatRegistryRow(rec))))
+		if err != nil || len(back) != 1 {
+			t.Fatalf("reparse = %v %v", back, err)
+		}
+		back[0].RawFields = nil
+		if !reflect.DeepEqual(back[0], rec) {
+			t.Fatalf("round trip:\n got %+v\nwant %+v", back[0], rec)
+		}
+	}
+	if got := registryFileLayout(parseFixture(t, "registry_11col.txt")).Name; got != "11col" {
+		t.Fatalf("file layout = %s", got)
+	}
+	if got := registryFileLayout(nil).Name; got != "16col" {
+		t.Fatalf("empty file layout = %s", got)
+	}
+}
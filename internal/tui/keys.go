@@ -0,0 +1,110 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyCode identifies a non-printing key; printable input is KeyRune with Key.Rune set.
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyEnter
+	KeyTab
+	KeyBacktab
+	KeyEsc
+	KeyBackspace
+	KeyDelete
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDn
+	KeyCtrlC
+	KeyCtrlS
+)
+
+// Key is one keypress.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// csiKeys maps the final part of ESC [ ... and ESC O ... sequences sent by xterm-style
+// terminals (and by PuTTY and the Linux console for the ~ forms).
+var csiKeys = map[string]KeyCode{
+	"A": KeyUp, "B": KeyDown, "C": KeyRight, "D": KeyLeft,
+	"H": KeyHome, "F": KeyEnd, "Z": KeyBacktab,
+	"1~": KeyHome, "7~": KeyHome, "4~": KeyEnd, "8~": KeyEnd,
+	"3~": KeyDelete, "5~": KeyPgUp, "6~": KeyPgDn,
+}
+
+// parseKeys decodes one read from the terminal. A lone ESC is the Escape key; unknown
+// escape sequences are dropped whole so their bytes are not typed into a form.
+func parseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b:
+			if len(b) == 1 {
+				keys = append(keys, Key{Code: KeyEsc})
+				b = b[1:]
+				continue
+			}
+			if b[1] != '[' && b[1] != 'O' {
+				// Alt+key arrives as ESC followed by the key; treat it as Escape.
+				keys = append(keys, Key{Code: KeyEsc})
+				b = b[1:]
+				continue
+			}
+			// Parameters are digits and ';', then one final byte in 0x40-0x7e.
+			i := 2
+			for i < len(b) && (b[i] >= '0' && b[i] <= '9' || b[i] == ';') {
+				i++
+			}
+			if i == len(b) {
+				return keys
+			}
+			seq := string(b[2:i])
+			if b[i] == '~' {
+				seq += "~"
+			} else {
+				// Modified arrows (ESC [ 1 ; 5 A) keep only the final byte.
+				seq = string(b[i])
+			}
+			if code, ok := csiKeys[seq]; ok {
+				keys = append(keys, Key{Code: code})
+			}
+			b = b[i+1:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+			b = b[1:]
+			if c == '\r' && len(b) > 0 && b[0] == '\n' {
+				b = b[1:]
+			}
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+			b = b[1:]
+		case c == 0x13:
+			keys = append(keys, Key{Code: KeyCtrlS})
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			if r != utf8.RuneError || n > 1 {
+				keys = append(keys, Key{Code: KeyRune, Rune: r})
+			}
+			b = b[n:]
+		}
+	}
+	return keys
+}
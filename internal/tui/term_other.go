@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("the terminal UI needs a Unix terminal; use the CLI or 'relayops serve' instead")
+
+func makeRaw(fd int) (func(), error) { return nil, errNoTerminal }
+
+func termSize(fd int) (int, int, error) { return 0, 0, errNoTerminal }
+
+func notifyResize(c chan<- os.Signal) {}
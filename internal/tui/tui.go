@@ -0,0 +1,581 @@
+// Package tui is a full-screen terminal interface for operators who run the station
+// over SSH: folder panes (inbox, outbox, sent, failed), a message viewer, a compose form
+// and a live session log fed from the audit trail. It drives the same store and ops
+// calls as the CLI, so everything it does is recorded like any other change.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/4current/relayops/internal/compose"
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/store"
+)
+
+// Options configure the UI.
+type Options struct {
+	// NewSender builds the transport used by the send key. Send is disabled when nil.
+	NewSender func() (ops.Sender, error)
+	// Transport names the sender in the title bar and log, e.g. "pat telnet".
+	Transport string
+	// Station is shown in the title bar.
+	Station string
+	// PollInterval is how often the audit trail is checked for changes (default 1s).
+	PollInterval time.Duration
+	// SendLimit caps messages per send (default 25).
+	SendLimit int
+}
+
+type folderID int
+
+const (
+	folderInbox folderID = iota
+	folderOutbox
+	folderSent
+	folderFailed
+)
+
+var folderNames = []string{"Inbox", "Outbox", "Sent", "Failed"}
+
+type pane int
+
+const (
+	paneFolders pane = iota
+	paneList
+	paneViewer
+)
+
+const (
+	listLimit = 200 // rows loaded per folder
+	logLines  = 200 // session log lines kept
+)
+
+// row is one line of a folder listing.
+type row struct {
+	ID      string
+	Status  core.MessageStatus
+	Subject string
+	Time    time.Time
+}
+
+// form is the compose form. Text fields are edited at the end (append and backspace),
+// which keeps editing predictable over slow links.
+type form struct {
+	fields [fieldCount]string // indexed by field; the queue slot is unused
+	field  int
+	queue  bool
+}
+
+// Form fields in screen and tab order.
+const (
+	fieldTo = iota
+	fieldSubject
+	fieldTags
+	fieldQueue
+	fieldBody
+	fieldCount
+)
+
+var fieldLabels = []string{"To", "Subject", "Tags", "Queue", "Body"}
+
+type sendDone struct {
+	res ops.SendResult
+	err error
+}
+
+// App is the UI state. It is driven by keys and poll ticks and rendered by View; the
+// terminal loop lives in Run.
+type App struct {
+	st   *store.Store
+	opts Options
+
+	rows    [4][]row
+	sel     [4]int
+	folder  folderID
+	focus   pane
+	viewing *core.Message
+	scroll  int
+
+	composing *form
+
+	log     []string
+	lastSeq int64
+	flash   string
+
+	sending    bool
+	cancelSend context.CancelFunc
+	done       chan sendDone
+	quitArmed  bool
+}
+
+// New returns an App over st. Call Load before the first View.
+func New(st *store.Store, opts Options) *App {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.SendLimit <= 0 {
+		opts.SendLimit = 25
+	}
+	return &App{st: st, opts: opts, done: make(chan sendDone, 1)}
+}
+
+// Load reads the folders and the recent audit trail for the session log.
+func (a *App) Load(ctx context.Context) error {
+	last, err := a.st.LastEventSeq(ctx)
+	if err != nil {
+		return err
+	}
+	a.lastSeq = max(0, last-20)
+	if err := a.poll(ctx); err != nil {
+		return err
+	}
+	return a.refresh(ctx)
+}
+
+// refresh reloads every folder, keeping the selection on the same message if it is
+// still listed.
+func (a *App) refresh(ctx context.Context) error {
+	inbox, err := a.st.QueryMessages(ctx, store.MessageFilter{Folder: "inbox"})
+	if err != nil {
+		return err
+	}
+	// Received mail is stored as draft; keep it out of the outbox.
+	local, err := a.st.QueryMessages(ctx, store.MessageFilter{NotFolder: "inbox"})
+	if err != nil {
+		return err
+	}
+	var rows [4][]row
+	for i := len(inbox) - 1; i >= 0 && len(rows[folderInbox]) < listLimit; i-- {
+		rows[folderInbox] = append(rows[folderInbox], messageRow(inbox[i]))
+	}
+	for i := len(local) - 1; i >= 0; i-- {
+		m := local[i]
+		f := folderOutbox
+		switch m.Status {
+		case core.StatusDraft, core.StatusQueued, core.StatusSending:
+		case core.StatusSent:
+			f = folderSent
+		case core.StatusFailed:
+			f = folderFailed
+		default:
+			continue
+		}
+		if len(rows[f]) < listLimit {
+			rows[f] = append(rows[f], messageRow(m))
+		}
+	}
+
+	for f := range rows {
+		prev := a.selected(folderID(f))
+		a.rows[f] = rows[f]
+		a.sel[f] = 0
+		if prev != nil {
+			if i := slices.IndexFunc(rows[f], func(r row) bool { return r.ID == prev.ID }); i >= 0 {
+				a.sel[f] = i
+			}
+		}
+	}
+	return a.loadViewer(ctx)
+}
+
+func messageRow(m *core.Message) row {
+	t := m.UpdatedAt
+	if m.SentAt != nil {
+		t = *m.SentAt
+	}
+	return row{ID: m.ID, Status: m.Status, Subject: m.Subject, Time: t}
+}
+
+func (a *App) selected(f folderID) *row {
+	if a.sel[f] < len(a.rows[f]) {
+		return &a.rows[f][a.sel[f]]
+	}
+	return nil
+}
+
+// loadViewer loads the selected message for the viewer pane.
+func (a *App) loadViewer(ctx context.Context) error {
+	r := a.selected(a.folder)
+	if r == nil {
+		a.viewing = nil
+		return nil
+	}
+	if a.viewing != nil && a.viewing.ID == r.ID && a.viewing.Status == r.Status {
+		return nil
+	}
+	m, ok, err := a.st.GetMessage(ctx, r.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		m = nil
+	}
+	if a.viewing == nil || m == nil || a.viewing.ID != m.ID {
+		a.scroll = 0
+	}
+	a.viewing = m
+	return nil
+}
+
+// poll appends new audit events to the session log and reloads the folders when any
+// arrived, so changes made by the CLI, daemon or API show up too.
+func (a *App) poll(ctx context.Context) error {
+	evs, err := a.st.ListEvents(ctx, store.EventFilter{AfterSeq: a.lastSeq, Limit: 500})
+	if err != nil {
+		return err
+	}
+	for _, ev := range evs {
+		a.lastSeq = ev.Seq
+		a.logf("%s", eventLine(ev))
+	}
+	if len(evs) > 0 {
+		return a.refresh(ctx)
+	}
+	return nil
+}
+
+func eventLine(ev store.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-14s %s", ev.Time.Local().Format("15:04:05"), ev.Actor, ev.Kind)
+	if ev.MessageID != "" {
+		fmt.Fprintf(&b, " %s", shortID(ev.MessageID))
+	}
+	if ev.OldStatus != "" || ev.NewStatus != "" {
+		fmt.Fprintf(&b, " %s→%s", ev.OldStatus, ev.NewStatus)
+	}
+	if ev.Payload != "" && ev.Payload != "{}" {
+		fmt.Fprintf(&b, " %s", ev.Payload)
+	}
+	return b.String()
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func (a *App) logf(format string, args ...any) {
+	a.log = append(a.log, fmt.Sprintf(format, args...))
+	if len(a.log) > logLines {
+		a.log = a.log[len(a.log)-logLines:]
+	}
+}
+
+// HandleKey applies one keypress and reports whether the UI should exit.
+func (a *App) HandleKey(ctx context.Context, k Key) (quit bool, err error) {
+	if k.Code != KeyCtrlC && !(k.Code == KeyRune && k.Rune == 'x') {
+		a.quitArmed = false
+	}
+	if a.composing != nil {
+		return false, a.composeKey(ctx, k)
+	}
+	a.flash = ""
+
+	switch {
+	case k.Code == KeyCtrlC, k.Code == KeyRune && k.Rune == 'x':
+		if a.sending && !a.quitArmed {
+			a.quitArmed = true
+			a.flash = "A send is in progress. Press x again to abort it and exit."
+			return false, nil
+		}
+		return true, nil
+	case k.Code == KeyTab:
+		a.focus = (a.focus + 1) % (paneViewer + 1)
+	case k.Code == KeyRight:
+		a.focus = min(a.focus+1, paneViewer)
+	case k.Code == KeyBacktab, k.Code == KeyLeft, k.Code == KeyEsc:
+		a.focus = max(a.focus-1, paneFolders)
+	case k.Code == KeyEnter:
+		if a.focus < paneViewer {
+			a.focus++
+		}
+	case k.Code == KeyUp, k.Code == KeyRune && k.Rune == 'k':
+		return false, a.move(ctx, -1)
+	case k.Code == KeyDown, k.Code == KeyRune && k.Rune == 'j':
+		return false, a.move(ctx, 1)
+	case k.Code == KeyPgUp:
+		return false, a.move(ctx, -10)
+	case k.Code == KeyPgDn:
+		return false, a.move(ctx, 10)
+	case k.Code == KeyRune && k.Rune >= '1' && k.Rune <= '4':
+		a.folder = folderID(k.Rune - '1')
+		a.focus = paneList
+		return false, a.loadViewer(ctx)
+	case k.Code == KeyRune && k.Rune == 'c':
+		a.composing = &form{queue: true}
+	case k.Code == KeyRune && k.Rune == 'q':
+		return false, a.queueSelected(ctx)
+	case k.Code == KeyRune && k.Rune == 'r':
+		return false, a.retry(ctx, false)
+	case k.Code == KeyRune && k.Rune == 'R':
+		return false, a.retry(ctx, true)
+	case k.Code == KeyRune && k.Rune == 's':
+		a.startSend(ctx)
+	case k.Code == KeyRune && k.Rune == 'g':
+		return false, a.refresh(ctx)
+	}
+	return false, nil
+}
+
+func (a *App) move(ctx context.Context, d int) error {
+	switch a.focus {
+	case paneFolders:
+		a.folder = folderID(min(max(int(a.folder)+d, 0), len(folderNames)-1))
+	case paneList:
+		if n := len(a.rows[a.folder]); n > 0 {
+			a.sel[a.folder] = min(max(a.sel[a.folder]+d, 0), n-1)
+		}
+	case paneViewer:
+		a.scroll = max(a.scroll+d, 0)
+		return nil
+	}
+	return a.loadViewer(ctx)
+}
+
+// queueSelected queues the selected draft or failed message.
+func (a *App) queueSelected(ctx context.Context) error {
+	r := a.selected(a.folder)
+	switch {
+	case r == nil:
+		a.flash = "No message selected."
+		return nil
+	case a.folder == folderInbox:
+		a.flash = "Received messages cannot be queued."
+		return nil
+	case r.Status == core.StatusQueued:
+		a.flash = "Already queued."
+		return nil
+	case r.Status != core.StatusDraft && r.Status != core.StatusFailed:
+		a.flash = fmt.Sprintf("Cannot queue a %s message.", r.Status)
+		return nil
+	}
+	if err := a.st.SetStatusByID(ctx, r.ID, core.StatusQueued, ""); err != nil {
+		return err
+	}
+	a.flash = "Queued: " + r.Subject
+	return a.poll(ctx)
+}
+
+// retry re-queues the selected failed message, or every failed message when all is set.
+func (a *App) retry(ctx context.Context, all bool) error {
+	var ids []string
+	if all {
+		for _, r := range a.rows[folderFailed] {
+			ids = append(ids, r.ID)
+		}
+	} else if r := a.selected(a.folder); r != nil && r.Status == core.StatusFailed {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) == 0 {
+		a.flash = "No failed message to retry."
+		return nil
+	}
+	for _, id := range ids {
+		if err := a.st.SetStatusByID(ctx, id, core.StatusQueued, ""); err != nil {
+			return err
+		}
+	}
+	a.flash = fmt.Sprintf("Re-queued %d failed message(s); press s to send.", len(ids))
+	return a.poll(ctx)
+}
+
+// startSend sends the queue in the background; the result arrives on a.done.
+func (a *App) startSend(ctx context.Context) {
+	switch {
+	case a.sending:
+		a.flash = "A send is already in progress."
+		return
+	case a.opts.NewSender == nil:
+		a.flash = "No transport configured."
+		return
+	}
+	sender, err := a.opts.NewSender()
+	if err != nil {
+		a.logf("send: %v", err)
+		a.flash = "Send unavailable: " + err.Error()
+		return
+	}
+	sctx, cancel := context.WithCancel(ctx)
+	a.sending, a.cancelSend = true, cancel
+	a.logf("send: starting over %s", a.opts.Transport)
+	go func() {
+		res, err := ops.SendQueued(sctx, a.st, "", a.opts.SendLimit, sender)
+		a.done <- sendDone{res, err}
+	}()
+}
+
+func (a *App) finishSend(ctx context.Context, d sendDone) error {
+	a.sending = false
+	a.cancelSend()
+	// Log the send's own events first so the summary line comes last.
+	err := a.poll(ctx)
+	if d.err != nil {
+		a.logf("send: failed: %v", d.err)
+		a.flash = "Send failed: " + d.err.Error()
+	} else {
+		a.logf("send: done, %d sent, %d failed", d.res.Sent, d.res.Failed)
+		a.flash = fmt.Sprintf("Send done: %d sent, %d failed.", d.res.Sent, d.res.Failed)
+	}
+	return err
+}
+
+func (a *App) composeKey(ctx context.Context, k Key) error {
+	f := a.composing
+	switch k.Code {
+	case KeyEsc, KeyCtrlC:
+		a.composing = nil
+		a.flash = "Compose cancelled."
+	case KeyCtrlS:
+		return a.saveCompose(ctx)
+	case KeyTab:
+		f.field = (f.field + 1) % fieldCount
+	case KeyBacktab:
+		f.field = (f.field + fieldCount - 1) % fieldCount
+	case KeyUp:
+		f.field = max(f.field-1, 0)
+	case KeyDown:
+		f.field = min(f.field+1, fieldCount-1)
+	case KeyEnter:
+		if f.field == fieldBody {
+			f.fields[fieldBody] += "\n"
+		} else {
+			f.field = min(f.field+1, fieldCount-1)
+		}
+	case KeyBackspace:
+		if f.field != fieldQueue {
+			s := []rune(f.fields[f.field])
+			if len(s) > 0 {
+				f.fields[f.field] = string(s[:len(s)-1])
+			}
+		}
+	case KeyRune:
+		if f.field == fieldQueue {
+			if k.Rune == ' ' {
+				f.queue = !f.queue
+			}
+		} else {
+			f.fields[f.field] += string(k.Rune)
+		}
+	}
+	return nil
+}
+
+func (a *App) saveCompose(ctx context.Context) error {
+	f := a.composing
+	msg, err := compose.Build(ctx, compose.Request{
+		Subject: f.fields[fieldSubject],
+		Body:    f.fields[fieldBody],
+		To:      strings.Split(f.fields[fieldTo], ","),
+		Tags:    strings.Split(f.fields[fieldTags], ","),
+	})
+	if err == nil && len(msg.To) == 0 {
+		err = fmt.Errorf("at least one recipient is required")
+	}
+	if err != nil {
+		a.flash = err.Error()
+		return nil
+	}
+	if err := a.st.SaveMessage(ctx, msg); err != nil {
+		return err
+	}
+	status := "draft"
+	if f.queue {
+		if err := a.st.SetStatusByID(ctx, msg.ID, core.StatusQueued, ""); err != nil {
+			return err
+		}
+		status = "queued"
+	}
+	a.composing = nil
+	a.folder, a.focus = folderOutbox, paneList
+	a.flash = fmt.Sprintf("Saved %q as %s.", msg.Subject, status)
+	if err := a.poll(ctx); err != nil {
+		return err
+	}
+	if i := slices.IndexFunc(a.rows[folderOutbox], func(r row) bool { return r.ID == msg.ID }); i >= 0 {
+		a.sel[folderOutbox] = i
+	}
+	return a.loadViewer(ctx)
+}
+
+// Run takes over the terminal on in/out until the operator exits or ctx ends. An
+// unfinished send is cancelled on exit.
+func (a *App) Run(ctx context.Context, in, out *os.File) error {
+	fd := int(in.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	defer restore()
+	if err := a.Load(ctx); err != nil {
+		return err
+	}
+
+	// Alternate screen, hidden cursor; undone on the way out.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+	defer func() {
+		if a.sending {
+			a.cancelSend()
+		}
+	}()
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- slices.Clone(buf[:n])
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	tick := time.NewTicker(a.opts.PollInterval)
+	defer tick.Stop()
+
+	for {
+		w, h, err := termSize(fd)
+		if err != nil {
+			return fmt.Errorf("terminal: %w", err)
+		}
+		fmt.Fprint(out, "\x1b[H"+strings.Join(a.View(w, h), "\r\n"))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case b, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range parseKeys(b) {
+				quit, err := a.HandleKey(ctx, k)
+				if err != nil {
+					a.flash = "Error: " + err.Error()
+				}
+				if quit {
+					return nil
+				}
+			}
+		case d := <-a.done:
+			if err := a.finishSend(ctx, d); err != nil {
+				a.flash = "Error: " + err.Error()
+			}
+		case <-tick.C:
+			if err := a.poll(ctx); err != nil {
+				a.flash = "Error: " + err.Error()
+			}
+		case <-resize:
+		}
+	}
+}
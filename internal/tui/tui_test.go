@@ -0,0 +1,216 @@
+package tui
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4current/relayops/internal/core"
+	"github.com/4current/relayops/internal/ops"
+	"github.com/4current/relayops/internal/store"
+	"github.com/4current/relayops/internal/transport/sim"
+)
+
+func TestParseKeys(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []Key
+	}{
+		{"ab", []Key{{KeyRune, 'a'}, {KeyRune, 'b'}}},
+		{"\x1b[A\x1b[B\x1bOC\x1b[D", []Key{{Code: KeyUp}, {Code: KeyDown}, {Code: KeyRight}, {Code: KeyLeft}}},
+		{"\x1b[5~\x1b[6~\x1b[3~\x1b[Z", []Key{{Code: KeyPgUp}, {Code: KeyPgDn}, {Code: KeyDelete}, {Code: KeyBacktab}}},
+		{"\x1b[1;5A", []Key{{Code: KeyUp}}},
+		{"\x1b", []Key{{Code: KeyEsc}}},
+		{"\r\n\t\x7f\x03\x13", []Key{{Code: KeyEnter}, {Code: KeyTab}, {Code: KeyBackspace}, {Code: KeyCtrlC}, {Code: KeyCtrlS}}},
+		{"é\x1b[99xz", []Key{{KeyRune, 'é'}, {KeyRune, 'z'}}},
+	} {
+		got := parseKeys([]byte(c.in))
+		if len(got) != len(c.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", c.in, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("parseKeys(%q)[%d] = %v, want %v", c.in, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func setup(t *testing.T) (*App, *store.Store, context.Context) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("USERPROFILE", tmp)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	st, err := store.Open(ctx)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	app := New(st, Options{
+		NewSender: func() (ops.Sender, error) { return sim.New(), nil },
+		Transport: "sim",
+	})
+	return app, st, ctx
+}
+
+func save(t *testing.T, ctx context.Context, st *store.Store, subject string, status core.MessageStatus) *core.Message {
+	t.Helper()
+	m := core.NewMessage(subject, "body of "+subject)
+	m.To = []core.Address{{Callsign: "W1AW"}}
+	if err := st.SaveMessage(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if status != core.StatusDraft {
+		if err := st.SetStatusByID(ctx, m.ID, status, "link down"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func press(t *testing.T, ctx context.Context, a *App, keys string) {
+	t.Helper()
+	for _, k := range parseKeys([]byte(keys)) {
+		if _, err := a.HandleKey(ctx, k); err != nil {
+			t.Fatalf("key %v: %v", k, err)
+		}
+	}
+}
+
+var sgr = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// screen renders a and checks every line is exactly w cells wide.
+func screen(t *testing.T, a *App, w, h int) string {
+	t.Helper()
+	lines := a.View(w, h)
+	if len(lines) != h {
+		t.Fatalf("View returned %d lines, want %d", len(lines), h)
+	}
+	for i, l := range lines {
+		if n := utf8.RuneCountInString(sgr.ReplaceAllString(l, "")); n != w {
+			t.Fatalf("line %d is %d cells, want %d: %q", i, n, w, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestFoldersQueueAndRetry(t *testing.T) {
+	a, st, ctx := setup(t)
+	in := save(t, ctx, st, "Shelter status", core.StatusDraft)
+	if err := st.UpsertBackendState(ctx, in.ID, "pat", "InBox", "Received", ""); err != nil {
+		t.Fatal(err)
+	}
+	draft := save(t, ctx, st, "Net report", core.StatusDraft)
+	failed := save(t, ctx, st, "Supply request", core.StatusFailed)
+	if err := a.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for f, want := range map[folderID]int{folderInbox: 1, folderOutbox: 1, folderSent: 0, folderFailed: 1} {
+		if got := len(a.rows[f]); got != want {
+			t.Errorf("%s has %d rows, want %d", folderNames[f], got, want)
+		}
+	}
+	if a.viewing == nil || a.viewing.ID != in.ID {
+		t.Fatalf("viewer should show the inbox message")
+	}
+	if s := screen(t, a, 100, 30); !strings.Contains(s, "body of Shelter status") {
+		t.Fatalf("viewer body missing:\n%s", s)
+	}
+
+	// Received mail cannot be queued.
+	press(t, ctx, a, "1q")
+	if !strings.Contains(a.flash, "cannot be queued") {
+		t.Fatalf("flash = %q", a.flash)
+	}
+
+	press(t, ctx, a, "2q")
+	if m, _, _ := st.GetMessage(ctx, draft.ID); m.Status != core.StatusQueued {
+		t.Fatalf("draft status = %s, want queued", m.Status)
+	}
+
+	press(t, ctx, a, "4r")
+	if m, _, _ := st.GetMessage(ctx, failed.ID); m.Status != core.StatusQueued {
+		t.Fatalf("failed status = %s, want queued", m.Status)
+	}
+	if len(a.rows[folderFailed]) != 0 || len(a.rows[folderOutbox]) != 2 {
+		t.Fatalf("folders not refreshed: failed=%d outbox=%d", len(a.rows[folderFailed]), len(a.rows[folderOutbox]))
+	}
+	if !strings.Contains(strings.Join(a.log, "\n"), "message.status") {
+		t.Fatalf("session log lacks status events: %v", a.log)
+	}
+}
+
+func TestComposeAndSend(t *testing.T) {
+	a, _, ctx := setup(t)
+	if err := a.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	press(t, ctx, a, "c")
+	if a.composing == nil {
+		t.Fatal("c should open the compose form")
+	}
+	press(t, ctx, a, "W1AW\rDrill\x7f\x7f\x7f\x7f\x7fNet check-in\rnet\r\r")
+	press(t, ctx, a, "QSL from the EOC\rall well")
+	screen(t, a, 80, 24)
+	press(t, ctx, a, "\x13")
+	if a.composing != nil {
+		t.Fatalf("compose still open: %q", a.flash)
+	}
+
+	if len(a.rows[folderOutbox]) != 1 {
+		t.Fatalf("outbox has %d rows", len(a.rows[folderOutbox]))
+	}
+	m := a.viewing
+	if m == nil || m.Subject != "Net check-in" || m.Body != "QSL from the EOC\nall well" || m.Status != core.StatusQueued {
+		t.Fatalf("composed = %+v", m)
+	}
+	if len(m.To) != 1 || m.To[0].Callsign != "W1AW" || len(m.Tags) != 1 || m.Tags[0] != "net" {
+		t.Fatalf("composed to=%v tags=%v", m.To, m.Tags)
+	}
+
+	press(t, ctx, a, "s")
+	if !a.sending {
+		t.Fatalf("send did not start: %q", a.flash)
+	}
+	if quit, _ := a.HandleKey(ctx, Key{Code: KeyRune, Rune: 'x'}); quit {
+		t.Fatal("first x during a send should only warn")
+	}
+	select {
+	case d := <-a.done:
+		if err := a.finishSend(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("send did not finish")
+	}
+	if len(a.rows[folderSent]) != 1 || len(a.rows[folderOutbox]) != 0 {
+		t.Fatalf("after send: sent=%d outbox=%d", len(a.rows[folderSent]), len(a.rows[folderOutbox]))
+	}
+	if !strings.Contains(screen(t, a, 80, 24), "1 sent, 0 failed") {
+		t.Fatal("send result not shown")
+	}
+}
+
+func TestViewSanitizesAndFitsSmallTerminals(t *testing.T) {
+	a, st, ctx := setup(t)
+	save(t, ctx, st, "evil \x1b]0;pwned\x07 subject", core.StatusDraft)
+	if err := a.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	press(t, ctx, a, "2")
+	s := screen(t, a, 60, 16)
+	if strings.Contains(sgr.ReplaceAllString(s, ""), "\x1b") || strings.Contains(s, "\x07") {
+		t.Fatalf("control characters reached the screen: %q", s)
+	}
+	if s := screen(t, a, 20, 5); !strings.Contains(s, "too small") {
+		t.Fatalf("small terminal: %q", s)
+	}
+}
@@ -0,0 +1,282 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/4current/relayops/internal/core"
+)
+
+const (
+	folderWidth = 14
+	minWidth    = 50
+	minHeight   = 14
+)
+
+const (
+	sgrReset   = "\x1b[0m"
+	sgrInverse = "\x1b[7m"
+	sgrBold    = "\x1b[1m"
+	sgrDim     = "\x1b[2m"
+)
+
+func styled(sgr, s string) string { return sgr + s + sgrReset }
+
+// clean makes s safe to print: message content comes over the air, so control
+// characters (which could drive the terminal) are replaced and tabs become spaces.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r), r == utf8.RuneError:
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// fit cleans s and truncates or pads it to exactly w cells.
+func fit(s string, w int) string {
+	if w <= 0 {
+		return ""
+	}
+	r := []rune(clean(s))
+	if len(r) > w {
+		if w == 1 {
+			return "…"
+		}
+		return string(r[:w-1]) + "…"
+	}
+	return string(r) + strings.Repeat(" ", w-len(r))
+}
+
+// rule is a horizontal line of w cells with title at its start.
+func rule(title string, w int) string {
+	return fit(title+strings.Repeat("─", max(0, w-utf8.RuneCountInString(title))), w)
+}
+
+// wrap splits text into lines of at most w cells, breaking long lines at the width.
+func wrap(text string, w int) []string {
+	var out []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		r := []rune(line)
+		for len(r) > w {
+			out = append(out, string(r[:w]))
+			r = r[w:]
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
+
+// View renders the screen as h lines of w cells each.
+func (a *App) View(w, h int) []string {
+	if w < minWidth || h < minHeight {
+		lines := make([]string, h)
+		for i := range lines {
+			lines[i] = fit("", w)
+		}
+		if h > 0 {
+			lines[0] = fit(fmt.Sprintf("Terminal too small (%dx%d); need at least %dx%d.", w, h, minWidth, minHeight), w)
+		}
+		return lines
+	}
+
+	logH := min(max(h/4, 3), 10)
+	bodyH := h - 3 - logH
+	rightW := w - folderWidth - 1
+
+	title := " RelayOps"
+	if a.opts.Station != "" {
+		title += "  " + a.opts.Station
+	}
+	if a.opts.Transport != "" {
+		title += "  transport: " + a.opts.Transport
+	}
+	if a.sending {
+		title += "  ● sending…"
+	}
+
+	lines := []string{styled(sgrInverse, fit(title, w))}
+	left := a.folderPane(bodyH)
+	var right []string
+	if a.composing != nil {
+		right = a.composePane(rightW, bodyH)
+	} else {
+		right = a.browsePane(rightW, bodyH)
+	}
+	for i := 0; i < bodyH; i++ {
+		lines = append(lines, left[i]+"│"+right[i])
+	}
+
+	lines = append(lines, styled(sgrBold, rule("── Session log ", w)))
+	logs := a.log[max(0, len(a.log)-logH):]
+	for i := 0; i < logH; i++ {
+		s := ""
+		if i < len(logs) {
+			s = logs[i]
+		}
+		lines = append(lines, fit(s, w))
+	}
+
+	help := "Tab pane  ↑↓ move  1-4 folder  c compose  q queue  r retry  R retry all  s send  g refresh  x exit"
+	if a.composing != nil {
+		help = "Tab/↑↓ field  Enter next (newline in body)  Space toggles queue  Ctrl-S save  Esc cancel"
+	}
+	if a.flash != "" {
+		help = a.flash
+	}
+	return append(lines, styled(sgrInverse, fit(" "+help, w)))
+}
+
+func (a *App) folderPane(h int) []string {
+	lines := make([]string, h)
+	for i := range lines {
+		lines[i] = fit("", folderWidth)
+	}
+	lines[0] = styled(sgrBold, fit(" Folders", folderWidth))
+	for i, name := range folderNames {
+		if i+2 >= h {
+			break
+		}
+		s := fit(fmt.Sprintf(" %d %-7s%4d", i+1, name, len(a.rows[i])), folderWidth)
+		if folderID(i) == a.folder {
+			if a.focus == paneFolders && a.composing == nil {
+				s = styled(sgrInverse, s)
+			} else {
+				s = styled(sgrBold, s)
+			}
+		}
+		lines[i+2] = s
+	}
+	return lines
+}
+
+func (a *App) browsePane(w, h int) []string {
+	listH := max(3, h*2/5)
+	viewH := h - listH - 1
+	lines := make([]string, 0, h)
+
+	rows := a.rows[a.folder]
+	header := fmt.Sprintf(" %s (%d)", folderNames[a.folder], len(rows))
+	lines = append(lines, styled(sgrBold, fit(header, w)))
+	sel := a.sel[a.folder]
+	off := max(0, sel-(listH-2))
+	for i := off; i < off+listH-1; i++ {
+		if i >= len(rows) {
+			s := ""
+			if i == 0 {
+				s = "  (empty)"
+			}
+			lines = append(lines, styled(sgrDim, fit(s, w)))
+			continue
+		}
+		r := rows[i]
+		s := fit(fmt.Sprintf(" %-8s %s  %s", r.Status, r.Time.Local().Format("01-02 15:04"), r.Subject), w)
+		if i == sel {
+			if a.focus == paneList {
+				s = styled(sgrInverse, s)
+			} else {
+				s = styled(sgrBold, s)
+			}
+		}
+		lines = append(lines, s)
+	}
+
+	sep := "─ Message "
+	if a.focus == paneViewer {
+		sep = "─ Message (↑↓ scroll) "
+	}
+	lines = append(lines, styled(sgrBold, rule(sep, w)))
+
+	body := a.viewerLines(w)
+	a.scroll = min(a.scroll, max(0, len(body)-viewH))
+	for i := 0; i < viewH; i++ {
+		s := ""
+		if j := a.scroll + i; j < len(body) {
+			s = body[j]
+		}
+		lines = append(lines, fit(s, w))
+	}
+	return lines
+}
+
+func (a *App) viewerLines(w int) []string {
+	m := a.viewing
+	if m == nil {
+		return []string{" No message selected."}
+	}
+	var to []string
+	for _, addr := range m.To {
+		to = append(to, addressString(addr))
+	}
+	lines := []string{
+		" Subject: " + m.Subject,
+		" From:    " + addressString(m.From),
+		" To:      " + strings.Join(to, ", "),
+		fmt.Sprintf(" Status:  %s   Created: %s", m.Status, m.CreatedAt.Local().Format("2006-01-02 15:04")),
+	}
+	if len(m.Tags) > 0 {
+		lines = append(lines, " Tags:    "+strings.Join(m.Tags, ", "))
+	}
+	if m.LastError != "" && m.Status == core.StatusFailed {
+		lines = append(lines, " Error:   "+m.LastError)
+	}
+	if n := len(m.Attachments); n > 0 {
+		lines = append(lines, fmt.Sprintf(" Attachments: %d", n))
+	}
+	lines = append(lines, "")
+	for _, l := range wrap(m.Body, w-1) {
+		lines = append(lines, " "+l)
+	}
+	return lines
+}
+
+func (a *App) composePane(w, h int) []string {
+	f := a.composing
+	lines := []string{styled(sgrBold, fit(" Compose", w))}
+	label := func(i int, name, value string) string {
+		s := fmt.Sprintf(" %-8s %s", name+":", value)
+		if f.field != i {
+			return fit(s, w)
+		}
+		if i < fieldQueue {
+			s += "_"
+		}
+		return styled(sgrInverse, fit(s, w))
+	}
+	for i := fieldTo; i < fieldQueue; i++ {
+		lines = append(lines, label(i, fieldLabels[i], f.fields[i]))
+	}
+	box := "[ ]"
+	if f.queue {
+		box = "[x]"
+	}
+	lines = append(lines, label(fieldQueue, fieldLabels[fieldQueue], box+" send with the next session"))
+	lines = append(lines, label(fieldBody, fieldLabels[fieldBody], ""))
+
+	bodyH := h - len(lines)
+	body := wrap(f.fields[fieldBody], w-2)
+	if f.field == fieldBody {
+		body[len(body)-1] += "_"
+	}
+	body = body[max(0, len(body)-bodyH):]
+	for i := 0; i < bodyH; i++ {
+		s := ""
+		if i < len(body) {
+			s = "  " + body[i]
+		}
+		lines = append(lines, fit(s, w))
+	}
+	return lines
+}
+
+func addressString(a core.Address) string {
+	if a.Email != "" {
+		return a.Email
+	}
+	return a.Callsign
+}
@@ -0,0 +1,246 @@
+// Package vault seals individual store fields (message bodies, metadata, credentials)
+// with AES-256-GCM.
+//
+// Sealed values are plain strings so they fit in the existing TEXT columns:
+//
+//	enc:v1:<key id>:<base64(nonce || ciphertext)>
+//
+// The key id is derived from the key material itself, so a passphrase or key file can be
+// checked against the store without keeping a separate verifier.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	prefix = "enc:v1:"
+
+	// KDFPassphrase and KDFKeyFile name how a key was obtained; stored alongside the key id.
+	KDFPassphrase = "pbkdf2-sha256"
+	KDFKeyFile    = "keyfile"
+
+	pbkdf2Iterations = 600_000
+	keyLen           = 32
+	saltLen          = 16
+)
+
+// ErrNoKey is returned when a sealed value references a key that is not loaded.
+var ErrNoKey = errors.New("vault: key not available (store is locked)")
+
+// Key is a 256-bit data key.
+type Key struct {
+	ID  string
+	KDF string
+	raw []byte
+}
+
+// Secret is where key material comes from. At most one field is normally set.
+type Secret struct {
+	Passphrase string
+	KeyFile    string
+}
+
+func (s Secret) Empty() bool {
+	return s.Passphrase == "" && s.KeyFile == ""
+}
+
+// SecretFromEnv reads RELAYOPS_PASSPHRASE and RELAYOPS_KEY_FILE.
+func SecretFromEnv() Secret {
+	return Secret{
+		Passphrase: os.Getenv("RELAYOPS_PASSPHRASE"),
+		KeyFile:    os.Getenv("RELAYOPS_KEY_FILE"),
+	}
+}
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DeriveKey derives a data key from a passphrase and salt.
+func DeriveKey(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("vault: empty passphrase")
+	}
+	raw, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("vault: derive key: %w", err)
+	}
+	return newKey(raw, KDFPassphrase), nil
+}
+
+// LoadKeyFile reads a 32-byte key stored raw, hex-encoded or base64-encoded.
+func LoadKeyFile(path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vault: read key file: %w", err)
+	}
+	if len(b) == keyLen {
+		return newKey(b, KDFKeyFile), nil
+	}
+	txt := strings.TrimSpace(string(b))
+	if raw, err := hex.DecodeString(txt); err == nil && len(raw) == keyLen {
+		return newKey(raw, KDFKeyFile), nil
+	}
+	if raw, err := base64.StdEncoding.DecodeString(txt); err == nil && len(raw) == keyLen {
+		return newKey(raw, KDFKeyFile), nil
+	}
+	return nil, fmt.Errorf("vault: key file %s must hold %d bytes (raw, hex or base64)", path, keyLen)
+}
+
+// GenerateKeyFile writes a new random hex-encoded key to path (mode 0600).
+func GenerateKeyFile(path string) error {
+	raw := make([]byte, keyLen)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(hex.EncodeToString(raw)+"\n"), 0o600)
+}
+
+func newKey(raw []byte, kdf string) *Key {
+	sum := sha256.Sum256(append([]byte("relayops-key-id:"), raw...))
+	return &Key{ID: hex.EncodeToString(sum[:8]), KDF: kdf, raw: raw}
+}
+
+// IsSealed reports whether s was produced by Seal.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// KeyID returns the key id a sealed value was written with.
+func KeyID(s string) (string, bool) {
+	if !IsSealed(s) {
+		return "", false
+	}
+	rest := s[len(prefix):]
+	i := strings.IndexByte(rest, ':')
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// Seal encrypts plaintext with k.
+func (k *Key) Seal(plaintext string) (string, error) {
+	gcm, err := k.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ct := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(k.ID))
+	return prefix + k.ID + ":" + base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// Open decrypts a value sealed with k.
+func (k *Key) Open(sealed string) (string, error) {
+	id, ok := KeyID(sealed)
+	if !ok {
+		return "", fmt.Errorf("vault: value is not sealed")
+	}
+	if id != k.ID {
+		return "", ErrNoKey
+	}
+	ct, err := base64.StdEncoding.DecodeString(sealed[len(prefix)+len(id)+1:])
+	if err != nil {
+		return "", fmt.Errorf("vault: decode: %w", err)
+	}
+	gcm, err := k.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(ct) < gcm.NonceSize() {
+		return "", fmt.Errorf("vault: sealed value too short")
+	}
+	pt, err := gcm.Open(nil, ct[:gcm.NonceSize()], ct[gcm.NonceSize():], []byte(k.ID))
+	if err != nil {
+		return "", fmt.Errorf("vault: decrypt: %w", err)
+	}
+	return string(pt), nil
+}
+
+func (k *Key) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.raw)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring holds the keys a store has been unlocked with. The active key seals new values.
+// It is safe for concurrent use.
+type Keyring struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active *Key
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string]*Key{}}
+}
+
+// Add makes k available for Open; if active, it also becomes the sealing key.
+func (r *Keyring) Add(k *Key, active bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[k.ID] = k
+	if active {
+		r.active = k
+	}
+}
+
+// Active returns the sealing key, or nil if the ring has none.
+func (r *Keyring) Active() *Key {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Seal encrypts with the active key.
+func (r *Keyring) Seal(plaintext string) (string, error) {
+	k := r.Active()
+	if k == nil {
+		return "", ErrNoKey
+	}
+	return k.Seal(plaintext)
+}
+
+// Open decrypts sealed values and passes plaintext values through unchanged, so rows
+// written before encryption was enabled keep working.
+func (r *Keyring) Open(s string) (string, error) {
+	id, ok := KeyID(s)
+	if !ok {
+		return s, nil
+	}
+	if r == nil {
+		return "", ErrNoKey
+	}
+	r.mu.RLock()
+	k, ok := r.keys[id]
+	r.mu.RUnlock()
+	if !ok {
+		return "", ErrNoKey
+	}
+	return k.Open(s)
+}
@@ -0,0 +1,146 @@
+package vault_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/4current/relayops/internal/vault"
+)
+
+func testKey(t *testing.T) *vault.Key {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "relayops.key")
+	if err := vault.GenerateKeyFile(path); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	k, err := vault.LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyFile: %v", err)
+	}
+	return k
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	k := testKey(t)
+	for _, plain := range []string{"", "Patient: Jane Doe", "multi\nline ✔"} {
+		sealed, err := k.Seal(plain)
+		if err != nil {
+			t.Fatalf("Seal(%q): %v", plain, err)
+		}
+		if strings.Contains(sealed, plain) && plain != "" {
+			t.Fatalf("sealed value leaks plaintext: %q", sealed)
+		}
+		got, err := k.Open(sealed)
+		if err != nil || got != plain {
+			t.Fatalf("Open = %q, %v; want %q", got, err, plain)
+		}
+	}
+
+	// The same passphrase and salt give the same key; a different salt does not.
+	salt, err := vault.NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := vault.DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := vault.DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != b.ID {
+		t.Fatalf("DeriveKey is not deterministic: %s != %s", a.ID, b.ID)
+	}
+	sealed, _ := a.Seal("hunter2")
+	if got, err := b.Open(sealed); err != nil || got != "hunter2" {
+		t.Fatalf("Open with the re-derived key = %q, %v", got, err)
+	}
+}
+
+func TestSealedFormat(t *testing.T) {
+	k := testKey(t)
+	sealed, err := k.Seal("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enc:v1:<key id>:<base64(nonce || ciphertext)>
+	parts := strings.SplitN(sealed, ":", 4)
+	if len(parts) != 4 || parts[0] != "enc" || parts[1] != "v1" || parts[2] != k.ID {
+		t.Fatalf("sealed = %q, want enc:v1:%s:...", sealed, k.ID)
+	}
+	ct, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		t.Fatalf("payload is not base64: %v", err)
+	}
+	if want := 12 + len("hunter2") + 16; len(ct) != want { // GCM nonce + ciphertext + tag
+		t.Fatalf("payload is %d bytes, want %d", len(ct), want)
+	}
+	if !vault.IsSealed(sealed) || vault.IsSealed("hunter2") {
+		t.Fatal("IsSealed misreports")
+	}
+	if id, ok := vault.KeyID(sealed); !ok || id != k.ID {
+		t.Fatalf("KeyID = %q, %v", id, ok)
+	}
+
+	// Two seals of the same value differ (fresh nonce each time).
+	again, _ := k.Seal("hunter2")
+	if again == sealed {
+		t.Fatal("Seal reused a nonce")
+	}
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	k, other := testKey(t), testKey(t)
+	sealed, _ := k.Seal("hunter2")
+	if _, err := other.Open(sealed); !errors.Is(err, vault.ErrNoKey) {
+		t.Fatalf("Open with another key: want ErrNoKey, got %v", err)
+	}
+
+	// A key that claims the right id but has other material fails authentication.
+	forged := "enc:v1:" + other.ID + sealed[len("enc:v1:")+len(k.ID):]
+	if _, err := other.Open(forged); err == nil || errors.Is(err, vault.ErrNoKey) {
+		t.Fatalf("Open of a value re-labelled with another key id: want a decrypt error, got %v", err)
+	}
+
+	ring := vault.NewKeyring()
+	ring.Add(other, true)
+	if _, err := ring.Open(sealed); !errors.Is(err, vault.ErrNoKey) {
+		t.Fatalf("Keyring.Open without the key: want ErrNoKey, got %v", err)
+	}
+	ring.Add(k, false)
+	if got, err := ring.Open(sealed); err != nil || got != "hunter2" {
+		t.Fatalf("Keyring.Open with a retired key = %q, %v", got, err)
+	}
+	if got, err := ring.Open("plain"); err != nil || got != "plain" {
+		t.Fatalf("Keyring.Open of plaintext = %q, %v", got, err)
+	}
+}
+
+func TestOpenTamperedCiphertext(t *testing.T) {
+	k := testKey(t)
+	sealed, _ := k.Seal("hunter2")
+	i := strings.LastIndexByte(sealed, ':') + 1
+	ct, _ := base64.StdEncoding.DecodeString(sealed[i:])
+
+	for name, mutate := range map[string]func([]byte){
+		"nonce":      func(b []byte) { b[0] ^= 1 },
+		"ciphertext": func(b []byte) { b[12] ^= 1 },
+		"tag":        func(b []byte) { b[len(b)-1] ^= 1 },
+	} {
+		b := append([]byte(nil), ct...)
+		mutate(b)
+		if _, err := k.Open(sealed[:i] + base64.StdEncoding.EncodeToString(b)); err == nil {
+			t.Errorf("Open accepted a value with a flipped %s bit", name)
+		}
+	}
+	if _, err := k.Open(sealed[:i] + "AAAA"); err == nil {
+		t.Error("Open accepted a truncated value")
+	}
+	if _, err := k.Open(sealed[:i] + "not base64!"); err == nil {
+		t.Error("Open accepted a payload that is not base64")
+	}
+}